@@ -10,9 +10,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/swagger"
 	"github.com/minisource/log/config"
 	_ "github.com/minisource/log/docs" // Swagger docs
@@ -23,6 +21,7 @@ import (
 	"github.com/minisource/log/internal/router"
 	"github.com/minisource/log/internal/service"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 // @title Log Service API
@@ -57,6 +56,14 @@ func main() {
 		log.Printf("Warning: Failed to create indexes: %v", err)
 	}
 
+	// Monitor the connection pool
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database handle: %v", err)
+	}
+	poolMonitor := database.NewPoolMonitor(sqlDB.Stats, cfg.Postgres.PoolWaitWarnCount, cfg.Postgres.PoolWaitWarnDuration)
+	poolMonitor.Sample()
+
 	// Initialize Redis
 	var redisClient *redis.Client
 	if cfg.Redis.Host != "" {
@@ -77,19 +84,70 @@ func main() {
 
 	// Initialize repositories
 	logRepo := repository.NewLogRepository(db)
+	if cfg.Postgres.ShadowEnabled {
+		shadowDB, err := database.NewShadowDB(cfg.Postgres, db)
+		if err != nil {
+			log.Printf("Warning: dual-write disabled: %v", err)
+		} else {
+			logRepo.SetShadow(shadowDB, cfg.Postgres.ShadowTable)
+			log.Printf("Dual-writing log entries to shadow table %s", cfg.Postgres.ShadowTable)
+		}
+	}
+	if cfg.Postgres.ReplicaDSN != "" {
+		replicaDB, err := database.NewReplicaDB(cfg.Postgres)
+		if err != nil {
+			log.Printf("Warning: read replica disabled: %v", err)
+		} else {
+			logRepo.SetReplica(replicaDB)
+			log.Printf("Serving log reads from the read replica")
+		}
+	}
+	var indexAdvisor *database.MetadataIndexAdvisor
+	if cfg.Postgres.IndexAdvisorInterval > 0 {
+		indexAdvisor = database.NewMetadataIndexAdvisor(cfg.Postgres.IndexAdvisorThreshold)
+		logRepo.SetIndexAdvisor(indexAdvisor)
+	}
 	retentionRepo := repository.NewRetentionRepository(db)
 	alertRepo := repository.NewAlertRepository(db)
+	metricRepo := repository.NewMetricRepository(db)
+	tenantRepo := repository.NewTenantRepository(db)
 
 	// Initialize services
-	logService := service.NewLogService(logRepo, retentionRepo, alertRepo, redisClient, cfg)
-	retentionService := service.NewRetentionService(retentionRepo)
-	alertService := service.NewAlertService(alertRepo)
+	tenantService := service.NewTenantService(tenantRepo)
+	logService := service.NewLogService(logRepo, retentionRepo, alertRepo, tenantService, redisClient, cfg)
+	if replayed, err := logService.ReplayWAL(context.Background()); err != nil {
+		log.Printf("Failed to replay write-ahead log: %v", err)
+	} else if replayed > 0 {
+		log.Printf("Replayed %d entries from the write-ahead log", replayed)
+	}
+	retentionService := service.NewRetentionService(retentionRepo, logRepo)
+	alertService := service.NewAlertService(alertRepo, cfg)
+	metricService := service.NewMetricService(metricRepo, logRepo)
+	replayService := service.NewReplayService(logRepo, cfg)
+
+	var importStore service.ObjectStore
+	var archiveStore service.ObjectWriter
+	if cfg.Import.S3Bucket != "" {
+		store := service.NewS3ObjectStore(cfg.Import.S3Endpoint, cfg.Import.S3Bucket,
+			cfg.Import.S3Region, cfg.Import.S3AccessKey, cfg.Import.S3SecretKey)
+		importStore, archiveStore = store, store
+	}
+	importService := service.NewImportService(logRepo, logService, importStore, cfg.Import.S3Prefix)
+	archiveService := service.NewArchiveService(logRepo, archiveStore, cfg.Import.ArchivePrefix, cfg.Import.ArchiveWorkers)
+	archiveService.SetMaintenanceCheck(logService.InMaintenance)
 
 	// Initialize handlers
-	logHandler := handler.NewLogHandler(logService)
+	logHandler := handler.NewLogHandler(logService, handler.NewStreamLimiter(cfg.Server.MaxStreams))
 	retentionHandler := handler.NewRetentionHandler(retentionService)
 	alertHandler := handler.NewAlertHandler(alertService)
-	healthHandler := handler.NewHealthHandler()
+	metricHandler := handler.NewMetricHandler(metricService)
+	tenantHandler := handler.NewTenantHandler(tenantService)
+	replayHandler := handler.NewReplayHandler(replayService)
+	adminHandler := handler.NewAdminHandler(logService)
+	importHandler := handler.NewImportHandler(importService)
+	archiveHandler := handler.NewArchiveHandler(archiveService)
+	compatHandler := handler.NewCompatHandler(logService)
+	healthHandler := handler.NewHealthHandler(poolMonitor, logService)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -101,26 +159,68 @@ func main() {
 	})
 
 	// Global middleware
-	app.Use(recover.New())
-	app.Use(compress.New())
+	app.Use(middleware.Recover(logService.IngestSingle))
+	// Streams compress per frame themselves
+	app.Use(middleware.Compress(cfg.Server.CompressMinBytes, func(c *fiber.Ctx) bool {
+		return handler.IsStreamPath(c.Path())
+	}))
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization,X-Request-ID,X-Tenant-ID",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,HEAD,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-Request-ID,X-Tenant-ID,X-Admin-Key,X-Log-Env,X-Log-Source,X-Strict-JSON",
+		ExposeHeaders: "X-Total-Count,X-Next-Cursor,Retry-After",
 	}))
 	app.Use(middleware.RequestID())
 	app.Use(middleware.TenantExtractor())
-	app.Use(middleware.SecurityHeaders())
+	app.Use("/loki", middleware.ScopeOrgIDExtractor())
+	app.Use(middleware.AdminExtractor(cfg.Security.AdminKey))
+	app.Use(middleware.EntryContextExtractor())
+	app.Use("/api/v1/admin", middleware.RequireAdmin())
+	if cfg.Server.RequireTenant {
+		for _, prefix := range router.TenantDataPrefixes {
+			app.Use(prefix, middleware.RequireTenant())
+		}
+	}
+	queryLimiter := middleware.NewRateLimiter(redisClient, "query", cfg.Server.QueryRateLimit, cfg.Server.QueryRateWindow)
+	app.Use(middleware.TenantRateLimit(queryLimiter, cfg.Security.AdminKey, func(c *fiber.Ctx) bool {
+		return handler.IsQueryRequest(c.Method(), c.Path())
+	}))
+	app.Use(middleware.SecurityHeaders(cfg.Security))
 	app.Use(middleware.ContentType())
 
 	// Swagger route
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
 	// Setup routes
-	router.SetupRoutes(app, logHandler, retentionHandler, alertHandler, healthHandler)
+	router.SetupRoutes(app, logHandler, retentionHandler, alertHandler, metricHandler, tenantHandler, replayHandler, importHandler, archiveHandler, adminHandler, compatHandler, healthHandler)
 
 	// Start cleanup scheduler
-	go startCleanupScheduler(logService, cfg)
+	go startCleanupScheduler(logService, metricService, cfg)
+
+	// Start pool stats sampler
+	go startPoolMonitor(poolMonitor, cfg.Postgres.PoolStatsInterval)
+
+	// Start metadata index advisor
+	if indexAdvisor != nil {
+		go startIndexAdvisor(indexAdvisor, db, cfg.Postgres)
+	}
+
+	// Start scheduled alert evaluation
+	if cfg.Alert.EvaluationInterval > 0 {
+		go startAlertEvaluationScheduler(logService, cfg.Alert.EvaluationInterval)
+	}
+
+	// Start alert recovery scheduler
+	go startAlertRecoveryScheduler(logService)
+
+	// Start metric rule scheduler
+	go startMetricScheduler(metricService)
+
+	// Start alert digest scheduler
+	go startAlertDigestScheduler(logService)
+
+	// Start alert notification outbox sender
+	go startAlertOutboxSender(logService, cfg.Alert.OutboxInterval)
 
 	// Start server
 	go func() {
@@ -155,16 +255,13 @@ func main() {
 	}
 
 	// Close database
-	sqlDB, _ := db.DB()
-	if sqlDB != nil {
-		sqlDB.Close()
-	}
+	sqlDB.Close()
 
 	log.Println("Log Service stopped")
 }
 
 // startCleanupScheduler runs periodic log cleanup
-func startCleanupScheduler(logService *service.LogService, cfg *config.Config) {
+func startCleanupScheduler(logService *service.LogService, metricService *service.MetricService, cfg *config.Config) {
 	ticker := time.NewTicker(24 * time.Hour)
 	defer ticker.Stop()
 
@@ -175,7 +272,121 @@ func startCleanupScheduler(logService *service.LogService, cfg *config.Config) {
 			if err := logService.Cleanup(ctx); err != nil {
 				log.Printf("Cleanup failed: %v", err)
 			}
+			if logService.InMaintenance() {
+				log.Printf("Skipping metric series cleanup: maintenance mode is on")
+			} else if _, err := metricService.PruneSeries(ctx, time.Now(), cfg.Retention.MetricPointDays); err != nil {
+				log.Printf("Metric series cleanup failed: %v", err)
+			}
 			cancel()
 		}
 	}
 }
+
+// startMetricScheduler periodically evaluates log-to-metric rules
+func startMetricScheduler(metricService *service.MetricService) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		if err := metricService.EvaluateRules(ctx, time.Now()); err != nil {
+			log.Printf("Metric rule evaluation failed: %v", err)
+		}
+		cancel()
+	}
+}
+
+// startAlertEvaluationScheduler periodically evaluates all enabled alerts,
+// independent of ingestion
+func startAlertEvaluationScheduler(logService *service.LogService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		if err := logService.EvaluateAlerts(ctx, time.Now().UTC()); err != nil {
+			log.Printf("Scheduled alert evaluation failed: %v", err)
+		}
+		cancel()
+	}
+}
+
+// startAlertRecoveryScheduler periodically resolves alerts whose condition cleared
+func startAlertRecoveryScheduler(logService *service.LogService) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		if err := logService.CheckAlertRecoveries(ctx, time.Now().UTC()); err != nil {
+			log.Printf("Alert recovery check failed: %v", err)
+		}
+		cancel()
+	}
+}
+
+// startAlertDigestScheduler periodically sends alert digests whose interval elapsed
+func startAlertDigestScheduler(logService *service.LogService) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		logService.FlushAlertDigests(ctx, time.Now().UTC())
+		cancel()
+	}
+}
+
+// startAlertOutboxSender periodically retries undelivered alert notifications,
+// including those left pending by a previous process
+func startAlertOutboxSender(logService *service.LogService, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		if _, err := logService.DrainAlertOutbox(ctx, time.Now().UTC()); err != nil {
+			log.Printf("Alert outbox drain failed: %v", err)
+		}
+		cancel()
+	}
+}
+
+// startIndexAdvisor periodically logs index recommendations for frequently
+// filtered metadata paths, creating them when auto-create is enabled
+func startIndexAdvisor(advisor *database.MetadataIndexAdvisor, db *gorm.DB, cfg config.PostgresConfig) {
+	ticker := time.NewTicker(cfg.IndexAdvisorInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, rec := range advisor.Analyze() {
+			if !cfg.IndexAdvisorAutoCreate {
+				log.Printf("Index recommendation: metadata path %q was filtered %d times; consider: %s", rec.Path, rec.Filters, rec.Statement)
+				continue
+			}
+			log.Printf("Creating index %s for metadata path %q (filtered %d times)", rec.Index, rec.Path, rec.Filters)
+			if err := database.CreateRecommendedIndex(db, rec); err != nil {
+				log.Printf("Failed to create index %s: %v", rec.Index, err)
+			}
+		}
+	}
+}
+
+// startPoolMonitor periodically samples connection pool stats, warning when
+// callers wait for connections
+func startPoolMonitor(monitor *database.PoolMonitor, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if warning := monitor.Sample(); warning != "" {
+			log.Printf("Warning: %s", warning)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -15,6 +16,11 @@ type Config struct {
 	Logging   LoggingConfig
 	Tracing   TracingConfig
 	Retention RetentionConfig
+	Alert     AlertConfig
+	Ingestion IngestionConfig
+	Replay    ReplayConfig
+	Import    ImportConfig
+	Security  SecurityConfig
 }
 
 type ServerConfig struct {
@@ -23,6 +29,17 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
+	// RequireTenant rejects log, alert, metric and compatibility API requests
+	// without a resolvable tenant
+	RequireTenant bool
+	// MaxStreams caps concurrent streaming connections; 0 disables the cap
+	MaxStreams int
+	// CompressMinBytes is the smallest response body that is compressed
+	CompressMinBytes int
+	// QueryRateLimit caps query, aggregate and stats requests per tenant in
+	// each QueryRateWindow, independently of ingestion; 0 disables the limit
+	QueryRateLimit  int
+	QueryRateWindow time.Duration
 }
 
 type PostgresConfig struct {
@@ -36,6 +53,33 @@ type PostgresConfig struct {
 	MaxIdleConns       int
 	MaxLifetimeMinutes int
 	LogLevel           string
+	// PoolStatsInterval is how often pool stats are sampled
+	PoolStatsInterval time.Duration
+	// PoolWaitWarnCount and PoolWaitWarnDuration log a tuning warning when
+	// connection waits grow by at least this much between samples
+	PoolWaitWarnCount    int
+	PoolWaitWarnDuration time.Duration
+	// IndexAdvisorInterval is how often filtered metadata paths are analyzed
+	// (0 disables the advisor); paths filtered IndexAdvisorThreshold times in
+	// an interval get an index recommendation, created when
+	// IndexAdvisorAutoCreate is set
+	IndexAdvisorInterval   time.Duration
+	IndexAdvisorThreshold  int
+	IndexAdvisorAutoCreate bool
+	// ShadowEnabled mirrors every log insert into ShadowTable of the
+	// database at ShadowDSN (the primary database when empty) for migrations
+	ShadowEnabled bool
+	ShadowDSN     string
+	ShadowTable   string
+	// ServeStaleOnError answers queries that fail at the database with the
+	// last result of the same query, kept for StaleCacheTTL and marked stale
+	ServeStaleOnError bool
+	StaleCacheTTL     time.Duration
+	// ReplicaDSN serves log reads from a read replica when set. For
+	// ReadYourWritesWindow after a tenant writes, its reads go to the
+	// primary so replica lag never hides its own logs
+	ReplicaDSN           string
+	ReadYourWritesWindow time.Duration
 }
 
 type RedisConfig struct {
@@ -63,6 +107,129 @@ type RetentionConfig struct {
 	MaxSizeGB      int
 	CleanupEnabled bool
 	CleanupCron    string
+	// TierDays maps a retention tier name to its retention in days
+	TierDays map[string]int
+	// RoutingRules is a JSON array of {"tier": "...", "filter": {...}} rules
+	// assigning a retention tier to matching entries at ingestion
+	RoutingRules string
+	// AlertEventDays and MetricPointDays bound alert history and metric
+	// series; 0 keeps them forever
+	AlertEventDays  int
+	MetricPointDays int
+	// AnalyzeThreshold runs ANALYZE on log_entries after a cleanup deleting
+	// at least this many rows so query plans do not go stale; 0 disables.
+	// VacuumAfterCleanup runs VACUUM (ANALYZE) instead, also reclaiming the
+	// deleted rows' dead tuples.
+	AnalyzeThreshold   int
+	VacuumAfterCleanup bool
+}
+
+type AlertConfig struct {
+	MaxPerTenant int
+	// EvaluationInterval evaluates all enabled alerts on a schedule in
+	// addition to on ingestion; 0 disables scheduled evaluation
+	EvaluationInterval time.Duration
+	// Notifications are persisted to an outbox and retried every
+	// OutboxInterval with exponential backoff until OutboxMaxAttempts
+	// (0 retries forever)
+	OutboxInterval     time.Duration
+	OutboxRetryBackoff time.Duration
+	OutboxMaxAttempts  int
+}
+
+type SecurityConfig struct {
+	NoSniff       bool
+	XSSProtection bool
+	// FrameOptions is the X-Frame-Options value; empty omits the header
+	FrameOptions string
+	// HSTSMaxAge enables Strict-Transport-Security when positive (seconds)
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// ContentSecurityPolicy is sent verbatim; empty omits the header
+	ContentSecurityPolicy string
+	// AdminKey identifies operator callers (X-Admin-Key), who are exempt
+	// from query rate limits, may run unselective queries, may use the admin
+	// endpoints and may manage any tenant's settings; empty disables all of
+	// these
+	AdminKey string
+}
+
+type ImportConfig struct {
+	S3Endpoint  string
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	// S3Prefix is prepended to every import prefix
+	S3Prefix string
+	// ArchivePrefix is where archival writes objects in the same bucket
+	ArchivePrefix string
+	// ArchiveWorkers is the number of days archived concurrently
+	ArchiveWorkers int
+}
+
+type ReplayConfig struct {
+	// WebhookURLs is the allowlist of replay destinations; empty disables replay
+	WebhookURLs []string
+	Timeout     time.Duration
+	// JobRetention is how long finished jobs stay queryable before they are
+	// dropped from memory
+	JobRetention time.Duration
+}
+
+type IngestionConfig struct {
+	// MessageParsers maps a service name to a built-in pattern name
+	// (common, combined, request) or a regex with named capture groups
+	MessageParsers map[string]string
+	// EnvironmentPattern derives a missing environment from the service
+	// name: the regex group named environment, else its first group, e.g.
+	// -(prod|staging|dev)$; empty derives nothing
+	EnvironmentPattern string
+	// BufferHighWatermark is the number of pending async entries at which
+	// ingestion is rejected with 429 until the buffer drains
+	BufferHighWatermark int
+	// MaxMessageLength truncates longer messages (in bytes); 0 disables
+	MaxMessageLength int
+	// PreserveFullMessage stores the untruncated message gzip-compressed in metadata
+	PreserveFullMessage bool
+	// MetadataAllowKeys, when set, are the only top-level metadata keys kept;
+	// MetadataDenyKeys are always removed
+	MetadataAllowKeys []string
+	MetadataDenyKeys  []string
+	// FlushLevels are the levels that flush the async buffer immediately
+	// instead of waiting for the next tick
+	FlushLevels []string
+	// MaxAgeMode handles entries older than their retention: "accept" stores
+	// them with a warning, "reject" fails the request
+	MaxAgeMode string
+	// TraceIDFormat normalizes trace and span IDs: "hex" (lowercase, no
+	// dashes), "uuid" (dashed 128-bit trace IDs) or "none" to keep them as sent
+	TraceIDFormat string
+	// PipelineStages orders the ingestion stages that run (all, in default
+	// order, when empty); PipelineDisabled skips stages without reordering
+	PipelineStages   []string
+	PipelineDisabled []string
+	// DuplicateIDKeep collapses entries of one batch sharing an ID before
+	// insert, keeping the "first" or "last" occurrence
+	DuplicateIDKeep string
+	// EnrichmentURLs allowlists the webhooks tenants may configure to enrich
+	// their entries; each call is bounded by EnrichmentTimeout and failures
+	// store the entry unenriched
+	EnrichmentURLs    []string
+	EnrichmentTimeout time.Duration
+	// WALEnabled persists async entries to an append-only write-ahead log
+	// in WALDir before acknowledging them; the log is replayed on startup
+	WALEnabled bool
+	WALDir     string
+	// MaxServicesPerTenant caps the distinct service names of a tenant;
+	// entries for new services beyond it are rejected. 0 disables the cap
+	MaxServicesPerTenant int
+	// MaxFutureSkew is how far past now an entry's timestamp may be; later
+	// timestamps are handled by FutureMode: "clamp" stores them at now with
+	// the original in metadata, "reject" fails the request. 0 disables
+	MaxFutureSkew time.Duration
+	FutureMode    string
 }
 
 func Load() (*Config, error) {
@@ -70,23 +237,41 @@ func Load() (*Config, error) {
 
 	return &Config{
 		Server: ServerConfig{
-			Port:            getEnv("SERVER_PORT", "5002"),
-			Host:            getEnv("SERVER_HOST", "0.0.0.0"),
-			ReadTimeout:     getDuration("SERVER_READ_TIMEOUT", 30*time.Second),
-			WriteTimeout:    getDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
-			ShutdownTimeout: getDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+			Port:             getEnv("SERVER_PORT", "5002"),
+			Host:             getEnv("SERVER_HOST", "0.0.0.0"),
+			ReadTimeout:      getDuration("SERVER_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:     getDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			ShutdownTimeout:  getDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+			RequireTenant:    getEnvBool("REQUIRE_TENANT", false),
+			MaxStreams:       getEnvInt("SERVER_MAX_STREAMS", 100),
+			CompressMinBytes: getEnvInt("SERVER_COMPRESS_MIN_BYTES", 1024),
+			QueryRateLimit:   getEnvInt("SERVER_QUERY_RATE_LIMIT", 600),
+			QueryRateWindow:  getDuration("SERVER_QUERY_RATE_WINDOW", time.Minute),
 		},
 		Postgres: PostgresConfig{
-			Host:               getEnv("DB_HOST", "localhost"),
-			Port:               getEnv("DB_PORT", "5432"),
-			User:               getEnv("DB_USER", "postgres"),
-			Password:           getEnv("DB_PASSWORD", "postgres"),
-			DBName:             getEnv("DB_NAME", "minisource_logs"),
-			SSLMode:            getEnv("DB_SSL_MODE", "disable"),
-			MaxOpenConns:       getEnvInt("DB_MAX_OPEN_CONNS", 50),
-			MaxIdleConns:       getEnvInt("DB_MAX_IDLE_CONNS", 10),
-			MaxLifetimeMinutes: getEnvInt("DB_MAX_LIFETIME_MINS", 30),
-			LogLevel:           getEnv("DB_LOG_LEVEL", "info"),
+			Host:                   getEnv("DB_HOST", "localhost"),
+			Port:                   getEnv("DB_PORT", "5432"),
+			User:                   getEnv("DB_USER", "postgres"),
+			Password:               getEnv("DB_PASSWORD", "postgres"),
+			DBName:                 getEnv("DB_NAME", "minisource_logs"),
+			SSLMode:                getEnv("DB_SSL_MODE", "disable"),
+			MaxOpenConns:           getEnvInt("DB_MAX_OPEN_CONNS", 50),
+			MaxIdleConns:           getEnvInt("DB_MAX_IDLE_CONNS", 10),
+			MaxLifetimeMinutes:     getEnvInt("DB_MAX_LIFETIME_MINS", 30),
+			LogLevel:               getEnv("DB_LOG_LEVEL", "info"),
+			PoolStatsInterval:      getDuration("DB_POOL_STATS_INTERVAL", 30*time.Second),
+			PoolWaitWarnCount:      getEnvInt("DB_POOL_WAIT_WARN_COUNT", 100),
+			PoolWaitWarnDuration:   getDuration("DB_POOL_WAIT_WARN_DURATION", time.Second),
+			IndexAdvisorInterval:   getDuration("DB_INDEX_ADVISOR_INTERVAL", time.Hour),
+			IndexAdvisorThreshold:  getEnvInt("DB_INDEX_ADVISOR_THRESHOLD", 100),
+			IndexAdvisorAutoCreate: getEnvBool("DB_INDEX_ADVISOR_AUTO_CREATE", false),
+			ShadowEnabled:          getEnvBool("DB_SHADOW_ENABLED", false),
+			ShadowDSN:              getEnv("DB_SHADOW_DSN", ""),
+			ShadowTable:            getEnv("DB_SHADOW_TABLE", "log_entries"),
+			ServeStaleOnError:      getEnvBool("DB_SERVE_STALE_ON_ERROR", false),
+			StaleCacheTTL:          getDuration("DB_STALE_CACHE_TTL", time.Hour),
+			ReplicaDSN:             getEnv("DB_REPLICA_DSN", ""),
+			ReadYourWritesWindow:   getDuration("DB_READ_YOUR_WRITES_WINDOW", 5*time.Second),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -105,11 +290,71 @@ func Load() (*Config, error) {
 			SampleRate:  getEnvFloat("TRACING_SAMPLE_RATE", 1.0),
 		},
 		Retention: RetentionConfig{
-			Days:           getEnvInt("LOG_RETENTION_DAYS", 30),
-			RetentionDays:  getEnvInt("LOG_RETENTION_DAYS", 30),
-			MaxSizeGB:      getEnvInt("LOG_MAX_SIZE_GB", 50),
-			CleanupEnabled: getEnvBool("LOG_CLEANUP_ENABLED", true),
-			CleanupCron:    getEnv("LOG_CLEANUP_CRON", "0 2 * * *"),
+			Days:               getEnvInt("LOG_RETENTION_DAYS", 30),
+			RetentionDays:      getEnvInt("LOG_RETENTION_DAYS", 30),
+			MaxSizeGB:          getEnvInt("LOG_MAX_SIZE_GB", 50),
+			CleanupEnabled:     getEnvBool("LOG_CLEANUP_ENABLED", true),
+			CleanupCron:        getEnv("LOG_CLEANUP_CRON", "0 2 * * *"),
+			TierDays:           getEnvIntMap("LOG_RETENTION_TIER_DAYS"),
+			RoutingRules:       getEnv("LOG_RETENTION_ROUTING_RULES", ""),
+			AlertEventDays:     getEnvInt("ALERT_EVENT_RETENTION_DAYS", 90),
+			MetricPointDays:    getEnvInt("METRIC_POINT_RETENTION_DAYS", 30),
+			AnalyzeThreshold:   getEnvInt("LOG_CLEANUP_ANALYZE_THRESHOLD", 100000),
+			VacuumAfterCleanup: getEnvBool("LOG_CLEANUP_VACUUM", false),
+		},
+		Alert: AlertConfig{
+			MaxPerTenant:       getEnvInt("ALERT_MAX_PER_TENANT", 100),
+			EvaluationInterval: getDuration("ALERT_EVALUATION_INTERVAL", 1*time.Minute),
+			OutboxInterval:     getDuration("ALERT_OUTBOX_INTERVAL", 30*time.Second),
+			OutboxRetryBackoff: getDuration("ALERT_OUTBOX_RETRY_BACKOFF", 30*time.Second),
+			OutboxMaxAttempts:  getEnvInt("ALERT_OUTBOX_MAX_ATTEMPTS", 10),
+		},
+		Ingestion: IngestionConfig{
+			MessageParsers:       getEnvMap("INGEST_MESSAGE_PARSERS"),
+			EnvironmentPattern:   getEnv("INGEST_ENVIRONMENT_PATTERN", ""),
+			BufferHighWatermark:  getEnvInt("INGEST_BUFFER_HIGH_WATERMARK", 10000),
+			MaxMessageLength:     getEnvInt("INGEST_MAX_MESSAGE_LENGTH", 0),
+			PreserveFullMessage:  getEnvBool("INGEST_PRESERVE_FULL_MESSAGE", false),
+			FlushLevels:          splitList(getEnv("INGEST_FLUSH_LEVELS", "ERROR,FATAL")),
+			MetadataAllowKeys:    getEnvList("INGEST_METADATA_ALLOW_KEYS"),
+			MetadataDenyKeys:     getEnvList("INGEST_METADATA_DENY_KEYS"),
+			MaxAgeMode:           getEnv("INGEST_MAX_AGE_MODE", "accept"),
+			TraceIDFormat:        getEnv("INGEST_TRACE_ID_FORMAT", "hex"),
+			PipelineStages:       getEnvList("INGEST_PIPELINE_STAGES"),
+			PipelineDisabled:     getEnvList("INGEST_PIPELINE_DISABLED"),
+			DuplicateIDKeep:      getEnv("INGEST_DUPLICATE_ID_KEEP", "first"),
+			EnrichmentURLs:       getEnvList("INGEST_ENRICHMENT_URLS"),
+			EnrichmentTimeout:    getDuration("INGEST_ENRICHMENT_TIMEOUT", 250*time.Millisecond),
+			WALEnabled:           getEnvBool("INGEST_WAL_ENABLED", false),
+			WALDir:               getEnv("INGEST_WAL_DIR", "./data/wal"),
+			MaxServicesPerTenant: getEnvInt("INGEST_MAX_SERVICES_PER_TENANT", 0),
+			MaxFutureSkew:        getDuration("INGEST_MAX_FUTURE_SKEW", 0),
+			FutureMode:           getEnv("INGEST_FUTURE_MODE", "clamp"),
+		},
+		Replay: ReplayConfig{
+			WebhookURLs:  getEnvList("REPLAY_WEBHOOK_URLS"),
+			Timeout:      getDuration("REPLAY_WEBHOOK_TIMEOUT", 10*time.Second),
+			JobRetention: getDuration("REPLAY_JOB_RETENTION", time.Hour),
+		},
+		Import: ImportConfig{
+			S3Endpoint:     getEnv("IMPORT_S3_ENDPOINT", ""),
+			S3Bucket:       getEnv("IMPORT_S3_BUCKET", ""),
+			S3Region:       getEnv("IMPORT_S3_REGION", "us-east-1"),
+			S3AccessKey:    getEnv("IMPORT_S3_ACCESS_KEY", ""),
+			S3SecretKey:    getEnv("IMPORT_S3_SECRET_KEY", ""),
+			S3Prefix:       getEnv("IMPORT_S3_PREFIX", ""),
+			ArchivePrefix:  getEnv("ARCHIVE_S3_PREFIX", "archives/"),
+			ArchiveWorkers: getEnvInt("ARCHIVE_WORKERS", 4),
+		},
+		Security: SecurityConfig{
+			NoSniff:               getEnvBool("SECURITY_NOSNIFF", true),
+			XSSProtection:         getEnvBool("SECURITY_XSS_PROTECTION", true),
+			FrameOptions:          getEnv("SECURITY_FRAME_OPTIONS", "DENY"),
+			HSTSMaxAge:            getEnvInt("SECURITY_HSTS_MAX_AGE", 0),
+			HSTSIncludeSubdomains: getEnvBool("SECURITY_HSTS_INCLUDE_SUBDOMAINS", false),
+			HSTSPreload:           getEnvBool("SECURITY_HSTS_PRELOAD", false),
+			ContentSecurityPolicy: getEnv("SECURITY_CSP", ""),
+			AdminKey:              getEnv("SECURITY_ADMIN_KEY", ""),
 		},
 	}, nil
 }
@@ -148,6 +393,50 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvList parses a comma-separated list, dropping empty items
+func getEnvList(key string) []string {
+	return splitList(os.Getenv(key))
+}
+
+// splitList splits a comma-separated value, dropping empty items
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvMap parses "key=value;key2=value2" pairs
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	value := os.Getenv(key)
+	if value == "" {
+		return result
+	}
+	for _, pair := range strings.Split(value, ";") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result
+}
+
+// getEnvIntMap parses "key=1;key2=2" pairs, skipping non-integer values
+func getEnvIntMap(key string) map[string]int {
+	result := make(map[string]int)
+	for k, v := range getEnvMap(key) {
+		if intValue, err := strconv.Atoi(v); err == nil {
+			result[k] = intValue
+		}
+	}
+	return result
+}
+
 func getDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
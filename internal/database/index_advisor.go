@@ -0,0 +1,111 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// indexablePath restricts advised paths to plain keys that are safe to
+// embed in DDL
+var indexablePath = regexp.MustCompile(`^[A-Za-z0-9_]{1,48}$`)
+
+// IndexRecommendation suggests an expression index on a metadata path that
+// queries filtered on frequently
+type IndexRecommendation struct {
+	Path      string `json:"path"`
+	Filters   int64  `json:"filters"`
+	Index     string `json:"index"`
+	Statement string `json:"statement"`
+}
+
+// MetadataIndexAdvisor counts how often queries filter on each metadata
+// path and recommends an index once a path crosses the threshold
+type MetadataIndexAdvisor struct {
+	threshold int64
+
+	mu          sync.Mutex
+	counts      map[string]int64
+	recommended map[string]bool
+}
+
+// NewMetadataIndexAdvisor creates an advisor recommending paths filtered at
+// least threshold times between analyses
+func NewMetadataIndexAdvisor(threshold int) *MetadataIndexAdvisor {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &MetadataIndexAdvisor{
+		threshold:   int64(threshold),
+		counts:      make(map[string]int64),
+		recommended: make(map[string]bool),
+	}
+}
+
+// Observe records one query filtering on the given paths. A nil advisor
+// ignores observations.
+func (a *MetadataIndexAdvisor) Observe(paths ...string) {
+	if a == nil || len(paths) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, path := range paths {
+		if indexablePath.MatchString(path) && !a.recommended[path] {
+			a.counts[path]++
+		}
+	}
+}
+
+// Analyze returns recommendations for paths filtered at least threshold
+// times since the previous analysis, then starts a new sampling period.
+// Each path is recommended once.
+func (a *MetadataIndexAdvisor) Analyze() []IndexRecommendation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var recommendations []IndexRecommendation
+	for path, count := range a.counts {
+		if count < a.threshold {
+			continue
+		}
+		name, statement := MetadataIndexStatement(path)
+		recommendations = append(recommendations, IndexRecommendation{
+			Path:      path,
+			Filters:   count,
+			Index:     name,
+			Statement: statement,
+		})
+		a.recommended[path] = true
+	}
+	a.counts = make(map[string]int64)
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Path < recommendations[j].Path
+	})
+	return recommendations
+}
+
+// MetadataIndexStatement returns the name and DDL of the expression index
+// serving lookups on a top-level metadata path
+func MetadataIndexStatement(path string) (string, string) {
+	name := "idx_logs_meta_" + strings.ToLower(path)
+	return name, fmt.Sprintf(
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON log_entries ((metadata->>'%s'))",
+		name, path,
+	)
+}
+
+// CreateRecommendedIndex builds a recommended index without blocking writes
+func CreateRecommendedIndex(db *gorm.DB, recommendation IndexRecommendation) error {
+	if !indexablePath.MatchString(recommendation.Path) {
+		return fmt.Errorf("metadata path %q cannot be indexed", recommendation.Path)
+	}
+	_, statement := MetadataIndexStatement(recommendation.Path)
+	return db.Exec(statement).Error
+}
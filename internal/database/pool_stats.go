@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Pool gauge names, exposed in Prometheus text format
+const (
+	GaugeOpenConnections    = "db_pool_open_connections"
+	GaugeInUse              = "db_pool_in_use_connections"
+	GaugeIdle               = "db_pool_idle_connections"
+	GaugeMaxOpenConnections = "db_pool_max_open_connections"
+	GaugeWaitCount          = "db_pool_wait_count_total"
+	GaugeWaitDuration       = "db_pool_wait_duration_seconds_total"
+)
+
+// PoolMonitor samples connection pool stats into gauges and warns when
+// callers increasingly wait for connections
+type PoolMonitor struct {
+	stats            func() sql.DBStats
+	waitWarnCount    int64
+	waitWarnDuration time.Duration
+
+	mu     sync.RWMutex
+	gauges map[string]float64
+	last   sql.DBStats
+}
+
+// NewPoolMonitor creates a pool monitor with all gauges registered at zero.
+// stats is typically (*sql.DB).Stats; zero thresholds disable that warning.
+func NewPoolMonitor(stats func() sql.DBStats, waitWarnCount int, waitWarnDuration time.Duration) *PoolMonitor {
+	return &PoolMonitor{
+		stats:            stats,
+		waitWarnCount:    int64(waitWarnCount),
+		waitWarnDuration: waitWarnDuration,
+		gauges: map[string]float64{
+			GaugeOpenConnections:    0,
+			GaugeInUse:              0,
+			GaugeIdle:               0,
+			GaugeMaxOpenConnections: 0,
+			GaugeWaitCount:          0,
+			GaugeWaitDuration:       0,
+		},
+	}
+}
+
+// Sample refreshes the gauges. It returns a tuning warning when waits grew
+// past a threshold since the previous sample, or "" otherwise.
+func (m *PoolMonitor) Sample() string {
+	stats := m.stats()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gauges[GaugeOpenConnections] = float64(stats.OpenConnections)
+	m.gauges[GaugeInUse] = float64(stats.InUse)
+	m.gauges[GaugeIdle] = float64(stats.Idle)
+	m.gauges[GaugeMaxOpenConnections] = float64(stats.MaxOpenConnections)
+	m.gauges[GaugeWaitCount] = float64(stats.WaitCount)
+	m.gauges[GaugeWaitDuration] = stats.WaitDuration.Seconds()
+
+	waits := stats.WaitCount - m.last.WaitCount
+	waited := stats.WaitDuration - m.last.WaitDuration
+	m.last = stats
+
+	if (m.waitWarnCount > 0 && waits >= m.waitWarnCount) ||
+		(m.waitWarnDuration > 0 && waited >= m.waitWarnDuration) {
+		return fmt.Sprintf(
+			"database pool saturated: %d waits totalling %s since last sample with %d/%d connections in use; consider raising DB_MAX_OPEN_CONNS",
+			waits, waited, stats.InUse, stats.MaxOpenConnections,
+		)
+	}
+	return ""
+}
+
+// Gauges returns a snapshot of the current gauge values
+func (m *PoolMonitor) Gauges() map[string]float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]float64, len(m.gauges))
+	for name, value := range m.gauges {
+		snapshot[name] = value
+	}
+	return snapshot
+}
+
+// WritePrometheus writes the gauges in Prometheus text exposition format
+func (m *PoolMonitor) WritePrometheus(w io.Writer) error {
+	gauges := m.Gauges()
+	names := make([]string, 0, len(gauges))
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n%s %g\n", name, name, gauges[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -43,12 +43,61 @@ func NewPostgresDB(cfg config.PostgresConfig) (*gorm.DB, error) {
 	return db, nil
 }
 
+// NewShadowDB opens the dual-write destination: the database at
+// cfg.ShadowDSN, or primary when no DSN is set. Writing into the primary's
+// own log_entries table is rejected.
+func NewShadowDB(cfg config.PostgresConfig, primary *gorm.DB) (*gorm.DB, error) {
+	if cfg.ShadowDSN == "" {
+		if cfg.ShadowTable == "" || cfg.ShadowTable == (models.LogEntry{}).TableName() {
+			return nil, fmt.Errorf("shadow table must differ from the primary table when no shadow DSN is set")
+		}
+		return primary, nil
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.ShadowDSN), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to shadow database: %w", err)
+	}
+	return db, nil
+}
+
+// NewReplicaDB opens the read replica at cfg.ReplicaDSN with the primary's
+// pool settings
+func NewReplicaDB(cfg config.PostgresConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.ReplicaDSN), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to read replica: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.MaxLifetimeMinutes) * time.Minute)
+
+	return db, nil
+}
+
 // AutoMigrate runs database migrations
 func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&models.LogEntry{},
 		&models.LogRetention{},
 		&models.LogAlert{},
+		&models.LogAlertEvent{},
+		&models.AlertOutboxItem{},
+		&models.MetricRule{},
+		&models.MetricPoint{},
+		&models.TenantSettings{},
+		&models.TenantAlertSettings{},
+		&models.ErrorFingerprint{},
+		&models.LogCounter{},
 	)
 }
 
@@ -60,8 +109,18 @@ func CreateIndexes(db *gorm.DB) error {
          ON log_entries (tenant_id, service_name, timestamp DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_logs_level_time 
          ON log_entries (level, timestamp DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_tenant_level_time 
+         ON log_entries (tenant_id, level, timestamp DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_message_hash 
+         ON log_entries USING hash (message)`,
 		`CREATE INDEX IF NOT EXISTS idx_logs_metadata_gin 
          ON log_entries USING gin (metadata jsonb_path_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_host_pattern 
+         ON log_entries (host text_pattern_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_tenant_service_prefix 
+         ON log_entries (tenant_id, service_name text_pattern_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_logs_tenant_env_prefix 
+         ON log_entries (tenant_id, environment text_pattern_ops)`,
 	}
 
 	for _, idx := range indexes {
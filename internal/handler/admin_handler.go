@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+)
+
+// AdminHandler handles operator-only HTTP requests
+type AdminHandler struct {
+	logService *service.LogService
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(logService *service.LogService) *AdminHandler {
+	return &AdminHandler{logService: logService}
+}
+
+// PurgeService deletes all logs of a service
+// @Summary Purge a service's logs
+// @Description Deletes all logs of a decommissioned service for the current tenant. Requests without a tenant must pass all=true to purge across tenants, which requires X-Admin-Key. The confirm parameter must repeat the service name.
+// @Tags admin
+// @Produce json
+// @Param service query string true "Service name"
+// @Param confirm query string true "Must equal the service name"
+// @Param all query bool false "Purge across all tenants when no tenant is set"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Router /admin/logs [delete]
+func (h *AdminHandler) PurgeService(c *fiber.Ctx) error {
+	serviceName := c.Query("service")
+	if serviceName == "" {
+		return response.BadRequest(c, "invalid_service", "Service name is required")
+	}
+	if c.Query("confirm") != serviceName {
+		return response.BadRequest(c, "confirmation_required", "Set confirm to the service name to purge its logs")
+	}
+
+	var tenantID *uuid.UUID
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		tenantID = &tid
+	} else if !c.QueryBool("all") {
+		return response.BadRequest(c, "tenant_required", "Set a tenant or pass all=true to purge across tenants")
+	} else if !isAdmin(c) {
+		return errorWithDetails(c, fiber.StatusForbidden, "admin_required",
+			"Purging across tenants requires X-Admin-Key", fiber.Map{"service": serviceName})
+	}
+
+	deleted, err := h.logService.PurgeService(c.Context(), tenantID, serviceName)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, fiber.Map{
+		"service": serviceName,
+		"deleted": deleted,
+	})
+}
+
+// SelfTest runs a write/read/cache/delete round-trip against the full stack
+// @Summary Run a deployment self-test
+// @Description Ingests a synthetic entry under a reserved tenant, reads it back, checks the query cache and deletes it, reporting timing and pass/fail per step
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.SelfTestResult
+// @Failure 503 {object} map[string]interface{}
+// @Router /admin/selftest [post]
+func (h *AdminHandler) SelfTest(c *fiber.Ctx) error {
+	result := h.logService.SelfTest(c.Context())
+	if !result.Passed {
+		return errorWithDetails(c, fiber.StatusServiceUnavailable, "selftest_failed",
+			"One or more self-test steps failed", fiber.Map{"result": result})
+	}
+
+	return response.OK(c, result)
+}
+
+// GetMaintenance reports the maintenance mode state
+// @Summary Get maintenance mode
+// @Description Reports whether cleanup and other destructive background jobs are paused
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.MaintenanceStatus
+// @Router /admin/maintenance [get]
+func (h *AdminHandler) GetMaintenance(c *fiber.Ctx) error {
+	return response.OK(c, h.logService.MaintenanceStatus())
+}
+
+// SetMaintenance turns maintenance mode on or off
+// @Summary Set maintenance mode
+// @Description Pauses or resumes cleanup and other destructive background jobs; ingestion and queries are unaffected. The flag is held in memory and resets on restart.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body models.MaintenanceRequest true "Maintenance Request"
+// @Success 200 {object} models.MaintenanceStatus
+// @Failure 400 {object} response.Response
+// @Router /admin/maintenance [put]
+func (h *AdminHandler) SetMaintenance(c *fiber.Ctx) error {
+	var req models.MaintenanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	return response.OK(c, h.logService.SetMaintenanceMode(req.Enabled, req.Reason))
+}
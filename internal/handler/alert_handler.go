@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/minisource/go-common/response"
@@ -20,16 +22,18 @@ func NewAlertHandler(service *service.AlertService) *AlertHandler {
 
 // CreateAlert creates a new alert
 // @Summary Create alert
-// @Description Creates a new log alert rule
+// @Description Creates a new log alert rule, enabled unless enabled is false. Only enabled alerts count towards the per-tenant limit.
 // @Tags alerts
 // @Accept json
 // @Produce json
 // @Param alert body models.LogAlert true "Log Alert"
 // @Success 201 {object} models.LogAlert
 // @Failure 400 {object} response.Response
+// @Failure 409 {object} map[string]interface{}
 // @Router /alerts [post]
 func (h *AlertHandler) CreateAlert(c *fiber.Ctx) error {
-	var alert models.LogAlert
+	// Alerts are enabled unless the request disables them
+	alert := models.LogAlert{Enabled: true}
 	if err := c.BodyParser(&alert); err != nil {
 		return response.BadRequest(c, "invalid_request", err.Error())
 	}
@@ -42,7 +46,7 @@ func (h *AlertHandler) CreateAlert(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.CreateAlert(c.Context(), &alert); err != nil {
-		return response.InternalError(c, err.Error())
+		return alertError(c, err)
 	}
 
 	return response.Created(c, alert)
@@ -72,7 +76,7 @@ func (h *AlertHandler) UpdateAlert(c *fiber.Ctx) error {
 
 	alert.ID = id
 	if err := h.service.UpdateAlert(c.Context(), &alert); err != nil {
-		return response.InternalError(c, err.Error())
+		return alertError(c, err)
 	}
 
 	return response.OK(c, alert)
@@ -101,6 +105,30 @@ func (h *AlertHandler) GetAlert(c *fiber.Ctx) error {
 	return response.OK(c, alert)
 }
 
+// GetAlertEvents retrieves an alert's history
+// @Summary Get alert history
+// @Description Retrieves recent firing and resolution events for an alert
+// @Tags alerts
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Param limit query int false "Maximum events to return"
+// @Success 200 {array} models.LogAlertEvent
+// @Failure 400 {object} response.Response
+// @Router /alerts/{id}/events [get]
+func (h *AlertHandler) GetAlertEvents(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid alert ID format")
+	}
+
+	events, err := h.service.GetAlertEvents(c.Context(), id, c.QueryInt("limit", 100))
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, events)
+}
+
 // ListAlerts lists alerts for a tenant
 // @Summary List alerts
 // @Description Lists all alerts for the current tenant
@@ -151,6 +179,7 @@ func (h *AlertHandler) DeleteAlert(c *fiber.Ctx) error {
 // @Tags alerts
 // @Param id path string true "Alert ID"
 // @Success 204
+// @Failure 409 {object} map[string]interface{}
 // @Router /alerts/{id}/enable [post]
 func (h *AlertHandler) EnableAlert(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
@@ -159,7 +188,7 @@ func (h *AlertHandler) EnableAlert(c *fiber.Ctx) error {
 	}
 
 	if err := h.service.EnableAlert(c.Context(), id); err != nil {
-		return response.InternalError(c, err.Error())
+		return alertError(c, err)
 	}
 
 	return response.NoContent(c)
@@ -184,3 +213,59 @@ func (h *AlertHandler) DisableAlert(c *fiber.Ctx) error {
 
 	return response.NoContent(c)
 }
+
+// BulkAlerts applies an action to matching alerts
+// @Summary Bulk update alerts
+// @Description Enables, disables or deletes every alert of the tenant matching the selector (severity, service in the filter, name glob), e.g. to silence noisy alerts during an incident
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param request body models.AlertBulkRequest true "Selector and action"
+// @Success 200 {object} models.AlertBulkResult
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} map[string]interface{}
+// @Router /alerts/bulk [post]
+func (h *AlertHandler) BulkAlerts(c *fiber.Ctx) error {
+	var req models.AlertBulkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	var tenantID uuid.UUID
+	if tid := c.Locals("tenant_id"); tid != nil {
+		if t, ok := tid.(uuid.UUID); ok {
+			tenantID = t
+		}
+	}
+
+	result, err := h.service.BulkAlerts(c.Context(), tenantID, req)
+	if err != nil {
+		return alertError(c, err)
+	}
+
+	return response.OK(c, result)
+}
+
+// alertError maps alert service errors to HTTP responses
+func alertError(c *fiber.Ctx, err error) error {
+	var limitErr *service.AlertLimitError
+	if errors.As(err, &limitErr) {
+		return errorWithDetails(c, fiber.StatusConflict, "alert_limit_exceeded", limitErr.Error(), fiber.Map{
+			"count": limitErr.Count,
+			"limit": limitErr.Limit,
+		})
+	}
+	if errors.Is(err, service.ErrInvalidAlertType) {
+		return response.BadRequest(c, "invalid_alert_type", err.Error())
+	}
+	if errors.Is(err, service.ErrInvalidSeverityRoutes) {
+		return response.BadRequest(c, "invalid_severity_routes", err.Error())
+	}
+	if errors.Is(err, service.ErrInvalidBulkRequest) {
+		return response.BadRequest(c, "invalid_bulk_request", err.Error())
+	}
+	if errors.Is(err, service.ErrFilterTooComplex) || errors.Is(err, service.ErrInvalidFilter) {
+		return response.BadRequest(c, "invalid_filter", err.Error())
+	}
+	return response.InternalError(c, err.Error())
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+)
+
+// ArchiveHandler handles archival HTTP requests
+type ArchiveHandler struct {
+	service *service.ArchiveService
+}
+
+// NewArchiveHandler creates a new archive handler
+func NewArchiveHandler(service *service.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{service: service}
+}
+
+// ArchiveS3 moves a tenant's logs in a time range to object storage
+// @Summary Archive logs to object storage
+// @Description Uploads the tenant's logs in [start, end) as one gzipped NDJSON object per day, archiving several days concurrently. A day's logs are deleted only after its upload succeeds; failed days keep their logs and report an error. Archival is refused while maintenance mode is on.
+// @Tags logs
+// @Accept json
+// @Produce json
+// @Param request body models.ArchiveRequest true "Archive Request"
+// @Success 200 {object} models.ArchiveSummary
+// @Failure 400 {object} response.Response
+// @Failure 409 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /logs/archive/s3 [post]
+func (h *ArchiveHandler) ArchiveS3(c *fiber.Ctx) error {
+	if !h.service.Enabled() {
+		return errorWithDetails(c, fiber.StatusServiceUnavailable, "archive_disabled",
+			service.ErrArchiveNotConfigured.Error(), nil)
+	}
+
+	var req models.ArchiveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	tenantID, ok := c.Locals("tenant_id").(uuid.UUID)
+	if !ok {
+		return response.BadRequest(c, "tenant_required", "Archival requires a tenant")
+	}
+
+	summary, err := h.service.Archive(c.Context(), tenantID, req.Start, req.End)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidArchiveRange) {
+			return response.BadRequest(c, "invalid_range", err.Error())
+		}
+		if errors.Is(err, service.ErrMaintenanceMode) {
+			return errorWithDetails(c, fiber.StatusConflict, "maintenance_mode", err.Error(), nil)
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, summary)
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+)
+
+// CompatHandler serves ingestion and query APIs compatible with other log
+// systems so existing shippers and datasources can point at this service
+type CompatHandler struct {
+	logService *service.LogService
+}
+
+// NewCompatHandler creates a new compatibility handler
+func NewCompatHandler(logService *service.LogService) *CompatHandler {
+	return &CompatHandler{logService: logService}
+}
+
+// LokiPush handles Loki JSON pushes
+// @Summary Ingest logs in Loki push format
+// @Description Accepts Loki's JSON push body. Service, environment, level and host are taken from well-known stream labels; other labels are kept in metadata. The tenant comes from X-Tenant-ID or, if that is absent, a UUID X-Scope-OrgID. Protobuf pushes are not supported.
+// @Tags compat
+// @Accept json
+// @Param push body models.LokiPushRequest true "Loki Push Request"
+// @Success 204
+// @Failure 400 {object} response.Response
+// @Failure 415 {object} map[string]interface{}
+// @Router /loki/api/v1/push [post]
+func (h *CompatHandler) LokiPush(c *fiber.Ctx) error {
+	if ct := c.Get(fiber.HeaderContentType); ct != "" && !strings.HasPrefix(ct, fiber.MIMEApplicationJSON) {
+		return errorWithDetails(c, fiber.StatusUnsupportedMediaType, "unsupported_media_type",
+			"Only JSON Loki pushes are supported", fiber.Map{"content_type": ct})
+	}
+
+	var req models.LokiPushRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	entries, err := service.LokiPushEntries(req, lokiTenant(c))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLokiPush) {
+			return response.BadRequest(c, "invalid_request", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	if len(entries) > 0 {
+		if _, err := h.logService.IngestBatch(c.Context(), &models.LogBatch{Entries: entries}); err != nil {
+			if errors.Is(err, service.ErrEntryTooOld) {
+				return response.BadRequest(c, "entry_too_old", err.Error())
+			}
+			if errors.Is(err, service.ErrEntryInFuture) {
+				return response.BadRequest(c, "entry_in_future", err.Error())
+			}
+			if errors.Is(err, service.ErrInvalidLevel) {
+				return response.BadRequest(c, "invalid_level", err.Error())
+			}
+			return response.InternalError(c, err.Error())
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// LokiQueryRange handles Loki range queries
+// @Summary Query logs in Loki format
+// @Description Translates a LogQL-lite query (a stream selector of = matchers on service, environment and level labels, plus an optional |= line filter) and time range into a log query, returning Loki's streams result so Grafana's Loki datasource can read it
+// @Tags compat
+// @Produce json
+// @Param query query string true "LogQL query, e.g. {service=\"api\"} |= \"timeout\""
+// @Param start query string false "Start time as unix ns/seconds or RFC3339 (default one hour ago)"
+// @Param end query string false "End time as unix ns/seconds or RFC3339 (default now)"
+// @Param limit query int false "Maximum lines (default 100, max 1000)"
+// @Param direction query string false "backward (default) or forward; forward orders the newest lines oldest first"
+// @Success 200 {object} models.LokiQueryResponse
+// @Failure 400 {object} response.Response
+// @Router /loki/api/v1/query_range [get]
+func (h *CompatHandler) LokiQueryRange(c *fiber.Ctx) error {
+	filter, err := service.ParseLokiQuery(c.Query("query"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_query", err.Error())
+	}
+
+	end := time.Now().UTC()
+	if v := c.Query("end"); v != "" {
+		if end, err = service.ParseLokiTime(v); err != nil {
+			return response.BadRequest(c, "invalid_time", "end must be unix nanoseconds, seconds or RFC3339")
+		}
+	}
+	start := end.Add(-time.Hour)
+	if v := c.Query("start"); v != "" {
+		if start, err = service.ParseLokiTime(v); err != nil {
+			return response.BadRequest(c, "invalid_time", "start must be unix nanoseconds, seconds or RFC3339")
+		}
+	}
+	filter.StartTime = &start
+	filter.EndTime = &end
+
+	if tenantID := lokiTenant(c); tenantID != uuid.Nil {
+		filter.TenantID = &tenantID
+	}
+	// Larger limits are clamped rather than falling back to the default
+	filter.Page = 1
+	filter.PageSize = 100
+	if v := c.Query("limit"); v != "" {
+		if filter.PageSize, err = strconv.Atoi(v); err != nil || filter.PageSize < 1 {
+			return response.BadRequest(c, "invalid_limit", "limit must be a positive integer")
+		}
+		filter.PageSize = min(filter.PageSize, 1000)
+	}
+
+	result, err := h.logService.Query(c.Context(), filter)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	entries := result.Entries
+	if c.Query("direction") == "forward" {
+		reversed := make([]models.LogEntry, len(entries))
+		for i, entry := range entries {
+			reversed[len(entries)-1-i] = entry
+		}
+		entries = reversed
+	}
+
+	return c.JSON(service.LokiStreams(entries))
+}
+
+// ESBulk handles Elasticsearch _bulk ingestion
+// @Summary Ingest logs in Elasticsearch _bulk format
+// @Description Accepts _bulk NDJSON action/source pairs. index and create actions are ingested with @timestamp, message, level and service fields mapped onto the entry and other fields kept as metadata; other actions fail individually. Responds with an Elasticsearch-compatible bulk response.
+// @Tags compat
+// @Accept application/x-ndjson
+// @Produce json
+// @Success 200 {object} models.ESBulkResponse
+// @Router /_bulk [post]
+func (h *CompatHandler) ESBulk(c *fiber.Ctx) error {
+	start := time.Now()
+
+	var tenantID uuid.UUID
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		tenantID = tid
+	}
+
+	ops := service.ParseESBulk(c.Body(), tenantID)
+
+	var entries []models.LogEntry
+	for _, op := range ops {
+		if op.Entry != nil {
+			entries = append(entries, *op.Entry)
+		}
+	}
+
+	var result models.BatchResult
+	var ingestErr error
+	if len(entries) > 0 {
+		result, ingestErr = h.logService.IngestBatch(c.Context(), &models.LogBatch{Entries: entries})
+	}
+
+	return c.JSON(service.ESBulkResult(ops, result.Failures, ingestErr, time.Since(start)))
+}
+
+// OTLPLogs handles OTLP/HTTP log exports
+// @Summary Ingest logs in OTLP/HTTP format
+// @Description Accepts an OTLP ExportLogsServiceRequest as protobuf or JSON. service.name, deployment.environment and host.name resource attributes become the service, environment and host; severity numbers map onto levels; record attributes become metadata. Records that are too old, too far in the future or fail to store are rejected individually and reported in the partial success of the response, which uses the request's encoding.
+// @Tags compat
+// @Accept json
+// @Accept application/x-protobuf
+// @Produce json
+// @Produce application/x-protobuf
+// @Param logs body models.OTLPLogsRequest true "OTLP Logs Request"
+// @Success 200 {object} models.OTLPLogsResponse
+// @Failure 400 {object} response.Response
+// @Failure 415 {object} map[string]interface{}
+// @Router /api/v1/logs/otlp [post]
+func (h *CompatHandler) OTLPLogs(c *fiber.Ctx) error {
+	ct := c.Get(fiber.HeaderContentType)
+	protobuf := strings.HasPrefix(ct, "application/x-protobuf")
+	if !protobuf && !strings.HasPrefix(ct, fiber.MIMEApplicationJSON) {
+		return errorWithDetails(c, fiber.StatusUnsupportedMediaType, "unsupported_media_type",
+			"OTLP logs must be application/x-protobuf or application/json", fiber.Map{"content_type": ct})
+	}
+
+	var req models.OTLPLogsRequest
+	var err error
+	if protobuf {
+		req, err = service.DecodeOTLPLogsProto(c.Body())
+	} else {
+		err = json.Unmarshal(c.Body(), &req)
+	}
+	if err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	var tenantID uuid.UUID
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		tenantID = tid
+	}
+
+	var resp models.OTLPLogsResponse
+	if entries := service.OTLPEntries(req, tenantID); len(entries) > 0 {
+		// Records failing validation are reported, not the whole export
+		result, err := h.logService.IngestBatch(c.Context(), &models.LogBatch{Entries: entries, Partial: true})
+		if err != nil {
+			return response.InternalError(c, err.Error())
+		}
+		if len(result.Failures) > 0 {
+			resp.PartialSuccess = &models.OTLPPartialSuccess{
+				RejectedLogRecords: int64(len(result.Failures)),
+				ErrorMessage:       result.Failures[0].Reason,
+			}
+		}
+	}
+
+	if protobuf {
+		c.Set(fiber.HeaderContentType, "application/x-protobuf")
+		return c.Send(service.EncodeOTLPLogsResponseProto(resp))
+	}
+	return c.JSON(resp)
+}
+
+// lokiTenant returns the tenant resolved by TenantExtractor or, for Loki
+// clients, ScopeOrgIDExtractor
+func lokiTenant(c *fiber.Ctx) uuid.UUID {
+	tid, _ := c.Locals("tenant_id").(uuid.UUID)
+	return tid
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/minisource/log/internal/models"
+)
+
+// mimeTextCSV is the media type of CSV query results
+const mimeTextCSV = "text/csv"
+
+// writeCSV writes flattened rows as CSV with a header row of the columns.
+// Paging details, which have no place in the rows, go in X-Total-Count,
+// X-Has-More and X-Next-Cursor.
+func writeCSV(c *fiber.Ctx, result *models.LogRowsResult) error {
+	c.Set(fiber.HeaderContentType, mimeTextCSV+"; charset=utf-8")
+	c.Set("X-Total-Count", strconv.FormatInt(result.TotalCount, 10))
+	c.Set("X-Has-More", strconv.FormatBool(result.HasMore))
+	if result.NextCursor != "" {
+		c.Set("X-Next-Cursor", result.NextCursor)
+	}
+
+	w := csv.NewWriter(c.Response().BodyWriter())
+	if err := w.Write(result.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(result.Columns))
+	for _, row := range result.Rows {
+		for i, column := range result.Columns {
+			record[i] = csvValue(row[column])
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// csvValue renders a flattened value as a CSV field, matching its JSON
+// rendering for times and nested values
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case json.RawMessage:
+		return string(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
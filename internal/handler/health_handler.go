@@ -1,16 +1,28 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/database"
+	"github.com/minisource/log/internal/service"
 )
 
+// MetricRejectedServiceEntries counts entries rejected because their tenant
+// reached its distinct service cap
+const MetricRejectedServiceEntries = "log_ingest_rejected_service_entries_total"
+
 // HealthHandler handles health check requests
-type HealthHandler struct{}
+type HealthHandler struct {
+	pool       *database.PoolMonitor
+	logService *service.LogService
+}
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+// NewHealthHandler creates a new health handler; logService may be nil when
+// only pool gauges are exposed
+func NewHealthHandler(pool *database.PoolMonitor, logService *service.LogService) *HealthHandler {
+	return &HealthHandler{pool: pool, logService: logService}
 }
 
 // Health returns basic health status
@@ -54,3 +66,23 @@ func (h *HealthHandler) Live(c *fiber.Ctx) error {
 		"status": "alive",
 	})
 }
+
+// Metrics exposes operational gauges
+// @Summary Operational metrics
+// @Description Returns database connection pool gauges (open, in-use, idle, max open, cumulative wait count and duration) and ingestion counters in Prometheus text format
+// @Tags health
+// @Produce plain
+// @Success 200 {string} string
+// @Router /metrics [get]
+func (h *HealthHandler) Metrics(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4")
+	if err := h.pool.WritePrometheus(c); err != nil {
+		return err
+	}
+	if h.logService == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(c, "# TYPE %s counter\n%s %d\n",
+		MetricRejectedServiceEntries, MetricRejectedServiceEntries, h.logService.RejectedServiceEntries())
+	return err
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+)
+
+// errorWithDetails writes an error response with a status not covered by the
+// shared response helpers, carrying extra machine-readable details
+func errorWithDetails(c *fiber.Ctx, status int, code, message string, details fiber.Map) error {
+	body := fiber.Map{
+		"code":    code,
+		"message": message,
+	}
+	if details != nil {
+		body["details"] = details
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error":   body,
+	})
+}
+
+// isAdmin reports whether AdminExtractor marked the request as an operator's
+func isAdmin(c *fiber.Ctx) bool {
+	admin, _ := c.Locals("admin").(bool)
+	return admin
+}
+
+// canManageTenant reports whether the caller may act on tenantID given in a
+// path: its own tenant, or any tenant for an operator
+func canManageTenant(c *fiber.Ctx, tenantID uuid.UUID) bool {
+	if isAdmin(c) {
+		return true
+	}
+	own, ok := c.Locals("tenant_id").(uuid.UUID)
+	return ok && own == tenantID
+}
+
+// tenantForbidden writes the 403 for a request on another tenant
+func tenantForbidden(c *fiber.Ctx, tenantID uuid.UUID) error {
+	return errorWithDetails(c, fiber.StatusForbidden, "tenant_forbidden",
+		"Only the tenant itself or an X-Admin-Key caller may access this tenant", fiber.Map{"tenant_id": tenantID})
+}
+
+// accepted writes a 202 response for work queued for asynchronous processing
+func accepted(c *fiber.Ctx, data interface{}) error {
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// multiStatus writes a 207 response for a request that partly succeeded
+func multiStatus(c *fiber.Ctx, data interface{}) error {
+	return c.Status(fiber.StatusMultiStatus).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// queryResult writes a query result: as CSV rows when the request accepts
+// text/csv over JSON, otherwise as JSON, flattening entries into rows when
+// the request asks for flatten=true. With collapse=true runs of identical
+// consecutive entries are folded into one carrying a repeat_count.
+func queryResult(c *fiber.Ctx, result *models.LogQueryResult) error {
+	c.Vary(fiber.HeaderAccept)
+	if c.QueryBool("collapse") {
+		// The result may be shared with concurrent or cached readers
+		collapsed := *result
+		collapsed.Entries = models.CollapseRepeats(result.Entries)
+		result = &collapsed
+	}
+	if c.Accepts(fiber.MIMEApplicationJSON, mimeTextCSV) == mimeTextCSV {
+		return writeCSV(c, models.FlattenQueryResult(result))
+	}
+	if c.QueryBool("flatten") {
+		return response.OK(c, models.FlattenQueryResult(result))
+	}
+	return response.OK(c, result)
+}
+
+// parseIngestBody parses an ingestion body. Requests sending X-Strict-JSON:
+// true are decoded strictly, rejecting fields the entry schema does not know
+// so misspelled fields are caught instead of silently dropped.
+func parseIngestBody(c *fiber.Ctx, out interface{}) error {
+	if !strings.EqualFold(c.Get("X-Strict-JSON"), "true") {
+		return c.BodyParser(out)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("strict JSON: %w", err)
+	}
+	return nil
+}
+
+// applyEntryContext fills the environment and source an entry omits from the
+// request's X-Log-Env and X-Log-Source headers
+func applyEntryContext(c *fiber.Ctx, entry *models.LogEntry) {
+	if env, ok := c.Locals("log_environment").(string); ok && entry.Environment == "" {
+		entry.Environment = env
+	}
+	if source, ok := c.Locals("log_source").(string); ok && entry.Source == "" {
+		entry.Source = source
+	}
+}
+
+// entryCollection writes a collection response. Empty results are still a
+// 200 with an empty list; found tells clients whether anything matched.
+func entryCollection(c *fiber.Ctx, entries []models.LogEntry) error {
+	if entries == nil {
+		entries = []models.LogEntry{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    entries,
+		"found":   len(entries) > 0,
+	})
+}
+
+// queryPaths are the read endpoints under /logs that hit the database for
+// searches and aggregations
+var queryPaths = []string{
+	"/logs/query", "/logs/aggregate", "/logs/search-with-agg", "/logs/stats", "/logs/volume-forecast",
+	"/logs/histogram", "/logs/errors", "/logs/export", "/logs/suggest", "/logs/request-durations",
+}
+
+// IsQueryRequest reports whether a request searches or aggregates logs, which
+// the query rate limit applies to; ingestion is limited separately
+func IsQueryRequest(method, path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	if strings.HasSuffix(path, "/logs") {
+		return method == fiber.MethodGet || method == fiber.MethodHead
+	}
+	for _, queryPath := range queryPaths {
+		if strings.HasSuffix(path, queryPath) {
+			return true
+		}
+	}
+	return false
+}
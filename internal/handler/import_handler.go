@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+)
+
+// ImportHandler handles bulk import HTTP requests
+type ImportHandler struct {
+	service *service.ImportService
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(service *service.ImportService) *ImportHandler {
+	return &ImportHandler{service: service}
+}
+
+// ImportS3 imports NDJSON log archives from object storage
+// @Summary Import logs from object storage
+// @Description Imports NDJSON objects (gzipped when the key ends in .gz) under the configured prefix into the caller's tenant, skipping entries whose ID already exists. Entries are redacted and validated like ingested ones. With keep_tenants, an X-Admin-Key caller imports into the tenants the files name. Streams one NDJSON progress frame per object followed by a summary.
+// @Tags logs
+// @Accept json
+// @Produce application/x-ndjson
+// @Param request body models.ImportRequest false "Import Request"
+// @Success 200 {object} models.ImportProgress
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /logs/import/s3 [post]
+func (h *ImportHandler) ImportS3(c *fiber.Ctx) error {
+	if !h.service.Enabled() {
+		return errorWithDetails(c, fiber.StatusServiceUnavailable, "import_disabled",
+			service.ErrImportNotConfigured.Error(), nil)
+	}
+
+	var req models.ImportRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return response.BadRequest(c, "invalid_request", err.Error())
+		}
+	}
+
+	// Entries go to the caller's tenant unless an operator keeps the files'
+	var tenantID *uuid.UUID
+	if req.KeepTenants {
+		if !isAdmin(c) {
+			return errorWithDetails(c, fiber.StatusForbidden, "admin_required",
+				"Importing into the files' tenants requires X-Admin-Key", nil)
+		}
+	} else {
+		tid, ok := c.Locals("tenant_id").(uuid.UUID)
+		if !ok {
+			return response.BadRequest(c, "tenant_required", "Import requires a tenant")
+		}
+		tenantID = &tid
+	}
+
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is gone once the handler returns
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+
+		enc := json.NewEncoder(w)
+		summary, err := h.service.Import(ctx, req.Prefix, tenantID, func(frame models.ImportProgress) {
+			enc.Encode(frame)
+			w.Flush()
+		})
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		enc.Encode(summary)
+		w.Flush()
+	})
+
+	return nil
+}
@@ -1,7 +1,14 @@
 package handler
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,11 +21,12 @@ import (
 // LogHandler handles log HTTP requests
 type LogHandler struct {
 	logService *service.LogService
+	streams    *StreamLimiter
 }
 
 // NewLogHandler creates a new log handler
-func NewLogHandler(logService *service.LogService) *LogHandler {
-	return &LogHandler{logService: logService}
+func NewLogHandler(logService *service.LogService, streams *StreamLimiter) *LogHandler {
+	return &LogHandler{logService: logService, streams: streams}
 }
 
 // IngestSingle handles single log ingestion
@@ -28,12 +36,15 @@ func NewLogHandler(logService *service.LogService) *LogHandler {
 // @Accept json
 // @Produce json
 // @Param log body models.LogEntry true "Log Entry"
+// @Param X-Log-Env header string false "Environment for entries that omit it"
+// @Param X-Log-Source header string false "Source for entries that omit it"
+// @Param X-Strict-JSON header bool false "Reject unknown entry fields instead of ignoring them"
 // @Success 201 {object} models.LogEntry
 // @Failure 400 {object} response.Response
 // @Router /logs [post]
 func (h *LogHandler) IngestSingle(c *fiber.Ctx) error {
 	var entry models.LogEntry
-	if err := c.BodyParser(&entry); err != nil {
+	if err := parseIngestBody(c, &entry); err != nil {
 		return response.BadRequest(c, "invalid_request", err.Error())
 	}
 
@@ -43,8 +54,18 @@ func (h *LogHandler) IngestSingle(c *fiber.Ctx) error {
 			entry.TenantID = tid
 		}
 	}
+	applyEntryContext(c, &entry)
 
 	if err := h.logService.IngestSingle(c.Context(), &entry); err != nil {
+		if errors.Is(err, service.ErrEntryTooOld) {
+			return response.BadRequest(c, "entry_too_old", err.Error())
+		}
+		if errors.Is(err, service.ErrEntryInFuture) {
+			return response.BadRequest(c, "entry_in_future", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidLevel) {
+			return response.BadRequest(c, "invalid_level", err.Error())
+		}
 		return response.InternalError(c, err.Error())
 	}
 
@@ -53,17 +74,21 @@ func (h *LogHandler) IngestSingle(c *fiber.Ctx) error {
 
 // IngestBatch handles batch log ingestion
 // @Summary Ingest multiple log entries
-// @Description Ingests a batch of log entries
+// @Description Ingests a batch of log entries. Entries with a level other than DEBUG, INFO, WARN, ERROR or FATAL (any case), or that fail to insert, are left out while the rest are stored; the response then has status 207 and lists them under failures by index, so clients can retry just those. A batch with no valid entry fails with 400.
 // @Tags logs
 // @Accept json
 // @Produce json
 // @Param logs body models.LogBatch true "Log Batch"
-// @Success 201 {object} map[string]int
+// @Param X-Log-Env header string false "Environment for entries that omit it"
+// @Param X-Log-Source header string false "Source for entries that omit it"
+// @Param X-Strict-JSON header bool false "Reject unknown entry fields instead of ignoring them"
+// @Success 201 {object} map[string]interface{}
+// @Success 207 {object} map[string]interface{}
 // @Failure 400 {object} response.Response
 // @Router /logs/batch [post]
 func (h *LogHandler) IngestBatch(c *fiber.Ctx) error {
 	var batch models.LogBatch
-	if err := c.BodyParser(&batch); err != nil {
+	if err := parseIngestBody(c, &batch); err != nil {
 		return response.BadRequest(c, "invalid_request", err.Error())
 	}
 
@@ -77,23 +102,160 @@ func (h *LogHandler) IngestBatch(c *fiber.Ctx) error {
 			}
 		}
 	}
+	for i := range batch.Entries {
+		applyEntryContext(c, &batch.Entries[i])
+	}
 
-	if err := h.logService.IngestBatch(c.Context(), &batch); err != nil {
+	result, err := h.logService.IngestBatch(c.Context(), &batch)
+	if err != nil {
+		if errors.Is(err, service.ErrEntryTooOld) {
+			return response.BadRequest(c, "entry_too_old", err.Error())
+		}
+		if errors.Is(err, service.ErrEntryInFuture) {
+			return response.BadRequest(c, "entry_in_future", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidLevel) {
+			return response.BadRequest(c, "invalid_level", err.Error())
+		}
 		return response.InternalError(c, err.Error())
 	}
 
-	return response.Created(c, fiber.Map{
-		"count": len(batch.Entries),
+	body := fiber.Map{
+		"count":                len(batch.Entries),
+		"accepted":             result.Accepted,
+		"rejected":             result.Rejected,
+		"duplicates_collapsed": batch.DuplicatesCollapsed,
+	}
+	if result.Rejected > 0 {
+		body["failures"] = result.Failures
+		return multiStatus(c, body)
+	}
+	return response.Created(c, body)
+}
+
+// IngestBatchStream handles large batch ingestion with streamed progress
+// @Summary Ingest a batch with streamed progress
+// @Description Ingests a batch in sub-batches, streaming NDJSON progress frames followed by a summary
+// @Tags logs
+// @Accept json
+// @Produce application/x-ndjson
+// @Param logs body models.LogBatch true "Log Batch"
+// @Param X-Log-Env header string false "Environment for entries that omit it"
+// @Param X-Log-Source header string false "Source for entries that omit it"
+// @Param X-Strict-JSON header bool false "Reject unknown entry fields instead of ignoring them"
+// @Param chunk_size query int false "Entries per sub-batch (default 1000)"
+// @Success 200 {object} models.BatchProgress
+// @Failure 400 {object} response.Response
+// @Router /logs/batch/stream [post]
+func (h *LogHandler) IngestBatchStream(c *fiber.Ctx) error {
+	var batch models.LogBatch
+	if err := parseIngestBody(c, &batch); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	// Set tenant from context if available
+	if tenantID := c.Locals("tenant_id"); tenantID != nil {
+		if tid, ok := tenantID.(uuid.UUID); ok {
+			for i := range batch.Entries {
+				if batch.Entries[i].TenantID == uuid.Nil {
+					batch.Entries[i].TenantID = tid
+				}
+			}
+		}
+	}
+	for i := range batch.Entries {
+		applyEntryContext(c, &batch.Entries[i])
+	}
+
+	chunkSize, _ := strconv.Atoi(c.Query("chunk_size", "1000"))
+
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is gone once the handler returns
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+
+		enc := json.NewEncoder(w)
+		summary := h.logService.IngestBatchChunked(ctx, batch.Entries, chunkSize, func(frame models.BatchProgress) {
+			enc.Encode(frame)
+			w.Flush()
+		})
+		enc.Encode(summary)
+		w.Flush()
+	})
+
+	return nil
+}
+
+// IngestAsync handles buffered batch ingestion
+// @Summary Ingest logs asynchronously
+// @Description Queues a batch of log entries for buffered ingestion; returns 429 when the buffer is saturated
+// @Tags logs
+// @Accept json
+// @Produce json
+// @Param logs body models.LogBatch true "Log Batch"
+// @Param X-Log-Env header string false "Environment for entries that omit it"
+// @Param X-Log-Source header string false "Source for entries that omit it"
+// @Param X-Strict-JSON header bool false "Reject unknown entry fields instead of ignoring them"
+// @Success 202 {object} map[string]int
+// @Failure 400 {object} response.Response
+// @Failure 429 {object} map[string]interface{}
+// @Router /logs/async [post]
+func (h *LogHandler) IngestAsync(c *fiber.Ctx) error {
+	var batch models.LogBatch
+	if err := parseIngestBody(c, &batch); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	var tenantID uuid.UUID
+	if tid := c.Locals("tenant_id"); tid != nil {
+		if t, ok := tid.(uuid.UUID); ok {
+			tenantID = t
+		}
+	}
+
+	queued := 0
+	for _, entry := range batch.Entries {
+		if entry.TenantID == uuid.Nil {
+			entry.TenantID = tenantID
+		}
+		applyEntryContext(c, &entry)
+		if err := h.logService.BufferLog(entry); err != nil {
+			if errors.Is(err, service.ErrBufferSaturated) {
+				c.Set("Retry-After", strconv.Itoa(int(service.FlushInterval.Seconds())))
+				return errorWithDetails(c, fiber.StatusTooManyRequests, "buffer_saturated",
+					"Ingestion buffer is saturated, retry later", fiber.Map{"queued": queued})
+			}
+			if errors.Is(err, service.ErrEntryTooOld) {
+				return errorWithDetails(c, fiber.StatusBadRequest, "entry_too_old", err.Error(), fiber.Map{"queued": queued})
+			}
+			if errors.Is(err, service.ErrEntryInFuture) {
+				return errorWithDetails(c, fiber.StatusBadRequest, "entry_in_future", err.Error(), fiber.Map{"queued": queued})
+			}
+			if errors.Is(err, service.ErrInvalidLevel) {
+				return errorWithDetails(c, fiber.StatusBadRequest, "invalid_level", err.Error(), fiber.Map{"queued": queued})
+			}
+			return response.InternalError(c, err.Error())
+		}
+		queued++
+	}
+
+	return accepted(c, fiber.Map{
+		"queued": queued,
 	})
 }
 
 // Query handles log search/filtering
 // @Summary Query logs
-// @Description Search and filter logs
+// @Description Search and filter logs. Filters must narrow by tenant, time range, service, trace or request ID unless X-Admin-Key is sent. Send Accept: text/csv for the same result as CSV rows, metadata keys expanded into columns
 // @Tags logs
 // @Accept json
-// @Produce json
+// @Produce json,text/csv
 // @Param filter body models.LogFilter true "Log Filter"
+// @Param approx query bool false "Sample the table and return an approximate result"
+// @Param flatten query bool false "Return entries as rows with metadata keys expanded into columns"
+// @Param collapse query bool false "Fold runs of consecutive entries with the same service, level and message into one with a repeat_count"
+// @Param X-Profile header bool false "Report cache, DB and serialization timings in X-Profile-* response headers"
 // @Success 200 {object} models.LogQueryResult
 // @Failure 400 {object} response.Response
 // @Router /logs/query [post]
@@ -102,6 +264,9 @@ func (h *LogHandler) Query(c *fiber.Ctx) error {
 	if err := c.BodyParser(&filter); err != nil {
 		return response.BadRequest(c, "invalid_request", err.Error())
 	}
+	if err := service.ValidateFilter(filter); err != nil {
+		return response.BadRequest(c, "invalid_filter", err.Error())
+	}
 
 	// Apply tenant from context
 	if tenantID := c.Locals("tenant_id"); tenantID != nil {
@@ -110,12 +275,22 @@ func (h *LogHandler) Query(c *fiber.Ctx) error {
 		}
 	}
 
-	result, err := h.logService.Query(c.Context(), filter)
+	if err := service.ValidateSelective(filter); err != nil && !isAdmin(c) {
+		return response.BadRequest(c, "unselective_query", err.Error())
+	}
+
+	query := h.logService.Query
+	if c.QueryBool("approx") {
+		query = h.logService.QueryApprox
+	}
+
+	ctx, profile := profileContext(c)
+	result, err := query(ctx, filter)
 	if err != nil {
 		return response.InternalError(c, err.Error())
 	}
 
-	return response.OK(c, result)
+	return writeProfiled(c, profile, func() error { return queryResult(c, result) })
 }
 
 // GetByID retrieves a single log entry
@@ -147,7 +322,7 @@ func (h *LogHandler) GetByID(c *fiber.Ctx) error {
 // @Tags logs
 // @Produce json
 // @Param trace_id path string true "Trace ID"
-// @Success 200 {array} models.LogEntry
+// @Success 200 {array} models.LogEntry "Always 200; found is false when nothing matched"
 // @Router /logs/trace/{trace_id} [get]
 func (h *LogHandler) GetByTrace(c *fiber.Ctx) error {
 	traceID := c.Params("trace_id")
@@ -160,29 +335,77 @@ func (h *LogHandler) GetByTrace(c *fiber.Ctx) error {
 		return response.InternalError(c, err.Error())
 	}
 
-	return response.OK(c, entries)
+	return entryCollection(c, entries)
+}
+
+// ExportTrace downloads every entry of a trace as a bundle
+// @Summary Export a trace bundle
+// @Description Downloads all of a trace's entries in timestamp order, for offline analysis or attaching to a ticket. The default format is a single JSON bundle; format=zip returns an archive with one JSON file per service.
+// @Tags logs
+// @Produce json
+// @Produce application/zip
+// @Param trace_id path string true "Trace ID"
+// @Param format query string false "json (default) or zip"
+// @Success 200 {object} models.TraceBundle
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /logs/trace/{trace_id}/export [get]
+func (h *LogHandler) ExportTrace(c *fiber.Ctx) error {
+	traceID := c.Params("trace_id")
+	if traceID == "" {
+		return response.BadRequest(c, "invalid_trace_id", "Trace ID is required")
+	}
+	format := c.Query("format", "json")
+	if format != "json" && format != "zip" {
+		return response.BadRequest(c, "invalid_format", "format must be json or zip")
+	}
+
+	var tenantID *uuid.UUID
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		tenantID = &tid
+	}
+
+	bundle, err := h.logService.ExportTrace(c.Context(), tenantID, traceID)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+	if bundle.EntryCount == 0 {
+		return response.NotFound(c, "No logs found for trace")
+	}
+
+	c.Attachment("trace-" + bundle.TraceID + "." + format)
+	if format == "zip" {
+		c.Set(fiber.HeaderContentType, "application/zip")
+		return service.WriteTraceZip(c.Response().BodyWriter(), bundle)
+	}
+	return c.JSON(bundle)
 }
 
 // GetByRequest retrieves logs by request ID
 // @Summary Get logs by request ID
-// @Description Retrieves all logs for a request
+// @Description Retrieves all logs for a request, or for several requests given as a comma-separated list
 // @Tags logs
 // @Produce json
-// @Param request_id path string true "Request ID"
-// @Success 200 {array} models.LogEntry
+// @Param request_id path string true "Request ID(s), comma-separated"
+// @Success 200 {array} models.LogEntry "Always 200; found is false when nothing matched"
 // @Router /logs/request/{request_id} [get]
 func (h *LogHandler) GetByRequest(c *fiber.Ctx) error {
-	requestID := c.Params("request_id")
-	if requestID == "" {
+	var requestIDs []string
+	for _, id := range strings.Split(c.Params("request_id"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			requestIDs = append(requestIDs, id)
+		}
+	}
+	if len(requestIDs) == 0 {
 		return response.BadRequest(c, "invalid_request_id", "Request ID is required")
 	}
 
-	entries, err := h.logService.GetByRequestID(c.Context(), requestID)
+	entries, err := h.logService.GetByRequestIDs(c.Context(), requestIDs)
 	if err != nil {
 		return response.InternalError(c, err.Error())
 	}
 
-	return response.OK(c, entries)
+	return entryCollection(c, entries)
 }
 
 // GetStats retrieves log statistics
@@ -232,17 +455,31 @@ func (h *LogHandler) GetStats(c *fiber.Ctx) error {
 // @Produce json
 // @Param filter body models.LogFilter true "Log Filter"
 // @Param interval query string false "Time interval (minute, hour, day)"
+// @Param group_by query string false "Set to service for per-service counts of each bucket"
 // @Success 200 {array} models.LogAggregation
+// @Failure 400 {object} response.Response
 // @Router /logs/aggregate [post]
 func (h *LogHandler) Aggregate(c *fiber.Ctx) error {
 	var filter models.LogFilter
 	if err := c.BodyParser(&filter); err != nil {
 		return response.BadRequest(c, "invalid_request", err.Error())
 	}
+	if err := service.ValidateFilter(filter); err != nil {
+		return response.BadRequest(c, "invalid_filter", err.Error())
+	}
 
 	interval := c.Query("interval", "hour")
 
-	aggregations, err := h.logService.Aggregate(c.Context(), filter, interval)
+	aggregate := h.logService.Aggregate
+	switch c.Query("group_by") {
+	case "":
+	case "service":
+		aggregate = h.logService.AggregateByService
+	default:
+		return response.BadRequest(c, "invalid_group_by", "group_by must be service")
+	}
+
+	aggregations, err := aggregate(c.Context(), filter, interval)
 	if err != nil {
 		return response.InternalError(c, err.Error())
 	}
@@ -250,6 +487,284 @@ func (h *LogHandler) Aggregate(c *fiber.Ctx) error {
 	return response.OK(c, aggregations)
 }
 
+// SearchWithAggregation returns matching entries and their aggregation
+// @Summary Search logs with aggregation
+// @Description Returns a page of matching logs, their total and time-bucketed counts for the same filter in one call
+// @Tags logs
+// @Accept json
+// @Produce json
+// @Param filter body models.LogFilter true "Log Filter"
+// @Param interval query string false "Time interval (minute, hour, day)"
+// @Success 200 {object} models.LogSearchWithAggregation
+// @Failure 400 {object} response.Response
+// @Router /logs/search-with-agg [post]
+func (h *LogHandler) SearchWithAggregation(c *fiber.Ctx) error {
+	var filter models.LogFilter
+	if err := c.BodyParser(&filter); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+	if err := service.ValidateFilter(filter); err != nil {
+		return response.BadRequest(c, "invalid_filter", err.Error())
+	}
+
+	// Apply tenant from context
+	if tenantID := c.Locals("tenant_id"); tenantID != nil {
+		if tid, ok := tenantID.(uuid.UUID); ok {
+			filter.TenantID = &tid
+		}
+	}
+
+	result, err := h.logService.SearchWithAggregation(c.Context(), filter, c.Query("interval", "hour"))
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, result)
+}
+
+// GetAffectedTraces counts distinct traces matching a filter
+// @Summary Count affected traces
+// @Description Counts distinct trace IDs among logs matching the filter, ignoring entries without a trace
+// @Tags logs
+// @Produce json
+// @Param service query string false "Filter by service"
+// @Param level query string false "Filter by log level"
+// @Param min_level query string false "Filter by minimum log level"
+// @Param max_level query string false "Filter by maximum log level"
+// @Param environment query string false "Filter by environment"
+// @Param search query string false "Search message text"
+// @Param host query string false "Filter by host glob, e.g. web-*"
+// @Param error_type query string false "Filter by error type"
+// @Param source query string false "Filter by source, e.g. stdout"
+// @Param sources query string false "Comma-separated sources to match any of"
+// @Param metadata_key query string false "Top-level metadata key to match, e.g. order_id"
+// @Param metadata_value query string false "Value metadata_key must hold, e.g. 12345"
+// @Param metadata_search query string false "Text to find in metadata keys or values, case-insensitive"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Param around query string false "Center of a proximity window (RFC3339)"
+// @Param window_mins query int false "Minutes on each side of around (default 5)"
+// @Param min_ingest_lag_seconds query int false "Only entries stored more than this many seconds after their timestamp"
+// @Success 200 {object} map[string]int64
+// @Router /logs/affected-traces [get]
+func (h *LogHandler) GetAffectedTraces(c *fiber.Ctx) error {
+	filter := parseQueryFilter(c)
+
+	count, err := h.logService.CountAffectedTraces(c.Context(), filter)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, fiber.Map{
+		"affected_traces": count,
+	})
+}
+
+// GetRequestDurations measures requests from their paired log entries
+// @Summary Request durations
+// @Description Groups matching logs by request_id and measures each request with at least two entries, such as a start/end pair, from its first to its last entry. Returns the duration distribution and the slowest requests.
+// @Tags logs
+// @Produce json
+// @Param min_duration_ms query number false "Only list requests taking at least this long"
+// @Param limit query int false "Maximum slow requests listed (default 20, max 1000)"
+// @Param service query string false "Filter by service"
+// @Param level query string false "Filter by log level"
+// @Param environment query string false "Filter by environment"
+// @Param search query string false "Search message text"
+// @Param host query string false "Filter by host glob, e.g. web-*"
+// @Param source query string false "Filter by source, e.g. stdout"
+// @Param metadata_key query string false "Top-level metadata key to match, e.g. order_id"
+// @Param metadata_value query string false "Value metadata_key must hold, e.g. 12345"
+// @Param metadata_search query string false "Text to find in metadata keys or values, case-insensitive"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Success 200 {object} models.RequestDurations
+// @Failure 400 {object} response.Response
+// @Router /logs/request-durations [get]
+func (h *LogHandler) GetRequestDurations(c *fiber.Ctx) error {
+	var minDurationMs float64
+	if s := c.Query("min_duration_ms"); s != "" {
+		var err error
+		if minDurationMs, err = strconv.ParseFloat(s, 64); err != nil {
+			return response.BadRequest(c, "invalid_request", "min_duration_ms must be a number")
+		}
+	}
+
+	durations, err := h.logService.RequestDurations(c.Context(), parseQueryFilter(c), minDurationMs, c.QueryInt("limit"))
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, durations)
+}
+
+// GetHistogram buckets a numeric metadata field
+// @Summary Histogram of a metadata field
+// @Description Counts matching logs per equal-width range of a numeric metadata field, e.g. duration_ms. Entries without the field or with a non-numeric value are counted as missing.
+// @Tags logs
+// @Produce json
+// @Param field query string true "Numeric metadata field"
+// @Param min query number true "Start of the first bucket"
+// @Param max query number true "End of the last bucket"
+// @Param buckets query int false "Number of buckets (default 10, max 200)"
+// @Param service query string false "Filter by service"
+// @Param level query string false "Filter by log level"
+// @Param min_level query string false "Filter by minimum log level"
+// @Param max_level query string false "Filter by maximum log level"
+// @Param environment query string false "Filter by environment"
+// @Param search query string false "Search message text"
+// @Param host query string false "Filter by host glob, e.g. web-*"
+// @Param error_type query string false "Filter by error type"
+// @Param source query string false "Filter by source, e.g. stdout"
+// @Param sources query string false "Comma-separated sources to match any of"
+// @Param metadata_key query string false "Top-level metadata key to match, e.g. order_id"
+// @Param metadata_value query string false "Value metadata_key must hold, e.g. 12345"
+// @Param metadata_search query string false "Text to find in metadata keys or values, case-insensitive"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Success 200 {object} models.Histogram
+// @Failure 400 {object} response.Response
+// @Router /logs/histogram [get]
+func (h *LogHandler) GetHistogram(c *fiber.Ctx) error {
+	min, err := strconv.ParseFloat(c.Query("min"), 64)
+	if err != nil {
+		return response.BadRequest(c, "invalid_histogram", "min must be a number")
+	}
+	max, err := strconv.ParseFloat(c.Query("max"), 64)
+	if err != nil {
+		return response.BadRequest(c, "invalid_histogram", "max must be a number")
+	}
+
+	histogram, err := h.logService.Histogram(c.Context(), parseQueryFilter(c), c.Query("field"), min, max, c.QueryInt("buckets"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidHistogram) {
+			return response.BadRequest(c, "invalid_histogram", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, histogram)
+}
+
+// GetErrorGroups groups error logs by error type
+// @Summary Group errors by type
+// @Description Summarizes logs carrying an error_type per type with counts, affected services, first/last occurrence and the latest entry, most frequent first
+// @Tags logs
+// @Produce json
+// @Param limit query int false "Maximum groups (default 100, max 1000)"
+// @Param error_type query string false "Filter by error type"
+// @Param source query string false "Filter by source, e.g. stdout"
+// @Param sources query string false "Comma-separated sources to match any of"
+// @Param metadata_key query string false "Top-level metadata key to match, e.g. order_id"
+// @Param metadata_value query string false "Value metadata_key must hold, e.g. 12345"
+// @Param metadata_search query string false "Text to find in metadata keys or values, case-insensitive"
+// @Param service query string false "Filter by service"
+// @Param level query string false "Filter by log level"
+// @Param min_level query string false "Filter by minimum log level"
+// @Param environment query string false "Filter by environment"
+// @Param search query string false "Search message text"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Success 200 {array} models.ErrorGroup
+// @Router /logs/errors [get]
+func (h *LogHandler) GetErrorGroups(c *fiber.Ctx) error {
+	groups, err := h.logService.GroupErrors(c.Context(), parseQueryFilter(c), c.QueryInt("limit"))
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, groups)
+}
+
+// Export streams every log matching the filter
+// @Summary Export logs
+// @Description Streams all matching logs as NDJSON in timestamp order, reading a page at a time so large exports are never held in memory. The stream is gzip-compressed when the client accepts it.
+// @Tags logs
+// @Produce application/x-ndjson
+// @Param service query string false "Filter by service"
+// @Param level query string false "Filter by log level"
+// @Param min_level query string false "Filter by minimum log level"
+// @Param environment query string false "Filter by environment"
+// @Param search query string false "Search message text"
+// @Param error_type query string false "Filter by error type"
+// @Param source query string false "Filter by source, e.g. stdout"
+// @Param sources query string false "Comma-separated sources to match any of"
+// @Param metadata_key query string false "Top-level metadata key to match, e.g. order_id"
+// @Param metadata_value query string false "Value metadata_key must hold, e.g. 12345"
+// @Param metadata_search query string false "Text to find in metadata keys or values, case-insensitive"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Success 200 {string} string "NDJSON stream"
+// @Failure 400 {object} response.Response
+// @Router /logs/export [get]
+func (h *LogHandler) Export(c *fiber.Ctx) error {
+	filter := parseQueryFilter(c)
+	if err := service.ValidateSelective(filter); err != nil && !isAdmin(c) {
+		return response.BadRequest(c, "unselective_query", err.Error())
+	}
+
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Vary("Accept-Encoding")
+	// With Content-Encoding set the global compressor leaves the body alone
+	compress := acceptsGzip(c)
+	if compress {
+		c.Set("Content-Encoding", "gzip")
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is gone once the handler returns
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
+		var out io.Writer = w
+		if compress {
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+
+		// Headers are sent by now, so a failed page or a write to a client
+		// that went away can only end the stream early
+		enc := json.NewEncoder(out)
+		h.logService.Export(ctx, filter, func(entry models.LogEntry) error {
+			return enc.Encode(entry)
+		})
+	})
+
+	return nil
+}
+
+// GetTimeline retrieves a cross-service timeline for a request or trace
+// @Summary Get a request timeline
+// @Description Returns entries of a request or trace across all services, ordered by timestamp with offsets from the first entry and grouped into per-service lanes
+// @Tags logs
+// @Produce json
+// @Param request_id query string false "Request ID"
+// @Param trace_id query string false "Trace ID"
+// @Success 200 {object} models.Timeline
+// @Failure 400 {object} response.Response
+// @Router /logs/timeline [get]
+func (h *LogHandler) GetTimeline(c *fiber.Ctx) error {
+	filter := models.LogFilter{
+		RequestID: c.Query("request_id"),
+		TraceID:   c.Query("trace_id"),
+	}
+	if filter.RequestID == "" && filter.TraceID == "" {
+		return response.BadRequest(c, "invalid_request", "request_id or trace_id is required")
+	}
+
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		filter.TenantID = &tid
+	}
+
+	timeline, err := h.logService.GetTimeline(c.Context(), filter)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, timeline)
+}
+
 // GetServices retrieves available service names
 // @Summary Get service names
 // @Description Retrieves list of services that have logged entries
@@ -273,6 +788,105 @@ func (h *LogHandler) GetServices(c *fiber.Ctx) error {
 	return response.OK(c, services)
 }
 
+// GetSources retrieves available entry sources
+// @Summary Get sources
+// @Description Retrieves the distinct origins logged entries came from, such as stdout, stderr or a file path
+// @Tags logs
+// @Produce json
+// @Success 200 {array} string
+// @Router /logs/sources [get]
+func (h *LogHandler) GetSources(c *fiber.Ctx) error {
+	var tenantID *uuid.UUID
+	if tid := c.Locals("tenant_id"); tid != nil {
+		if t, ok := tid.(uuid.UUID); ok {
+			tenantID = &t
+		}
+	}
+
+	sources, err := h.logService.GetSources(c.Context(), tenantID)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, sources)
+}
+
+// GetSuggestions suggests field values for typeahead
+// @Summary Suggest field values
+// @Description Returns distinct values of a field starting with a prefix, in order, for search box typeahead. Fields: service_name, environment, host, source, error_type, level.
+// @Tags logs
+// @Produce json
+// @Param field query string true "Field to suggest values of"
+// @Param prefix query string false "Case-sensitive value prefix"
+// @Param limit query int false "Maximum suggestions (default 10, max 100)"
+// @Success 200 {array} string
+// @Failure 400 {object} response.Response
+// @Router /logs/suggest [get]
+func (h *LogHandler) GetSuggestions(c *fiber.Ctx) error {
+	var tenantID *uuid.UUID
+	if tid := c.Locals("tenant_id"); tid != nil {
+		if t, ok := tid.(uuid.UUID); ok {
+			tenantID = &t
+		}
+	}
+
+	suggestions, err := h.logService.Suggest(c.Context(), tenantID, c.Query("field"), c.Query("prefix"), c.QueryInt("limit"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSuggestField) {
+			return response.BadRequest(c, "invalid_field", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, suggestions)
+}
+
+// GetFirst retrieves the earliest log entry per service
+// @Summary Get first log per service
+// @Description Retrieves the earliest log timestamp and entry for each service
+// @Tags logs
+// @Produce json
+// @Success 200 {array} models.ServiceLogEdge
+// @Router /logs/first [get]
+func (h *LogHandler) GetFirst(c *fiber.Ctx) error {
+	var tenantID *uuid.UUID
+	if tid := c.Locals("tenant_id"); tid != nil {
+		if t, ok := tid.(uuid.UUID); ok {
+			tenantID = &t
+		}
+	}
+
+	edges, err := h.logService.GetFirstPerService(c.Context(), tenantID)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, edges)
+}
+
+// GetLast retrieves the latest log entry per service
+// @Summary Get last log per service
+// @Description Retrieves the latest log timestamp and entry for each service
+// @Tags logs
+// @Produce json
+// @Success 200 {array} models.ServiceLogEdge
+// @Router /logs/last [get]
+func (h *LogHandler) GetLast(c *fiber.Ctx) error {
+	var tenantID *uuid.UUID
+	if tid := c.Locals("tenant_id"); tid != nil {
+		if t, ok := tid.(uuid.UUID); ok {
+			tenantID = &t
+		}
+	}
+
+	edges, err := h.logService.GetLastPerService(c.Context(), tenantID)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, edges)
+}
+
 // GetStorage retrieves storage usage
 // @Summary Get storage usage
 // @Description Retrieves storage usage statistics
@@ -300,23 +914,68 @@ func (h *LogHandler) GetStorage(c *fiber.Ctx) error {
 	})
 }
 
+// GetVolumeForecast projects log volume and storage growth
+// @Summary Forecast log volume
+// @Description Projects daily log counts and storage for the coming days from a linear trend over recent daily counts
+// @Tags logs
+// @Produce json
+// @Param days query int false "Days to forecast (default 7, max 365)"
+// @Param history query int false "Complete days of history to fit (default 30, max 365)"
+// @Success 200 {object} models.VolumeForecast
+// @Router /logs/volume-forecast [get]
+func (h *LogHandler) GetVolumeForecast(c *fiber.Ctx) error {
+	var tenantID *uuid.UUID
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		tenantID = &tid
+	}
+
+	forecast, err := h.logService.ForecastVolume(c.Context(), tenantID, c.QueryInt("history"), c.QueryInt("days"), time.Now())
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, forecast)
+}
+
+// streamKeepAlive is how often an idle stream sends a keep-alive comment
+const streamKeepAlive = 15 * time.Second
+
 // Stream handles real-time log streaming via SSE
 // @Summary Stream logs
-// @Description Stream logs in real-time using Server-Sent Events
+// @Description Stream logs in real-time using Server-Sent Events. Entries are pushed as they are ingested, each as JSON in an event named after its level. Frames are gzip-compressed when the client accepts it.
 // @Tags logs
 // @Produce text/event-stream
 // @Param service query string false "Filter by service"
 // @Param level query string false "Filter by log level"
+// @Param format query string false "json (default) for whole entries with the level as the event type, or text for messages only"
 // @Success 200 {string} string "SSE stream"
+// @Failure 400 {object} response.Response
+// @Failure 503 {object} map[string]interface{}
 // @Router /logs/stream [get]
 func (h *LogHandler) Stream(c *fiber.Ctx) error {
+	if format := c.Query("format", "json"); format != "json" && format != "text" {
+		return response.BadRequest(c, "invalid_format", "format must be json or text")
+	}
+	if !h.streams.Acquire() {
+		c.Set("Retry-After", "5")
+		return errorWithDetails(c, fiber.StatusServiceUnavailable, "too_many_streams",
+			"Maximum number of concurrent streams reached", nil)
+	}
+
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
 	c.Set("Transfer-Encoding", "chunked")
 
+	compress := acceptsGzip(c)
+	if compress {
+		c.Set("Content-Encoding", "gzip")
+		c.Vary("Accept-Encoding")
+	}
+
 	service := c.Query("service")
 	level := models.LogLevel(c.Query("level"))
+	text := c.Query("format") == "text"
 
 	// Create filter
 	filter := models.LogFilter{
@@ -331,49 +990,90 @@ func (h *LogHandler) Stream(c *fiber.Ctx) error {
 		}
 	}
 
-	// Start streaming
-	ctx := c.Context()
-	lastCheck := time.Now()
+	// Shutdown closes done; a failed write means the client went away
+	done := c.Context().Done()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.streams.Release()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			// Check for new logs since last check
-			filter.StartTime = &lastCheck
-			result, err := h.logService.Query(c.Context(), filter)
-			if err == nil && len(result.Entries) > 0 {
-				for _, entry := range result.Entries {
-					c.Writef("data: %s\n\n", entry.Message)
+		sub := h.logService.Subscribe(ctx, filter)
+		defer sub.Close()
+
+		sse := NewSSEWriter(w, compress)
+		defer sse.Close()
+
+		// Flush headers once subscribed so the client knows it is listening
+		if err := sse.WriteComment("keep-alive"); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case entry := <-sub.C:
+				if err := writeStreamEntry(sse, entry, text); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if err := sse.WriteComment("keep-alive"); err != nil {
+					return
 				}
+			case <-done:
+				return
 			}
-			lastCheck = time.Now()
-			time.Sleep(1 * time.Second)
 		}
+	})
+
+	return nil
+}
+
+// writeStreamEntry writes an entry as JSON in an event named after its
+// level, or as its bare message for text streams
+func writeStreamEntry(sse *SSEWriter, entry models.LogEntry, text bool) error {
+	if text {
+		return sse.WriteEvent(entry.Message)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		// Skip an entry that cannot be encoded rather than end the stream
+		return nil
 	}
+	return sse.WriteNamedEvent(string(entry.Level), string(data))
 }
 
 // List handles simple log listing
 // @Summary List logs
-// @Description List logs with optional filters
+// @Description List logs with optional filters. Send Accept: text/csv for CSV rows
 // @Tags logs
-// @Produce json
+// @Produce json,text/csv
 // @Param page query int false "Page number"
-// @Param page_size query int false "Page size"
+// @Param page_size query int false "Page size (default: the tenant's default, else 100)"
+// @Param cursor query string false "next_cursor of the previous page, to page by keyset instead of page number"
 // @Param service query string false "Filter by service"
 // @Param level query string false "Filter by log level"
+// @Param flatten query bool false "Return entries as rows with metadata keys expanded into columns"
+// @Param collapse query bool false "Fold runs of consecutive entries with the same service, level and message into one with a repeat_count"
+// @Param X-Profile header bool false "Report cache, DB and serialization timings in X-Profile-* response headers"
 // @Success 200 {object} models.LogQueryResult
+// @Failure 400 {object} response.Response
 // @Router /logs [get]
 func (h *LogHandler) List(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
-	pageSize, _ := strconv.Atoi(c.Query("page_size", "100"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
 
 	filter := models.LogFilter{
 		ServiceName: c.Query("service"),
 		Level:       models.LogLevel(c.Query("level")),
 		Page:        page,
 		PageSize:    pageSize,
+		Cursor:      c.Query("cursor"),
+	}
+	if err := service.ValidateFilter(filter); err != nil {
+		return response.BadRequest(c, "invalid_filter", err.Error())
 	}
 
 	// Apply tenant from context
@@ -383,10 +1083,155 @@ func (h *LogHandler) List(c *fiber.Ctx) error {
 		}
 	}
 
-	result, err := h.logService.Query(c.Context(), filter)
+	if err := service.ValidateSelective(filter); err != nil && !isAdmin(c) {
+		return response.BadRequest(c, "unselective_query", err.Error())
+	}
+
+	ctx, profile := profileContext(c)
+	result, err := h.logService.Query(ctx, filter)
 	if err != nil {
 		return response.InternalError(c, err.Error())
 	}
 
-	return response.OK(c, result)
+	return writeProfiled(c, profile, func() error { return queryResult(c, result) })
+}
+
+// Count reports the number of matching logs without a body
+// @Summary Count logs
+// @Description Returns the number of logs matching the filter in the X-Total-Count header, without fetching them
+// @Tags logs
+// @Param service query string false "Filter by service"
+// @Param level query string false "Filter by log level"
+// @Param min_level query string false "Filter by minimum log level"
+// @Param max_level query string false "Filter by maximum log level"
+// @Param environment query string false "Filter by environment"
+// @Param search query string false "Search message text"
+// @Param host query string false "Filter by host glob, e.g. web-*"
+// @Param error_type query string false "Filter by error type"
+// @Param source query string false "Filter by source, e.g. stdout"
+// @Param sources query string false "Comma-separated sources to match any of"
+// @Param metadata_key query string false "Top-level metadata key to match, e.g. order_id"
+// @Param metadata_value query string false "Value metadata_key must hold, e.g. 12345"
+// @Param metadata_search query string false "Text to find in metadata keys or values, case-insensitive"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Success 200 {string} string "X-Total-Count header"
+// @Failure 400 {object} response.Response
+// @Router /logs [head]
+func (h *LogHandler) Count(c *fiber.Ctx) error {
+	filter := parseQueryFilter(c)
+	if err := service.ValidateSelective(filter); err != nil && !isAdmin(c) {
+		return response.BadRequest(c, "unselective_query", err.Error())
+	}
+
+	count, err := h.logService.Count(c.Context(), filter)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	c.Set("X-Total-Count", strconv.FormatInt(count, 10))
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// GetPreset runs a built-in named query
+// @Summary Query a filter preset
+// @Description Expands a named preset (errors-last-hour, slow-requests, recent-by-service) into a filter scoped to the caller's tenant and time-bounded relative to now, then runs it
+// @Tags logs
+// @Produce json
+// @Param name path string true "Preset name"
+// @Param service query string false "Service (required by recent-by-service)"
+// @Param min_latency_ms query int false "slow-requests threshold (default 1000)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (default: the tenant's default, else 100)"
+// @Param X-Profile header bool false "Report cache, DB and serialization timings in X-Profile-* response headers"
+// @Success 200 {object} models.LogQueryResult
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /logs/preset/{name} [get]
+func (h *LogHandler) GetPreset(c *fiber.Ctx) error {
+	var tenantID *uuid.UUID
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		tenantID = &tid
+	}
+
+	filter, err := service.PresetFilter(c.Params("name"), tenantID, time.Now(), service.PresetOptions{
+		ServiceName:  c.Query("service"),
+		MinLatencyMs: c.QueryInt("min_latency_ms"),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownPreset) {
+			return errorWithDetails(c, fiber.StatusNotFound, "preset_not_found", err.Error(),
+				fiber.Map{"presets": service.PresetNames()})
+		}
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+	filter.Page = c.QueryInt("page", 1)
+	filter.PageSize = c.QueryInt("page_size")
+
+	ctx, profile := profileContext(c)
+	result, err := h.logService.Query(ctx, filter)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return writeProfiled(c, profile, func() error { return response.OK(c, result) })
+}
+
+// parseQueryFilter builds a filter from common query string parameters
+func parseQueryFilter(c *fiber.Ctx) models.LogFilter {
+	filter := models.LogFilter{
+		ServiceName: c.Query("service"),
+		Level:       models.LogLevel(c.Query("level")),
+		MinLevel:    models.LogLevel(c.Query("min_level")),
+		MaxLevel:    models.LogLevel(c.Query("max_level")),
+		Environment: c.Query("environment"),
+		Search:      c.Query("search"),
+		HostPattern: c.Query("host"),
+		ErrorType:   c.Query("error_type"),
+		Source:      c.Query("source"),
+		MetadataKey: c.Query("metadata_key"),
+	}
+	filter.MetadataValue = c.Query("metadata_value")
+	filter.MetadataSearch = c.Query("metadata_search")
+	if sources := c.Query("sources"); sources != "" {
+		filter.Sources = strings.Split(sources, ",")
+	}
+	filter.MinLatencyMs = c.QueryInt("min_latency_ms")
+	filter.MinIngestLagSeconds = c.QueryInt("min_ingest_lag_seconds")
+
+	// meta.<key>=v1,v2 matches any of the values
+	c.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		if key, ok := strings.CutPrefix(string(k), "meta."); ok && key != "" {
+			if filter.MetadataIn == nil {
+				filter.MetadataIn = make(map[string][]string)
+			}
+			filter.MetadataIn[key] = append(filter.MetadataIn[key], strings.Split(string(v), ",")...)
+		}
+	})
+
+	if s := c.Query("start"); s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			filter.StartTime = &t
+		}
+	}
+	if e := c.Query("end"); e != "" {
+		if t, err := time.Parse(time.RFC3339, e); err == nil {
+			filter.EndTime = &t
+		}
+	}
+	if a := c.Query("around"); a != "" {
+		if t, err := time.Parse(time.RFC3339, a); err == nil {
+			filter.AroundTime = &t
+			filter.WindowMins = c.QueryInt("window_mins")
+		}
+	}
+
+	// Apply tenant from context
+	if tenantID := c.Locals("tenant_id"); tenantID != nil {
+		if tid, ok := tenantID.(uuid.UUID); ok {
+			filter.TenantID = &tid
+		}
+	}
+
+	return filter
 }
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+)
+
+// MetricHandler handles metric rule HTTP requests
+type MetricHandler struct {
+	service *service.MetricService
+}
+
+// NewMetricHandler creates a new metric handler
+func NewMetricHandler(service *service.MetricService) *MetricHandler {
+	return &MetricHandler{service: service}
+}
+
+// CreateRule creates a new metric rule
+// @Summary Create metric rule
+// @Description Creates a rule deriving a time series from matching logs
+// @Tags metrics
+// @Accept json
+// @Produce json
+// @Param rule body models.MetricRule true "Metric Rule"
+// @Success 201 {object} models.MetricRule
+// @Failure 400 {object} response.Response
+// @Router /metrics/rules [post]
+func (h *MetricHandler) CreateRule(c *fiber.Ctx) error {
+	var rule models.MetricRule
+	if err := c.BodyParser(&rule); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	// Set tenant from context
+	if tenantID := c.Locals("tenant_id"); tenantID != nil {
+		if tid, ok := tenantID.(uuid.UUID); ok {
+			rule.TenantID = tid
+		}
+	}
+
+	if err := h.service.CreateRule(c.Context(), &rule); err != nil {
+		if errors.Is(err, service.ErrInvalidMetricRule) {
+			return response.BadRequest(c, "invalid_rule", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.Created(c, rule)
+}
+
+// UpdateRule updates a metric rule
+// @Summary Update metric rule
+// @Description Updates the editable fields of one of the tenant's metric rules: name, description, enabled, filter, aggregation, field and interval_mins. The tenant, creation time and evaluation progress are kept.
+// @Tags metrics
+// @Accept json
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Param rule body models.MetricRule true "Metric Rule"
+// @Success 200 {object} models.MetricRule
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /metrics/rules/{id} [put]
+func (h *MetricHandler) UpdateRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid rule ID format")
+	}
+
+	var changes models.MetricRule
+	if err := c.BodyParser(&changes); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	tenantID, _ := c.Locals("tenant_id").(uuid.UUID)
+	rule, err := h.service.UpdateRule(c.Context(), tenantID, id, changes)
+	if err != nil {
+		if errors.Is(err, service.ErrMetricRuleNotFound) {
+			return response.NotFound(c, "Metric rule not found")
+		}
+		if errors.Is(err, service.ErrInvalidMetricRule) {
+			return response.BadRequest(c, "invalid_rule", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, rule)
+}
+
+// GetRule retrieves a metric rule
+// @Summary Get metric rule
+// @Description Retrieves one of the tenant's metric rules by ID
+// @Tags metrics
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} models.MetricRule
+// @Failure 404 {object} response.Response
+// @Router /metrics/rules/{id} [get]
+func (h *MetricHandler) GetRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid rule ID format")
+	}
+
+	tenantID, _ := c.Locals("tenant_id").(uuid.UUID)
+	rule, err := h.service.GetRule(c.Context(), tenantID, id)
+	if err != nil {
+		if errors.Is(err, service.ErrMetricRuleNotFound) {
+			return response.NotFound(c, "Metric rule not found")
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, rule)
+}
+
+// ListRules lists metric rules for a tenant
+// @Summary List metric rules
+// @Description Lists all metric rules for the current tenant
+// @Tags metrics
+// @Produce json
+// @Success 200 {array} models.MetricRule
+// @Router /metrics/rules [get]
+func (h *MetricHandler) ListRules(c *fiber.Ctx) error {
+	var tenantID uuid.UUID
+	if tid := c.Locals("tenant_id"); tid != nil {
+		if t, ok := tid.(uuid.UUID); ok {
+			tenantID = t
+		}
+	}
+
+	rules, err := h.service.GetRulesByTenant(c.Context(), tenantID)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, rules)
+}
+
+// DeleteRule deletes a metric rule
+// @Summary Delete metric rule
+// @Description Deletes one of the tenant's metric rules and its series
+// @Tags metrics
+// @Param id path string true "Rule ID"
+// @Success 204
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /metrics/rules/{id} [delete]
+func (h *MetricHandler) DeleteRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid rule ID format")
+	}
+
+	tenantID, _ := c.Locals("tenant_id").(uuid.UUID)
+	if err := h.service.DeleteRule(c.Context(), tenantID, id); err != nil {
+		if errors.Is(err, service.ErrMetricRuleNotFound) {
+			return response.NotFound(c, "Metric rule not found")
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.NoContent(c)
+}
+
+// GetSeries retrieves the time series produced by a rule
+// @Summary Get metric series
+// @Description Retrieves evaluated values for one of the tenant's metric rules
+// @Tags metrics
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Success 200 {array} models.MetricPoint
+// @Failure 404 {object} response.Response
+// @Router /metrics/rules/{id}/series [get]
+func (h *MetricHandler) GetSeries(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid rule ID format")
+	}
+
+	startTime := time.Now().Add(-24 * time.Hour)
+	endTime := time.Now()
+
+	if s := c.Query("start"); s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			startTime = t
+		}
+	}
+	if e := c.Query("end"); e != "" {
+		if t, err := time.Parse(time.RFC3339, e); err == nil {
+			endTime = t
+		}
+	}
+
+	tenantID, _ := c.Locals("tenant_id").(uuid.UUID)
+	points, err := h.service.GetSeries(c.Context(), tenantID, id, startTime, endTime)
+	if err != nil {
+		if errors.Is(err, service.ErrMetricRuleNotFound) {
+			return response.NotFound(c, "Metric rule not found")
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, points)
+}
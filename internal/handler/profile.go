@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/minisource/log/internal/service"
+)
+
+// profileContext returns a context that records query timings when the
+// request opts in with "X-Profile: true"
+func profileContext(c *fiber.Ctx) (context.Context, *service.QueryProfile) {
+	if !strings.EqualFold(c.Get("X-Profile"), "true") {
+		return c.Context(), nil
+	}
+	return service.WithQueryProfile(c.Context())
+}
+
+// writeProfiled runs write, timing it as serialization, and reports the
+// profile in X-Profile-* response headers when profiling is enabled
+func writeProfiled(c *fiber.Ctx, profile *service.QueryProfile, write func() error) error {
+	if profile == nil {
+		return write()
+	}
+
+	start := time.Now()
+	err := write()
+	profile.SerializeMs = service.ElapsedMs(start)
+
+	c.Set("X-Profile-Cache-Ms", formatMs(profile.CacheMs))
+	c.Set("X-Profile-Cache-Hit", strconv.FormatBool(profile.CacheHit))
+	c.Set("X-Profile-DB-Ms", formatMs(profile.DBMs))
+	c.Set("X-Profile-Serialize-Ms", formatMs(profile.SerializeMs))
+	return err
+}
+
+func formatMs(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', 3, 64)
+}
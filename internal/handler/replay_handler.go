@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+)
+
+// ReplayHandler handles log replay HTTP requests
+type ReplayHandler struct {
+	service *service.ReplayService
+}
+
+// NewReplayHandler creates a new replay handler
+func NewReplayHandler(service *service.ReplayService) *ReplayHandler {
+	return &ReplayHandler{service: service}
+}
+
+// StartReplay starts replaying historical logs to a webhook
+// @Summary Replay logs to a webhook
+// @Description Streams logs matching a filter to an allowlisted webhook in timestamp order. The filter is limited to the X-Tenant-ID tenant; only X-Admin-Key callers may replay without one, using the filter's tenant_id.
+// @Tags replay
+// @Accept json
+// @Produce json
+// @Param request body models.ReplayRequest true "Replay Request"
+// @Success 202 {object} models.ReplayJob
+// @Failure 400 {object} response.Response
+// @Router /logs/replay [post]
+func (h *ReplayHandler) StartReplay(c *fiber.Ctx) error {
+	var req models.ReplayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	tenantID, ok := replayTenant(c)
+	if !ok {
+		return response.BadRequest(c, "tenant_required", "Replay requires a tenant")
+	}
+	if tenantID != nil {
+		req.Filter.TenantID = tenantID
+	}
+
+	job, err := h.service.StartReplay(req)
+	if err != nil {
+		if errors.Is(err, service.ErrWebhookNotAllowed) {
+			return response.BadRequest(c, "webhook_not_allowed", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return accepted(c, job)
+}
+
+// GetReplay retrieves replay job progress
+// @Summary Get replay job
+// @Description Retrieves the status and progress of a replay job
+// @Tags replay
+// @Produce json
+// @Param job_id path string true "Replay Job ID"
+// @Success 200 {object} models.ReplayJob
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /logs/replay/{job_id} [get]
+func (h *ReplayHandler) GetReplay(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("job_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid replay job ID format")
+	}
+
+	tenantID, ok := replayTenant(c)
+	if !ok {
+		return response.BadRequest(c, "tenant_required", "Replay requires a tenant")
+	}
+
+	job, err := h.service.GetJob(id, tenantID)
+	if err != nil {
+		return response.NotFound(c, "Replay job not found")
+	}
+
+	return response.OK(c, job)
+}
+
+// PauseReplay pauses a replay job
+// @Summary Pause replay job
+// @Description Pauses a running replay job
+// @Tags replay
+// @Produce json
+// @Param job_id path string true "Replay Job ID"
+// @Success 200 {object} models.ReplayJob
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /logs/replay/{job_id}/pause [post]
+func (h *ReplayHandler) PauseReplay(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("job_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid replay job ID format")
+	}
+
+	tenantID, ok := replayTenant(c)
+	if !ok {
+		return response.BadRequest(c, "tenant_required", "Replay requires a tenant")
+	}
+
+	job, err := h.service.PauseJob(id, tenantID)
+	if err != nil {
+		return response.NotFound(c, "Replay job not found")
+	}
+
+	return response.OK(c, job)
+}
+
+// ResumeReplay resumes a paused replay job
+// @Summary Resume replay job
+// @Description Resumes a paused replay job
+// @Tags replay
+// @Produce json
+// @Param job_id path string true "Replay Job ID"
+// @Success 200 {object} models.ReplayJob
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /logs/replay/{job_id}/resume [post]
+func (h *ReplayHandler) ResumeReplay(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("job_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_id", "Invalid replay job ID format")
+	}
+
+	tenantID, ok := replayTenant(c)
+	if !ok {
+		return response.BadRequest(c, "tenant_required", "Replay requires a tenant")
+	}
+
+	job, err := h.service.ResumeJob(id, tenantID)
+	if err != nil {
+		return response.NotFound(c, "Replay job not found")
+	}
+
+	return response.OK(c, job)
+}
+
+// replayTenant returns the tenant that scopes a replay request: the caller's
+// tenant, or nil for an operator without one. It reports false for other
+// tenantless requests.
+func replayTenant(c *fiber.Ctx) (*uuid.UUID, bool) {
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		return &tid, true
+	}
+	return nil, isAdmin(c)
+}
@@ -110,6 +110,32 @@ func (h *RetentionHandler) ListPolicies(c *fiber.Ctx) error {
 	return response.OK(c, policies)
 }
 
+// Preview reports the age distribution of stored logs
+// @Summary Preview log ages
+// @Description Returns, per tenant, the oldest log timestamp, the log count and counts by age bucket (0-1d, 1-7d, 7-30d, 30-90d, 90-365d, 365d+), to inform retention decisions. The preview covers the caller's tenant; an X-Admin-Key caller without a tenant ID header previews every tenant.
+// @Tags retention
+// @Produce json
+// @Success 200 {array} models.RetentionPreview
+// @Failure 400 {object} response.Response
+// @Router /logs/retention/preview [get]
+// @Router /admin/retention/preview [get]
+func (h *RetentionHandler) Preview(c *fiber.Ctx) error {
+	// Only an operator may preview every tenant at once
+	var tenantID *uuid.UUID
+	if tid, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		tenantID = &tid
+	} else if !isAdmin(c) {
+		return response.BadRequest(c, "tenant_required", "Retention preview requires a tenant")
+	}
+
+	previews, err := h.service.Preview(c.Context(), tenantID)
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, previews)
+}
+
 // DeletePolicy deletes a retention policy
 // @Summary Delete retention policy
 // @Description Deletes a retention policy
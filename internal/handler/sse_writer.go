@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SSEWriter writes Server-Sent Events frames, optionally gzip-compressed.
+// Every frame is flushed through the compressor so clients can decode events
+// as they arrive instead of waiting for the stream to end.
+type SSEWriter struct {
+	w  *bufio.Writer
+	gz *gzip.Writer
+}
+
+// NewSSEWriter wraps a stream writer, compressing frames when compress is set
+func NewSSEWriter(w *bufio.Writer, compress bool) *SSEWriter {
+	sw := &SSEWriter{w: w}
+	if compress {
+		sw.gz = gzip.NewWriter(w)
+	}
+	return sw
+}
+
+// WriteEvent writes a single data frame and flushes it to the client
+func (s *SSEWriter) WriteEvent(data string) error {
+	return s.WriteNamedEvent("", data)
+}
+
+// WriteNamedEvent writes a data frame with an event type, which EventSource
+// clients can listen for by name; an empty event writes an unnamed frame
+func (s *SSEWriter) WriteNamedEvent(event, data string) error {
+	var frame strings.Builder
+	if event != "" {
+		fmt.Fprintf(&frame, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&frame, "data: %s\n", line)
+	}
+	frame.WriteString("\n")
+
+	return s.write(frame.String())
+}
+
+// WriteComment writes a comment frame, used as a keep-alive
+func (s *SSEWriter) WriteComment(text string) error {
+	return s.write(": " + text + "\n\n")
+}
+
+// Close terminates the gzip stream, if any, and flushes remaining bytes
+func (s *SSEWriter) Close() error {
+	if s.gz != nil {
+		if err := s.gz.Close(); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+func (s *SSEWriter) write(frame string) error {
+	if s.gz != nil {
+		if _, err := s.gz.Write([]byte(frame)); err != nil {
+			return err
+		}
+		// Sync flush emits a complete deflate block for this frame
+		if err := s.gz.Flush(); err != nil {
+			return err
+		}
+	} else if _, err := s.w.WriteString(frame); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// acceptsGzip reports whether the client advertised gzip support
+func acceptsGzip(c *fiber.Ctx) bool {
+	return c.AcceptsEncodings("gzip") == "gzip"
+}
+
+// IsStreamPath reports whether a path serves a long-lived stream, which the
+// global compression middleware must skip in favour of per-frame compression
+func IsStreamPath(path string) bool {
+	return strings.HasSuffix(path, "/stream")
+}
@@ -0,0 +1,44 @@
+package handler
+
+import "sync/atomic"
+
+// StreamLimiter caps the number of concurrently open streaming connections.
+// Each stream holds a pub/sub subscription and an open connection for its
+// lifetime, so unbounded streams exhaust both.
+type StreamLimiter struct {
+	max    int64
+	active int64
+}
+
+// NewStreamLimiter creates a limiter allowing max concurrent streams; 0 disables the cap
+func NewStreamLimiter(max int) *StreamLimiter {
+	return &StreamLimiter{max: int64(max)}
+}
+
+// Acquire reserves a stream slot, reporting false when the cap is reached
+func (l *StreamLimiter) Acquire() bool {
+	if l == nil {
+		return true
+	}
+	if atomic.AddInt64(&l.active, 1) > l.max && l.max > 0 {
+		atomic.AddInt64(&l.active, -1)
+		return false
+	}
+	return true
+}
+
+// Release frees a slot taken by Acquire
+func (l *StreamLimiter) Release() {
+	if l == nil {
+		return
+	}
+	atomic.AddInt64(&l.active, -1)
+}
+
+// Active returns the number of open streams
+func (l *StreamLimiter) Active() int {
+	if l == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&l.active))
+}
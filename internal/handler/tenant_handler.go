@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/go-common/response"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+)
+
+// TenantHandler handles per-tenant settings HTTP requests
+type TenantHandler struct {
+	service *service.TenantService
+}
+
+// NewTenantHandler creates a new tenant handler
+func NewTenantHandler(service *service.TenantService) *TenantHandler {
+	return &TenantHandler{service: service}
+}
+
+// ListSettings lists settings for all tenants
+// @Summary List tenant settings
+// @Description Lists ingestion settings for all tenants. Requires X-Admin-Key.
+// @Tags tenants
+// @Produce json
+// @Success 200 {array} models.TenantSettings
+// @Failure 403 {object} map[string]interface{}
+// @Router /tenants/settings [get]
+func (h *TenantHandler) ListSettings(c *fiber.Ctx) error {
+	if !isAdmin(c) {
+		return errorWithDetails(c, fiber.StatusForbidden, "admin_required",
+			"Listing every tenant's settings requires X-Admin-Key", nil)
+	}
+
+	settings, err := h.service.ListSettings(c.Context())
+	if err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, settings)
+}
+
+// GetSettings retrieves settings for a tenant
+// @Summary Get tenant settings
+// @Description Retrieves ingestion settings for the caller's tenant, or any tenant with X-Admin-Key
+// @Tags tenants
+// @Produce json
+// @Param tenant_id path string true "Tenant ID"
+// @Success 200 {object} models.TenantSettings
+// @Failure 404 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Router /tenants/{tenant_id}/settings [get]
+func (h *TenantHandler) GetSettings(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Params("tenant_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_tenant_id", "Invalid tenant ID format")
+	}
+	if !canManageTenant(c, tenantID) {
+		return tenantForbidden(c, tenantID)
+	}
+
+	settings, err := h.service.GetSettings(c.Context(), tenantID)
+	if err != nil {
+		return response.NotFound(c, "Tenant settings not found")
+	}
+
+	return response.OK(c, settings)
+}
+
+// UpsertSettings creates or replaces settings for a tenant
+// @Summary Update tenant settings
+// @Description Creates or replaces ingestion settings for the caller's tenant, or any tenant with X-Admin-Key. Services in count_only_services are counted per service, level and minute instead of stored.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param tenant_id path string true "Tenant ID"
+// @Param settings body models.TenantSettings true "Tenant Settings"
+// @Success 200 {object} models.TenantSettings
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Router /tenants/{tenant_id}/settings [put]
+func (h *TenantHandler) UpsertSettings(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Params("tenant_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_tenant_id", "Invalid tenant ID format")
+	}
+	if !canManageTenant(c, tenantID) {
+		return tenantForbidden(c, tenantID)
+	}
+
+	var settings models.TenantSettings
+	if err := c.BodyParser(&settings); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	settings.TenantID = tenantID
+	if err := h.service.UpsertSettings(c.Context(), &settings); err != nil {
+		if errors.Is(err, service.ErrInvalidTenantSettings) {
+			return response.BadRequest(c, "invalid_settings", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, settings)
+}
+
+// DeleteSettings removes settings for a tenant
+// @Summary Delete tenant settings
+// @Description Removes ingestion settings for the caller's tenant, or any tenant with X-Admin-Key, restoring defaults
+// @Tags tenants
+// @Param tenant_id path string true "Tenant ID"
+// @Success 204
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Router /tenants/{tenant_id}/settings [delete]
+func (h *TenantHandler) DeleteSettings(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Params("tenant_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_tenant_id", "Invalid tenant ID format")
+	}
+	if !canManageTenant(c, tenantID) {
+		return tenantForbidden(c, tenantID)
+	}
+
+	if err := h.service.DeleteSettings(c.Context(), tenantID); err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.NoContent(c)
+}
+
+// GetAlertSettings retrieves alert defaults for a tenant
+// @Summary Get tenant alert settings
+// @Description Retrieves the default notification channels applied to a tenant's alerts. Only the tenant itself or an X-Admin-Key caller may access them.
+// @Tags tenants
+// @Produce json
+// @Param tenant_id path string true "Tenant ID"
+// @Success 200 {object} models.TenantAlertSettings
+// @Failure 404 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Router /tenants/{tenant_id}/alert-settings [get]
+func (h *TenantHandler) GetAlertSettings(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Params("tenant_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_tenant_id", "Invalid tenant ID format")
+	}
+	if !canManageTenant(c, tenantID) {
+		return tenantForbidden(c, tenantID)
+	}
+
+	settings, err := h.service.GetAlertSettings(c.Context(), tenantID)
+	if err != nil {
+		return response.NotFound(c, "Tenant alert settings not found")
+	}
+
+	return response.OK(c, settings)
+}
+
+// UpsertAlertSettings creates or replaces alert defaults for a tenant
+// @Summary Update tenant alert settings
+// @Description Sets default notification channels, how they merge with an alert's own channels (fallback or union), and which channel kinds each severity notifies. Only the tenant itself or an X-Admin-Key caller may change them.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param tenant_id path string true "Tenant ID"
+// @Param settings body models.TenantAlertSettings true "Tenant Alert Settings"
+// @Success 200 {object} models.TenantAlertSettings
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Router /tenants/{tenant_id}/alert-settings [put]
+func (h *TenantHandler) UpsertAlertSettings(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Params("tenant_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_tenant_id", "Invalid tenant ID format")
+	}
+	if !canManageTenant(c, tenantID) {
+		return tenantForbidden(c, tenantID)
+	}
+
+	var settings models.TenantAlertSettings
+	if err := c.BodyParser(&settings); err != nil {
+		return response.BadRequest(c, "invalid_request", err.Error())
+	}
+
+	settings.TenantID = tenantID
+	if err := h.service.UpsertAlertSettings(c.Context(), &settings); err != nil {
+		if errors.Is(err, service.ErrInvalidAlertSettings) {
+			return response.BadRequest(c, "invalid_alert_settings", err.Error())
+		}
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.OK(c, settings)
+}
+
+// DeleteAlertSettings removes alert defaults for a tenant
+// @Summary Delete tenant alert settings
+// @Description Removes default notification channels for the caller's tenant, or any tenant with X-Admin-Key
+// @Tags tenants
+// @Param tenant_id path string true "Tenant ID"
+// @Success 204
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} map[string]interface{}
+// @Router /tenants/{tenant_id}/alert-settings [delete]
+func (h *TenantHandler) DeleteAlertSettings(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Params("tenant_id"))
+	if err != nil {
+		return response.BadRequest(c, "invalid_tenant_id", "Invalid tenant ID format")
+	}
+	if !canManageTenant(c, tenantID) {
+		return tenantForbidden(c, tenantID)
+	}
+
+	if err := h.service.DeleteAlertSettings(c.Context(), tenantID); err != nil {
+		return response.InternalError(c, err.Error())
+	}
+
+	return response.NoContent(c)
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// Compress compresses responses of at least minBytes, leaving smaller ones
+// as-is since compressing them costs more CPU than it saves. Streamed bodies
+// have no known size and are always compressed. Requests accepted by skip
+// are never compressed.
+func Compress(minBytes int, skip func(c *fiber.Ctx) bool) fiber.Handler {
+	compressor := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {},
+		fasthttp.CompressBrotliDefaultCompression,
+		fasthttp.CompressDefaultCompression,
+	)
+
+	return func(c *fiber.Ctx) error {
+		if skip != nil && skip(c) {
+			return c.Next()
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if !resp.IsBodyStream() && len(resp.Body()) < minBytes {
+			return nil
+		}
+		compressor(c.Context())
+		return nil
+	}
+}
@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/minisource/log/config"
 )
 
 // RequestID adds a unique request ID to each request
@@ -33,12 +37,110 @@ func TenantExtractor() fiber.Handler {
 	}
 }
 
-// SecurityHeaders adds security headers
-func SecurityHeaders() fiber.Handler {
+// ScopeOrgIDExtractor resolves the tenant from Loki's X-Scope-OrgID header
+// when it holds a UUID and TenantExtractor found no X-Tenant-ID
+func ScopeOrgIDExtractor() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		c.Set("X-Content-Type-Options", "nosniff")
-		c.Set("X-Frame-Options", "DENY")
-		c.Set("X-XSS-Protection", "1; mode=block")
+		if _, ok := c.Locals("tenant_id").(uuid.UUID); !ok {
+			if tenantID, err := uuid.Parse(c.Get("X-Scope-OrgID")); err == nil {
+				c.Locals("tenant_id", tenantID)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// AdminExtractor marks requests carrying adminKey in X-Admin-Key as operator
+// requests; an empty adminKey marks nobody
+func AdminExtractor(adminKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if isAdmin(c, adminKey) {
+			c.Locals("admin", true)
+		}
+		return c.Next()
+	}
+}
+
+// isAdmin reports whether the request carries adminKey in X-Admin-Key
+func isAdmin(c *fiber.Ctx, adminKey string) bool {
+	return adminKey != "" && subtle.ConstantTimeCompare([]byte(c.Get("X-Admin-Key")), []byte(adminKey)) == 1
+}
+
+// EntryContextExtractor extracts ambient entry fields sent once per request
+// in X-Log-Env and X-Log-Source, applied to ingested entries omitting them
+func EntryContextExtractor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if env := strings.TrimSpace(c.Get("X-Log-Env")); env != "" {
+			c.Locals("log_environment", env)
+		}
+		if source := strings.TrimSpace(c.Get("X-Log-Source")); source != "" {
+			c.Locals("log_source", source)
+		}
+		return c.Next()
+	}
+}
+
+// RequireTenant rejects requests without a tenant resolved by TenantExtractor
+func RequireTenant() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("tenant_id").(uuid.UUID); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "tenant_required",
+					"message": "A valid X-Tenant-ID header is required",
+				},
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireAdmin rejects requests AdminExtractor did not mark as an operator's
+func RequireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if admin, _ := c.Locals("admin").(bool); !admin {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "admin_required",
+					"message": "A valid X-Admin-Key header is required",
+				},
+			})
+		}
+		return c.Next()
+	}
+}
+
+// SecurityHeaders adds the configured security headers
+func SecurityHeaders(cfg config.SecurityConfig) fiber.Handler {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", cfg.HSTSMaxAge)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		if cfg.NoSniff {
+			c.Set("X-Content-Type-Options", "nosniff")
+		}
+		if cfg.FrameOptions != "" {
+			c.Set("X-Frame-Options", cfg.FrameOptions)
+		}
+		if cfg.XSSProtection {
+			c.Set("X-XSS-Protection", "1; mode=block")
+		}
+		if hsts != "" {
+			c.Set("Strict-Transport-Security", hsts)
+		}
+		if cfg.ContentSecurityPolicy != "" {
+			c.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
 		return c.Next()
 	}
 }
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// SelfServiceName is the service name used for entries the log service
+// records about its own failures
+const SelfServiceName = "log-service"
+
+// PanicStore persists a log entry describing a recovered panic
+type PanicStore func(ctx context.Context, entry *models.LogEntry) error
+
+// Recover recovers from panics, records them as FATAL entries in the log
+// store and returns 500. Every request's panic is recorded, concurrent ones
+// included; a panic raised while recording is swallowed so a failing store
+// cannot recurse.
+func Recover(store PanicStore) fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			if store != nil {
+				recordPanic(c, store, r)
+			}
+
+			err = c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "internal_error",
+					"message": "Internal server error",
+				},
+			})
+		}()
+
+		return c.Next()
+	}
+}
+
+// recordPanic builds and stores the FATAL entry for a recovered panic
+func recordPanic(c *fiber.Ctx, store PanicStore, r interface{}) {
+	defer func() {
+		if nested := recover(); nested != nil {
+			fmt.Printf("Failed to record panic: %v\n", nested)
+		}
+	}()
+
+	metadata, _ := json.Marshal(map[string]string{
+		"stack":  string(debug.Stack()),
+		"route":  c.Route().Path,
+		"method": c.Method(),
+		"path":   c.Path(),
+	})
+
+	entry := &models.LogEntry{
+		ServiceName: SelfServiceName,
+		Level:       models.LogLevelFatal,
+		Message:     fmt.Sprintf("panic: %v", r),
+		Timestamp:   time.Now().UTC(),
+		Metadata:    metadata,
+	}
+	if tenantID, ok := c.Locals("tenant_id").(uuid.UUID); ok {
+		entry.TenantID = tenantID
+	}
+	if requestID, ok := c.Locals("request_id").(string); ok {
+		entry.RequestID = requestID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := store(ctx, entry); err != nil {
+		fmt.Printf("Failed to record panic: %v\n", err)
+	}
+}
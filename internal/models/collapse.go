@@ -0,0 +1,26 @@
+package models
+
+// CollapseRepeats folds each run of consecutive entries sharing a service,
+// level and message into the run's first entry, whose RepeatCount is the
+// length of the run, like journald's "message repeated N times". Entries
+// outside a run are returned as they are. The input is left unmodified.
+func CollapseRepeats(entries []LogEntry) []LogEntry {
+	collapsed := make([]LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if n := len(collapsed); n > 0 && sameLine(collapsed[n-1], entry) {
+			last := &collapsed[n-1]
+			if last.RepeatCount == 0 {
+				last.RepeatCount = 1
+			}
+			last.RepeatCount++
+			continue
+		}
+		collapsed = append(collapsed, entry)
+	}
+	return collapsed
+}
+
+// sameLine reports whether two entries would read as the same log line
+func sameLine(a, b LogEntry) bool {
+	return a.ServiceName == b.ServiceName && a.Level == b.Level && a.Message == b.Message
+}
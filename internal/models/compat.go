@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// LokiPushRequest is the JSON body of a Loki push (POST /loki/api/v1/push)
+type LokiPushRequest struct {
+	Streams []LokiStream `json:"streams"`
+}
+
+// LokiStream is a set of log lines sharing one label set. Each value is
+// [timestamp_ns, line] with an optional third element of structured metadata.
+type LokiStream struct {
+	Stream map[string]string   `json:"stream"`
+	Values [][]json.RawMessage `json:"values"`
+}
+
+// LokiQueryResponse is a Loki query_range response with a streams result
+type LokiQueryResponse struct {
+	Status string        `json:"status"`
+	Data   LokiQueryData `json:"data"`
+}
+
+// LokiQueryData holds the result of a Loki query
+type LokiQueryData struct {
+	ResultType string             `json:"resultType"`
+	Result     []LokiStreamResult `json:"result"`
+}
+
+// LokiStreamResult is one stream of a query result; values are
+// [timestamp_ns, line] pairs
+type LokiStreamResult struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// ESBulkResponse is an Elasticsearch-compatible _bulk response
+type ESBulkResponse struct {
+	Took   int64                         `json:"took"`
+	Errors bool                          `json:"errors"`
+	Items  []map[string]ESBulkItemResult `json:"items"`
+}
+
+// ESBulkItemResult is the outcome of one _bulk action, keyed by action name
+// in the response
+type ESBulkItemResult struct {
+	Index  string       `json:"_index"`
+	ID     string       `json:"_id"`
+	Status int          `json:"status"`
+	Result string       `json:"result,omitempty"`
+	Error  *ESBulkError `json:"error,omitempty"`
+}
+
+// ESBulkError describes why a _bulk action failed
+type ESBulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// OTLPLogsRequest is an OTLP/HTTP logs export (ExportLogsServiceRequest),
+// decoded from JSON or protobuf
+type OTLPLogsRequest struct {
+	ResourceLogs []OTLPResourceLogs `json:"resourceLogs"`
+}
+
+// OTLPResourceLogs holds the logs of one resource, e.g. a service instance
+type OTLPResourceLogs struct {
+	Resource  OTLPResource    `json:"resource"`
+	ScopeLogs []OTLPScopeLogs `json:"scopeLogs"`
+}
+
+// OTLPResource describes the entity producing logs
+type OTLPResource struct {
+	Attributes []OTLPKeyValue `json:"attributes"`
+}
+
+// OTLPScopeLogs holds the logs of one instrumentation scope
+type OTLPScopeLogs struct {
+	Scope      OTLPScope       `json:"scope"`
+	LogRecords []OTLPLogRecord `json:"logRecords"`
+}
+
+// OTLPScope identifies the instrumentation library that emitted the logs
+type OTLPScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// OTLPLogRecord is a single OTLP log record. Trace and span IDs are hex, as
+// in OTLP/JSON; protobuf bytes are hex-encoded on decode.
+type OTLPLogRecord struct {
+	TimeUnixNano         OTLPUint64     `json:"timeUnixNano"`
+	ObservedTimeUnixNano OTLPUint64     `json:"observedTimeUnixNano"`
+	SeverityNumber       int32          `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 OTLPAnyValue   `json:"body"`
+	Attributes           []OTLPKeyValue `json:"attributes"`
+	TraceID              string         `json:"traceId"`
+	SpanID               string         `json:"spanId"`
+}
+
+// OTLPKeyValue is a named attribute
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+// OTLPAnyValue holds exactly one of its fields, or none for an empty value.
+// BytesValue is base64, as in OTLP/JSON.
+type OTLPAnyValue struct {
+	StringValue *string           `json:"stringValue,omitempty"`
+	BoolValue   *bool             `json:"boolValue,omitempty"`
+	IntValue    *OTLPInt64        `json:"intValue,omitempty"`
+	DoubleValue *float64          `json:"doubleValue,omitempty"`
+	ArrayValue  *OTLPArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *OTLPKeyValueList `json:"kvlistValue,omitempty"`
+	BytesValue  *string           `json:"bytesValue,omitempty"`
+}
+
+// OTLPArrayValue is a list of values
+type OTLPArrayValue struct {
+	Values []OTLPAnyValue `json:"values"`
+}
+
+// OTLPKeyValueList is a nested map of attributes
+type OTLPKeyValueList struct {
+	Values []OTLPKeyValue `json:"values"`
+}
+
+// OTLPLogsResponse is an OTLP/HTTP logs export response
+// (ExportLogsServiceResponse)
+type OTLPLogsResponse struct {
+	PartialSuccess *OTLPPartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+// OTLPPartialSuccess reports log records the server rejected
+type OTLPPartialSuccess struct {
+	RejectedLogRecords int64  `json:"rejectedLogRecords,omitempty"`
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
+
+// OTLPUint64 is a 64-bit OTLP/JSON integer, sent as a decimal string or a
+// number
+type OTLPUint64 uint64
+
+// UnmarshalJSON accepts a quoted or bare integer
+func (v *OTLPUint64) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseUint(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = OTLPUint64(n)
+	return nil
+}
+
+// OTLPInt64 is a signed 64-bit OTLP/JSON integer, sent as a decimal string
+// or a number
+type OTLPInt64 int64
+
+// UnmarshalJSON accepts a quoted or bare integer
+func (v *OTLPInt64) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = OTLPInt64(n)
+	return nil
+}
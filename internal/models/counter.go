@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogCounter counts the entries of one service and level received in a
+// minute but not stored, for tenants that keep only counts of high-volume logs
+type LogCounter struct {
+	TenantID    uuid.UUID `json:"tenant_id" gorm:"type:uuid;primaryKey"`
+	ServiceName string    `json:"service_name" gorm:"type:varchar(100);primaryKey"`
+	Level       LogLevel  `json:"level" gorm:"type:varchar(10);primaryKey"`
+	Bucket      time.Time `json:"bucket" gorm:"primaryKey;index:idx_log_counters_bucket"`
+	Count       int64     `json:"count" gorm:"not null;default:0"`
+}
+
+// TableName returns the table name for GORM
+func (LogCounter) TableName() string {
+	return "log_counters"
+}
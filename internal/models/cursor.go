@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for a cursor not produced by EncodeCursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor returns an opaque cursor positioned at the entry
+func EncodeCursor(entry LogEntry) string {
+	raw := entry.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + entry.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor returns the timestamp and ID of the entry a cursor is
+// positioned at
+func DecodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	entryID, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	return timestamp, entryID, nil
+}
+
+// ResolveCursor moves the position of Cursor into AfterTimestamp and
+// AfterID, so equal positions share one form
+func (f *LogFilter) ResolveCursor() error {
+	if f.Cursor == "" {
+		return nil
+	}
+	timestamp, id, err := DecodeCursor(f.Cursor)
+	if err != nil {
+		return err
+	}
+	f.AfterTimestamp, f.AfterID, f.Cursor = &timestamp, &id, ""
+	return nil
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// FlatMetadataPrefix prefixes flattened metadata columns whose name collides
+// with a core entry field
+const FlatMetadataPrefix = "metadata."
+
+// flatCoreColumns are the core entry fields of a flattened row, in order
+var flatCoreColumns = []string{
+	"id", "tenant_id", "service_name", "level", "message", "timestamp",
+	"trace_id", "span_id", "user_id", "request_id", "source", "host",
+	"environment", "retention_tier", "error_type", "stack_trace", "created_at",
+}
+
+// isFlatCoreColumn reports whether name is a core column of a flattened row
+func isFlatCoreColumn(name string) bool {
+	for _, column := range flatCoreColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LogRowsResult is a query result with each entry flattened into a row:
+// core fields and metadata keys side by side as columns
+type LogRowsResult struct {
+	// Columns lists every column present in Rows: core fields first, then
+	// metadata columns sorted by name
+	Columns    []string                 `json:"columns"`
+	Rows       []map[string]interface{} `json:"rows"`
+	TotalCount int64                    `json:"total_count"`
+	Page       int                      `json:"page"`
+	PageSize   int                      `json:"page_size"`
+	HasMore    bool                     `json:"has_more"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+	// Approximate marks sampled results whose TotalCount is an estimate
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// FlattenEntry returns the entry as a single row. Nested metadata objects are
+// expanded into dot-joined columns (metadata {"user":{"id":1}} becomes
+// user.id); metadata columns named like a core field are prefixed with
+// FlatMetadataPrefix. Arrays are kept as values. Collapsed entries carry
+// their repeat_count as an extra column.
+func FlattenEntry(entry LogEntry) map[string]interface{} {
+	row := flattenEntry(entry)
+	if entry.RepeatCount > 0 {
+		row["repeat_count"] = entry.RepeatCount
+	}
+	return row
+}
+
+// flattenEntry returns the stored fields of the entry as a single row
+func flattenEntry(entry LogEntry) map[string]interface{} {
+	var userID interface{}
+	if entry.UserID != nil {
+		userID = entry.UserID.String()
+	}
+	row := map[string]interface{}{
+		"id":             entry.ID.String(),
+		"tenant_id":      entry.TenantID.String(),
+		"service_name":   entry.ServiceName,
+		"level":          string(entry.Level),
+		"message":        entry.Message,
+		"timestamp":      entry.Timestamp,
+		"trace_id":       entry.TraceID,
+		"span_id":        entry.SpanID,
+		"user_id":        userID,
+		"request_id":     entry.RequestID,
+		"source":         entry.Source,
+		"host":           entry.Host,
+		"environment":    entry.Environment,
+		"retention_tier": entry.RetentionTier,
+		"error_type":     entry.ErrorType,
+		"stack_trace":    entry.StackTrace,
+		"created_at":     entry.CreatedAt,
+	}
+
+	if len(entry.Metadata) == 0 {
+		return row
+	}
+	var metadata map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(entry.Metadata))
+	decoder.UseNumber()
+	if err := decoder.Decode(&metadata); err != nil {
+		// Not an object: keep the raw value under a single column
+		row["metadata"] = entry.Metadata
+		return row
+	}
+	flattenMetadata(row, "", metadata)
+	return row
+}
+
+// flattenMetadata copies metadata values into row, joining nested object
+// keys onto prefix
+func flattenMetadata(row map[string]interface{}, prefix string, metadata map[string]interface{}) {
+	for key, value := range metadata {
+		column := prefix + key
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenMetadata(row, column+".", nested)
+			continue
+		}
+		if prefix == "" && isFlatCoreColumn(column) {
+			column = FlatMetadataPrefix + column
+		}
+		row[column] = value
+	}
+}
+
+// FlattenQueryResult converts a query result into flattened rows
+func FlattenQueryResult(result *LogQueryResult) *LogRowsResult {
+	rows := make([]map[string]interface{}, len(result.Entries))
+	metadataColumns := make(map[string]bool)
+	for i, entry := range result.Entries {
+		rows[i] = FlattenEntry(entry)
+		for column := range rows[i] {
+			if !isFlatCoreColumn(column) {
+				metadataColumns[column] = true
+			}
+		}
+	}
+
+	extra := make([]string, 0, len(metadataColumns))
+	for column := range metadataColumns {
+		extra = append(extra, column)
+	}
+	sort.Strings(extra)
+
+	return &LogRowsResult{
+		Columns:     append(append([]string{}, flatCoreColumns...), extra...),
+		Rows:        rows,
+		TotalCount:  result.TotalCount,
+		Page:        result.Page,
+		PageSize:    result.PageSize,
+		HasMore:     result.HasMore,
+		NextCursor:  result.NextCursor,
+		Approximate: result.Approximate,
+	}
+}
@@ -2,9 +2,13 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // LogLevel represents log severity
@@ -18,6 +22,52 @@ const (
 	LogLevelFatal LogLevel = "FATAL"
 )
 
+// LogLevels lists the valid levels from least to most severe
+var LogLevels = []LogLevel{LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError, LogLevelFatal}
+
+// Valid reports whether the level is one of LogLevels
+func (l LogLevel) Valid() bool {
+	for _, level := range LogLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Rank returns the level's severity as its index in LogLevels, or -1 for an
+// unknown level
+func (l LogLevel) Rank() int {
+	for i, level := range LogLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// LevelsBetween returns the levels at or above min and at or below max,
+// least severe first; an empty bound is open. The result is empty when min
+// exceeds max or a bound is not a valid level.
+func LevelsBetween(min, max LogLevel) []LogLevel {
+	lo, hi := 0, len(LogLevels)-1
+	if min != "" {
+		lo = min.Rank()
+	}
+	if max != "" {
+		hi = max.Rank()
+	}
+	if lo < 0 || hi < 0 || lo > hi {
+		return []LogLevel{}
+	}
+	return append([]LogLevel{}, LogLevels[lo:hi+1]...)
+}
+
+// NormalizeLogLevel trims and uppercases a level, so "info" becomes INFO
+func NormalizeLogLevel(level LogLevel) LogLevel {
+	return LogLevel(strings.ToUpper(strings.TrimSpace(string(level))))
+}
+
 // LogEntry represents a single log entry
 type LogEntry struct {
 	ID          uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
@@ -34,7 +84,16 @@ type LogEntry struct {
 	Source      string          `json:"source,omitempty" gorm:"type:varchar(255)"`
 	Host        string          `json:"host,omitempty" gorm:"type:varchar(255)"`
 	Environment string          `json:"environment,omitempty" gorm:"type:varchar(50);index:idx_logs_env"`
-	CreatedAt   time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	// RetentionTier overrides tenant retention with a tier-specific period
+	RetentionTier string `json:"retention_tier,omitempty" gorm:"type:varchar(50);index:idx_logs_retention_tier"`
+	// ErrorType classifies error entries for grouping, e.g. *net.OpError
+	ErrorType string `json:"error_type,omitempty" gorm:"type:varchar(255);index:idx_logs_error_type"`
+	// StackTrace holds the error's stack trace and wrapped error chain
+	StackTrace string    `json:"stack_trace,omitempty" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+	// RepeatCount is set on read by CollapseRepeats to the number of
+	// consecutive identical entries the entry stands for
+	RepeatCount int `json:"repeat_count,omitempty" gorm:"-"`
 }
 
 // TableName returns the table name for GORM
@@ -42,26 +101,336 @@ func (LogEntry) TableName() string {
 	return "log_entries"
 }
 
+// BeforeCreate normalizes the entry so every write path, including direct
+// repository use, stores consistent values
+func (e *LogEntry) BeforeCreate(tx *gorm.DB) error {
+	e.Normalize()
+	return nil
+}
+
+// Normalize uppercases the level, trims the service name and converts the
+// timestamp to UTC
+func (e *LogEntry) Normalize() {
+	e.Level = NormalizeLogLevel(e.Level)
+	e.ServiceName = strings.TrimSpace(e.ServiceName)
+	if !e.Timestamp.IsZero() {
+		e.Timestamp = e.Timestamp.UTC()
+	}
+}
+
 // LogBatch represents a batch of log entries for bulk ingestion
 type LogBatch struct {
 	Entries []LogEntry `json:"entries"`
+	// DuplicatesCollapsed counts entries dropped at ingestion for repeating
+	// an ID earlier in the batch
+	DuplicatesCollapsed int `json:"-"`
+	// Rejected lists the entries left out of the batch as invalid
+	Rejected []RejectedEntry `json:"-"`
+	// Partial leaves out entries that are too old or too far in the future
+	// like those with invalid levels, instead of failing the batch
+	Partial bool `json:"-"`
+}
+
+// RejectedEntry identifies an entry of a batch that was not ingested
+type RejectedEntry struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// BatchResult reports which entries of a batch were stored. Failures
+// identify rejected entries by their index in the request, so clients can
+// retry just those.
+type BatchResult struct {
+	Accepted int             `json:"accepted"`
+	Rejected int             `json:"rejected"`
+	Failures []RejectedEntry `json:"failures,omitempty"`
+}
+
+// BatchProgress is a single frame of a streamed batch ingestion response
+type BatchProgress struct {
+	Type          string `json:"type"` // "progress" or "summary"
+	SubBatch      int    `json:"sub_batch,omitempty"`
+	Accepted      int    `json:"accepted"`
+	Rejected      int    `json:"rejected"`
+	AcceptedTotal int    `json:"accepted_total"`
+	RejectedTotal int    `json:"rejected_total"`
+	Total         int    `json:"total"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ReplayStatus is the lifecycle state of a replay job
+type ReplayStatus string
+
+const (
+	ReplayStatusRunning   ReplayStatus = "running"
+	ReplayStatusPaused    ReplayStatus = "paused"
+	ReplayStatusCompleted ReplayStatus = "completed"
+	ReplayStatusFailed    ReplayStatus = "failed"
+)
+
+// ReplayRequest starts a replay of historical logs to a webhook
+type ReplayRequest struct {
+	Filter     LogFilter `json:"filter"`
+	WebhookURL string    `json:"webhook_url"`
+	RatePerSec float64   `json:"rate_per_sec,omitempty"` // 0 sends as fast as possible
+}
+
+// ReplayJob reports the progress of a replay
+type ReplayJob struct {
+	ID         uuid.UUID    `json:"id"`
+	Status     ReplayStatus `json:"status"`
+	WebhookURL string       `json:"webhook_url"`
+	Sent       int64        `json:"sent"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+// ImportRequest selects the objects to import, relative to the configured prefix
+type ImportRequest struct {
+	Prefix string `json:"prefix"`
+	// KeepTenants imports entries into the tenants named in the files rather
+	// than the caller's; it requires X-Admin-Key
+	KeepTenants bool `json:"keep_tenants,omitempty"`
+}
+
+// ImportProgress is a single frame of a streamed import response
+type ImportProgress struct {
+	Type          string `json:"type"` // "file" or "summary"
+	Key           string `json:"key,omitempty"`
+	Imported      int64  `json:"imported"`
+	Skipped       int64  `json:"skipped"`
+	Failed        int64  `json:"failed"`
+	Files         int    `json:"files,omitempty"`
+	ImportedTotal int64  `json:"imported_total"`
+	SkippedTotal  int64  `json:"skipped_total"`
+	FailedTotal   int64  `json:"failed_total"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ArchiveRequest selects the time range to archive to object storage
+type ArchiveRequest struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ArchiveChunk reports the archival of one day of entries
+type ArchiveChunk struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Key      string    `json:"key,omitempty"`
+	Archived int64     `json:"archived"`
+	Deleted  int64     `json:"deleted"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// ArchiveSummary reports an archival run, one chunk per day in range order
+type ArchiveSummary struct {
+	Chunks   []ArchiveChunk `json:"chunks"`
+	Archived int64          `json:"archived"`
+	Deleted  int64          `json:"deleted"`
+	// Failed counts chunks that were not archived; their entries are kept
+	Failed int `json:"failed"`
+}
+
+// TimelineEntry is a log entry positioned relative to the start of a timeline
+type TimelineEntry struct {
+	LogEntry
+	OffsetMs int64 `json:"offset_ms"`
+}
+
+// TimelineLane groups a timeline's entries by service
+type TimelineLane struct {
+	ServiceName   string          `json:"service_name"`
+	FirstOffsetMs int64           `json:"first_offset_ms"`
+	LastOffsetMs  int64           `json:"last_offset_ms"`
+	Entries       []TimelineEntry `json:"entries"`
+}
+
+// Timeline is a cross-service view of a request or trace
+type Timeline struct {
+	Start      *time.Time      `json:"start,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+	Entries    []TimelineEntry `json:"entries"`
+	Lanes      []TimelineLane  `json:"lanes"`
+}
+
+// TraceBundle packages every entry of a trace for offline analysis
+type TraceBundle struct {
+	TraceID    string     `json:"trace_id"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Services   []string   `json:"services"`
+	EntryCount int        `json:"entry_count"`
+	Entries    []LogEntry `json:"entries"`
+}
+
+// SelfTestStep is the outcome of one step of a deployment self-test
+type SelfTestStep struct {
+	Name       string  `json:"name"`
+	Passed     bool    `json:"passed"`
+	Skipped    bool    `json:"skipped,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// SelfTestResult reports a full write/read/cache/delete round-trip
+type SelfTestResult struct {
+	Passed     bool           `json:"passed"`
+	DurationMs float64        `json:"duration_ms"`
+	Steps      []SelfTestStep `json:"steps"`
+}
+
+// MaintenanceStatus reports whether destructive background jobs are paused
+type MaintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Reason  string     `json:"reason,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// MaintenanceRequest turns maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
 }
 
 // LogFilter defines query filters for logs
 type LogFilter struct {
-	TenantID    *uuid.UUID `json:"tenant_id,omitempty"`
-	ServiceName string     `json:"service_name,omitempty"`
-	Level       LogLevel   `json:"level,omitempty"`
-	MinLevel    LogLevel   `json:"min_level,omitempty"`
-	StartTime   *time.Time `json:"start_time,omitempty"`
-	EndTime     *time.Time `json:"end_time,omitempty"`
-	TraceID     string     `json:"trace_id,omitempty"`
-	UserID      *uuid.UUID `json:"user_id,omitempty"`
-	RequestID   string     `json:"request_id,omitempty"`
-	Search      string     `json:"search,omitempty"`
-	Environment string     `json:"environment,omitempty"`
-	Page        int        `json:"page,omitempty"`
-	PageSize    int        `json:"page_size,omitempty"`
+	TenantID     *uuid.UUID `json:"tenant_id,omitempty"`
+	ServiceName  string     `json:"service_name,omitempty"`
+	Level        LogLevel   `json:"level,omitempty"`
+	MinLevel     LogLevel   `json:"min_level,omitempty"`
+	MaxLevel     LogLevel   `json:"max_level,omitempty"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	TraceID      string     `json:"trace_id,omitempty"`
+	UserID       *uuid.UUID `json:"user_id,omitempty"`
+	RequestID    string     `json:"request_id,omitempty"`
+	RequestIDs   []string   `json:"request_ids,omitempty"`
+	Search       string     `json:"search,omitempty"`
+	ExactMessage string     `json:"exact_message,omitempty"`
+	Environment  string     `json:"environment,omitempty"`
+	AroundTime   *time.Time `json:"around_time,omitempty"`
+	WindowMins   int        `json:"window_mins,omitempty"`
+	MinLatencyMs int        `json:"min_latency_ms,omitempty"`
+	Page         int        `json:"page,omitempty"`
+	PageSize     int        `json:"page_size,omitempty"`
+
+	// AfterTimestamp and AfterID page by keyset instead of offset, returning
+	// the entries after the one they identify in descending (timestamp, id)
+	// order. Cursor carries both as the NextCursor of a previous result.
+	// Keyset pages skip the total count.
+	AfterTimestamp *time.Time `json:"after_timestamp,omitempty"`
+	AfterID        *uuid.UUID `json:"after_id,omitempty"`
+	Cursor         string     `json:"cursor,omitempty"`
+
+	// MetadataIn matches entries whose top-level metadata key equals any of
+	// the listed values; keys are combined with AND
+	MetadataIn map[string][]string `json:"metadata_in,omitempty"`
+	// MetadataKey and MetadataValue match entries whose top-level metadata
+	// key holds the value, e.g. order_id=12345, with a containment query the
+	// metadata GIN index serves. Numbers and booleans also match unquoted.
+	MetadataKey   string `json:"metadata_key,omitempty"`
+	MetadataValue string `json:"metadata_value,omitempty"`
+	// MetadataSearch matches entries whose metadata keys or values contain
+	// the text, case-insensitively, anywhere in the metadata document
+	MetadataSearch string `json:"metadata_search,omitempty"`
+	// MinIngestLagSeconds matches entries stored more than this many seconds
+	// after their timestamp, exposing late-shipped logs
+	MinIngestLagSeconds int `json:"min_ingest_lag_seconds,omitempty"`
+	// HostPattern matches hosts against a glob where * matches any run of
+	// characters, e.g. web-*
+	HostPattern string `json:"host_pattern,omitempty"`
+	// ErrorType matches entries of one error type
+	ErrorType string `json:"error_type,omitempty"`
+	// Source matches entries from one origin, e.g. stdout, stderr or a file
+	Source string `json:"source,omitempty"`
+	// Sources matches entries from any of the listed origins
+	Sources []string `json:"sources,omitempty"`
+	// Or matches entries matching any of the groups, each an AND of its own
+	// predicates, in addition to the predicates above
+	Or []LogFilter `json:"or,omitempty"`
+}
+
+// DefaultProximityWindowMins is the window used with AroundTime when WindowMins is unset
+const DefaultProximityWindowMins = 5
+
+// ProximityWindow returns the time range covered by AroundTime, extending
+// WindowMins on each side of it
+func (f LogFilter) ProximityWindow() (start, end time.Time, ok bool) {
+	if f.AroundTime == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	window := f.WindowMins
+	if window < 1 {
+		window = DefaultProximityWindowMins
+	}
+	span := time.Duration(window) * time.Minute
+	return f.AroundTime.Add(-span), f.AroundTime.Add(span), true
+}
+
+// MetadataPaths returns the top-level metadata keys the filter and its Or
+// groups match on, sorted
+func (f LogFilter) MetadataPaths() []string {
+	seen := make(map[string]bool)
+	f.collectMetadataPaths(seen)
+	var paths []string
+	for key := range seen {
+		paths = append(paths, key)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (f LogFilter) collectMetadataPaths(seen map[string]bool) {
+	for key := range f.MetadataIn {
+		seen[key] = true
+	}
+	if f.MetadataKey != "" {
+		seen[f.MetadataKey] = true
+	}
+	if f.MinLatencyMs > 0 {
+		seen["latency_ms"] = true
+	}
+	for _, group := range f.Or {
+		group.collectMetadataPaths(seen)
+	}
+}
+
+// Or group limits, bounding the SQL a single filter can generate
+const (
+	MaxFilterDepth  = 3
+	MaxFilterGroups = 32
+)
+
+// GroupDepth returns how deeply Or groups nest; a filter without groups has
+// depth 0
+func (f LogFilter) GroupDepth() int {
+	depth := 0
+	for _, group := range f.Or {
+		if d := group.GroupDepth() + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// GroupCount returns the number of Or groups at every level
+func (f LogFilter) GroupCount() int {
+	count := len(f.Or)
+	for _, group := range f.Or {
+		count += group.GroupCount()
+	}
+	return count
+}
+
+// Selective reports whether the filter narrows by an indexed predicate: a
+// tenant, a time range, a service, or a trace or request ID. Or groups only
+// widen a filter, so they are not considered.
+func (f LogFilter) Selective() bool {
+	return f.TenantID != nil ||
+		f.StartTime != nil || f.EndTime != nil || f.AroundTime != nil ||
+		f.ServiceName != "" ||
+		f.TraceID != "" || f.RequestID != "" || len(f.RequestIDs) > 0
 }
 
 // LogStats represents aggregated log statistics
@@ -78,13 +447,104 @@ type TimeRange struct {
 	End   time.Time `json:"end"`
 }
 
+// ServiceLogEdge represents the earliest or latest log entry for a service
+type ServiceLogEdge struct {
+	ServiceName string    `json:"service_name"`
+	Timestamp   time.Time `json:"timestamp"`
+	Entry       *LogEntry `json:"entry,omitempty"`
+}
+
 // LogAggregation represents aggregated log data
 type LogAggregation struct {
+	// ServiceName is set when the aggregation is grouped by service
+	ServiceName string             `json:"service_name,omitempty"`
 	Bucket      time.Time          `json:"bucket"`
 	Count       int64              `json:"count"`
 	LevelCounts map[LogLevel]int64 `json:"level_counts,omitempty"`
 }
 
+// ErrorGroup summarizes the entries sharing an error type
+type ErrorGroup struct {
+	ErrorType string    `json:"error_type"`
+	Count     int64     `json:"count"`
+	Services  int64     `json:"services"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// LatestID and LatestMessage identify the most recent entry, whose
+	// stack trace is available from GET /logs/{id}
+	LatestID      uuid.UUID `json:"latest_id"`
+	LatestMessage string    `json:"latest_message"`
+}
+
+// RequestDuration spans the first to the last entry logged for a request
+type RequestDuration struct {
+	RequestID  string    `json:"request_id"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	DurationMs float64   `json:"duration_ms"`
+	Entries    int64     `json:"entries"`
+}
+
+// RequestDurations is the duration distribution of requests with at least
+// two entries, with the slowest of them
+type RequestDurations struct {
+	Requests int64             `json:"requests"`
+	P50Ms    float64           `json:"p50_ms"`
+	P90Ms    float64           `json:"p90_ms"`
+	P99Ms    float64           `json:"p99_ms"`
+	MaxMs    float64           `json:"max_ms"`
+	Slowest  []RequestDuration `json:"slowest"`
+}
+
+// LogSearchWithAggregation combines a page of entries with the time-bucketed
+// counts of the same filter
+type LogSearchWithAggregation struct {
+	Entries     []LogEntry       `json:"entries"`
+	Total       int64            `json:"total"`
+	Aggregation []LogAggregation `json:"aggregation"`
+}
+
+// VolumeDay is the log volume of one UTC day
+type VolumeDay struct {
+	Date  time.Time `json:"date"`
+	Count int64     `json:"count"`
+	Bytes int64     `json:"bytes"`
+}
+
+// VolumeForecast projects daily log volume and storage from a linear trend
+// over recent daily counts
+type VolumeForecast struct {
+	History []VolumeDay `json:"history"`
+	// Forecast holds the projected days following History
+	Forecast []VolumeDay `json:"forecast"`
+	// SlopePerDay is the trend's change in entries per day
+	SlopePerDay float64 `json:"slope_per_day"`
+	// AvgEntryBytes is the current storage size per stored entry
+	AvgEntryBytes int64 `json:"avg_entry_bytes"`
+	// ProjectedBytes is the storage the forecast days add in total
+	ProjectedBytes int64 `json:"projected_bytes"`
+}
+
+// HistogramBucket counts the entries whose field value falls in [Min, Max)
+type HistogramBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int64   `json:"count"`
+}
+
+// Histogram is the distribution of a numeric metadata field over equal-width
+// buckets
+type Histogram struct {
+	Field   string            `json:"field"`
+	Buckets []HistogramBucket `json:"buckets"`
+	// Underflow counts values below the first bucket
+	Underflow int64 `json:"underflow"`
+	// Overflow counts values at or above the end of the last bucket
+	Overflow int64 `json:"overflow"`
+	// Missing counts entries without the field or with a non-numeric value
+	Missing int64 `json:"missing"`
+}
+
 // LogRetention defines retention policy
 type LogRetention struct {
 	ID             uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
@@ -102,21 +562,63 @@ func (LogRetention) TableName() string {
 	return "log_retention_policies"
 }
 
+// AgeBucketDays are the upper bounds, in days, of the retention preview age
+// buckets. Logs older than the last bound fall into a final open-ended bucket.
+var AgeBucketDays = []int{1, 7, 30, 90, 365}
+
+// RetentionPreview summarizes the age of a tenant's stored logs, to inform
+// retention decisions
+type RetentionPreview struct {
+	TenantID        uuid.UUID   `json:"tenant_id"`
+	OldestTimestamp time.Time   `json:"oldest_timestamp"`
+	TotalCount      int64       `json:"total_count"`
+	AgeBuckets      []AgeBucket `json:"age_buckets"`
+}
+
+// AgeBucket counts logs at least MinDays and less than MaxDays old. MaxDays
+// is zero for the open-ended oldest bucket.
+type AgeBucket struct {
+	Label   string `json:"label"`
+	MinDays int    `json:"min_days"`
+	MaxDays int    `json:"max_days,omitempty"`
+	Count   int64  `json:"count"`
+}
+
+// NewAgeBuckets returns the empty age buckets bounded by AgeBucketDays
+func NewAgeBuckets() []AgeBucket {
+	buckets := make([]AgeBucket, 0, len(AgeBucketDays)+1)
+	minDays := 0
+	for _, maxDays := range AgeBucketDays {
+		buckets = append(buckets, AgeBucket{
+			Label:   fmt.Sprintf("%dd-%dd", minDays, maxDays),
+			MinDays: minDays,
+			MaxDays: maxDays,
+		})
+		minDays = maxDays
+	}
+	return append(buckets, AgeBucket{Label: fmt.Sprintf("%dd+", minDays), MinDays: minDays})
+}
+
 // LogAlert defines alerting rules for logs
 type LogAlert struct {
-	ID            uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	TenantID      uuid.UUID       `json:"tenant_id" gorm:"type:uuid;index"`
-	Name          string          `json:"name" gorm:"type:varchar(255);not null"`
-	Description   string          `json:"description,omitempty" gorm:"type:text"`
-	Enabled       bool            `json:"enabled" gorm:"default:true"`
-	Filter        json.RawMessage `json:"filter" gorm:"type:jsonb;not null"`
-	Threshold     int             `json:"threshold" gorm:"not null"`
-	WindowMins    int             `json:"window_mins" gorm:"not null;default:5"`
-	Severity      string          `json:"severity" gorm:"type:varchar(20);not null"`
-	Channels      json.RawMessage `json:"channels" gorm:"type:jsonb"`
-	LastTriggered *time.Time      `json:"last_triggered,omitempty"`
-	CreatedAt     time.Time       `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	ID             uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID       uuid.UUID       `json:"tenant_id" gorm:"type:uuid;index"`
+	Name           string          `json:"name" gorm:"type:varchar(255);not null"`
+	Description    string          `json:"description,omitempty" gorm:"type:text"`
+	Enabled        bool            `json:"enabled" gorm:"default:true"`
+	Filter         json.RawMessage `json:"filter" gorm:"type:jsonb;not null"`
+	Type           AlertType       `json:"type" gorm:"type:varchar(20);not null;default:threshold"` // "threshold" (default) or "new_error"
+	Threshold      int             `json:"threshold" gorm:"not null"`
+	WindowMins     int             `json:"window_mins" gorm:"not null;default:5"`
+	LookbackMins   int             `json:"lookback_mins,omitempty"` // new_error: minutes a fingerprint must be unseen, default 7 days
+	Severity       string          `json:"severity" gorm:"type:varchar(20);not null"`
+	Channels       json.RawMessage `json:"channels" gorm:"type:jsonb"`
+	SeverityRoutes json.RawMessage `json:"severity_routes,omitempty" gorm:"type:jsonb"` // severity to channel kinds, overriding the tenant's
+	LastTriggered  *time.Time      `json:"last_triggered,omitempty"`
+	Firing         bool            `json:"firing" gorm:"default:false"`
+	FiringSince    *time.Time      `json:"firing_since,omitempty"`
+	CreatedAt      time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 // TableName returns the table name for GORM
@@ -124,6 +626,135 @@ func (LogAlert) TableName() string {
 	return "log_alerts"
 }
 
+// AlertBulkAction is applied to every alert an AlertSelector matches
+type AlertBulkAction string
+
+const (
+	AlertBulkEnable  AlertBulkAction = "enable"
+	AlertBulkDisable AlertBulkAction = "disable"
+	AlertBulkDelete  AlertBulkAction = "delete"
+)
+
+// AlertSelector matches a tenant's alerts; set fields are combined with AND
+type AlertSelector struct {
+	Severity string `json:"severity,omitempty"`
+	// ServiceName matches alerts whose filter targets the service
+	ServiceName string `json:"service_name,omitempty"`
+	// NamePattern matches alert names against a glob where * matches any
+	// run of characters, e.g. checkout-*
+	NamePattern string `json:"name_pattern,omitempty"`
+}
+
+// AlertBulkRequest applies an action to the alerts a selector matches
+type AlertBulkRequest struct {
+	Selector AlertSelector   `json:"selector"`
+	Action   AlertBulkAction `json:"action"`
+}
+
+// AlertBulkResult reports how many alerts matched a bulk request and how
+// many were changed; enabling an enabled alert does not count as a change
+type AlertBulkResult struct {
+	Action   AlertBulkAction `json:"action"`
+	Matched  int             `json:"matched"`
+	Affected int64           `json:"affected"`
+}
+
+// AlertType selects what makes an alert fire
+type AlertType string
+
+const (
+	// AlertTypeThreshold fires when matching entries in the window reach the
+	// threshold
+	AlertTypeThreshold AlertType = "threshold"
+	// AlertTypeNewError fires for each matching entry whose message
+	// fingerprint was not seen within the lookback, surfacing new failure
+	// modes rather than volume
+	AlertTypeNewError AlertType = "new_error"
+)
+
+// ErrorFingerprint tracks when a normalized message was seen, for new error
+// detection
+type ErrorFingerprint struct {
+	TenantID    uuid.UUID `json:"tenant_id" gorm:"type:uuid;primaryKey"`
+	Fingerprint string    `json:"fingerprint" gorm:"type:varchar(64);primaryKey"`
+	ServiceName string    `json:"service_name" gorm:"type:varchar(100)"`
+	// Message is the first message seen with the fingerprint
+	Message   string    `json:"message" gorm:"type:text"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" gorm:"index:idx_error_fingerprints_last_seen"`
+}
+
+// TableName returns the table name for GORM
+func (ErrorFingerprint) TableName() string {
+	return "log_error_fingerprints"
+}
+
+// AlertNotificationType distinguishes firing from recovery notifications
+type AlertNotificationType string
+
+const (
+	AlertNotificationFiring   AlertNotificationType = "firing"
+	AlertNotificationResolved AlertNotificationType = "resolved"
+	AlertNotificationDigest   AlertNotificationType = "digest"
+)
+
+// AlertNotification is sent to an alert's channels when it fires or resolves
+type AlertNotification struct {
+	Type        AlertNotificationType `json:"type"`
+	AlertID     uuid.UUID             `json:"alert_id"`
+	TenantID    uuid.UUID             `json:"tenant_id"`
+	AlertName   string                `json:"alert_name"`
+	Severity    string                `json:"severity"`
+	Channels    json.RawMessage       `json:"channels,omitempty"`
+	Count       int64                 `json:"count"`
+	Threshold   int                   `json:"threshold"`
+	WindowMins  int                   `json:"window_mins"`
+	Message     string                `json:"message,omitempty"`
+	Fingerprint string                `json:"fingerprint,omitempty"` // normalized message of a new_error alert
+	FiredAt     time.Time             `json:"fired_at"`
+	ResolvedAt  *time.Time            `json:"resolved_at,omitempty"`
+	DurationSec int64                 `json:"duration_sec,omitempty"`
+	// Digest lists the collected notifications of a digest
+	Digest []AlertNotification `json:"digest,omitempty"`
+}
+
+// LogAlertEvent records a single firing or resolution of an alert
+type LogAlertEvent struct {
+	ID        uuid.UUID             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	AlertID   uuid.UUID             `json:"alert_id" gorm:"type:uuid;index:idx_alert_events_alert"`
+	TenantID  uuid.UUID             `json:"tenant_id" gorm:"type:uuid"`
+	Type      AlertNotificationType `json:"type" gorm:"type:varchar(20)"`
+	Count     int64                 `json:"count"`
+	Threshold int                   `json:"threshold"`
+	Message   string                `json:"message,omitempty" gorm:"type:text"`
+	CreatedAt time.Time             `json:"created_at" gorm:"autoCreateTime;index:idx_alert_events_created"`
+}
+
+// TableName returns the table name for GORM
+func (LogAlertEvent) TableName() string {
+	return "log_alert_events"
+}
+
+// AlertOutboxItem is a persisted pending notification, retried until sent so
+// notifications survive restarts
+type AlertOutboxItem struct {
+	ID            uuid.UUID             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	AlertID       uuid.UUID             `json:"alert_id" gorm:"type:uuid"`
+	TenantID      uuid.UUID             `json:"tenant_id" gorm:"type:uuid"`
+	Type          AlertNotificationType `json:"type" gorm:"type:varchar(20)"`
+	Payload       json.RawMessage       `json:"payload" gorm:"type:jsonb"`
+	Attempts      int                   `json:"attempts" gorm:"default:0"`
+	LastError     string                `json:"last_error,omitempty" gorm:"type:text"`
+	NextAttemptAt time.Time             `json:"next_attempt_at" gorm:"index:idx_alert_outbox_due,where:sent_at IS NULL"`
+	SentAt        *time.Time            `json:"sent_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for GORM
+func (AlertOutboxItem) TableName() string {
+	return "log_alert_outbox"
+}
+
 // LogQueryResult represents paginated query results
 type LogQueryResult struct {
 	Entries    []LogEntry `json:"entries"`
@@ -131,4 +762,12 @@ type LogQueryResult struct {
 	Page       int        `json:"page"`
 	PageSize   int        `json:"page_size"`
 	HasMore    bool       `json:"has_more"`
+	// NextCursor continues the query after the last entry; it is empty on
+	// the last page. TotalCount is not computed for keyset pages.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Approximate marks sampled results whose TotalCount is an estimate
+	Approximate bool `json:"approximate,omitempty"`
+	// Stale marks a previously cached result served because the database
+	// failed
+	Stale bool `json:"stale,omitempty"`
 }
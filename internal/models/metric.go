@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetricAggregation defines how matching logs are reduced to a metric value
+type MetricAggregation string
+
+const (
+	MetricAggregationCount MetricAggregation = "count"
+	MetricAggregationSum   MetricAggregation = "sum"
+	MetricAggregationAvg   MetricAggregation = "avg"
+	MetricAggregationMin   MetricAggregation = "min"
+	MetricAggregationMax   MetricAggregation = "max"
+)
+
+// MetricRule derives a time series from logs matching a filter
+type MetricRule struct {
+	ID            uuid.UUID         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID      uuid.UUID         `json:"tenant_id" gorm:"type:uuid;index"`
+	Name          string            `json:"name" gorm:"type:varchar(255);not null"`
+	Description   string            `json:"description,omitempty" gorm:"type:text"`
+	Enabled       bool              `json:"enabled" gorm:"default:true"`
+	Filter        json.RawMessage   `json:"filter" gorm:"type:jsonb;not null"`
+	Aggregation   MetricAggregation `json:"aggregation" gorm:"type:varchar(20);not null;default:'count'"`
+	Field         string            `json:"field,omitempty" gorm:"type:varchar(255)"` // numeric metadata key for sum/avg/min/max
+	IntervalMins  int               `json:"interval_mins" gorm:"not null;default:1"`
+	LastEvaluated *time.Time        `json:"last_evaluated,omitempty"`
+	CreatedAt     time.Time         `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time         `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (MetricRule) TableName() string {
+	return "log_metric_rules"
+}
+
+// MetricPoint is a single evaluated value of a metric rule
+type MetricPoint struct {
+	RuleID    uuid.UUID `json:"rule_id" gorm:"type:uuid;primaryKey"`
+	Bucket    time.Time `json:"bucket" gorm:"primaryKey"`
+	Value     float64   `json:"value"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for GORM
+func (MetricPoint) TableName() string {
+	return "log_metric_points"
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TenantSettings holds per-tenant toggles for ingestion behaviors
+type TenantSettings struct {
+	TenantID         uuid.UUID `json:"tenant_id" gorm:"type:uuid;primaryKey"`
+	DedupEnabled     bool      `json:"dedup_enabled" gorm:"default:false"`
+	SamplingEnabled  bool      `json:"sampling_enabled" gorm:"default:false"`
+	SampleRate       float64   `json:"sample_rate" gorm:"default:1"` // fraction of DEBUG/INFO entries kept
+	RedactionEnabled bool      `json:"redaction_enabled" gorm:"default:false"`
+	DampeningEnabled bool      `json:"dampening_enabled" gorm:"default:false"`
+	DampeningLimit   int       `json:"dampening_limit" gorm:"default:100"` // identical messages kept per minute
+	// EnrichmentURL receives each entry at ingestion and returns metadata
+	// to merge; it must be in the configured enrichment allowlist
+	EnrichmentURL string `json:"enrichment_url,omitempty" gorm:"type:varchar(500)"`
+	// DefaultPageSize is the page size of the tenant's queries that omit
+	// one; 0 uses the global default
+	DefaultPageSize int `json:"default_page_size,omitempty" gorm:"default:0"`
+	// CountOnlyServices lists services, or "*" for all, whose entries are
+	// counted per service, level and minute instead of stored, keeping stats
+	// and aggregations of high-volume logs without their rows.
+	// CountOnlySampleRate is the fraction of those entries still stored.
+	CountOnlyServices   json.RawMessage `json:"count_only_services,omitempty" gorm:"type:jsonb"`
+	CountOnlySampleRate float64         `json:"count_only_sample_rate,omitempty" gorm:"default:0"`
+	CreatedAt           time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (TenantSettings) TableName() string {
+	return "log_tenant_settings"
+}
+
+// AlertChannelMerge controls how tenant default channels combine with an alert's own
+type AlertChannelMerge string
+
+const (
+	// AlertChannelMergeFallback uses the defaults only when the alert has no channels
+	AlertChannelMergeFallback AlertChannelMerge = "fallback"
+	// AlertChannelMergeUnion sends to the alert's channels plus the defaults
+	AlertChannelMergeUnion AlertChannelMerge = "union"
+)
+
+// TenantAlertSettings holds per-tenant alert notification defaults
+type TenantAlertSettings struct {
+	TenantID        uuid.UUID         `json:"tenant_id" gorm:"type:uuid;primaryKey"`
+	DefaultChannels json.RawMessage   `json:"default_channels" gorm:"type:jsonb"`
+	ChannelMerge    AlertChannelMerge `json:"channel_merge" gorm:"type:varchar(20);default:fallback"`
+	SeverityRoutes  json.RawMessage   `json:"severity_routes,omitempty" gorm:"type:jsonb"` // severity to channel kinds, e.g. {"fatal":["pagerduty"]}
+	// DigestEnabled collects notifications into one summary per DigestIntervalMins
+	DigestEnabled      bool      `json:"digest_enabled" gorm:"default:false"`
+	DigestIntervalMins int       `json:"digest_interval_mins" gorm:"default:15"`
+	CreatedAt          time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (TenantAlertSettings) TableName() string {
+	return "log_tenant_alert_settings"
+}
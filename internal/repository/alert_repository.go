@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/minisource/log/internal/models"
@@ -18,9 +19,20 @@ func NewAlertRepository(db *gorm.DB) *AlertRepository {
 	return &AlertRepository{db: db}
 }
 
-// Create inserts a new alert
+// Create inserts a new alert. GORM inserts the column default for a false
+// Enabled, so a disabled alert is disabled in the same transaction.
 func (r *AlertRepository) Create(ctx context.Context, alert *models.LogAlert) error {
-	return r.db.WithContext(ctx).Create(alert).Error
+	enabled := alert.Enabled
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(alert).Error; err != nil {
+			return err
+		}
+		if enabled {
+			return nil
+		}
+		alert.Enabled = false
+		return tx.Model(alert).Update("enabled", false).Error
+	})
 }
 
 // Update updates an alert
@@ -52,11 +64,131 @@ func (r *AlertRepository) FindEnabled(ctx context.Context) ([]models.LogAlert, e
 	return alerts, err
 }
 
+// CountEnabledByTenantID counts enabled alerts for a tenant
+func (r *AlertRepository) CountEnabledByTenantID(ctx context.Context, tenantID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.LogAlert{}).
+		Where("tenant_id = ? AND enabled = ?", tenantID, true).
+		Count(&count).Error
+	return count, err
+}
+
+// FindFiring retrieves all enabled alerts currently firing
+func (r *AlertRepository) FindFiring(ctx context.Context) ([]models.LogAlert, error) {
+	var alerts []models.LogAlert
+	err := r.db.WithContext(ctx).Where("enabled = ? AND firing = ?", true, true).Find(&alerts).Error
+	return alerts, err
+}
+
+// SetFiring records the firing state of an alert. It only transitions from
+// the opposite state, returning false when another evaluator got there first.
+func (r *AlertRepository) SetFiring(ctx context.Context, id uuid.UUID, firing bool, since *time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.LogAlert{}).
+		Where("id = ? AND firing = ?", id, !firing).
+		Updates(map[string]interface{}{
+			"firing":       firing,
+			"firing_since": since,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
 // Delete removes an alert
 func (r *AlertRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return r.db.WithContext(ctx).Delete(&models.LogAlert{}, "id = ?", id).Error
 }
 
+// SetEnabledByIDs enables or disables a tenant's alerts, returning how many
+// changed state
+func (r *AlertRepository) SetEnabledByIDs(ctx context.Context, tenantID uuid.UUID, ids []uuid.UUID, enabled bool) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.LogAlert{}).
+		Where("tenant_id = ? AND id IN ? AND enabled = ?", tenantID, ids, !enabled).
+		Update("enabled", enabled)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByIDs removes a tenant's alerts, returning how many were deleted
+func (r *AlertRepository) DeleteByIDs(ctx context.Context, tenantID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id IN ?", tenantID, ids).
+		Delete(&models.LogAlert{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateEvent records an alert history event
+func (r *AlertRepository) CreateEvent(ctx context.Context, event *models.LogAlertEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// FindEvents retrieves the most recent history events of an alert
+func (r *AlertRepository) FindEvents(ctx context.Context, alertID uuid.UUID, limit int) ([]models.LogAlertEvent, error) {
+	var events []models.LogAlertEvent
+	err := r.db.WithContext(ctx).
+		Where("alert_id = ?", alertID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// DeleteEventsOlderThan removes alert history events created before the cutoff
+func (r *AlertRepository) DeleteEventsOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ?", before).
+		Delete(&models.LogAlertEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateOutboxItem persists a pending notification
+func (r *AlertRepository) CreateOutboxItem(ctx context.Context, item *models.AlertOutboxItem) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+// FindDueOutboxItems retrieves unsent notifications whose next attempt is
+// due, oldest first
+func (r *AlertRepository) FindDueOutboxItems(ctx context.Context, now time.Time, maxAttempts, limit int) ([]models.AlertOutboxItem, error) {
+	var items []models.AlertOutboxItem
+	query := r.db.WithContext(ctx).
+		Where("sent_at IS NULL AND next_attempt_at <= ?", now)
+	if maxAttempts > 0 {
+		query = query.Where("attempts < ?", maxAttempts)
+	}
+	err := query.Order("created_at ASC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+// MarkOutboxItemSent records a successful delivery
+func (r *AlertRepository) MarkOutboxItemSent(ctx context.Context, id uuid.UUID, sentAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.AlertOutboxItem{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"sent_at":  sentAt,
+			"attempts": gorm.Expr("attempts + 1"),
+		}).Error
+}
+
+// MarkOutboxItemFailed records a failed delivery and schedules the retry
+func (r *AlertRepository) MarkOutboxItemFailed(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.AlertOutboxItem{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastError,
+		}).Error
+}
+
+// DeleteSentOutboxItemsOlderThan removes delivered notifications sent before the cutoff
+func (r *AlertRepository) DeleteSentOutboxItemsOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("sent_at IS NOT NULL AND sent_at < ?", before).
+		Delete(&models.AlertOutboxItem{})
+	return result.RowsAffected, result.Error
+}
+
 // UpdateLastTriggered updates the last triggered timestamp
 func (r *AlertRepository) UpdateLastTriggered(ctx context.Context, id uuid.UUID) error {
 	return r.db.WithContext(ctx).
@@ -64,3 +196,38 @@ func (r *AlertRepository) UpdateLastTriggered(ctx context.Context, id uuid.UUID)
 		Where("id = ?", id).
 		Update("last_triggered", gorm.Expr("NOW()")).Error
 }
+
+// TouchFingerprint records a sighting of a message fingerprint and returns
+// when it was last seen before, or nil for a first sighting. Of concurrent
+// first sightings exactly one reports nil.
+func (r *AlertRepository) TouchFingerprint(ctx context.Context, fingerprint *models.ErrorFingerprint, seenAt time.Time) (*time.Time, error) {
+	var result struct {
+		Inserted bool
+		LastSeen *time.Time
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		WITH prev AS (
+			SELECT last_seen FROM log_error_fingerprints WHERE tenant_id = ? AND fingerprint = ?
+		), upsert AS (
+			INSERT INTO log_error_fingerprints (tenant_id, fingerprint, service_name, message, first_seen, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (tenant_id, fingerprint)
+			DO UPDATE SET last_seen = GREATEST(log_error_fingerprints.last_seen, EXCLUDED.last_seen)
+			RETURNING (xmax = 0) AS inserted
+		)
+		SELECT upsert.inserted, prev.last_seen FROM upsert LEFT JOIN prev ON TRUE`,
+		fingerprint.TenantID, fingerprint.Fingerprint,
+		fingerprint.TenantID, fingerprint.Fingerprint, fingerprint.ServiceName, fingerprint.Message, seenAt, seenAt,
+	).Scan(&result).Error
+	if err != nil {
+		return nil, err
+	}
+	if result.Inserted {
+		return nil, nil
+	}
+	if result.LastSeen == nil {
+		// Inserted concurrently after this statement's snapshot
+		return &seenAt, nil
+	}
+	return result.LastSeen, nil
+}
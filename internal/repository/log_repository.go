@@ -2,18 +2,27 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/minisource/log/internal/database"
 	"github.com/minisource/log/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // LogRepository handles log entry persistence
 type LogRepository struct {
-	db *gorm.DB
+	db          *gorm.DB
+	replica     *gorm.DB
+	advisor     *database.MetadataIndexAdvisor
+	shadow      *gorm.DB
+	shadowTable string
 }
 
 // NewLogRepository creates a new log repository
@@ -21,9 +30,60 @@ func NewLogRepository(db *gorm.DB) *LogRepository {
 	return &LogRepository{db: db}
 }
 
+// SetIndexAdvisor reports the metadata paths of every built query to advisor
+func (r *LogRepository) SetIndexAdvisor(advisor *database.MetadataIndexAdvisor) {
+	r.advisor = advisor
+}
+
+// SetShadow enables dual-writing: entries inserted into the primary are
+// also inserted into table of db
+func (r *LogRepository) SetShadow(db *gorm.DB, table string) {
+	r.shadow = db
+	r.shadowTable = table
+}
+
+// SetReplica serves reads from a read replica. Writes, and reads whose
+// context is marked with ReadPrimary, keep using the primary.
+func (r *LogRepository) SetReplica(db *gorm.DB) {
+	r.replica = db
+}
+
+// HasReplica reports whether reads may be served by a read replica
+func (r *LogRepository) HasReplica() bool {
+	return r.replica != nil
+}
+
+// readPrimaryKey marks a context whose reads must see the primary
+type readPrimaryKey struct{}
+
+// ReadPrimary marks ctx so log reads go to the primary instead of the
+// replica, for callers that must see their own recent writes
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+// ReadsPrimary reports whether reads under ctx go to the primary
+func ReadsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(readPrimaryKey{}).(bool)
+	return primary
+}
+
+// reader returns the database serving reads under ctx
+func (r *LogRepository) reader(ctx context.Context) *gorm.DB {
+	if r.replica == nil || ReadsPrimary(ctx) {
+		return r.db
+	}
+	return r.replica
+}
+
 // Create inserts a single log entry
 func (r *LogRepository) Create(ctx context.Context, entry *models.LogEntry) error {
-	return r.db.WithContext(ctx).Create(entry).Error
+	result := r.db.WithContext(ctx).Create(entry)
+	if result.Error != nil {
+		return result.Error
+	}
+	r.mirror(ctx, []models.LogEntry{*entry}, result.RowsAffected)
+	return nil
 }
 
 // CreateBatch inserts multiple log entries
@@ -31,13 +91,57 @@ func (r *LogRepository) CreateBatch(ctx context.Context, entries []models.LogEnt
 	if len(entries) == 0 {
 		return nil
 	}
-	return r.db.WithContext(ctx).CreateInBatches(entries, 1000).Error
+	result := r.db.WithContext(ctx).CreateInBatches(entries, 1000)
+	if result.Error != nil {
+		return result.Error
+	}
+	r.mirror(ctx, entries, result.RowsAffected)
+	return nil
+}
+
+// mirror writes entries stored in the primary to the shadow destination.
+// Failures and row count mismatches are logged, never returned, so the
+// shadow cannot block ingestion.
+func (r *LogRepository) mirror(ctx context.Context, entries []models.LogEntry, primaryRows int64) {
+	if r.shadow == nil || len(entries) == 0 {
+		return
+	}
+
+	// Entries already copied by a backfill are skipped rather than failing
+	result := r.shadow.WithContext(ctx).
+		Table(r.shadowTable).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(entries, 1000)
+	if result.Error != nil {
+		fmt.Printf("Shadow write of %d entries to %s failed: %v\n", len(entries), r.shadowTable, result.Error)
+		return
+	}
+	if result.RowsAffected != primaryRows {
+		fmt.Printf("Shadow write discrepancy: primary stored %d entries, %s stored %d\n",
+			primaryRows, r.shadowTable, result.RowsAffected)
+	}
+}
+
+// CreateBatchSkipDuplicates inserts entries, skipping ones whose ID already
+// exists, and returns the number actually inserted
+func (r *LogRepository) CreateBatchSkipDuplicates(ctx context.Context, entries []models.LogEntry) (int64, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entries)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	r.mirror(ctx, entries, result.RowsAffected)
+	return result.RowsAffected, nil
 }
 
 // FindByID retrieves a log entry by ID
 func (r *LogRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.LogEntry, error) {
 	var entry models.LogEntry
-	err := r.db.WithContext(ctx).First(&entry, "id = ?", id).Error
+	err := r.reader(ctx).WithContext(ctx).First(&entry, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -46,11 +150,14 @@ func (r *LogRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Log
 
 // Query finds log entries matching the filter
 func (r *LogRepository) Query(ctx context.Context, filter models.LogFilter) ([]models.LogEntry, int64, error) {
+	return r.queryPage(r.buildQuery(ctx, filter), filter)
+}
+
+// queryPage counts the rows of a filtered query and fetches the filter's page
+func (r *LogRepository) queryPage(query *gorm.DB, filter models.LogFilter) ([]models.LogEntry, int64, error) {
 	var entries []models.LogEntry
 	var total int64
 
-	query := r.buildQuery(filter)
-
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -61,13 +168,19 @@ func (r *LogRepository) Query(ctx context.Context, filter models.LogFilter) ([]m
 	if page < 1 {
 		page = 1
 	}
-	pageSize := filter.PageSize
-	if pageSize < 1 || pageSize > 1000 {
-		pageSize = 100
+	pageSize := pageSizeOf(filter)
+
+	// Proximity queries return the entries closest to the point first
+	if filter.AroundTime != nil {
+		query = query.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "ABS(EXTRACT(EPOCH FROM (timestamp - ?)))",
+			Vars: []interface{}{*filter.AroundTime},
+		}})
 	}
 
+	// Ordering ties by ID lets a keyset page continue where this one ends
 	offset := (page - 1) * pageSize
-	err := query.Order("timestamp DESC").Offset(offset).Limit(pageSize).Find(&entries).Error
+	err := query.Order("timestamp DESC, id DESC").Offset(offset).Limit(pageSize).Find(&entries).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -75,9 +188,126 @@ func (r *LogRepository) Query(ctx context.Context, filter models.LogFilter) ([]m
 	return entries, total, nil
 }
 
+// pageSizeOf returns the filter's page size, falling back to 100 when it is
+// unset or above 1000
+func pageSizeOf(filter models.LogFilter) int {
+	if filter.PageSize < 1 || filter.PageSize > 1000 {
+		return 100
+	}
+	return filter.PageSize
+}
+
+// QueryCursor returns the page of entries after the filter's keyset
+// position in descending (timestamp, id) order. It skips the count, which
+// dominates latency on big tables; hasMore reports whether entries remain
+// past the page.
+func (r *LogRepository) QueryCursor(ctx context.Context, filter models.LogFilter) ([]models.LogEntry, bool, error) {
+	pageSize := pageSizeOf(filter)
+
+	query := r.buildQuery(ctx, filter)
+	if filter.AfterTimestamp != nil {
+		if filter.AfterID != nil {
+			query = query.Where("(timestamp, id) < (?, ?)", *filter.AfterTimestamp, *filter.AfterID)
+		} else {
+			query = query.Where("timestamp < ?", *filter.AfterTimestamp)
+		}
+	}
+
+	// One extra row tells whether another page follows
+	var entries []models.LogEntry
+	if err := query.Order("timestamp DESC, id DESC").Limit(pageSize + 1).Find(&entries).Error; err != nil {
+		return nil, false, err
+	}
+	if len(entries) > pageSize {
+		return entries[:pageSize], true, nil
+	}
+	return entries, false, nil
+}
+
+// SearchWithAggregation returns a page of matching entries, their total and
+// time-bucketed counts, building the filtered query once for all three
+func (r *LogRepository) SearchWithAggregation(ctx context.Context, filter models.LogFilter, interval string) ([]models.LogEntry, int64, []models.LogAggregation, error) {
+	// A new session lets each statement start from the shared filter
+	query := r.buildQuery(ctx, filter).Session(&gorm.Session{})
+
+	entries, total, err := r.queryPage(query, filter)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	aggregations, err := r.aggregate(query, interval)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return entries, total, aggregations, nil
+}
+
+// Count returns the number of entries matching the filter
+func (r *LogRepository) Count(ctx context.Context, filter models.LogFilter) (int64, error) {
+	var count int64
+	err := r.buildQuery(ctx, filter).Count(&count).Error
+	return count, err
+}
+
+// QueryAfter returns up to limit entries matching the filter in ascending
+// (timestamp, id) order, starting after the given position
+func (r *LogRepository) QueryAfter(ctx context.Context, filter models.LogFilter, afterTime time.Time, afterID uuid.UUID, limit int) ([]models.LogEntry, error) {
+	var entries []models.LogEntry
+	query := r.buildQuery(ctx, filter)
+	if !afterTime.IsZero() {
+		query = query.Where("(timestamp, id) > (?, ?)", afterTime, afterID)
+	}
+	err := query.Order("timestamp ASC, id ASC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// approxSampleRows is the number of rows an approximate query aims to sample
+const approxSampleRows = 100000
+
+// QueryApprox samples the table with TABLESAMPLE and returns matching entries
+// with a total extrapolated from the sampling rate
+func (r *LogRepository) QueryApprox(ctx context.Context, filter models.LogFilter) ([]models.LogEntry, int64, error) {
+	r.advisor.Observe(filter.MetadataPaths()...)
+
+	var reltuples float64
+	if err := r.reader(ctx).WithContext(ctx).
+		Raw("SELECT GREATEST(reltuples, 0) FROM pg_class WHERE relname = 'log_entries'").
+		Scan(&reltuples).Error; err != nil {
+		return nil, 0, err
+	}
+
+	percent := 100.0
+	if reltuples > approxSampleRows {
+		percent = approxSampleRows / reltuples * 100
+	}
+
+	sampled := func() *gorm.DB {
+		base := r.reader(ctx).WithContext(ctx).
+			Table(fmt.Sprintf("log_entries TABLESAMPLE SYSTEM (%f)", percent))
+		return r.applyFilter(base, filter)
+	}
+
+	var sampleCount int64
+	if err := sampled().Count(&sampleCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.LogEntry
+	if err := sampled().Order("timestamp DESC, id DESC").Limit(pageSizeOf(filter)).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	estimated := int64(float64(sampleCount) * 100 / percent)
+	return entries, estimated, nil
+}
+
 // buildQuery creates the GORM query from filter
-func (r *LogRepository) buildQuery(filter models.LogFilter) *gorm.DB {
-	query := r.db.Model(&models.LogEntry{})
+func (r *LogRepository) buildQuery(ctx context.Context, filter models.LogFilter) *gorm.DB {
+	r.advisor.Observe(filter.MetadataPaths()...)
+	return r.applyFilter(r.reader(ctx).WithContext(ctx).Model(&models.LogEntry{}), filter)
+}
+
+// applyFilter adds the filter predicates to a query
+func (r *LogRepository) applyFilter(query *gorm.DB, filter models.LogFilter) *gorm.DB {
 
 	if filter.TenantID != nil {
 		query = query.Where("tenant_id = ?", filter.TenantID)
@@ -91,9 +321,8 @@ func (r *LogRepository) buildQuery(filter models.LogFilter) *gorm.DB {
 		query = query.Where("level = ?", filter.Level)
 	}
 
-	if filter.MinLevel != "" {
-		levels := getLevelsAtOrAbove(filter.MinLevel)
-		query = query.Where("level IN ?", levels)
+	if filter.MinLevel != "" || filter.MaxLevel != "" {
+		query = query.Where("level IN ?", models.LevelsBetween(filter.MinLevel, filter.MaxLevel))
 	}
 
 	if filter.StartTime != nil {
@@ -116,39 +345,145 @@ func (r *LogRepository) buildQuery(filter models.LogFilter) *gorm.DB {
 		query = query.Where("request_id = ?", filter.RequestID)
 	}
 
+	if len(filter.RequestIDs) > 0 {
+		query = query.Where("request_id IN ?", filter.RequestIDs)
+	}
+
 	if filter.Environment != "" {
 		query = query.Where("environment = ?", filter.Environment)
 	}
 
+	if filter.ExactMessage != "" {
+		query = query.Where("message = ?", filter.ExactMessage)
+	}
+
+	if filter.HostPattern != "" {
+		query = query.Where(hostPatternClause(filter.HostPattern))
+	}
+
+	if filter.ErrorType != "" {
+		query = query.Where("error_type = ?", filter.ErrorType)
+	}
+
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+
+	if len(filter.Sources) > 0 {
+		query = query.Where("source IN ?", filter.Sources)
+	}
+
+	if start, end, ok := filter.ProximityWindow(); ok {
+		query = query.Where("timestamp BETWEEN ? AND ?", start, end)
+	}
+
+	if filter.MinLatencyMs > 0 {
+		// CASE guards the cast against non-numeric values
+		query = query.Where("CASE WHEN jsonb_typeof(metadata->'latency_ms') = 'number' THEN (metadata->>'latency_ms')::numeric END >= ?", filter.MinLatencyMs)
+	}
+
+	if filter.MinIngestLagSeconds > 0 {
+		query = query.Where("created_at - timestamp > ? * INTERVAL '1 second'", filter.MinIngestLagSeconds)
+	}
+
+	for _, key := range sortedKeys(filter.MetadataIn) {
+		sql, vars := metadataInClause(key, filter.MetadataIn[key])
+		query = query.Where(sql, vars...)
+	}
+
+	if filter.MetadataKey != "" {
+		sql, vars := metadataInClause(filter.MetadataKey, []string{filter.MetadataValue})
+		query = query.Where(sql, vars...)
+	}
+
 	if filter.Search != "" {
 		search := "%" + strings.ToLower(filter.Search) + "%"
 		query = query.Where("LOWER(message) LIKE ?", search)
 	}
 
+	if filter.MetadataSearch != "" {
+		query = query.Where("metadata::text ILIKE ?", "%"+filter.MetadataSearch+"%")
+	}
+
+	if groups := r.orGroups(filter.Or); groups != nil {
+		query = query.Where(groups)
+	}
+
 	return query
 }
 
-// getLevelsAtOrAbove returns all log levels at or above the given level
-func getLevelsAtOrAbove(level models.LogLevel) []models.LogLevel {
-	levels := []models.LogLevel{
-		models.LogLevelDebug,
-		models.LogLevelInfo,
-		models.LogLevelWarn,
-		models.LogLevelError,
-		models.LogLevelFatal,
+// orGroups builds the parenthesized OR of the groups' predicates, or nil
+// when there are no groups or one of them matches everything
+func (r *LogRepository) orGroups(groups []models.LogFilter) *gorm.DB {
+	var combined *gorm.DB
+	for _, group := range groups {
+		if reflect.ValueOf(group).IsZero() {
+			return nil
+		}
+		conditions := r.applyFilter(r.db.Session(&gorm.Session{NewDB: true}), group)
+		if combined == nil {
+			combined = r.db.Session(&gorm.Session{NewDB: true}).Where(conditions)
+		} else {
+			combined = combined.Or(conditions)
+		}
 	}
+	return combined
+}
+
+// likeEscaper escapes LIKE metacharacters so they match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
 
-	var result []models.LogLevel
-	found := false
-	for _, l := range levels {
-		if l == level {
-			found = true
+// hostPatternClause translates a host glob into SQL. A pattern without
+// wildcards compares exactly; otherwise * becomes % in a LIKE with the other
+// LIKE metacharacters escaped. Anchored prefix patterns (web-*) keep a
+// literal prefix, which idx_logs_host_pattern can serve.
+func hostPatternClause(pattern string) (string, string) {
+	if !strings.Contains(pattern, "*") {
+		return "host = ?", pattern
+	}
+
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = likeEscaper.Replace(part)
+	}
+	return "host LIKE ?", strings.Join(parts, "%")
+}
+
+// metadataInClause ORs one containment test per value so each can use the
+// jsonb_path_ops GIN index. Values that are valid JSON scalars (numbers,
+// booleans) also match their unquoted form.
+func metadataInClause(key string, values []string) (string, []interface{}) {
+	var sql []string
+	var vars []interface{}
+	for _, value := range values {
+		candidates := []interface{}{value}
+		var scalar interface{}
+		if err := json.Unmarshal([]byte(value), &scalar); err == nil {
+			switch scalar.(type) {
+			case float64, bool:
+				candidates = append(candidates, json.RawMessage(value))
+			}
 		}
-		if found {
-			result = append(result, l)
+		for _, candidate := range candidates {
+			doc, _ := json.Marshal(map[string]interface{}{key: candidate})
+			sql = append(sql, "metadata @> ?::jsonb")
+			vars = append(vars, string(doc))
 		}
 	}
-	return result
+	if len(sql) == 0 {
+		return "FALSE", nil
+	}
+	return "(" + strings.Join(sql, " OR ") + ")", vars
+}
+
+// sortedKeys returns map keys in a stable order so generated SQL is stable
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // GetStats retrieves aggregated statistics
@@ -162,25 +497,32 @@ func (r *LogRepository) GetStats(ctx context.Context, tenantID *uuid.UUID, start
 		},
 	}
 
-	query := r.db.WithContext(ctx).Model(&models.LogEntry{}).
-		Where("timestamp >= ? AND timestamp <= ?", startTime, endTime)
-
-	if tenantID != nil {
-		query = query.Where("tenant_id = ?", tenantID)
+	// Each query starts from a fresh scope so all three respect the tenant
+	scoped := func() *gorm.DB {
+		query := r.reader(ctx).WithContext(ctx).Model(&models.LogEntry{}).
+			Where("timestamp >= ? AND timestamp <= ?", startTime, endTime)
+		if tenantID != nil {
+			query = query.Where("tenant_id = ?", tenantID)
+		}
+		return query
 	}
 
 	// Total count
-	query.Count(&stats.TotalCount)
+	if err := scoped().Count(&stats.TotalCount).Error; err != nil {
+		return nil, err
+	}
 
 	// Level counts
 	var levelResults []struct {
 		Level models.LogLevel
 		Count int64
 	}
-	r.db.WithContext(ctx).Model(&models.LogEntry{}).
+	err := scoped().
 		Select("level, COUNT(*) as count").
-		Where("timestamp >= ? AND timestamp <= ?", startTime, endTime).
-		Group("level").Scan(&levelResults)
+		Group("level").Scan(&levelResults).Error
+	if err != nil {
+		return nil, err
+	}
 
 	for _, lr := range levelResults {
 		stats.LevelCounts[lr.Level] = lr.Count
@@ -191,10 +533,12 @@ func (r *LogRepository) GetStats(ctx context.Context, tenantID *uuid.UUID, start
 		ServiceName string
 		Count       int64
 	}
-	r.db.WithContext(ctx).Model(&models.LogEntry{}).
+	err = scoped().
 		Select("service_name, COUNT(*) as count").
-		Where("timestamp >= ? AND timestamp <= ?", startTime, endTime).
-		Group("service_name").Scan(&serviceResults)
+		Group("service_name").Scan(&serviceResults).Error
+	if err != nil {
+		return nil, err
+	}
 
 	for _, sr := range serviceResults {
 		stats.ServiceCounts[sr.ServiceName] = sr.Count
@@ -203,21 +547,218 @@ func (r *LogRepository) GetStats(ctx context.Context, tenantID *uuid.UUID, start
 	return stats, nil
 }
 
+// IncrementCounters adds counts to their per-minute counters, creating
+// missing ones
+func (r *LogRepository) IncrementCounters(ctx context.Context, counters []models.LogCounter) error {
+	if len(counters) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "tenant_id"}, {Name: "service_name"}, {Name: "level"}, {Name: "bucket"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count": gorm.Expr("log_counters.count + excluded.count"),
+			}),
+		}).
+		Create(&counters).Error
+}
+
+// CounterAggregate sums counters matching the filter per service and time
+// bucket. Counters carry only tenant, service, level and time, so callers
+// must check the filter with CountersApply first.
+func (r *LogRepository) CounterAggregate(ctx context.Context, filter models.LogFilter, interval string) ([]models.LogAggregation, error) {
+	query := r.reader(ctx).WithContext(ctx).Model(&models.LogCounter{})
+	if filter.TenantID != nil {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.ServiceName != "" {
+		query = query.Where("service_name = ?", filter.ServiceName)
+	}
+	if filter.Level != "" {
+		query = query.Where("level = ?", filter.Level)
+	}
+	if filter.MinLevel != "" || filter.MaxLevel != "" {
+		query = query.Where("level IN ?", models.LevelsBetween(filter.MinLevel, filter.MaxLevel))
+	}
+	if filter.StartTime != nil {
+		query = query.Where("bucket >= ?", filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("bucket <= ?", filter.EndTime)
+	}
+	if start, end, ok := filter.ProximityWindow(); ok {
+		query = query.Where("bucket BETWEEN ? AND ?", start, end)
+	}
+
+	var results []struct {
+		ServiceName string
+		Bucket      time.Time
+		Count       int64
+	}
+	err := query.Select(fmt.Sprintf("service_name, %s as bucket, SUM(count) as count", truncExpression(interval, "bucket"))).
+		Group("service_name, 2").
+		Order("service_name, 2").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	aggregations := make([]models.LogAggregation, len(results))
+	for i, res := range results {
+		aggregations[i] = models.LogAggregation{
+			ServiceName: res.ServiceName,
+			Bucket:      res.Bucket,
+			Count:       res.Count,
+		}
+	}
+	return aggregations, nil
+}
+
+// CounterTotals sums a tenant's counters between startTime and endTime per
+// service and level
+func (r *LogRepository) CounterTotals(ctx context.Context, tenantID *uuid.UUID, startTime, endTime time.Time) ([]models.LogCounter, error) {
+	query := r.reader(ctx).WithContext(ctx).Model(&models.LogCounter{}).
+		Where("bucket >= ? AND bucket <= ?", startTime, endTime)
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	var totals []models.LogCounter
+	err := query.Select("service_name, level, SUM(count) as count").
+		Group("service_name, level").
+		Scan(&totals).Error
+	return totals, err
+}
+
 // Aggregate retrieves aggregated log counts over time
 func (r *LogRepository) Aggregate(ctx context.Context, filter models.LogFilter, interval string) ([]models.LogAggregation, error) {
-	var bucketExpr string
+	return r.aggregate(r.buildQuery(ctx, filter), interval)
+}
+
+// AggregateByService retrieves log counts over time per service, ordered by
+// service and bucket
+func (r *LogRepository) AggregateByService(ctx context.Context, filter models.LogFilter, interval string) ([]models.LogAggregation, error) {
+	query := r.buildQuery(ctx, filter)
+
+	var results []struct {
+		ServiceName string
+		Bucket      time.Time
+		Count       int64
+	}
+	err := query.Select(fmt.Sprintf("service_name, %s as bucket, COUNT(*) as count", bucketExpression(interval))).
+		Group("service_name, bucket").
+		Order("service_name, bucket").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	aggregations := make([]models.LogAggregation, len(results))
+	for i, res := range results {
+		aggregations[i] = models.LogAggregation{
+			ServiceName: res.ServiceName,
+			Bucket:      res.Bucket,
+			Count:       res.Count,
+		}
+	}
+	return aggregations, nil
+}
+
+// GroupByErrorType summarizes matching entries that carry an error type per
+// type, most frequent first
+func (r *LogRepository) GroupByErrorType(ctx context.Context, filter models.LogFilter, limit int) ([]models.ErrorGroup, error) {
+	var groups []models.ErrorGroup
+	err := r.buildQuery(ctx, filter).
+		Select(`error_type, COUNT(*) AS count, COUNT(DISTINCT service_name) AS services,
+			MIN(timestamp) AS first_seen, MAX(timestamp) AS last_seen,
+			(array_agg(id ORDER BY timestamp DESC))[1] AS latest_id,
+			(array_agg(message ORDER BY timestamp DESC))[1] AS latest_message`).
+		Where("error_type IS NOT NULL AND error_type <> ''").
+		Group("error_type").
+		Order("count DESC, error_type").
+		Limit(limit).
+		Scan(&groups).Error
+	return groups, err
+}
+
+// RequestDurations computes, for each request with at least two entries
+// matching the filter, the time from its first to its last entry. It returns
+// the distribution over all of them and the slowest, up to limit, taking at
+// least minDurationMs.
+func (r *LogRepository) RequestDurations(ctx context.Context, filter models.LogFilter, minDurationMs float64, limit int) (*models.RequestDurations, error) {
+	// The session lets both queries below reuse the filtered base
+	base := r.buildQuery(ctx, filter).Session(&gorm.Session{})
+	requests := func() *gorm.DB {
+		return base.
+			Select(`request_id, MIN(timestamp) AS start, MAX(timestamp) AS "end",
+				EXTRACT(EPOCH FROM MAX(timestamp) - MIN(timestamp)) * 1000 AS duration_ms,
+				COUNT(*) AS entries`).
+			Where("request_id IS NOT NULL AND request_id <> ''").
+			Group("request_id").
+			Having("COUNT(*) > 1")
+	}
+
+	var distribution struct {
+		Requests int64
+		P50      float64
+		P90      float64
+		P99      float64
+		Max      float64
+	}
+	err := r.reader(ctx).WithContext(ctx).Table("(?) AS requests", requests()).
+		Select(`COUNT(*) AS requests,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms), 0) AS p50,
+			COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY duration_ms), 0) AS p90,
+			COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms), 0) AS p99,
+			COALESCE(MAX(duration_ms), 0) AS max`).
+		Scan(&distribution).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RequestDurations{
+		Requests: distribution.Requests,
+		P50Ms:    distribution.P50,
+		P90Ms:    distribution.P90,
+		P99Ms:    distribution.P99,
+		MaxMs:    distribution.Max,
+	}
+	err = r.reader(ctx).WithContext(ctx).Table("(?) AS requests", requests()).
+		Where("duration_ms >= ?", minDurationMs).
+		Order("duration_ms DESC, request_id").
+		Limit(limit).
+		Scan(&result.Slowest).Error
+	if err != nil {
+		return nil, err
+	}
+	if result.Slowest == nil {
+		result.Slowest = []models.RequestDuration{}
+	}
+	return result, nil
+}
+
+// bucketExpression returns the SQL truncating timestamps to interval,
+// defaulting to hours
+func bucketExpression(interval string) string {
+	return truncExpression(interval, "timestamp")
+}
+
+// truncExpression returns the SQL truncating column to interval, defaulting
+// to hours
+func truncExpression(interval, column string) string {
 	switch interval {
 	case "minute":
-		bucketExpr = "date_trunc('minute', timestamp)"
-	case "hour":
-		bucketExpr = "date_trunc('hour', timestamp)"
+		return fmt.Sprintf("date_trunc('minute', %s)", column)
 	case "day":
-		bucketExpr = "date_trunc('day', timestamp)"
+		return fmt.Sprintf("date_trunc('day', %s)", column)
 	default:
-		bucketExpr = "date_trunc('hour', timestamp)"
+		return fmt.Sprintf("date_trunc('hour', %s)", column)
 	}
+}
 
-	query := r.buildQuery(filter)
+// aggregate buckets the rows of a filtered query by interval
+func (r *LogRepository) aggregate(query *gorm.DB, interval string) ([]models.LogAggregation, error) {
+	bucketExpr := bucketExpression(interval)
 
 	var results []struct {
 		Bucket time.Time
@@ -244,22 +785,131 @@ func (r *LogRepository) Aggregate(ctx context.Context, filter models.LogFilter,
 	return aggregations, nil
 }
 
-// DeleteOlderThan removes log entries older than the specified time
-func (r *LogRepository) DeleteOlderThan(ctx context.Context, tenantID *uuid.UUID, before time.Time) (int64, error) {
+// CountDistinctTraces counts distinct non-empty trace IDs matching the filter
+func (r *LogRepository) CountDistinctTraces(ctx context.Context, filter models.LogFilter) (int64, error) {
+	var count int64
+	err := r.buildQuery(ctx, filter).
+		Where("trace_id IS NOT NULL AND trace_id <> ''").
+		Distinct("trace_id").
+		Count(&count).Error
+	return count, err
+}
+
+// AggregateValue reduces logs matching the filter to a single value. Numeric
+// aggregations read the given metadata key, ignoring non-numeric values.
+func (r *LogRepository) AggregateValue(ctx context.Context, filter models.LogFilter, aggregation models.MetricAggregation, field string) (float64, error) {
+	query := r.buildQuery(ctx, filter)
+
+	var value float64
+	switch aggregation {
+	case models.MetricAggregationSum, models.MetricAggregationAvg,
+		models.MetricAggregationMin, models.MetricAggregationMax:
+		fn := strings.ToUpper(string(aggregation))
+		err := query.
+			Select(fmt.Sprintf("COALESCE(%s((metadata->>?)::numeric), 0)", fn), field).
+			Where("jsonb_typeof(metadata->?) = 'number'", field).
+			Scan(&value).Error
+		return value, err
+	default:
+		err := query.Select("COUNT(*)").Scan(&value).Error
+		return value, err
+	}
+}
+
+// Histogram counts matching entries per width_bucket of a numeric metadata
+// field over [min, max) split into buckets ranges. Bucket 0 holds values
+// below min and bucket buckets+1 values at or above max. missing counts
+// entries whose field is absent or not a number.
+func (r *LogRepository) Histogram(ctx context.Context, filter models.LogFilter, field string, min, max float64, buckets int) (map[int]int64, int64, error) {
+	query := r.buildQuery(ctx, filter).Session(&gorm.Session{})
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	err := query.
+		Select("width_bucket((metadata->>?)::numeric, ?::numeric, ?::numeric, ?) AS bucket, COUNT(*) AS count",
+			field, min, max, buckets).
+		Where("jsonb_typeof(metadata->?) = 'number'", field).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var missing int64
+	err = query.
+		Where("jsonb_typeof(metadata->?) IS DISTINCT FROM 'number'", field).
+		Count(&missing).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	counts := make(map[int]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Bucket] = row.Count
+	}
+	return counts, missing, nil
+}
+
+// DeleteOlderThan removes log entries older than the specified time,
+// leaving entries in any of the excluded retention tiers untouched
+func (r *LogRepository) DeleteOlderThan(ctx context.Context, tenantID *uuid.UUID, before time.Time, excludeTiers []string) (int64, error) {
 	query := r.db.WithContext(ctx).Where("timestamp < ?", before)
 
 	if tenantID != nil {
 		query = query.Where("tenant_id = ?", tenantID)
 	}
 
+	if len(excludeTiers) > 0 {
+		query = query.Where("retention_tier IS NULL OR retention_tier NOT IN ?", excludeTiers)
+	}
+
 	result := query.Delete(&models.LogEntry{})
 	return result.RowsAffected, result.Error
 }
 
+// DeleteByService removes all entries of a service, scoped to a tenant when given
+func (r *LogRepository) DeleteByService(ctx context.Context, tenantID *uuid.UUID, serviceName string) (int64, error) {
+	query := r.db.WithContext(ctx).Where("service_name = ?", serviceName)
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", *tenantID)
+	}
+	result := query.Delete(&models.LogEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByIDs removes the entries with the given IDs
+func (r *LogRepository) DeleteByIDs(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&models.LogEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// Analyze refreshes the planner statistics of log_entries, first reclaiming
+// dead tuples when vacuum is set. VACUUM cannot run inside a transaction.
+func (r *LogRepository) Analyze(ctx context.Context, vacuum bool) error {
+	statement := "ANALYZE " + models.LogEntry{}.TableName()
+	if vacuum {
+		statement = "VACUUM (ANALYZE) " + models.LogEntry{}.TableName()
+	}
+	return r.db.WithContext(ctx).Exec(statement).Error
+}
+
+// DeleteOlderThanInTier removes entries of a retention tier older than the specified time
+func (r *LogRepository) DeleteOlderThanInTier(ctx context.Context, tier string, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("retention_tier = ? AND timestamp < ?", tier, before).
+		Delete(&models.LogEntry{})
+	return result.RowsAffected, result.Error
+}
+
 // GetByTraceID retrieves all log entries for a trace
 func (r *LogRepository) GetByTraceID(ctx context.Context, traceID string) ([]models.LogEntry, error) {
 	var entries []models.LogEntry
-	err := r.db.WithContext(ctx).
+	err := r.reader(ctx).WithContext(ctx).
 		Where("trace_id = ?", traceID).
 		Order("timestamp ASC").
 		Find(&entries).Error
@@ -269,17 +919,37 @@ func (r *LogRepository) GetByTraceID(ctx context.Context, traceID string) ([]mod
 // GetByRequestID retrieves all log entries for a request
 func (r *LogRepository) GetByRequestID(ctx context.Context, requestID string) ([]models.LogEntry, error) {
 	var entries []models.LogEntry
-	err := r.db.WithContext(ctx).
+	err := r.reader(ctx).WithContext(ctx).
 		Where("request_id = ?", requestID).
 		Order("timestamp ASC").
 		Find(&entries).Error
 	return entries, err
 }
 
+// GetByRequestIDs retrieves all log entries for a group of requests
+func (r *LogRepository) GetByRequestIDs(ctx context.Context, requestIDs []string) ([]models.LogEntry, error) {
+	var entries []models.LogEntry
+	err := r.reader(ctx).WithContext(ctx).
+		Where("request_id IN ?", requestIDs).
+		Order("timestamp ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// FindOrdered retrieves up to limit entries matching the filter, oldest first
+func (r *LogRepository) FindOrdered(ctx context.Context, filter models.LogFilter, limit int) ([]models.LogEntry, error) {
+	var entries []models.LogEntry
+	err := r.buildQuery(ctx, filter).
+		Order("timestamp ASC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
+
 // GetServices returns distinct service names
 func (r *LogRepository) GetServices(ctx context.Context, tenantID *uuid.UUID) ([]string, error) {
 	var services []string
-	query := r.db.WithContext(ctx).Model(&models.LogEntry{}).
+	query := r.reader(ctx).WithContext(ctx).Model(&models.LogEntry{}).
 		Distinct("service_name")
 
 	if tenantID != nil {
@@ -290,6 +960,70 @@ func (r *LogRepository) GetServices(ctx context.Context, tenantID *uuid.UUID) ([
 	return services, err
 }
 
+// GetSources returns distinct non-empty entry sources
+func (r *LogRepository) GetSources(ctx context.Context, tenantID *uuid.UUID) ([]string, error) {
+	var sources []string
+	query := r.reader(ctx).WithContext(ctx).Model(&models.LogEntry{}).
+		Distinct("source").
+		Where("source <> ''")
+
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	err := query.Order("source").Pluck("source", &sources).Error
+	return sources, err
+}
+
+// Suggest returns up to limit distinct values of column starting with prefix,
+// in order. The column must come from a fixed allowlist, never from input.
+func (r *LogRepository) Suggest(ctx context.Context, tenantID *uuid.UUID, column, prefix string, limit int) ([]string, error) {
+	var values []string
+	query := r.reader(ctx).WithContext(ctx).Model(&models.LogEntry{}).
+		Distinct(column).
+		Where(column+" LIKE ?", likeEscaper.Replace(prefix)+"%").
+		Where(column + " <> ''")
+
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	err := query.Order(column).Limit(limit).Pluck(column, &values).Error
+	return values, err
+}
+
+// GetServiceEdges returns the earliest (or latest) log entry per service,
+// fetched in a single query
+func (r *LogRepository) GetServiceEdges(ctx context.Context, tenantID *uuid.UUID, latest bool) ([]models.ServiceLogEdge, error) {
+	order := "timestamp ASC, id ASC"
+	if latest {
+		order = "timestamp DESC, id DESC"
+	}
+
+	query := r.reader(ctx).WithContext(ctx).Model(&models.LogEntry{}).
+		Select("DISTINCT ON (service_name) *")
+
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	var entries []models.LogEntry
+	if err := query.Order("service_name").Order(order).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	edges := make([]models.ServiceLogEdge, len(entries))
+	for i := range entries {
+		edges[i] = models.ServiceLogEdge{
+			ServiceName: entries[i].ServiceName,
+			Timestamp:   entries[i].Timestamp,
+			Entry:       &entries[i],
+		}
+	}
+
+	return edges, nil
+}
+
 // GetStorageSize returns approximate storage size in bytes
 func (r *LogRepository) GetStorageSize(ctx context.Context, tenantID *uuid.UUID) (int64, error) {
 	var size int64
@@ -311,3 +1045,71 @@ func (r *LogRepository) GetStorageSize(ctx context.Context, tenantID *uuid.UUID)
 	err := r.db.Raw(query).Scan(&size).Error
 	return size, err
 }
+
+// AgeDistribution returns, per tenant, the oldest entry timestamp, the entry
+// count and the counts by models.AgeBucketDays age bucket relative to now.
+// Entries timestamped after now count toward the youngest bucket.
+func (r *LogRepository) AgeDistribution(ctx context.Context, tenantID *uuid.UUID, now time.Time) ([]models.RetentionPreview, error) {
+	scoped := func() *gorm.DB {
+		query := r.reader(ctx).WithContext(ctx).Model(&models.LogEntry{})
+		if tenantID != nil {
+			query = query.Where("tenant_id = ?", tenantID)
+		}
+		return query
+	}
+
+	var totals []struct {
+		TenantID        uuid.UUID
+		OldestTimestamp time.Time
+		TotalCount      int64
+	}
+	err := scoped().
+		Select("tenant_id, MIN(timestamp) as oldest_timestamp, COUNT(*) as total_count").
+		Group("tenant_id").
+		Order("tenant_id").
+		Scan(&totals).Error
+	if err != nil || len(totals) == 0 {
+		return nil, err
+	}
+
+	// Bucket i holds entries newer than now minus AgeBucketDays[i] days
+	var expr strings.Builder
+	args := make([]interface{}, 0, len(models.AgeBucketDays))
+	expr.WriteString("CASE")
+	for i, days := range models.AgeBucketDays {
+		fmt.Fprintf(&expr, " WHEN timestamp > ? THEN %d", i)
+		args = append(args, now.AddDate(0, 0, -days))
+	}
+	fmt.Fprintf(&expr, " ELSE %d END", len(models.AgeBucketDays))
+
+	var counts []struct {
+		TenantID uuid.UUID
+		Bucket   int
+		Count    int64
+	}
+	err = scoped().
+		Select("tenant_id, "+expr.String()+" as bucket, COUNT(*) as count", args...).
+		Group("tenant_id, bucket").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make([]models.RetentionPreview, len(totals))
+	index := make(map[uuid.UUID]int, len(totals))
+	for i, total := range totals {
+		previews[i] = models.RetentionPreview{
+			TenantID:        total.TenantID,
+			OldestTimestamp: total.OldestTimestamp,
+			TotalCount:      total.TotalCount,
+			AgeBuckets:      models.NewAgeBuckets(),
+		}
+		index[total.TenantID] = i
+	}
+	for _, count := range counts {
+		if i, ok := index[count.TenantID]; ok {
+			previews[i].AgeBuckets[count.Bucket].Count = count.Count
+		}
+	}
+	return previews, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MetricRepository handles metric rule and series persistence
+type MetricRepository struct {
+	db *gorm.DB
+}
+
+// NewMetricRepository creates a new metric repository
+func NewMetricRepository(db *gorm.DB) *MetricRepository {
+	return &MetricRepository{db: db}
+}
+
+// Create inserts a new metric rule
+func (r *MetricRepository) Create(ctx context.Context, rule *models.MetricRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+// Update updates a metric rule
+func (r *MetricRepository) Update(ctx context.Context, rule *models.MetricRule) error {
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+// FindByID retrieves a tenant's metric rule by ID
+func (r *MetricRepository) FindByID(ctx context.Context, tenantID, id uuid.UUID) (*models.MetricRule, error) {
+	var rule models.MetricRule
+	err := r.db.WithContext(ctx).First(&rule, "id = ? AND tenant_id = ?", id, tenantID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// FindByTenantID retrieves all metric rules for a tenant
+func (r *MetricRepository) FindByTenantID(ctx context.Context, tenantID uuid.UUID) ([]models.MetricRule, error) {
+	var rules []models.MetricRule
+	err := r.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Find(&rules).Error
+	return rules, err
+}
+
+// FindEnabled retrieves all enabled metric rules
+func (r *MetricRepository) FindEnabled(ctx context.Context) ([]models.MetricRule, error) {
+	var rules []models.MetricRule
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Find(&rules).Error
+	return rules, err
+}
+
+// Delete removes a tenant's metric rule and its series, returning
+// gorm.ErrRecordNotFound when the tenant has no such rule
+func (r *MetricRepository) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.MetricRule{}, "id = ? AND tenant_id = ?", id, tenantID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&models.MetricPoint{}, "rule_id = ?", id).Error
+	})
+}
+
+// UpdateLastEvaluated records the end of the last evaluated bucket
+func (r *MetricRepository) UpdateLastEvaluated(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.MetricRule{}).
+		Where("id = ?", id).
+		Update("last_evaluated", at).Error
+}
+
+// SavePoint inserts or replaces a series value
+func (r *MetricRepository) SavePoint(ctx context.Context, point *models.MetricPoint) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "rule_id"}, {Name: "bucket"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value"}),
+		}).
+		Create(point).Error
+}
+
+// DeletePointsOlderThan removes series values in buckets before the cutoff
+func (r *MetricRepository) DeletePointsOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("bucket < ?", before).
+		Delete(&models.MetricPoint{})
+	return result.RowsAffected, result.Error
+}
+
+// FindSeries retrieves series values for a rule within a time range
+func (r *MetricRepository) FindSeries(ctx context.Context, ruleID uuid.UUID, start, end time.Time) ([]models.MetricPoint, error) {
+	var points []models.MetricPoint
+	err := r.db.WithContext(ctx).
+		Where("rule_id = ? AND bucket >= ? AND bucket <= ?", ruleID, start, end).
+		Order("bucket").
+		Find(&points).Error
+	return points, err
+}
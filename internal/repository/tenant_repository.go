@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TenantRepository handles per-tenant settings persistence
+type TenantRepository struct {
+	db *gorm.DB
+}
+
+// NewTenantRepository creates a new tenant repository
+func NewTenantRepository(db *gorm.DB) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+// FindSettings retrieves settings for a tenant
+func (r *TenantRepository) FindSettings(ctx context.Context, tenantID uuid.UUID) (*models.TenantSettings, error) {
+	var settings models.TenantSettings
+	err := r.db.WithContext(ctx).First(&settings, "tenant_id = ?", tenantID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// FindAllSettings retrieves settings for all tenants
+func (r *TenantRepository) FindAllSettings(ctx context.Context) ([]models.TenantSettings, error) {
+	var settings []models.TenantSettings
+	err := r.db.WithContext(ctx).Find(&settings).Error
+	return settings, err
+}
+
+// UpsertSettings creates or replaces settings for a tenant
+func (r *TenantRepository) UpsertSettings(ctx context.Context, settings *models.TenantSettings) error {
+	columns, err := r.updatableColumns(settings)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}},
+			DoUpdates: clause.AssignmentColumns(columns),
+		}).
+		Create(settings).Error
+}
+
+// DeleteSettings removes settings for a tenant
+func (r *TenantRepository) DeleteSettings(ctx context.Context, tenantID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.TenantSettings{}, "tenant_id = ?", tenantID).Error
+}
+
+// FindAlertSettings retrieves alert defaults for a tenant
+func (r *TenantRepository) FindAlertSettings(ctx context.Context, tenantID uuid.UUID) (*models.TenantAlertSettings, error) {
+	var settings models.TenantAlertSettings
+	err := r.db.WithContext(ctx).First(&settings, "tenant_id = ?", tenantID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpsertAlertSettings creates or replaces alert defaults for a tenant
+func (r *TenantRepository) UpsertAlertSettings(ctx context.Context, settings *models.TenantAlertSettings) error {
+	columns, err := r.updatableColumns(settings)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}},
+			DoUpdates: clause.AssignmentColumns(columns),
+		}).
+		Create(settings).Error
+}
+
+// DeleteAlertSettings removes alert defaults for a tenant
+func (r *TenantRepository) DeleteAlertSettings(ctx context.Context, tenantID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.TenantAlertSettings{}, "tenant_id = ?", tenantID).Error
+}
+
+// updatableColumns lists the columns an upsert of model replaces: all but the
+// tenant key and created_at, which keeps the time of the first insert
+func (r *TenantRepository) updatableColumns(model interface{}) ([]string, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		if name != "tenant_id" && name != "created_at" {
+			columns = append(columns, name)
+		}
+	}
+	return columns, nil
+}
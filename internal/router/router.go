@@ -5,35 +5,87 @@ import (
 	"github.com/minisource/log/internal/handler"
 )
 
+// TenantDataPrefixes are the route prefixes serving tenant log, alert and
+// metric data, which REQUIRE_TENANT guards
+var TenantDataPrefixes = []string{
+	"/api/v1/logs",
+	"/api/v1/alerts",
+	"/api/v1/metrics",
+	"/loki",
+	"/_bulk",
+}
+
 // SetupRoutes configures all API routes
 func SetupRoutes(
 	app *fiber.App,
 	logHandler *handler.LogHandler,
 	retentionHandler *handler.RetentionHandler,
 	alertHandler *handler.AlertHandler,
+	metricHandler *handler.MetricHandler,
+	tenantHandler *handler.TenantHandler,
+	replayHandler *handler.ReplayHandler,
+	importHandler *handler.ImportHandler,
+	archiveHandler *handler.ArchiveHandler,
+	adminHandler *handler.AdminHandler,
+	compatHandler *handler.CompatHandler,
 	healthHandler *handler.HealthHandler,
 ) {
 	// Health endpoints
 	app.Get("/health", healthHandler.Health)
 	app.Get("/ready", healthHandler.Ready)
 	app.Get("/live", healthHandler.Live)
+	app.Get("/metrics", healthHandler.Metrics)
+
+	// Loki-compatible endpoints, at the paths shippers expect
+	loki := app.Group("/loki/api/v1")
+	loki.Post("/push", compatHandler.LokiPush)
+	loki.Get("/query_range", compatHandler.LokiQueryRange)
+
+	// Elasticsearch-compatible bulk ingestion
+	app.Post("/_bulk", compatHandler.ESBulk)
 
 	// API v1
 	api := app.Group("/api/v1")
 
 	// Log endpoints
 	logs := api.Group("/logs")
+	// Registered before GET, which also answers HEAD
+	logs.Head("/", logHandler.Count)
 	logs.Get("/", logHandler.List)
 	logs.Post("/", logHandler.IngestSingle)
 	logs.Post("/batch", logHandler.IngestBatch)
+	logs.Post("/batch/stream", logHandler.IngestBatchStream)
+	logs.Post("/async", logHandler.IngestAsync)
+	logs.Post("/otlp", compatHandler.OTLPLogs)
+	logs.Post("/import/s3", importHandler.ImportS3)
+	logs.Post("/archive/s3", archiveHandler.ArchiveS3)
 	logs.Post("/query", logHandler.Query)
 	logs.Get("/stats", logHandler.GetStats)
 	logs.Post("/aggregate", logHandler.Aggregate)
+	logs.Post("/search-with-agg", logHandler.SearchWithAggregation)
 	logs.Get("/services", logHandler.GetServices)
+	logs.Get("/sources", logHandler.GetSources)
+	logs.Get("/suggest", logHandler.GetSuggestions)
 	logs.Get("/storage", logHandler.GetStorage)
+	logs.Get("/retention/preview", retentionHandler.Preview)
+	logs.Get("/volume-forecast", logHandler.GetVolumeForecast)
+	logs.Get("/histogram", logHandler.GetHistogram)
+	logs.Get("/request-durations", logHandler.GetRequestDurations)
+	logs.Get("/errors", logHandler.GetErrorGroups)
+	logs.Get("/export", logHandler.Export)
+	logs.Get("/first", logHandler.GetFirst)
+	logs.Get("/last", logHandler.GetLast)
+	logs.Get("/affected-traces", logHandler.GetAffectedTraces)
+	logs.Get("/timeline", logHandler.GetTimeline)
+	logs.Get("/preset/:name", logHandler.GetPreset)
 	logs.Get("/stream", logHandler.Stream)
 	logs.Get("/trace/:trace_id", logHandler.GetByTrace)
+	logs.Get("/trace/:trace_id/export", logHandler.ExportTrace)
 	logs.Get("/request/:request_id", logHandler.GetByRequest)
+	logs.Post("/replay", replayHandler.StartReplay)
+	logs.Get("/replay/:job_id", replayHandler.GetReplay)
+	logs.Post("/replay/:job_id/pause", replayHandler.PauseReplay)
+	logs.Post("/replay/:job_id/resume", replayHandler.ResumeReplay)
 	logs.Get("/:id", logHandler.GetByID)
 
 	// Retention policy endpoints
@@ -48,9 +100,38 @@ func SetupRoutes(
 	alerts := api.Group("/alerts")
 	alerts.Get("/", alertHandler.ListAlerts)
 	alerts.Post("/", alertHandler.CreateAlert)
+	alerts.Post("/bulk", alertHandler.BulkAlerts)
 	alerts.Get("/:id", alertHandler.GetAlert)
 	alerts.Put("/:id", alertHandler.UpdateAlert)
 	alerts.Delete("/:id", alertHandler.DeleteAlert)
 	alerts.Post("/:id/enable", alertHandler.EnableAlert)
 	alerts.Post("/:id/disable", alertHandler.DisableAlert)
+	alerts.Get("/:id/events", alertHandler.GetAlertEvents)
+
+	// Metric rule endpoints
+	metrics := api.Group("/metrics")
+	metrics.Get("/rules", metricHandler.ListRules)
+	metrics.Post("/rules", metricHandler.CreateRule)
+	metrics.Get("/rules/:id", metricHandler.GetRule)
+	metrics.Put("/rules/:id", metricHandler.UpdateRule)
+	metrics.Delete("/rules/:id", metricHandler.DeleteRule)
+	metrics.Get("/rules/:id/series", metricHandler.GetSeries)
+
+	// Tenant settings endpoints
+	tenants := api.Group("/tenants")
+	tenants.Get("/settings", tenantHandler.ListSettings)
+	tenants.Get("/:tenant_id/settings", tenantHandler.GetSettings)
+	tenants.Put("/:tenant_id/settings", tenantHandler.UpsertSettings)
+	tenants.Delete("/:tenant_id/settings", tenantHandler.DeleteSettings)
+	tenants.Get("/:tenant_id/alert-settings", tenantHandler.GetAlertSettings)
+	tenants.Put("/:tenant_id/alert-settings", tenantHandler.UpsertAlertSettings)
+	tenants.Delete("/:tenant_id/alert-settings", tenantHandler.DeleteAlertSettings)
+
+	// Admin endpoints
+	admin := api.Group("/admin")
+	admin.Delete("/logs", adminHandler.PurgeService)
+	admin.Post("/selftest", adminHandler.SelfTest)
+	admin.Get("/retention/preview", retentionHandler.Preview)
+	admin.Get("/maintenance", adminHandler.GetMaintenance)
+	admin.Put("/maintenance", adminHandler.SetMaintenance)
 }
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// ErrInvalidAlertSettings is returned for malformed tenant alert defaults
+var ErrInvalidAlertSettings = errors.New("invalid alert settings")
+
+// GetAlertSettings retrieves alert defaults for a tenant
+func (s *TenantService) GetAlertSettings(ctx context.Context, tenantID uuid.UUID) (*models.TenantAlertSettings, error) {
+	return s.repo.FindAlertSettings(ctx, tenantID)
+}
+
+// UpsertAlertSettings creates or replaces alert defaults for a tenant
+func (s *TenantService) UpsertAlertSettings(ctx context.Context, settings *models.TenantAlertSettings) error {
+	switch settings.ChannelMerge {
+	case "":
+		settings.ChannelMerge = models.AlertChannelMergeFallback
+	case models.AlertChannelMergeFallback, models.AlertChannelMergeUnion:
+	default:
+		return fmt.Errorf("%w: unknown channel_merge %q", ErrInvalidAlertSettings, settings.ChannelMerge)
+	}
+	if settings.DigestIntervalMins < 1 {
+		settings.DigestIntervalMins = 15
+	}
+	if len(settings.DefaultChannels) > 0 {
+		var channels []json.RawMessage
+		if err := json.Unmarshal(settings.DefaultChannels, &channels); err != nil {
+			return fmt.Errorf("%w: default_channels must be an array", ErrInvalidAlertSettings)
+		}
+	}
+	if err := validateSeverityRoutes(settings.SeverityRoutes); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAlertSettings, err)
+	}
+	return s.repo.UpsertAlertSettings(ctx, settings)
+}
+
+// DeleteAlertSettings removes alert defaults for a tenant
+func (s *TenantService) DeleteAlertSettings(ctx context.Context, tenantID uuid.UUID) error {
+	return s.repo.DeleteAlertSettings(ctx, tenantID)
+}
+
+// ResolveChannels returns the channels an alert notifies at trigger time,
+// applying the tenant's default channels according to its merge strategy and
+// then routing by severity
+func (s *TenantService) ResolveChannels(ctx context.Context, alert models.LogAlert) json.RawMessage {
+	var settings *models.TenantAlertSettings
+	if s != nil {
+		if found, err := s.repo.FindAlertSettings(ctx, alert.TenantID); err == nil {
+			settings = found
+		}
+	}
+
+	channels := alert.Channels
+	if settings != nil && len(settings.DefaultChannels) > 0 {
+		switch {
+		case len(channelList(alert.Channels)) == 0:
+			channels = settings.DefaultChannels
+		case settings.ChannelMerge == models.AlertChannelMergeUnion:
+			channels = mergeChannels(alert.Channels, settings.DefaultChannels)
+		}
+	}
+
+	routes := alert.SeverityRoutes
+	if len(routes) == 0 && settings != nil {
+		routes = settings.SeverityRoutes
+	}
+	return routeChannels(channels, routes, alert.Severity)
+}
+
+// DigestInterval returns the tenant's alert digest interval, or false when
+// digesting is disabled
+func (s *TenantService) DigestInterval(ctx context.Context, tenantID uuid.UUID) (time.Duration, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	settings, err := s.repo.FindAlertSettings(ctx, tenantID)
+	if err != nil || !settings.DigestEnabled {
+		return 0, false
+	}
+
+	mins := settings.DigestIntervalMins
+	if mins < 1 {
+		mins = 15
+	}
+	return time.Duration(mins) * time.Minute, true
+}
+
+// mergeChannels concatenates channel arrays, dropping duplicates
+func mergeChannels(lists ...json.RawMessage) json.RawMessage {
+	seen := make(map[string]bool)
+	merged := make([]json.RawMessage, 0)
+	for _, list := range lists {
+		for _, channel := range channelList(list) {
+			if key := string(channel); !seen[key] {
+				seen[key] = true
+				merged = append(merged, channel)
+			}
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// channelList decodes a JSON array of channels, treating anything else as empty
+func channelList(raw json.RawMessage) []json.RawMessage {
+	var channels []json.RawMessage
+	if len(raw) == 0 || json.Unmarshal(raw, &channels) != nil {
+		return nil
+	}
+	return channels
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// pendingDigest collects a tenant's notifications until its interval elapses
+type pendingDigest struct {
+	since time.Time
+	items []models.AlertNotification
+}
+
+// alertDigests holds pending digests per tenant
+type alertDigests struct {
+	mu      sync.Mutex
+	pending map[uuid.UUID]*pendingDigest
+}
+
+// add queues a notification for the tenant's next digest
+func (d *alertDigests) add(n models.AlertNotification, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pending == nil {
+		d.pending = make(map[uuid.UUID]*pendingDigest)
+	}
+	digest, ok := d.pending[n.TenantID]
+	if !ok {
+		digest = &pendingDigest{since: now}
+		d.pending[n.TenantID] = digest
+	}
+	digest.items = append(digest.items, n)
+}
+
+// take removes and returns the pending digests accepted by due
+func (d *alertDigests) take(due func(tenantID uuid.UUID, since time.Time) bool) map[uuid.UUID]*pendingDigest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ready := make(map[uuid.UUID]*pendingDigest)
+	for tenantID, digest := range d.pending {
+		if due(tenantID, digest.since) {
+			ready[tenantID] = digest
+			delete(d.pending, tenantID)
+		}
+	}
+	return ready
+}
+
+// FlushAlertDigests sends one summary notification per tenant whose digest
+// interval has elapsed since its first collected notification
+func (s *LogService) FlushAlertDigests(ctx context.Context, now time.Time) {
+	ready := s.digests.take(func(tenantID uuid.UUID, since time.Time) bool {
+		interval, enabled := s.tenants.DigestInterval(ctx, tenantID)
+		// Digests of tenants that turned digesting off are sent right away
+		return !enabled || !now.Before(since.Add(interval))
+	})
+
+	for tenantID, digest := range ready {
+		var channels []json.RawMessage
+		var count int64
+		for _, item := range digest.items {
+			channels = append(channels, item.Channels)
+			count += item.Count
+		}
+
+		s.send(ctx, models.AlertNotification{
+			Type:     models.AlertNotificationDigest,
+			TenantID: tenantID,
+			Channels: mergeChannels(channels...),
+			Count:    count,
+			FiredAt:  digest.since,
+			Digest:   digest.items,
+		})
+	}
+}
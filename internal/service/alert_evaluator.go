@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// SetNotifier replaces the notifier used for alert notifications
+func (s *LogService) SetNotifier(n Notifier) {
+	s.notifier = n
+}
+
+// checkAlerts evaluates every enabled alert matched by the given entries
+func (s *LogService) checkAlerts(ctx context.Context, entries []models.LogEntry) {
+	alerts, err := s.alertRepo.FindEnabled(ctx)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	var newErrorAlerts []models.LogAlert
+	for _, alert := range alerts {
+		if alert.Type == models.AlertTypeNewError {
+			newErrorAlerts = append(newErrorAlerts, alert)
+			continue
+		}
+		for _, entry := range entries {
+			if s.matchesAlert(entry, alert) {
+				s.evaluateAlert(ctx, alert, entry.Message, now)
+				break
+			}
+		}
+	}
+	s.checkNewErrors(ctx, newErrorAlerts, entries, now)
+}
+
+// EvaluateAlerts evaluates every enabled alert against its window regardless
+// of ingestion, so slow accumulations fire during quiet periods. Alerts
+// fired by the ingestion path are already firing and skipped; the firing
+// transition admits a single winner, so racing evaluations notify once.
+func (s *LogService) EvaluateAlerts(ctx context.Context, now time.Time) error {
+	alerts, err := s.alertRepo.FindEnabled(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		if alert.Type == models.AlertTypeNewError {
+			continue
+		}
+		s.evaluateAlert(ctx, alert, "", now)
+	}
+	return nil
+}
+
+// matchesAlert checks if a log entry matches an alert filter
+func (s *LogService) matchesAlert(entry models.LogEntry, alert models.LogAlert) bool {
+	// Parse the filter from JSON
+	var filter models.LogFilter
+	if err := json.Unmarshal(alert.Filter, &filter); err != nil {
+		return false
+	}
+
+	if filter.ServiceName != "" && filter.ServiceName != entry.ServiceName {
+		return false
+	}
+
+	if filter.Level != "" && filter.Level != entry.Level {
+		return false
+	}
+
+	if filter.TenantID != nil && *filter.TenantID != entry.TenantID {
+		return false
+	}
+
+	if filter.Environment != "" && filter.Environment != entry.Environment {
+		return false
+	}
+
+	return true
+}
+
+// alertWindowCount counts logs matching the alert filter within its window
+func (s *LogService) alertWindowCount(ctx context.Context, alert models.LogAlert, now time.Time) (int64, error) {
+	var filter models.LogFilter
+	if err := json.Unmarshal(alert.Filter, &filter); err != nil {
+		return 0, err
+	}
+
+	start := now.Add(-time.Duration(alertWindowMins(alert)) * time.Minute)
+	filter.TenantID = &alert.TenantID
+	filter.StartTime = &start
+	filter.EndTime = &now
+
+	return s.logRepo.Count(ctx, filter)
+}
+
+// evaluateAlert fires the alert when the windowed count reaches its threshold
+func (s *LogService) evaluateAlert(ctx context.Context, alert models.LogAlert, message string, now time.Time) {
+	if alert.Firing {
+		return
+	}
+
+	count, err := s.alertWindowCount(ctx, alert, now)
+	if err != nil || count < int64(alertThreshold(alert)) {
+		return
+	}
+
+	// Only one evaluator wins the transition to firing
+	fired, err := s.alertRepo.SetFiring(ctx, alert.ID, true, &now)
+	if err != nil || !fired {
+		return
+	}
+	s.alertRepo.UpdateLastTriggered(ctx, alert.ID)
+
+	s.notify(ctx, models.AlertNotification{
+		Type:       models.AlertNotificationFiring,
+		AlertID:    alert.ID,
+		TenantID:   alert.TenantID,
+		AlertName:  alert.Name,
+		Severity:   alert.Severity,
+		Channels:   s.tenants.ResolveChannels(ctx, alert),
+		Count:      count,
+		Threshold:  alertThreshold(alert),
+		WindowMins: alertWindowMins(alert),
+		Message:    message,
+		FiredAt:    now,
+	})
+}
+
+// CheckAlertRecoveries resolves firing alerts whose windowed count dropped
+// below the threshold, sending a single resolution notification for each
+func (s *LogService) CheckAlertRecoveries(ctx context.Context, now time.Time) error {
+	alerts, err := s.alertRepo.FindFiring(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		count, err := s.alertWindowCount(ctx, alert, now)
+		if err != nil || count >= int64(alertThreshold(alert)) {
+			continue
+		}
+
+		resolved, err := s.alertRepo.SetFiring(ctx, alert.ID, false, nil)
+		if err != nil || !resolved {
+			continue
+		}
+
+		firedAt := now
+		if alert.FiringSince != nil {
+			firedAt = *alert.FiringSince
+		}
+		resolvedAt := now
+
+		s.notify(ctx, models.AlertNotification{
+			Type:        models.AlertNotificationResolved,
+			AlertID:     alert.ID,
+			TenantID:    alert.TenantID,
+			AlertName:   alert.Name,
+			Severity:    alert.Severity,
+			Channels:    s.tenants.ResolveChannels(ctx, alert),
+			Count:       count,
+			Threshold:   alertThreshold(alert),
+			WindowMins:  alertWindowMins(alert),
+			FiredAt:     firedAt,
+			ResolvedAt:  &resolvedAt,
+			DurationSec: int64(now.Sub(firedAt).Seconds()),
+		})
+	}
+
+	return nil
+}
+
+// notify records the alert event and sends the notification, or queues it
+// when the tenant digests its notifications
+func (s *LogService) notify(ctx context.Context, n models.AlertNotification) {
+	event := &models.LogAlertEvent{
+		AlertID:   n.AlertID,
+		TenantID:  n.TenantID,
+		Type:      n.Type,
+		Count:     n.Count,
+		Threshold: n.Threshold,
+		Message:   n.Message,
+	}
+	if err := s.alertRepo.CreateEvent(ctx, event); err != nil {
+		fmt.Printf("Failed to record %s event for alert %s: %v\n", n.Type, n.AlertID, err)
+	}
+
+	if _, digesting := s.tenants.DigestInterval(ctx, n.TenantID); digesting {
+		s.digests.add(n, time.Now().UTC())
+		return
+	}
+
+	s.send(ctx, n)
+}
+
+// alertThreshold returns the alert threshold, treating unset as 1
+func alertThreshold(alert models.LogAlert) int {
+	if alert.Threshold < 1 {
+		return 1
+	}
+	return alert.Threshold
+}
+
+// alertWindowMins returns the alert window, treating unset as 5 minutes
+func alertWindowMins(alert models.LogAlert) int {
+	if alert.WindowMins < 1 {
+		return 5
+	}
+	return alert.WindowMins
+}
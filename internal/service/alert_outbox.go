@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// outboxBatchSize bounds the notifications delivered per drain
+const outboxBatchSize = 100
+
+// maxOutboxBackoff caps the delay between delivery retries
+const maxOutboxBackoff = time.Hour
+
+// send persists a notification to the outbox and attempts delivery right
+// away; failed deliveries are retried by DrainAlertOutbox, so notifications
+// survive restarts and are delivered at least once
+func (s *LogService) send(ctx context.Context, n models.AlertNotification) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		fmt.Printf("Failed to encode %s notification for alert %s: %v\n", n.Type, n.AlertID, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	item := &models.AlertOutboxItem{
+		AlertID:  n.AlertID,
+		TenantID: n.TenantID,
+		Type:     n.Type,
+		Payload:  payload,
+		// Leased to the immediate attempt so a concurrent drain skips it
+		NextAttemptAt: now.Add(s.outboxBackoff(0)),
+	}
+	if err := s.alertRepo.CreateOutboxItem(ctx, item); err != nil {
+		// Without an outbox row, fall back to a single best-effort delivery
+		fmt.Printf("Failed to persist %s notification for alert %s: %v\n", n.Type, n.AlertID, err)
+		if err := s.notifier.Notify(ctx, n); err != nil {
+			fmt.Printf("Failed to send %s notification for alert %s: %v\n", n.Type, n.AlertID, err)
+		}
+		return
+	}
+
+	s.deliver(ctx, *item, n, now)
+}
+
+// DrainAlertOutbox retries due outbox notifications, returning how many were
+// delivered
+func (s *LogService) DrainAlertOutbox(ctx context.Context, now time.Time) (int, error) {
+	items, err := s.alertRepo.FindDueOutboxItems(ctx, now, s.config.Alert.OutboxMaxAttempts, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, item := range items {
+		var n models.AlertNotification
+		if err := json.Unmarshal(item.Payload, &n); err != nil {
+			fmt.Printf("Dropping undecodable outbox notification %s: %v\n", item.ID, err)
+			if err := s.alertRepo.MarkOutboxItemSent(ctx, item.ID, now); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if s.deliver(ctx, item, n, now) {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// deliver sends an outbox notification and records the outcome, returning
+// whether it was delivered
+func (s *LogService) deliver(ctx context.Context, item models.AlertOutboxItem, n models.AlertNotification, now time.Time) bool {
+	if err := s.notifier.Notify(ctx, n); err != nil {
+		attempts := item.Attempts + 1
+		fmt.Printf("Failed to send %s notification for alert %s (attempt %d): %v\n", n.Type, n.AlertID, attempts, err)
+		if max := s.config.Alert.OutboxMaxAttempts; max > 0 && attempts >= max {
+			fmt.Printf("Giving up on %s notification for alert %s after %d attempts\n", n.Type, n.AlertID, attempts)
+		}
+		if err := s.alertRepo.MarkOutboxItemFailed(ctx, item.ID, now.Add(s.outboxBackoff(attempts)), err.Error()); err != nil {
+			fmt.Printf("Failed to reschedule outbox notification %s: %v\n", item.ID, err)
+		}
+		return false
+	}
+
+	if err := s.alertRepo.MarkOutboxItemSent(ctx, item.ID, time.Now().UTC()); err != nil {
+		// The notification will be sent again; delivery is at-least-once
+		fmt.Printf("Failed to mark outbox notification %s sent: %v\n", item.ID, err)
+	}
+	return true
+}
+
+// outboxBackoff returns the delay before the next delivery attempt, doubling
+// the configured backoff per failed attempt
+func (s *LogService) outboxBackoff(attempts int) time.Duration {
+	backoff := s.config.Alert.OutboxRetryBackoff
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+	for i := 0; i < attempts && backoff < maxOutboxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxOutboxBackoff {
+		return maxOutboxBackoff
+	}
+	return backoff
+}
+
+// PruneAlertOutbox removes delivered notifications older than the alert
+// history retention
+func (s *LogService) PruneAlertOutbox(ctx context.Context, now time.Time) (int64, error) {
+	days := s.config.Retention.AlertEventDays
+	if days <= 0 {
+		return 0, nil
+	}
+	return s.alertRepo.DeleteSentOutboxItemsOlderThan(ctx, now.AddDate(0, 0, -days))
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// severityRoutes maps a lowercased alert severity to the channel kinds it
+// notifies
+type severityRoutes map[string][]string
+
+// parseSeverityRoutes decodes a severity routing table such as
+// {"fatal":["pagerduty"],"error":["slack"],"warn":["email"]}
+func parseSeverityRoutes(raw json.RawMessage) (severityRoutes, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var decoded map[string][]string
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, errors.New("severity_routes must map severities to arrays of channel kinds")
+	}
+	routes := make(severityRoutes, len(decoded))
+	for severity, kinds := range decoded {
+		routes[strings.ToLower(severity)] = kinds
+	}
+	return routes, nil
+}
+
+// validateSeverityRoutes rejects a malformed severity routing table
+func validateSeverityRoutes(raw json.RawMessage) error {
+	_, err := parseSeverityRoutes(raw)
+	return err
+}
+
+// routeChannels keeps the channels whose kind is routed for severity. A
+// severity without a route, or an unusable table, notifies every channel.
+func routeChannels(channels, rawRoutes json.RawMessage, severity string) json.RawMessage {
+	routes, err := parseSeverityRoutes(rawRoutes)
+	if err != nil || routes == nil {
+		return channels
+	}
+	kinds, ok := routes[strings.ToLower(severity)]
+	if !ok {
+		return channels
+	}
+
+	routed := make([]json.RawMessage, 0)
+	for _, channel := range channelList(channels) {
+		if containsString(kinds, channelKind(channel)) {
+			routed = append(routed, channel)
+		}
+	}
+	if len(routed) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(routed)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// channelKind returns the kind of a channel: the part of a string channel
+// before the first colon, e.g. slack for "slack:#oncall", or the type field
+// of an object channel
+func channelKind(channel json.RawMessage) string {
+	var name string
+	if json.Unmarshal(channel, &name) == nil {
+		kind, _, _ := strings.Cut(name, ":")
+		return kind
+	}
+
+	var object struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(channel, &object) == nil {
+		return object.Type
+	}
+	return ""
+}
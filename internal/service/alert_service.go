@@ -2,20 +2,46 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/minisource/log/config"
 	"github.com/minisource/log/internal/models"
 	"github.com/minisource/log/internal/repository"
 )
 
+// AlertLimitError is returned when a tenant would exceed its enabled alert cap
+type AlertLimitError struct {
+	Count int64
+	Limit int
+}
+
+func (e *AlertLimitError) Error() string {
+	return fmt.Sprintf("enabled alert limit reached: %d of %d", e.Count, e.Limit)
+}
+
+// ErrInvalidAlertType is returned for an alert type other than threshold or new_error
+var ErrInvalidAlertType = errors.New("invalid alert type")
+
+// ErrInvalidSeverityRoutes is returned for a malformed severity routing table
+var ErrInvalidSeverityRoutes = errors.New("invalid severity routes")
+
+// ErrInvalidBulkRequest is returned for a bulk request with an unknown
+// action or an empty selector
+var ErrInvalidBulkRequest = errors.New("invalid bulk request")
+
 // AlertService handles alert business logic
 type AlertService struct {
-	repo *repository.AlertRepository
+	repo   *repository.AlertRepository
+	config *config.Config
 }
 
 // NewAlertService creates a new alert service
-func NewAlertService(repo *repository.AlertRepository) *AlertService {
-	return &AlertService{repo: repo}
+func NewAlertService(repo *repository.AlertRepository, cfg *config.Config) *AlertService {
+	return &AlertService{repo: repo, config: cfg}
 }
 
 // CreateAlert creates a new alert
@@ -23,14 +49,60 @@ func (s *AlertService) CreateAlert(ctx context.Context, alert *models.LogAlert)
 	if alert.ID == uuid.Nil {
 		alert.ID = uuid.New()
 	}
+	if err := validateAlertType(alert); err != nil {
+		return err
+	}
+	if err := validateSeverityRoutes(alert.SeverityRoutes); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSeverityRoutes, err)
+	}
+	if err := validateAlertFilter(alert); err != nil {
+		return err
+	}
+	// Only enabled alerts count towards the limit
+	if alert.Enabled {
+		if err := s.checkAlertLimit(ctx, alert.TenantID); err != nil {
+			return err
+		}
+	}
 	return s.repo.Create(ctx, alert)
 }
 
 // UpdateAlert updates an alert
 func (s *AlertService) UpdateAlert(ctx context.Context, alert *models.LogAlert) error {
+	if err := validateAlertType(alert); err != nil {
+		return err
+	}
+	if err := validateSeverityRoutes(alert.SeverityRoutes); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSeverityRoutes, err)
+	}
+	if err := validateAlertFilter(alert); err != nil {
+		return err
+	}
 	return s.repo.Update(ctx, alert)
 }
 
+// validateAlertFilter rejects alert filters beyond the Or group limits
+func validateAlertFilter(alert *models.LogAlert) error {
+	var filter models.LogFilter
+	if json.Unmarshal(alert.Filter, &filter) != nil {
+		return nil
+	}
+	return ValidateFilter(filter)
+}
+
+// validateAlertType defaults an unset alert type to threshold and rejects
+// unknown types
+func validateAlertType(alert *models.LogAlert) error {
+	switch alert.Type {
+	case "":
+		alert.Type = models.AlertTypeThreshold
+	case models.AlertTypeThreshold, models.AlertTypeNewError:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidAlertType, alert.Type)
+	}
+	return nil
+}
+
 // GetAlert retrieves an alert by ID
 func (s *AlertService) GetAlert(ctx context.Context, id uuid.UUID) (*models.LogAlert, error) {
 	return s.repo.FindByID(ctx, id)
@@ -41,6 +113,14 @@ func (s *AlertService) GetAlertsByTenant(ctx context.Context, tenantID uuid.UUID
 	return s.repo.FindByTenantID(ctx, tenantID)
 }
 
+// GetAlertEvents retrieves recent firing and resolution events for an alert
+func (s *AlertService) GetAlertEvents(ctx context.Context, id uuid.UUID, limit int) ([]models.LogAlertEvent, error) {
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+	return s.repo.FindEvents(ctx, id, limit)
+}
+
 // DeleteAlert removes an alert
 func (s *AlertService) DeleteAlert(ctx context.Context, id uuid.UUID) error {
 	return s.repo.Delete(ctx, id)
@@ -52,6 +132,11 @@ func (s *AlertService) EnableAlert(ctx context.Context, id uuid.UUID) error {
 	if err != nil {
 		return err
 	}
+	if !alert.Enabled {
+		if err := s.checkAlertLimit(ctx, alert.TenantID); err != nil {
+			return err
+		}
+	}
 	alert.Enabled = true
 	return s.repo.Update(ctx, alert)
 }
@@ -66,7 +151,100 @@ func (s *AlertService) DisableAlert(ctx context.Context, id uuid.UUID) error {
 	return s.repo.Update(ctx, alert)
 }
 
+// BulkAlerts applies an action to every alert of the tenant the selector
+// matches. An empty selector is rejected so a mistake cannot touch every
+// alert; a name pattern of * selects them all. Enabling fails without
+// changes when the tenant would exceed its enabled alert cap.
+func (s *AlertService) BulkAlerts(ctx context.Context, tenantID uuid.UUID, req models.AlertBulkRequest) (*models.AlertBulkResult, error) {
+	switch req.Action {
+	case models.AlertBulkEnable, models.AlertBulkDisable, models.AlertBulkDelete:
+	default:
+		return nil, fmt.Errorf("%w: action must be enable, disable or delete", ErrInvalidBulkRequest)
+	}
+	if req.Selector == (models.AlertSelector{}) {
+		return nil, fmt.Errorf("%w: selector needs a severity, service_name or name_pattern", ErrInvalidBulkRequest)
+	}
+
+	alerts, err := s.repo.FindByTenantID(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	var disabled int64
+	for _, alert := range alerts {
+		if !alertMatchesSelector(alert, req.Selector) {
+			continue
+		}
+		ids = append(ids, alert.ID)
+		if !alert.Enabled {
+			disabled++
+		}
+	}
+
+	result := &models.AlertBulkResult{Action: req.Action, Matched: len(ids)}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	switch req.Action {
+	case models.AlertBulkEnable:
+		if err := s.checkAlertLimitFor(ctx, tenantID, disabled); err != nil {
+			return nil, err
+		}
+		result.Affected, err = s.repo.SetEnabledByIDs(ctx, tenantID, ids, true)
+	case models.AlertBulkDisable:
+		result.Affected, err = s.repo.SetEnabledByIDs(ctx, tenantID, ids, false)
+	case models.AlertBulkDelete:
+		result.Affected, err = s.repo.DeleteByIDs(ctx, tenantID, ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// alertMatchesSelector reports whether an alert matches every set field of
+// the selector
+func alertMatchesSelector(alert models.LogAlert, selector models.AlertSelector) bool {
+	if selector.Severity != "" && !strings.EqualFold(selector.Severity, alert.Severity) {
+		return false
+	}
+	if selector.NamePattern != "" && !matchesGlob(selector.NamePattern, alert.Name) {
+		return false
+	}
+	if selector.ServiceName != "" {
+		var filter models.LogFilter
+		if json.Unmarshal(alert.Filter, &filter) != nil || filter.ServiceName != selector.ServiceName {
+			return false
+		}
+	}
+	return true
+}
+
 // GetEnabledAlerts retrieves all enabled alerts
 func (s *AlertService) GetEnabledAlerts(ctx context.Context) ([]models.LogAlert, error) {
 	return s.repo.FindEnabled(ctx)
 }
+
+// checkAlertLimit ensures the tenant can enable one more alert
+func (s *AlertService) checkAlertLimit(ctx context.Context, tenantID uuid.UUID) error {
+	return s.checkAlertLimitFor(ctx, tenantID, 1)
+}
+
+// checkAlertLimitFor ensures the tenant can enable n more alerts
+func (s *AlertService) checkAlertLimitFor(ctx context.Context, tenantID uuid.UUID, n int64) error {
+	limit := s.config.Alert.MaxPerTenant
+	if limit <= 0 {
+		return nil
+	}
+
+	count, err := s.repo.CountEnabledByTenantID(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if n > 0 && count+n > int64(limit) {
+		return &AlertLimitError{Count: count, Limit: limit}
+	}
+	return nil
+}
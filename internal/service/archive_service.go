@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+)
+
+// archivePageSize is the number of entries read per query while archiving
+const archivePageSize = 1000
+
+// Archive errors
+var (
+	// ErrArchiveNotConfigured is returned when no object store is configured
+	ErrArchiveNotConfigured = errors.New("object storage archival is not configured")
+	// ErrInvalidArchiveRange is returned for empty or inverted ranges
+	ErrInvalidArchiveRange = errors.New("archive end must be after start")
+)
+
+// ArchiveService moves log entries to object storage as gzipped NDJSON, one
+// object per tenant, day and run, in the format the import service reads back
+type ArchiveService struct {
+	logRepo       *repository.LogRepository
+	store         ObjectWriter
+	prefix        string
+	workers       int
+	inMaintenance func() bool
+}
+
+// NewArchiveService creates an archive service archiving up to workers days
+// concurrently; a nil store disables archival
+func NewArchiveService(logRepo *repository.LogRepository, store ObjectWriter, prefix string, workers int) *ArchiveService {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ArchiveService{logRepo: logRepo, store: store, prefix: prefix, workers: workers}
+}
+
+// SetMaintenanceCheck sets the check archival consults before deleting
+// entries; while it reports true, Archive fails with ErrMaintenanceMode
+func (s *ArchiveService) SetMaintenanceCheck(inMaintenance func() bool) {
+	s.inMaintenance = inMaintenance
+}
+
+// Enabled reports whether an object store is configured
+func (s *ArchiveService) Enabled() bool {
+	return s.store != nil
+}
+
+// Archive uploads a tenant's entries in [start, end) split into UTC days,
+// archiving days concurrently. A day's entries are deleted only after its
+// upload succeeded; a failed day keeps its entries and is reported in its
+// chunk without stopping the others.
+func (s *ArchiveService) Archive(ctx context.Context, tenantID uuid.UUID, start, end time.Time) (models.ArchiveSummary, error) {
+	var summary models.ArchiveSummary
+	if s.store == nil {
+		return summary, ErrArchiveNotConfigured
+	}
+	if !end.After(start) {
+		return summary, ErrInvalidArchiveRange
+	}
+	if s.inMaintenance != nil && s.inMaintenance() {
+		return summary, ErrMaintenanceMode
+	}
+
+	summary.Chunks = archiveChunks(start.UTC(), end.UTC())
+
+	sem := make(chan struct{}, s.workers)
+	var wg sync.WaitGroup
+	for i := range summary.Chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk *models.ArchiveChunk) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.archiveChunk(ctx, tenantID, chunk)
+		}(&summary.Chunks[i])
+	}
+	wg.Wait()
+
+	for _, chunk := range summary.Chunks {
+		summary.Archived += chunk.Archived
+		summary.Deleted += chunk.Deleted
+		if chunk.Error != "" {
+			summary.Failed++
+		}
+	}
+	return summary, nil
+}
+
+// archiveChunks splits [start, end) at UTC day boundaries
+func archiveChunks(start, end time.Time) []models.ArchiveChunk {
+	var chunks []models.ArchiveChunk
+	for chunkStart := start; chunkStart.Before(end); {
+		chunkEnd := chunkStart.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		chunks = append(chunks, models.ArchiveChunk{Start: chunkStart, End: chunkEnd})
+		chunkStart = chunkEnd
+	}
+	return chunks
+}
+
+// archiveChunk uploads the chunk's entries, then deletes exactly the entries
+// uploaded, so entries arriving mid-archive are left for the next run
+func (s *ArchiveService) archiveChunk(ctx context.Context, tenantID uuid.UUID, chunk *models.ArchiveChunk) {
+	// The filter's end is inclusive
+	last := chunk.End.Add(-time.Nanosecond)
+	filter := models.LogFilter{TenantID: &tenantID, StartTime: &chunk.Start, EndTime: &last}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	var ids []uuid.UUID
+	var afterTime time.Time
+	var afterID uuid.UUID
+	for {
+		entries, err := s.logRepo.QueryAfter(ctx, filter, afterTime, afterID, archivePageSize)
+		if err != nil {
+			chunk.Error = err.Error()
+			return
+		}
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				chunk.Error = err.Error()
+				return
+			}
+			ids = append(ids, entry.ID)
+		}
+		if len(entries) < archivePageSize {
+			break
+		}
+		afterTime, afterID = entries[len(entries)-1].Timestamp, entries[len(entries)-1].ID
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := gz.Close(); err != nil {
+		chunk.Error = err.Error()
+		return
+	}
+
+	// Each upload gets its own object so re-archiving a day never replaces
+	// an earlier archive of it
+	key := fmt.Sprintf("%s%s/%s/%s.ndjson.gz", s.prefix, tenantID, chunk.Start.Format("2006-01-02"), uuid.New())
+	if err := s.store.Put(ctx, key, buf.Bytes()); err != nil {
+		chunk.Error = fmt.Sprintf("upload failed, entries kept: %v", err)
+		return
+	}
+	chunk.Key = key
+	chunk.Archived = int64(len(ids))
+
+	for i := 0; i < len(ids); i += archivePageSize {
+		batch := ids[i:min(i+archivePageSize, len(ids))]
+		deleted, err := s.logRepo.DeleteByIDs(ctx, batch)
+		chunk.Deleted += deleted
+		if err != nil {
+			chunk.Error = fmt.Sprintf("archived but delete failed: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// countOnlyServices decodes a tenant's count-only service list, treating a
+// malformed list as empty
+func countOnlyServices(settings *models.TenantSettings) []string {
+	var services []string
+	if len(settings.CountOnlyServices) == 0 || json.Unmarshal(settings.CountOnlyServices, &services) != nil {
+		return nil
+	}
+	return services
+}
+
+// isCountOnly reports whether a tenant keeps only counts of a service's entries
+func isCountOnly(settings *models.TenantSettings, serviceName string) bool {
+	for _, name := range countOnlyServices(settings) {
+		if name == "*" || name == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// counterKey identifies the per-minute counter an entry is counted under
+type counterKey struct {
+	tenantID uuid.UUID
+	service  string
+	level    models.LogLevel
+	bucket   time.Time
+}
+
+// countEntries records count-only entries on the run and returns the entries
+// to store: everything else plus the sampled share of count-only entries.
+// Stored entries are not counted, so counters plus rows always add up to the
+// accepted volume. The counts are written by commitCounters once the request
+// is stored.
+func (s *LogService) countEntries(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+	kept := entries[:0]
+	for _, entry := range entries {
+		settings := run.TenantSettings(ctx, entry.TenantID)
+		if settings == nil || !isCountOnly(settings, entry.ServiceName) || rand.Float64() < settings.CountOnlySampleRate {
+			kept = append(kept, entry)
+			continue
+		}
+		key := counterKey{entry.TenantID, entry.ServiceName, entry.Level, entry.Timestamp.UTC().Truncate(time.Minute)}
+		run.counts[key]++
+		run.counted = append(run.counted, entry.ID)
+	}
+	return kept, nil
+}
+
+// commitCounters increments the counters of the entries a run counted. It
+// is called only after the rest of the request was stored, so a failed
+// request that the client retries is not counted twice. Once committed, the
+// dedup markers of counted entries are kept like those of stored entries.
+func (s *LogService) commitCounters(ctx context.Context, run *IngestionRun) error {
+	if run == nil || len(run.counts) == 0 {
+		return nil
+	}
+
+	counters := make([]models.LogCounter, 0, len(run.counts))
+	for key, count := range run.counts {
+		counters = append(counters, models.LogCounter{
+			TenantID:    key.tenantID,
+			ServiceName: key.service,
+			Level:       key.level,
+			Bucket:      key.bucket,
+			Count:       count,
+		})
+	}
+	if err := s.logRepo.IncrementCounters(ctx, counters); err != nil {
+		return fmt.Errorf("failed to count entries: %w", err)
+	}
+
+	for _, id := range run.counted {
+		delete(run.dedupMarkers, id)
+	}
+	run.counts = make(map[counterKey]int64)
+	run.counted = nil
+	return nil
+}
+
+// countersApply reports whether counters can answer a filter: they hold only
+// tenant, service, level and time, so any other predicate excludes them
+func countersApply(filter models.LogFilter) bool {
+	filter.TenantID = nil
+	filter.ServiceName = ""
+	filter.Level = ""
+	filter.MinLevel = ""
+	filter.MaxLevel = ""
+	filter.StartTime = nil
+	filter.EndTime = nil
+	filter.AroundTime = nil
+	filter.WindowMins = 0
+	filter.Page = 0
+	filter.PageSize = 0
+	return reflect.ValueOf(filter).IsZero()
+}
+
+// withCounters adds counter buckets to an aggregation, summing across
+// services unless byService is set
+func withCounters(aggregations, counters []models.LogAggregation, byService bool) []models.LogAggregation {
+	if len(counters) == 0 {
+		return aggregations
+	}
+
+	type bucketKey struct {
+		service string
+		bucket  int64
+	}
+	index := make(map[bucketKey]int, len(aggregations))
+	for i, agg := range aggregations {
+		index[bucketKey{agg.ServiceName, agg.Bucket.UnixNano()}] = i
+	}
+	for _, counter := range counters {
+		if !byService {
+			counter.ServiceName = ""
+		}
+		key := bucketKey{counter.ServiceName, counter.Bucket.UnixNano()}
+		if i, ok := index[key]; ok {
+			aggregations[i].Count += counter.Count
+			continue
+		}
+		index[key] = len(aggregations)
+		aggregations = append(aggregations, counter)
+	}
+
+	sort.SliceStable(aggregations, func(i, j int) bool {
+		if aggregations[i].ServiceName != aggregations[j].ServiceName {
+			return aggregations[i].ServiceName < aggregations[j].ServiceName
+		}
+		return aggregations[i].Bucket.Before(aggregations[j].Bucket)
+	})
+	return aggregations
+}
+
+// addCounterTotals adds counter totals to stats
+func addCounterTotals(stats *models.LogStats, totals []models.LogCounter) {
+	for _, total := range totals {
+		stats.TotalCount += total.Count
+		stats.LevelCounts[total.Level] += total.Count
+		stats.ServiceCounts[total.ServiceName] += total.Count
+	}
+}
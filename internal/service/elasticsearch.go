@@ -0,0 +1,250 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// ESBulkOp is one parsed _bulk action. Entry is nil when the action failed
+// to parse or is not an ingestion action; Err then explains why.
+type ESBulkOp struct {
+	Action string
+	Index  string
+	Entry  *models.LogEntry
+	Err    *models.ESBulkError
+}
+
+// ParseESBulk parses an Elasticsearch _bulk NDJSON body of action/source
+// pairs. index and create actions become log entries: @timestamp, message,
+// level and service fields are mapped onto the entry and remaining fields
+// are kept as metadata. A UUID _id is used as the entry ID. Other actions
+// are reported as failed without consuming a source line, except update
+// which carries one.
+func ParseESBulk(body []byte, tenantID uuid.UUID) []ESBulkOp {
+	var ops []ESBulkOp
+	lines := bytes.Split(body, []byte("\n"))
+
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) == 0 {
+			continue
+		}
+
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			ops = append(ops, ESBulkOp{Action: "index", Err: &models.ESBulkError{
+				Type:   "illegal_argument_exception",
+				Reason: fmt.Sprintf("malformed action/metadata line [%d]", i+1),
+			}})
+			continue
+		}
+
+		var op ESBulkOp
+		var id string
+		for name, meta := range action {
+			op.Action, op.Index, id = name, meta.Index, meta.ID
+		}
+
+		switch op.Action {
+		case "index", "create":
+		case "update":
+			i++
+			fallthrough
+		default:
+			op.Err = &models.ESBulkError{
+				Type:   "illegal_argument_exception",
+				Reason: fmt.Sprintf("action [%s] is not supported", op.Action),
+			}
+			ops = append(ops, op)
+			continue
+		}
+
+		i++
+		if i >= len(lines) || len(bytes.TrimSpace(lines[i])) == 0 {
+			op.Err = &models.ESBulkError{
+				Type:   "illegal_argument_exception",
+				Reason: fmt.Sprintf("action on line [%d] has no source", i),
+			}
+			ops = append(ops, op)
+			continue
+		}
+
+		entry, err := esDocumentEntry(lines[i], op.Index, tenantID)
+		if err != nil {
+			op.Err = &models.ESBulkError{Type: "mapper_parsing_exception", Reason: err.Error()}
+			ops = append(ops, op)
+			continue
+		}
+		if parsed, err := uuid.Parse(id); err == nil {
+			entry.ID = parsed
+		} else {
+			entry.ID = uuid.New()
+		}
+		op.Entry = entry
+		ops = append(ops, op)
+	}
+
+	return ops
+}
+
+// ESBulkResult builds the _bulk response. ingestErr fails every action that
+// produced an entry; otherwise failures fail the actions whose entries were
+// rejected, indexed by position among the ops that produced an entry.
+func ESBulkResult(ops []ESBulkOp, failures []models.RejectedEntry, ingestErr error, took time.Duration) models.ESBulkResponse {
+	resp := models.ESBulkResponse{
+		Took:  took.Milliseconds(),
+		Items: make([]map[string]models.ESBulkItemResult, 0, len(ops)),
+	}
+
+	rejected := make(map[int]string, len(failures))
+	for _, failure := range failures {
+		rejected[failure.Index] = failure.Reason
+	}
+
+	entryIndex := -1
+	for _, op := range ops {
+		if op.Entry != nil {
+			entryIndex++
+		}
+		item := models.ESBulkItemResult{Index: op.Index}
+		reason, isRejected := rejected[entryIndex]
+		switch {
+		case op.Err != nil:
+			item.Status = http.StatusBadRequest
+			item.Error = op.Err
+		case errors.Is(ingestErr, ErrEntryTooOld), errors.Is(ingestErr, ErrEntryInFuture):
+			item.ID = op.Entry.ID.String()
+			item.Status = http.StatusBadRequest
+			item.Error = &models.ESBulkError{Type: "illegal_argument_exception", Reason: ingestErr.Error()}
+		case ingestErr != nil:
+			item.ID = op.Entry.ID.String()
+			item.Status = http.StatusInternalServerError
+			item.Error = &models.ESBulkError{Type: "internal_server_error", Reason: ingestErr.Error()}
+		case isRejected:
+			item.ID = op.Entry.ID.String()
+			item.Status = http.StatusBadRequest
+			item.Error = &models.ESBulkError{Type: "illegal_argument_exception", Reason: reason}
+		default:
+			item.ID = op.Entry.ID.String()
+			item.Status = http.StatusCreated
+			item.Result = "created"
+		}
+		if item.Error != nil {
+			resp.Errors = true
+		}
+		resp.Items = append(resp.Items, map[string]models.ESBulkItemResult{op.Action: item})
+	}
+
+	return resp
+}
+
+// esDocumentEntry maps an Elasticsearch document onto a log entry. The
+// index name is the service when the document names none.
+func esDocumentEntry(source []byte, index string, tenantID uuid.UUID) (*models.LogEntry, error) {
+	// Numbers stay json.Number so large integers keep their precision in
+	// metadata
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(source))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse source: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to parse source: unexpected data after the document")
+	}
+
+	entry := &models.LogEntry{TenantID: tenantID}
+
+	if ts, ok := takeField(doc, "@timestamp", "timestamp"); ok {
+		parsed, err := esTimestamp(ts)
+		if err != nil {
+			return nil, err
+		}
+		entry.Timestamp = parsed
+	}
+	if message, ok := takeField(doc, "message", "msg"); ok {
+		entry.Message = fmt.Sprint(message)
+	}
+	if level, ok := takeField(doc, "log.level", "level", "severity"); ok {
+		entry.Level = lokiLevel(fmt.Sprint(level))
+	} else {
+		entry.Level = models.LogLevelInfo
+	}
+	if service, ok := takeField(doc, "service.name", "service_name", "service"); ok {
+		entry.ServiceName = fmt.Sprint(service)
+	} else {
+		entry.ServiceName = index
+	}
+
+	if len(doc) > 0 {
+		metadata, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		entry.Metadata = metadata
+	}
+	return entry, nil
+}
+
+// takeField removes and returns the first present field among names. Dotted
+// names also match ECS-style nested objects; emptied parents are removed.
+func takeField(doc map[string]interface{}, names ...string) (interface{}, bool) {
+	for _, name := range names {
+		if v, ok := doc[name]; ok {
+			delete(doc, name)
+			return v, true
+		}
+
+		parent, child, nested := strings.Cut(name, ".")
+		if !nested {
+			continue
+		}
+		obj, ok := doc[parent].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := obj[child]; ok {
+			delete(obj, child)
+			if len(obj) == 0 {
+				delete(doc, parent)
+			}
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// esTimestamp parses an RFC3339 string or epoch milliseconds
+func esTimestamp(v interface{}) (time.Time, error) {
+	switch ts := v.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse @timestamp [%s]", ts)
+		}
+		return parsed.UTC(), nil
+	case json.Number:
+		if ms, err := ts.Int64(); err == nil {
+			return time.UnixMilli(ms).UTC(), nil
+		}
+		ms, err := ts.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse @timestamp [%s]", ts)
+		}
+		return time.UnixMilli(int64(ms)).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("failed to parse @timestamp [%v]", v)
+	}
+}
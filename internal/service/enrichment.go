@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// enrichmentConcurrency bounds the enrichment calls in flight per batch
+const enrichmentConcurrency = 8
+
+// maxEnrichmentResponse bounds the enrichment response body read
+const maxEnrichmentResponse = 64 << 10
+
+// ErrEnrichmentURLNotAllowed is returned for enrichment URLs outside the allowlist
+var ErrEnrichmentURLNotAllowed = errors.New("enrichment URL is not in the enrichment allowlist")
+
+// WebhookEnricher adds metadata to entries by POSTing each one to a tenant's
+// enrichment webhook and merging the JSON object it returns
+type WebhookEnricher struct {
+	client  *http.Client
+	allowed []string
+	timeout time.Duration
+}
+
+// NewWebhookEnricher creates an enricher calling only the allowed URLs, each
+// call bounded by timeout
+func NewWebhookEnricher(allowed []string, timeout time.Duration) *WebhookEnricher {
+	if timeout <= 0 {
+		timeout = 250 * time.Millisecond
+	}
+	return &WebhookEnricher{
+		client:  &http.Client{},
+		allowed: allowed,
+		timeout: timeout,
+	}
+}
+
+// Enrich posts the entry to url and merges the returned object into its
+// metadata. Keys the entry already has are kept. On any error the entry is
+// left unchanged.
+func (e *WebhookEnricher) Enrich(ctx context.Context, entry *models.LogEntry, url string) error {
+	if !e.urlAllowed(url) {
+		return ErrEnrichmentURLNotAllowed
+	}
+
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("enrichment webhook returned status %d", resp.StatusCode)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEnrichmentResponse)).Decode(&fields); err != nil {
+		return fmt.Errorf("enrichment webhook returned invalid JSON: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	metadata := make(map[string]json.RawMessage)
+	if len(entry.Metadata) > 0 {
+		if err := json.Unmarshal(entry.Metadata, &metadata); err != nil {
+			return fmt.Errorf("entry metadata is not an object: %w", err)
+		}
+	}
+	for key, value := range fields {
+		if _, exists := metadata[key]; !exists {
+			metadata[key] = value
+		}
+	}
+
+	merged, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	entry.Metadata = merged
+	return nil
+}
+
+// urlAllowed reports whether url is in the enrichment allowlist
+func (e *WebhookEnricher) urlAllowed(url string) bool {
+	for _, allowed := range e.allowed {
+		if url == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// enrichEntries runs the enrichment webhook of each entry's tenant, failing
+// open: entries whose call fails are kept as they are
+func (s *LogService) enrichEntries(ctx context.Context, run *IngestionRun, entries []models.LogEntry) []models.LogEntry {
+	sem := make(chan struct{}, enrichmentConcurrency)
+	var wg sync.WaitGroup
+	for i := range entries {
+		settings := run.TenantSettings(ctx, entries[i].TenantID)
+		if settings == nil || settings.EnrichmentURL == "" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(entry *models.LogEntry, url string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := s.enricher.Enrich(ctx, entry, url); err != nil {
+				fmt.Printf("Enrichment of entry %s failed, storing it unenriched: %v\n", entry.ID, err)
+			}
+		}(&entries[i], settings.EnrichmentURL)
+	}
+	wg.Wait()
+	return entries
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// EnvironmentDeriver fills in a missing environment from a capture over the
+// service name, for orgs naming services like auth-prod or auth-staging
+type EnvironmentDeriver struct {
+	pattern *regexp.Regexp
+	group   int
+}
+
+// NewEnvironmentDeriver compiles a regex capturing the environment from the
+// service name, e.g. -(prod|staging)$. The group named environment is used
+// when present, else the first group. An empty pattern derives nothing.
+func NewEnvironmentDeriver(pattern string) (*EnvironmentDeriver, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid environment pattern: %w", err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("environment pattern %q has no capture group", pattern)
+	}
+
+	group := 1
+	if i := re.SubexpIndex("environment"); i > 0 {
+		group = i
+	}
+	return &EnvironmentDeriver{pattern: re, group: group}, nil
+}
+
+// Apply sets the environment captured from the service name on an entry
+// without one. Entries that set their environment are left as sent.
+func (d *EnvironmentDeriver) Apply(entry *models.LogEntry) {
+	if d == nil || entry.Environment != "" {
+		return
+	}
+	if match := d.pattern.FindStringSubmatch(entry.ServiceName); match != nil {
+		entry.Environment = match[d.group]
+	}
+}
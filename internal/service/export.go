@@ -0,0 +1,103 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// exportPageSize is how many entries an export reads per query
+const exportPageSize = 1000
+
+// Export passes every entry matching the filter to emit in timestamp order.
+// Entries are read a page at a time, so exports of any size hold at most one
+// page in memory. An emit error stops the export. It returns the number of
+// entries emitted.
+func (s *LogService) Export(ctx context.Context, filter models.LogFilter, emit func(models.LogEntry) error) (int, error) {
+	ctx = s.readContext(ctx, filter.TenantID)
+	exported := 0
+	var afterTime time.Time
+	var afterID uuid.UUID
+	for {
+		entries, err := s.logRepo.QueryAfter(ctx, filter, afterTime, afterID, exportPageSize)
+		if err != nil {
+			return exported, err
+		}
+		for _, entry := range entries {
+			if err := emit(entry); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+		if len(entries) < exportPageSize {
+			return exported, nil
+		}
+		afterTime, afterID = entries[len(entries)-1].Timestamp, entries[len(entries)-1].ID
+	}
+}
+
+// ExportTrace bundles every entry of a trace in timestamp order, with the
+// services it passed through in order of first appearance
+func (s *LogService) ExportTrace(ctx context.Context, tenantID *uuid.UUID, traceID string) (*models.TraceBundle, error) {
+	filter := models.LogFilter{TenantID: tenantID, TraceID: traceID}
+	s.normalizeFilterIDs(&filter)
+
+	bundle := &models.TraceBundle{
+		TraceID:    filter.TraceID,
+		ExportedAt: time.Now().UTC(),
+		Services:   []string{},
+		Entries:    []models.LogEntry{},
+	}
+	seen := make(map[string]bool)
+	_, err := s.Export(ctx, filter, func(entry models.LogEntry) error {
+		if !seen[entry.ServiceName] {
+			seen[entry.ServiceName] = true
+			bundle.Services = append(bundle.Services, entry.ServiceName)
+		}
+		bundle.Entries = append(bundle.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	bundle.EntryCount = len(bundle.Entries)
+	return bundle, nil
+}
+
+// traceFileName replaces characters unsafe in archive paths
+var traceFileName = strings.NewReplacer("/", "_", "\\", "_", "..", "_")
+
+// WriteTraceZip writes a trace bundle as a zip archive holding one JSON file
+// per service, named after it, with that service's entries in order
+func WriteTraceZip(w io.Writer, bundle *models.TraceBundle) error {
+	byService := make(map[string][]models.LogEntry, len(bundle.Services))
+	for _, entry := range bundle.Entries {
+		byService[entry.ServiceName] = append(byService[entry.ServiceName], entry)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, service := range bundle.Services {
+		name := traceFileName.Replace(service)
+		if name == "" {
+			name = "unknown"
+		}
+		file, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name + ".json",
+			Method:   zip.Deflate,
+			Modified: bundle.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(file).Encode(byService[service]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// ErrInvalidFilter is returned for a filter with inconsistent fields
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// ErrUnselectiveQuery is returned for a query that would scan every log
+var ErrUnselectiveQuery = errors.New("query is not selective")
+
+// ErrFilterTooComplex is returned for a filter whose Or groups nest too
+// deeply or are too many
+var ErrFilterTooComplex = errors.New("filter too complex")
+
+// ValidateFilter rejects filters beyond the Or group depth and count limits,
+// metadata values given without a key and malformed keyset positions
+func ValidateFilter(filter models.LogFilter) error {
+	if depth := filter.GroupDepth(); depth > models.MaxFilterDepth {
+		return fmt.Errorf("%w: or groups nest %d deep, at most %d allowed", ErrFilterTooComplex, depth, models.MaxFilterDepth)
+	}
+	if count := filter.GroupCount(); count > models.MaxFilterGroups {
+		return fmt.Errorf("%w: %d or groups, at most %d allowed", ErrFilterTooComplex, count, models.MaxFilterGroups)
+	}
+	if filter.AfterID != nil && filter.AfterTimestamp == nil {
+		return fmt.Errorf("%w: after_id requires after_timestamp", ErrInvalidFilter)
+	}
+	if filter.Cursor != "" {
+		if _, _, err := models.DecodeCursor(filter.Cursor); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+	}
+	return validateMetadataPair(filter)
+}
+
+// ValidateSelective rejects filters without a tenant, time range, service,
+// trace or request ID, which would scan the whole table
+func ValidateSelective(filter models.LogFilter) error {
+	if filter.Selective() {
+		return nil
+	}
+	return fmt.Errorf("%w: narrow it by tenant, time range, service, trace_id or request_id", ErrUnselectiveQuery)
+}
+
+// validateMetadataPair rejects a MetadataValue without a MetadataKey in the
+// filter or any of its Or groups
+func validateMetadataPair(filter models.LogFilter) error {
+	if filter.MetadataValue != "" && filter.MetadataKey == "" {
+		return fmt.Errorf("%w: metadata_value requires metadata_key", ErrInvalidFilter)
+	}
+	for _, group := range filter.Or {
+		if err := validateMetadataPair(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesFilter checks a single entry against a filter in memory, mirroring
+// the predicates LogRepository.buildQuery applies in SQL
+func matchesFilter(entry models.LogEntry, filter models.LogFilter) bool {
+	if filter.TenantID != nil && *filter.TenantID != entry.TenantID {
+		return false
+	}
+	if filter.ServiceName != "" && filter.ServiceName != entry.ServiceName {
+		return false
+	}
+	if filter.Level != "" && filter.Level != entry.Level {
+		return false
+	}
+	if (filter.MinLevel != "" || filter.MaxLevel != "") && !levelBetween(entry.Level, filter.MinLevel, filter.MaxLevel) {
+		return false
+	}
+	if filter.StartTime != nil && entry.Timestamp.Before(*filter.StartTime) {
+		return false
+	}
+	if filter.EndTime != nil && entry.Timestamp.After(*filter.EndTime) {
+		return false
+	}
+	if filter.TraceID != "" && filter.TraceID != entry.TraceID {
+		return false
+	}
+	if filter.UserID != nil && (entry.UserID == nil || *filter.UserID != *entry.UserID) {
+		return false
+	}
+	if filter.RequestID != "" && filter.RequestID != entry.RequestID {
+		return false
+	}
+	if len(filter.RequestIDs) > 0 && !containsString(filter.RequestIDs, entry.RequestID) {
+		return false
+	}
+	if filter.Environment != "" && filter.Environment != entry.Environment {
+		return false
+	}
+	if filter.ExactMessage != "" && filter.ExactMessage != entry.Message {
+		return false
+	}
+	if filter.ErrorType != "" && filter.ErrorType != entry.ErrorType {
+		return false
+	}
+	if filter.Source != "" && filter.Source != entry.Source {
+		return false
+	}
+	if len(filter.Sources) > 0 && !containsString(filter.Sources, entry.Source) {
+		return false
+	}
+	if filter.HostPattern != "" && !matchesGlob(filter.HostPattern, entry.Host) {
+		return false
+	}
+	if start, end, ok := filter.ProximityWindow(); ok && (entry.Timestamp.Before(start) || entry.Timestamp.After(end)) {
+		return false
+	}
+	if filter.MinLatencyMs > 0 && entryLatencyMs(entry) < float64(filter.MinLatencyMs) {
+		return false
+	}
+	if filter.MinIngestLagSeconds > 0 &&
+		(entry.CreatedAt.IsZero() || entry.CreatedAt.Sub(entry.Timestamp) <= time.Duration(filter.MinIngestLagSeconds)*time.Second) {
+		return false
+	}
+	if len(filter.MetadataIn) > 0 && !matchesMetadataIn(entry, filter.MetadataIn) {
+		return false
+	}
+	if filter.MetadataKey != "" &&
+		!matchesMetadataIn(entry, map[string][]string{filter.MetadataKey: {filter.MetadataValue}}) {
+		return false
+	}
+	if filter.Search != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(filter.Search)) {
+		return false
+	}
+	if filter.MetadataSearch != "" && !matchesMetadataSearch(entry, filter.MetadataSearch) {
+		return false
+	}
+	if len(filter.Or) > 0 && !matchesAnyGroup(entry, filter.Or) {
+		return false
+	}
+	return true
+}
+
+// matchesAnyGroup reports whether the entry matches at least one Or group
+func matchesAnyGroup(entry models.LogEntry, groups []models.LogFilter) bool {
+	for _, group := range groups {
+		if matchesFilter(entry, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMetadataIn reports whether every key holds one of its listed values,
+// comparing strings as-is and other scalars by their JSON text
+func matchesMetadataIn(entry models.LogEntry, in map[string][]string) bool {
+	var metadata map[string]json.RawMessage
+	if len(entry.Metadata) == 0 || json.Unmarshal(entry.Metadata, &metadata) != nil {
+		return false
+	}
+	for key, values := range in {
+		raw, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		value := string(raw)
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			value = s
+		}
+		if !containsString(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesMetadataSearch reports whether the entry's metadata text contains
+// search case-insensitively, as the repository's ILIKE over metadata::text
+func matchesMetadataSearch(entry models.LogEntry, search string) bool {
+	return len(entry.Metadata) > 0 &&
+		strings.Contains(strings.ToLower(string(entry.Metadata)), strings.ToLower(search))
+}
+
+// matchesGlob reports whether s matches pattern, where * matches any run of
+// characters and everything else matches literally
+func matchesGlob(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+
+	first, last := parts[0], parts[len(parts)-1]
+	if !strings.HasPrefix(s, first) {
+		return false
+	}
+	s = s[len(first):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
+
+// entryLatencyMs returns the numeric latency_ms metadata field, or -1
+func entryLatencyMs(entry models.LogEntry) float64 {
+	var metadata struct {
+		LatencyMs interface{} `json:"latency_ms"`
+	}
+	if len(entry.Metadata) == 0 || json.Unmarshal(entry.Metadata, &metadata) != nil {
+		return -1
+	}
+	if v, ok := metadata.LatencyMs.(float64); ok {
+		return v
+	}
+	return -1
+}
+
+// levelBetween reports whether level is one of models.LevelsBetween(min, max)
+func levelBetween(level, min, max models.LogLevel) bool {
+	for _, l := range models.LevelsBetween(min, max) {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString reports whether values contains v
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
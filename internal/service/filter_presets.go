@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// DefaultSlowRequestMs is the slow-requests threshold when none is given
+const DefaultSlowRequestMs = 1000
+
+var (
+	// ErrUnknownPreset is returned for preset names that are not defined
+	ErrUnknownPreset = errors.New("unknown filter preset")
+	// ErrPresetParam is returned when a preset is missing a required parameter
+	ErrPresetParam = errors.New("missing preset parameter")
+)
+
+// PresetOptions are the caller-supplied parameters presets may use
+type PresetOptions struct {
+	ServiceName  string
+	MinLatencyMs int
+}
+
+// filterPreset expands into a filter relative to now
+type filterPreset func(now time.Time, opts PresetOptions) (models.LogFilter, error)
+
+var filterPresets = map[string]filterPreset{
+	// Errors and fatals from the last hour
+	"errors-last-hour": func(now time.Time, opts PresetOptions) (models.LogFilter, error) {
+		return lastWindow(now, time.Hour, models.LogFilter{
+			MinLevel:    models.LogLevelError,
+			ServiceName: opts.ServiceName,
+		}), nil
+	},
+	// Requests from the last hour whose parsed latency_ms meets the threshold
+	"slow-requests": func(now time.Time, opts PresetOptions) (models.LogFilter, error) {
+		threshold := opts.MinLatencyMs
+		if threshold < 1 {
+			threshold = DefaultSlowRequestMs
+		}
+		return lastWindow(now, time.Hour, models.LogFilter{
+			MinLatencyMs: threshold,
+			ServiceName:  opts.ServiceName,
+		}), nil
+	},
+	// Everything one service logged in the last 15 minutes
+	"recent-by-service": func(now time.Time, opts PresetOptions) (models.LogFilter, error) {
+		if opts.ServiceName == "" {
+			return models.LogFilter{}, fmt.Errorf("%w: service is required", ErrPresetParam)
+		}
+		return lastWindow(now, 15*time.Minute, models.LogFilter{ServiceName: opts.ServiceName}), nil
+	},
+}
+
+// PresetNames returns the names of the built-in presets
+func PresetNames() []string {
+	names := make([]string, 0, len(filterPresets))
+	for name := range filterPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PresetFilter expands a named preset into a filter scoped to the tenant
+func PresetFilter(name string, tenantID *uuid.UUID, now time.Time, opts PresetOptions) (models.LogFilter, error) {
+	preset, ok := filterPresets[name]
+	if !ok {
+		return models.LogFilter{}, fmt.Errorf("%w: %s", ErrUnknownPreset, name)
+	}
+
+	filter, err := preset(now.UTC(), opts)
+	if err != nil {
+		return filter, err
+	}
+	filter.TenantID = tenantID
+	return filter, nil
+}
+
+// lastWindow bounds a filter to the span ending at now
+func lastWindow(now time.Time, span time.Duration, filter models.LogFilter) models.LogFilter {
+	start := now.Add(-span)
+	filter.StartTime = &start
+	filter.EndTime = &now
+	return filter
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// Future modes for entries timestamped beyond the allowed clock skew
+const (
+	FutureModeClamp  = "clamp"
+	FutureModeReject = "reject"
+)
+
+// OriginalTimestampKey is the metadata key holding the timestamp an entry
+// was sent with when it was clamped to the ingestion time
+const OriginalTimestampKey = "_original_timestamp"
+
+// ErrEntryInFuture is returned when an entry's timestamp is further in the
+// future than the allowed clock skew
+var ErrEntryInFuture = errors.New("entry timestamp is in the future")
+
+// checkFutureSkew applies the configured future mode to entries dated more
+// than MaxFutureSkew after now. In reject mode the first such entry fails
+// the whole request; otherwise its timestamp becomes now and the original is
+// kept in metadata.
+func (s *LogService) checkFutureSkew(entries []models.LogEntry, now time.Time) error {
+	skew := s.config.Ingestion.MaxFutureSkew
+	if skew <= 0 {
+		return nil
+	}
+
+	limit := now.Add(skew)
+	for i := range entries {
+		entry := &entries[i]
+		if !entry.Timestamp.After(limit) {
+			continue
+		}
+		if s.config.Ingestion.FutureMode == FutureModeReject {
+			err := fmt.Errorf("%w: timestamp %s is more than %s after %s",
+				ErrEntryInFuture, entry.Timestamp.Format(time.RFC3339), skew, now.Format(time.RFC3339))
+			if len(entries) > 1 {
+				return fmt.Errorf("entries[%d]: %w", i, err)
+			}
+			return err
+		}
+		clampTimestamp(entry, now)
+	}
+	return nil
+}
+
+// clampTimestamp moves the entry to now, recording its original timestamp in
+// metadata
+func clampTimestamp(entry *models.LogEntry, now time.Time) {
+	metadata := make(map[string]json.RawMessage)
+	if len(entry.Metadata) > 0 {
+		if err := json.Unmarshal(entry.Metadata, &metadata); err != nil {
+			metadata = make(map[string]json.RawMessage)
+		}
+	}
+
+	original, _ := json.Marshal(entry.Timestamp.Format(time.RFC3339Nano))
+	metadata[OriginalTimestampKey] = original
+	if data, err := json.Marshal(metadata); err == nil {
+		entry.Metadata = data
+	}
+	entry.Timestamp = now
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// Histogram bucket bounds
+const (
+	defaultHistogramBuckets = 10
+	maxHistogramBuckets     = 200
+)
+
+// ErrInvalidHistogram is returned for histogram requests with an unusable range
+var ErrInvalidHistogram = errors.New("invalid histogram")
+
+// Histogram buckets the numeric metadata field of entries matching the
+// filter into buckets equal-width ranges over [min, max). Entries without
+// the field or with a non-numeric value are counted as missing.
+func (s *LogService) Histogram(ctx context.Context, filter models.LogFilter, field string, min, max float64, buckets int) (*models.Histogram, error) {
+	if field == "" {
+		return nil, fmt.Errorf("%w: field is required", ErrInvalidHistogram)
+	}
+	if math.IsNaN(min) || math.IsNaN(max) || math.IsInf(min, 0) || math.IsInf(max, 0) || max <= min {
+		return nil, fmt.Errorf("%w: max must be greater than min", ErrInvalidHistogram)
+	}
+	if buckets == 0 {
+		buckets = defaultHistogramBuckets
+	}
+	if buckets < 1 || buckets > maxHistogramBuckets {
+		return nil, fmt.Errorf("%w: buckets must be between 1 and %d", ErrInvalidHistogram, maxHistogramBuckets)
+	}
+
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	counts, missing, err := s.logRepo.Histogram(ctx, filter, field, min, max, buckets)
+	if err != nil {
+		return nil, err
+	}
+	return NewHistogram(field, min, max, buckets, counts, missing), nil
+}
+
+// NewHistogram builds a histogram from width_bucket counts, where bucket 0
+// holds values below min and bucket buckets+1 values at or above max
+func NewHistogram(field string, min, max float64, buckets int, counts map[int]int64, missing int64) *models.Histogram {
+	histogram := &models.Histogram{
+		Field:     field,
+		Buckets:   make([]models.HistogramBucket, buckets),
+		Underflow: counts[0],
+		Overflow:  counts[buckets+1],
+		Missing:   missing,
+	}
+	width := (max - min) / float64(buckets)
+	for i := range histogram.Buckets {
+		upper := min + float64(i+1)*width
+		if i == buckets-1 {
+			upper = max
+		}
+		histogram.Buckets[i] = models.HistogramBucket{
+			Min:   min + float64(i)*width,
+			Max:   upper,
+			Count: counts[i+1],
+		}
+	}
+	return histogram
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+)
+
+// importBatchSize is the number of lines inserted per CreateBatch call
+const importBatchSize = 1000
+
+// ErrImportNotConfigured is returned when no object store is configured
+var ErrImportNotConfigured = errors.New("object storage import is not configured")
+
+// ImportService bulk-loads NDJSON log archives from object storage
+type ImportService struct {
+	logRepo    *repository.LogRepository
+	logService *LogService
+	store      ObjectStore
+	prefix     string
+}
+
+// NewImportService creates a new import service; a nil store disables
+// imports. Entries are prepared by logService's ingestion pipeline.
+func NewImportService(logRepo *repository.LogRepository, logService *LogService, store ObjectStore, prefix string) *ImportService {
+	return &ImportService{logRepo: logRepo, logService: logService, store: store, prefix: prefix}
+}
+
+// Enabled reports whether an object store is configured
+func (s *ImportService) Enabled() bool {
+	return s.store != nil
+}
+
+// Import reads every NDJSON object (optionally gzipped, by .gz suffix) under
+// the prefix, which is appended to the configured one. Entries are imported
+// into tenantID whatever tenant the file names; a nil tenantID keeps the
+// file's tenants, failing entries without one. Entries are redacted and
+// validated like ingested ones, and entries whose ID already exists are
+// skipped. A frame is reported per object and the summary is returned.
+func (s *ImportService) Import(ctx context.Context, prefix string, tenantID *uuid.UUID, progress func(models.ImportProgress)) (models.ImportProgress, error) {
+	summary := models.ImportProgress{Type: "summary"}
+	if s.store == nil {
+		return summary, ErrImportNotConfigured
+	}
+
+	keys, err := s.store.List(ctx, s.prefix+strings.TrimPrefix(prefix, "/"))
+	if err != nil {
+		return summary, err
+	}
+
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		frame := s.importObject(ctx, key, tenantID)
+		summary.Files++
+		summary.ImportedTotal += frame.Imported
+		summary.SkippedTotal += frame.Skipped
+		summary.FailedTotal += frame.Failed
+
+		frame.ImportedTotal = summary.ImportedTotal
+		frame.SkippedTotal = summary.SkippedTotal
+		frame.FailedTotal = summary.FailedTotal
+		if progress != nil {
+			progress(frame)
+		}
+	}
+
+	return summary, nil
+}
+
+// importObject imports a single object, recording failures in the frame
+func (s *ImportService) importObject(ctx context.Context, key string, tenantID *uuid.UUID) models.ImportProgress {
+	frame := models.ImportProgress{Type: "file", Key: key}
+
+	body, err := s.store.Open(ctx, key)
+	if err != nil {
+		frame.Error = err.Error()
+		return frame
+	}
+	defer body.Close()
+
+	var reader io.Reader = body
+	if strings.HasSuffix(key, ".gz") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			frame.Error = err.Error()
+			return frame
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	batch := make([]models.LogEntry, 0, importBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		defer func() { batch = batch[:0] }()
+
+		entries, rejected, err := s.logService.PrepareImport(ctx, batch)
+		frame.Failed += int64(rejected)
+		if err != nil {
+			frame.Failed += int64(len(entries))
+			frame.Error = err.Error()
+			return
+		}
+		if len(entries) == 0 {
+			return
+		}
+		inserted, err := s.logRepo.CreateBatchSkipDuplicates(ctx, entries)
+		if err != nil {
+			frame.Failed += int64(len(entries))
+			frame.Error = err.Error()
+			return
+		}
+		frame.Imported += inserted
+		frame.Skipped += int64(len(entries)) - inserted
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry models.LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Timestamp.IsZero() {
+			frame.Failed++
+			continue
+		}
+		if entry.ID == uuid.Nil {
+			entry.ID = uuid.New()
+		}
+		if tenantID != nil {
+			entry.TenantID = *tenantID
+		} else if entry.TenantID == uuid.Nil {
+			frame.Failed++
+			continue
+		}
+
+		batch = append(batch, entry)
+		if len(batch) == importBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		frame.Error = err.Error()
+	}
+	return frame
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// dedupWindow is how long a content hash is remembered for deduplication
+const dedupWindow = 5 * time.Minute
+
+// Occurrences kept when a batch repeats an entry ID
+const (
+	DuplicateIDKeepFirst = "first"
+	DuplicateIDKeepLast  = "last"
+)
+
+// redactedValue replaces sensitive values
+const redactedValue = "[REDACTED]"
+
+var (
+	// redactPattern matches key=value / key: value pairs with sensitive keys
+	redactPattern = regexp.MustCompile(`(?i)\b(password|passwd|secret|token|api[_-]?key|authorization)(\s*[=:]\s*)("[^"]*"|\S+)`)
+
+	sensitiveKeys = map[string]bool{
+		"password":      true,
+		"passwd":        true,
+		"secret":        true,
+		"token":         true,
+		"api_key":       true,
+		"apikey":        true,
+		"authorization": true,
+	}
+)
+
+// redactEntry masks sensitive values in the message and top-level metadata
+func redactEntry(entry *models.LogEntry) {
+	entry.Message = redactPattern.ReplaceAllString(entry.Message, "${1}${2}"+redactedValue)
+
+	if len(entry.Metadata) == 0 {
+		return
+	}
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal(entry.Metadata, &metadata); err != nil {
+		return
+	}
+
+	changed := false
+	for key := range metadata {
+		if sensitiveKeys[strings.ToLower(key)] {
+			metadata[key] = json.RawMessage(`"` + redactedValue + `"`)
+			changed = true
+		}
+	}
+	if changed {
+		if data, err := json.Marshal(metadata); err == nil {
+			entry.Metadata = data
+		}
+	}
+}
+
+// sampledOut reports whether a low-severity entry is dropped by sampling.
+// WARN and above are always kept.
+func sampledOut(entry models.LogEntry, rate float64) bool {
+	if entry.Level != models.LogLevelDebug && entry.Level != models.LogLevelInfo {
+		return false
+	}
+	return rand.Float64() >= rate
+}
+
+// isDuplicate reports whether an identical entry was already seen in this
+// batch or, when Redis is available, within the dedup window. Markers it sets
+// are recorded on the run so releaseDedup can undo them.
+func (s *LogService) isDuplicate(ctx context.Context, run *IngestionRun, entry models.LogEntry, seen map[string]bool) bool {
+	hash := contentHash(entry)
+	if seen[hash] {
+		return true
+	}
+	seen[hash] = true
+
+	if s.redis == nil {
+		return false
+	}
+	key := "log_dedup:" + hash
+	added, err := s.redis.SetNX(ctx, key, 1, dedupWindow).Result()
+	if err != nil {
+		return false
+	}
+	if added {
+		run.dedupMarkers[entry.ID] = append(run.dedupMarkers[entry.ID], key)
+	}
+	return !added
+}
+
+// releaseDedup deletes the dedup markers a run set for entries that were not
+// stored, so a client retrying them after a failed insert is not dropped as
+// a duplicate
+func (s *LogService) releaseDedup(ctx context.Context, run *IngestionRun, stored []models.LogEntry) {
+	if s.redis == nil || run == nil || len(run.dedupMarkers) == 0 {
+		return
+	}
+	storedIDs := make(map[uuid.UUID]bool, len(stored))
+	for _, entry := range stored {
+		storedIDs[entry.ID] = true
+	}
+	var keys []string
+	for id, markers := range run.dedupMarkers {
+		if !storedIDs[id] {
+			keys = append(keys, markers...)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	// The request context may be what failed the insert
+	if err := s.redis.Del(context.WithoutCancel(ctx), keys...).Err(); err != nil {
+		fmt.Printf("Failed to release %d dedup markers: %v\n", len(keys), err)
+	}
+}
+
+// CollapseDuplicateIDs removes entries repeating an ID earlier in the batch,
+// keeping the first occurrence or, with DuplicateIDKeepLast, the last one in
+// the position of the first. It returns the kept entries and how many were
+// collapsed.
+func CollapseDuplicateIDs(entries []models.LogEntry, keep string) ([]models.LogEntry, int) {
+	positions := make(map[uuid.UUID]int, len(entries))
+	kept := make([]models.LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		pos, seen := positions[entry.ID]
+		if !seen || entry.ID == uuid.Nil {
+			positions[entry.ID] = len(kept)
+			kept = append(kept, entry)
+			continue
+		}
+		if keep == DuplicateIDKeepLast {
+			kept[pos] = entry
+		}
+	}
+	return kept, len(entries) - len(kept)
+}
+
+// collapseDuplicateIDs applies the configured duplicate ID handling to a
+// batch about to be inserted
+func (s *LogService) collapseDuplicateIDs(entries []models.LogEntry) ([]models.LogEntry, int) {
+	kept, collapsed := CollapseDuplicateIDs(entries, s.config.Ingestion.DuplicateIDKeep)
+	if collapsed > 0 {
+		fmt.Printf("Collapsed %d entries with duplicate IDs in batch of %d\n", collapsed, len(entries))
+	}
+	return kept, collapsed
+}
+
+// contentHash identifies an entry by its content rather than its ID
+func contentHash(entry models.LogEntry) string {
+	h := sha256.New()
+	h.Write([]byte(entry.TenantID.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.ServiceName))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Level))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Message))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Timestamp.UTC().Format(time.RFC3339Nano)))
+	h.Write([]byte{0})
+	h.Write(entry.Metadata)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// messageDampener limits identical messages per tenant and service per minute
+type messageDampener struct {
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+// allow reports whether the entry is within the per-minute limit
+func (d *messageDampener) allow(entry models.LogEntry, limit int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().Truncate(time.Minute)
+	if !now.Equal(d.window) || d.counts == nil {
+		d.window = now
+		d.counts = make(map[string]int)
+	}
+
+	key := entry.TenantID.String() + "|" + entry.ServiceName + "|" + entry.Message
+	d.counts[key]++
+	return d.counts[key] <= limit
+}
+
+// serviceNamesTTL bounds how long a tenant's loaded service names are kept,
+// so tenants no longer sending are dropped and names deleted by retention
+// stop counting against the cap
+const serviceNamesTTL = 10 * time.Minute
+
+type knownServices struct {
+	names     map[string]bool
+	expiresAt time.Time
+}
+
+// serviceCardinality caps the distinct service names per tenant, guarding
+// indexes against clients that send random service names
+type serviceCardinality struct {
+	mu       sync.Mutex
+	services map[uuid.UUID]*knownServices
+	rejected int64
+}
+
+// allow reports whether the entry's service is known to its tenant or fits
+// under the limit, recording it as known when it does. A tenant's services
+// are loaded on first sight and again once they expire, without holding the
+// lock; when loading fails the entry is allowed and the load retried next
+// time.
+func (c *serviceCardinality) allow(ctx context.Context, entry models.LogEntry, limit int, load func(ctx context.Context, tenantID uuid.UUID) ([]string, error)) bool {
+	c.mu.Lock()
+	known, ok := c.services[entry.TenantID]
+	if !ok || !time.Now().Before(known.expiresAt) {
+		c.mu.Unlock()
+		names, err := load(ctx, entry.TenantID)
+		if err != nil {
+			return true
+		}
+		loaded := &knownServices{names: make(map[string]bool, len(names)), expiresAt: time.Now().Add(serviceNamesTTL)}
+		for _, name := range names {
+			loaded.names[name] = true
+		}
+
+		c.mu.Lock()
+		// Another ingestion may have loaded the tenant meanwhile
+		if known, ok = c.services[entry.TenantID]; !ok || !time.Now().Before(known.expiresAt) {
+			c.store(entry.TenantID, loaded)
+			known = loaded
+		}
+	}
+	defer c.mu.Unlock()
+
+	if known.names[entry.ServiceName] {
+		return true
+	}
+	if len(known.names) >= limit {
+		c.rejected++
+		fmt.Printf("Warning: rejecting entry for new service %q: tenant %s is at its limit of %d services\n",
+			entry.ServiceName, entry.TenantID, limit)
+		return false
+	}
+	known.names[entry.ServiceName] = true
+	return true
+}
+
+// store caches a tenant's services, dropping expired tenants; c.mu must be held
+func (c *serviceCardinality) store(tenantID uuid.UUID, known *knownServices) {
+	if c.services == nil {
+		c.services = make(map[uuid.UUID]*knownServices)
+	}
+	now := time.Now()
+	for id, cached := range c.services {
+		if !now.Before(cached.expiresAt) {
+			delete(c.services, id)
+		}
+	}
+	c.services[tenantID] = known
+}
+
+// Rejected returns the number of entries rejected for exceeding the cap
+func (c *serviceCardinality) Rejected() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rejected
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// Ingestion stage names, in their default order
+const (
+	StageNormalize    = "normalize"
+	StageParse        = "parse"
+	StageMetadataKeys = "metadata_keys"
+	StageRedact       = "redact"
+	StageTruncate     = "truncate"
+	StageRoute        = "route"
+	StageValidate     = "validate"
+	StageServiceCap   = "service_cap"
+	StageSample       = "sample"
+	StageDampen       = "dampen"
+	StageDedup        = "dedup"
+	StageCount        = "count_only"
+	StageEnrich       = "enrich"
+)
+
+// IngestionStage is one step of the ingestion pipeline. Process returns the
+// entries to keep; an error fails the whole request.
+type IngestionStage interface {
+	Name() string
+	Process(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error)
+}
+
+// IngestionRun carries the state of one ingestion request across stages
+type IngestionRun struct {
+	Now      time.Time
+	tenants  *TenantService
+	settings map[uuid.UUID]*models.TenantSettings
+	// dedupMarkers are the Redis dedup keys set by this run, by entry ID
+	dedupMarkers map[uuid.UUID][]string
+	// counts are the counter increments of count-only entries, and counted
+	// their IDs, held until commitCounters writes them
+	counts  map[counterKey]int64
+	counted []uuid.UUID
+	// rejected collects the entries failing validation, by ID, for partial
+	// batches; when nil, a failing entry fails the request
+	rejected map[uuid.UUID]error
+}
+
+// NewIngestionRun creates the state for one ingestion request; tenants may
+// be nil when no tenant settings apply
+func NewIngestionRun(now time.Time, tenants *TenantService) *IngestionRun {
+	return &IngestionRun{
+		Now:          now,
+		tenants:      tenants,
+		settings:     make(map[uuid.UUID]*models.TenantSettings),
+		dedupMarkers: make(map[uuid.UUID][]string),
+		counts:       make(map[counterKey]int64),
+	}
+}
+
+// TenantSettings returns a tenant's settings, looked up once per run, or nil
+// when the tenant has none
+func (r *IngestionRun) TenantSettings(ctx context.Context, tenantID uuid.UUID) *models.TenantSettings {
+	settings, ok := r.settings[tenantID]
+	if !ok {
+		settings = r.tenants.CachedSettings(ctx, tenantID)
+		r.settings[tenantID] = settings
+	}
+	return settings
+}
+
+// stageFunc adapts a function to IngestionStage
+type stageFunc struct {
+	name    string
+	process func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error)
+}
+
+func (s stageFunc) Name() string { return s.name }
+
+func (s stageFunc) Process(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+	return s.process(ctx, run, entries)
+}
+
+// NewIngestionStage creates a named stage from a function
+func NewIngestionStage(name string, process func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error)) IngestionStage {
+	return stageFunc{name: name, process: process}
+}
+
+// IngestionPipeline runs ingestion stages in order
+type IngestionPipeline struct {
+	stages []IngestionStage
+}
+
+// NewIngestionPipeline arranges the available stages. order lists the stages
+// to run by name, keeping the available order when empty; disabled stages
+// are skipped. Unknown or repeated names are an error.
+func NewIngestionPipeline(available []IngestionStage, order, disabled []string) (*IngestionPipeline, error) {
+	byName := make(map[string]IngestionStage, len(available))
+	for _, stage := range available {
+		byName[stage.Name()] = stage
+	}
+
+	if len(order) == 0 {
+		for _, stage := range available {
+			order = append(order, stage.Name())
+		}
+	}
+
+	skip := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("unknown ingestion stage %q", name)
+		}
+		skip[name] = true
+	}
+
+	pipeline := &IngestionPipeline{}
+	used := make(map[string]bool, len(order))
+	for _, name := range order {
+		stage, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown ingestion stage %q", name)
+		}
+		if used[name] {
+			return nil, fmt.Errorf("ingestion stage %q listed twice", name)
+		}
+		used[name] = true
+		if !skip[name] {
+			pipeline.stages = append(pipeline.stages, stage)
+		}
+	}
+	return pipeline, nil
+}
+
+// Stages returns the names of the stages that run, in order
+func (p *IngestionPipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, stage := range p.stages {
+		names[i] = stage.Name()
+	}
+	return names
+}
+
+// Run passes the entries through every stage, stopping early once all
+// entries are dropped
+func (p *IngestionPipeline) Run(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+	for _, stage := range p.stages {
+		if len(entries) == 0 {
+			break
+		}
+		var err error
+		if entries, err = stage.Process(ctx, run, entries); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// ingestionStages returns the service's stages in their default order
+func (s *LogService) ingestionStages() []IngestionStage {
+	return []IngestionStage{
+		// Runs first so later stages see stored values
+		eachEntry(StageNormalize, func(entry *models.LogEntry) {
+			entry.Normalize()
+			s.normalizeIDs(entry)
+			s.environments.Apply(entry)
+		}),
+		eachEntry(StageParse, func(entry *models.LogEntry) { s.parser.Apply(entry) }),
+		eachEntry(StageMetadataKeys, func(entry *models.LogEntry) { s.metadataKeys.Apply(entry) }),
+		// Before truncation, so a preserved full message is stored redacted
+		tenantFilter(StageRedact, func(ctx context.Context, entry *models.LogEntry, settings *models.TenantSettings) bool {
+			if settings.RedactionEnabled {
+				redactEntry(entry)
+			}
+			return true
+		}),
+		eachEntry(StageTruncate, func(entry *models.LogEntry) {
+			TruncateMessage(entry, s.config.Ingestion.MaxMessageLength, s.config.Ingestion.PreserveFullMessage)
+		}),
+		eachEntry(StageRoute, s.routeRetentionTier),
+		NewIngestionStage(StageValidate, func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+			if run.rejected != nil {
+				return s.rejectInvalidEntries(ctx, run, entries), nil
+			}
+			if err := s.checkFutureSkew(entries, run.Now); err != nil {
+				return nil, err
+			}
+			if err := s.checkMaxAge(ctx, entries, run.Now); err != nil {
+				return nil, err
+			}
+			return entries, nil
+		}),
+		NewIngestionStage(StageServiceCap, func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+			limit := s.config.Ingestion.MaxServicesPerTenant
+			if limit <= 0 {
+				return entries, nil
+			}
+			kept := entries[:0]
+			for _, entry := range entries {
+				if s.serviceNames.allow(ctx, entry, limit, s.tenantServices) {
+					kept = append(kept, entry)
+				}
+			}
+			return kept, nil
+		}),
+		tenantFilter(StageSample, func(ctx context.Context, entry *models.LogEntry, settings *models.TenantSettings) bool {
+			return !settings.SamplingEnabled || !sampledOut(*entry, settings.SampleRate)
+		}),
+		tenantFilter(StageDampen, func(ctx context.Context, entry *models.LogEntry, settings *models.TenantSettings) bool {
+			return !settings.DampeningEnabled || s.dampener.allow(*entry, settings.DampeningLimit)
+		}),
+		NewIngestionStage(StageDedup, func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+			seen := make(map[string]bool)
+			return filterByTenant(ctx, run, entries, func(ctx context.Context, entry *models.LogEntry, settings *models.TenantSettings) bool {
+				return !settings.DedupEnabled || !s.isDuplicate(ctx, run, *entry, seen)
+			}), nil
+		}),
+		// After deduplication, so duplicates and retries are not counted
+		NewIngestionStage(StageCount, s.countEntries),
+		// Last, so only kept and redacted entries leave the service
+		NewIngestionStage(StageEnrich, func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+			return s.enrichEntries(ctx, run, entries), nil
+		}),
+	}
+}
+
+// tenantServices loads the service names a tenant has logged under
+func (s *LogService) tenantServices(ctx context.Context, tenantID uuid.UUID) ([]string, error) {
+	return s.logRepo.GetServices(ctx, &tenantID)
+}
+
+// RejectedServiceEntries returns the number of entries rejected because
+// their tenant reached the distinct service cap
+func (s *LogService) RejectedServiceEntries() int64 {
+	return s.serviceNames.Rejected()
+}
+
+// eachEntry creates a stage that transforms every entry
+func eachEntry(name string, apply func(entry *models.LogEntry)) IngestionStage {
+	return NewIngestionStage(name, func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+		for i := range entries {
+			apply(&entries[i])
+		}
+		return entries, nil
+	})
+}
+
+// tenantFilter creates a stage that applies a tenant's settings to each of
+// its entries; keep returning false drops the entry. Entries of tenants
+// without settings pass through.
+func tenantFilter(name string, keep func(ctx context.Context, entry *models.LogEntry, settings *models.TenantSettings) bool) IngestionStage {
+	return NewIngestionStage(name, func(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+		return filterByTenant(ctx, run, entries, keep), nil
+	})
+}
+
+// filterByTenant keeps the entries accepted under their tenant's settings
+func filterByTenant(ctx context.Context, run *IngestionRun, entries []models.LogEntry, keep func(ctx context.Context, entry *models.LogEntry, settings *models.TenantSettings) bool) []models.LogEntry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		settings := run.TenantSettings(ctx, entry.TenantID)
+		if settings != nil && !keep(ctx, &entry, settings) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// IngestionStages returns the names of the configured ingestion stages, in order
+func (s *LogService) IngestionStages() []string {
+	return s.pipeline.Stages()
+}
+
+// ingest applies entry defaults and runs the ingestion pipeline, returning
+// the entries to store and the run, which releaseDedup needs once the store
+// is attempted
+func (s *LogService) ingest(ctx context.Context, entries []models.LogEntry, now time.Time) ([]models.LogEntry, *IngestionRun, error) {
+	return s.ingestRun(ctx, NewIngestionRun(now, s.tenants), entries)
+}
+
+// ingestRun is ingest with a run prepared by the caller
+func (s *LogService) ingestRun(ctx context.Context, run *IngestionRun, entries []models.LogEntry) ([]models.LogEntry, *IngestionRun, error) {
+	for i := range entries {
+		applyEntryDefaults(&entries[i], run.Now)
+	}
+	entries, err := s.pipeline.Run(ctx, run, entries)
+	return entries, run, err
+}
+
+// importStages are the stages imported entries pass through: archives are
+// shaped, redacted and validated like ingested entries, but not sampled,
+// dampened, deduplicated, counted or enriched
+var importStages = map[string]bool{
+	StageNormalize:    true,
+	StageParse:        true,
+	StageMetadataKeys: true,
+	StageRedact:       true,
+	StageTruncate:     true,
+	StageRoute:        true,
+	StageValidate:     true,
+}
+
+// PrepareImport runs imported entries through the import stages, in their
+// configured order. Entries with an invalid level or failing validation are
+// left out and counted in rejected.
+func (s *LogService) PrepareImport(ctx context.Context, entries []models.LogEntry) (kept []models.LogEntry, rejected int, err error) {
+	run := NewIngestionRun(time.Now().UTC(), s.tenants)
+	run.rejected = make(map[uuid.UUID]error)
+
+	kept = entries[:0]
+	for i := range entries {
+		if ValidateLevel(&entries[i]) != nil {
+			rejected++
+			continue
+		}
+		applyEntryDefaults(&entries[i], run.Now)
+		kept = append(kept, entries[i])
+	}
+
+	for _, stage := range s.pipeline.stages {
+		if len(kept) == 0 {
+			break
+		}
+		if !importStages[stage.Name()] {
+			continue
+		}
+		if kept, err = stage.Process(ctx, run, kept); err != nil {
+			return nil, rejected + len(kept), err
+		}
+	}
+	return kept, rejected + len(run.rejected), nil
+}
+
+// rejectInvalidEntries validates each entry on its own, recording those that
+// fail on the run and returning the rest
+func (s *LogService) rejectInvalidEntries(ctx context.Context, run *IngestionRun, entries []models.LogEntry) []models.LogEntry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		single := []models.LogEntry{entry}
+		err := s.checkFutureSkew(single, run.Now)
+		if err == nil {
+			err = s.checkMaxAge(ctx, single, run.Now)
+		}
+		if err != nil {
+			run.rejected[entry.ID] = err
+			continue
+		}
+		// Clamping may have moved the timestamp
+		kept = append(kept, single[0])
+	}
+	return kept
+}
+
+// applyEntryDefaults assigns an ID and timestamp when missing
+func applyEntryDefaults(entry *models.LogEntry, now time.Time) {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = now
+	}
+}
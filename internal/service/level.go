@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// ErrInvalidLevel is returned for an entry whose level is not one of
+// models.LogLevels
+var ErrInvalidLevel = errors.New("invalid log level")
+
+// ValidateLevel normalizes the entry's level, so "info" is stored as INFO,
+// and rejects levels outside models.LogLevels
+func ValidateLevel(entry *models.LogEntry) error {
+	entry.Level = models.NormalizeLogLevel(entry.Level)
+	if entry.Level.Valid() {
+		return nil
+	}
+
+	allowed := make([]string, len(models.LogLevels))
+	for i, level := range models.LogLevels {
+		allowed[i] = string(level)
+	}
+	if entry.Level == "" {
+		return fmt.Errorf("%w: level is required, expected one of %s", ErrInvalidLevel, strings.Join(allowed, ", "))
+	}
+	return fmt.Errorf("%w: %q, expected one of %s", ErrInvalidLevel, entry.Level, strings.Join(allowed, ", "))
+}
+
+// rejectInvalidLevels removes the entries with invalid levels from the
+// batch, recording each in batch.Rejected by its index in the request. It
+// returns the first rejection.
+func rejectInvalidLevels(batch *models.LogBatch) error {
+	var first error
+	valid := batch.Entries[:0]
+	for i := range batch.Entries {
+		entry := batch.Entries[i]
+		if err := ValidateLevel(&entry); err != nil {
+			batch.Rejected = append(batch.Rejected, models.RejectedEntry{Index: i, Reason: err.Error()})
+			if first == nil {
+				first = fmt.Errorf("entries[%d]: %w", i, err)
+			}
+			continue
+		}
+		valid = append(valid, entry)
+	}
+	batch.Entries = valid
+	return first
+}
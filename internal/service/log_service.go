@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,18 +16,52 @@ import (
 	"github.com/minisource/log/internal/models"
 	"github.com/minisource/log/internal/repository"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
+// FlushInterval is how often the async buffer is written to the database
+const FlushInterval = 5 * time.Second
+
+// sharedQueryTimeout bounds a query coalesced across concurrent callers,
+// which no single caller's context may cancel
+const sharedQueryTimeout = 30 * time.Second
+
+// ErrBufferSaturated is returned when the async buffer is above its high-watermark
+var ErrBufferSaturated = errors.New("ingestion buffer saturated")
+
 // LogService handles log business logic
 type LogService struct {
 	logRepo       *repository.LogRepository
 	retentionRepo *repository.RetentionRepository
 	alertRepo     *repository.AlertRepository
+	tenants       *TenantService
 	redis         *redis.Client
 	config        *config.Config
 	bufferMu      sync.Mutex
 	buffer        []models.LogEntry
+	flushing      int64
 	flushTicker   *time.Ticker
+	queryGroup    singleflight.Group
+	parser        *MessageParser
+	environments  *EnvironmentDeriver
+	metadataKeys  *MetadataKeyFilter
+	routes        []RetentionRoute
+	dampener      messageDampener
+	serviceNames  serviceCardinality
+	notifier      Notifier
+	digests       alertDigests
+	streams       *StreamBroker
+	maintenance   maintenanceMode
+	pipeline      *IngestionPipeline
+	enricher      *WebhookEnricher
+	stale         staleResults
+	wal           *WriteAheadLog
+}
+
+// RetentionRoute assigns a retention tier to entries matching its filter
+type RetentionRoute struct {
+	Tier   string           `json:"tier"`
+	Filter models.LogFilter `json:"filter"`
 }
 
 // NewLogService creates a new log service
@@ -31,6 +69,7 @@ func NewLogService(
 	logRepo *repository.LogRepository,
 	retentionRepo *repository.RetentionRepository,
 	alertRepo *repository.AlertRepository,
+	tenants *TenantService,
 	redisClient *redis.Client,
 	cfg *config.Config,
 ) *LogService {
@@ -38,13 +77,52 @@ func NewLogService(
 		logRepo:       logRepo,
 		retentionRepo: retentionRepo,
 		alertRepo:     alertRepo,
+		tenants:       tenants,
 		redis:         redisClient,
 		config:        cfg,
 		buffer:        make([]models.LogEntry, 0, 1000),
+		notifier:      logNotifier{},
+		streams:       NewStreamBroker(redisClient),
+		enricher:      NewWebhookEnricher(cfg.Ingestion.EnrichmentURLs, cfg.Ingestion.EnrichmentTimeout),
+	}
+
+	parser, err := NewMessageParser(cfg.Ingestion.MessageParsers)
+	if err != nil {
+		fmt.Printf("Skipping invalid message patterns: %v\n", err)
+	}
+	svc.parser = parser
+
+	environments, err := NewEnvironmentDeriver(cfg.Ingestion.EnvironmentPattern)
+	if err != nil {
+		fmt.Printf("Environment derivation disabled: %v\n", err)
+	}
+	svc.environments = environments
+	svc.metadataKeys = NewMetadataKeyFilter(cfg.Ingestion.MetadataAllowKeys, cfg.Ingestion.MetadataDenyKeys)
+
+	pipeline, err := NewIngestionPipeline(svc.ingestionStages(), cfg.Ingestion.PipelineStages, cfg.Ingestion.PipelineDisabled)
+	if err != nil {
+		fmt.Printf("Invalid ingestion pipeline, using the default: %v\n", err)
+		pipeline, _ = NewIngestionPipeline(svc.ingestionStages(), nil, nil)
+	}
+	svc.pipeline = pipeline
+
+	if cfg.Retention.RoutingRules != "" {
+		if err := json.Unmarshal([]byte(cfg.Retention.RoutingRules), &svc.routes); err != nil {
+			fmt.Printf("Retention routing disabled: %v\n", err)
+		}
+	}
+
+	if cfg.Ingestion.WALEnabled {
+		wal, err := OpenWriteAheadLog(cfg.Ingestion.WALDir)
+		if err != nil {
+			fmt.Printf("Write-ahead log disabled: %v\n", err)
+		} else {
+			svc.wal = wal
+		}
 	}
 
 	// Start background flush
-	svc.flushTicker = time.NewTicker(5 * time.Second)
+	svc.flushTicker = time.NewTicker(FlushInterval)
 	go svc.backgroundFlush()
 
 	return svc
@@ -52,63 +130,266 @@ func NewLogService(
 
 // IngestSingle ingests a single log entry
 func (s *LogService) IngestSingle(ctx context.Context, entry *models.LogEntry) error {
-	// Set defaults
-	if entry.ID == uuid.Nil {
-		entry.ID = uuid.New()
+	if err := ValidateLevel(entry); err != nil {
+		return err
 	}
-	if entry.Timestamp.IsZero() {
-		entry.Timestamp = time.Now().UTC()
+	now := time.Now().UTC()
+	applyEntryDefaults(entry, now)
+
+	kept, run, err := s.ingest(ctx, []models.LogEntry{*entry}, now)
+	if err != nil {
+		s.releaseDedup(ctx, run, nil)
+		return err
+	}
+	if len(kept) == 0 {
+		// Dropped by tenant sampling, dampening or deduplication, or counted
+		if err := s.commitCounters(ctx, run); err != nil {
+			s.releaseDedup(ctx, run, nil)
+			return err
+		}
+		return nil
 	}
+	*entry = kept[0]
+
+	if err := s.logRepo.Create(ctx, entry); err != nil {
+		s.releaseDedup(ctx, run, nil)
+		return err
+	}
+	s.markWrites(ctx, []models.LogEntry{*entry})
+	s.streams.Publish(ctx, []models.LogEntry{*entry})
 
 	// Check alerts asynchronously
-	go s.checkAlerts(context.Background(), *entry)
+	go s.checkAlerts(context.Background(), []models.LogEntry{*entry})
 
-	return s.logRepo.Create(ctx, entry)
+	return nil
 }
 
-// IngestBatch ingests multiple log entries
-func (s *LogService) IngestBatch(ctx context.Context, batch *models.LogBatch) error {
-	entries := batch.Entries
+// IngestBatch ingests multiple log entries. Entries with invalid levels or
+// that fail to insert are left out and listed in batch.Rejected while the
+// rest are stored; the batch fails only when no entry could be stored.
+func (s *LogService) IngestBatch(ctx context.Context, batch *models.LogBatch) (models.BatchResult, error) {
+	// IDs are assigned up front so stored entries can be traced back to
+	// their index in the request
 	now := time.Now().UTC()
-
-	for i := range entries {
-		if entries[i].ID == uuid.Nil {
-			entries[i].ID = uuid.New()
+	total := len(batch.Entries)
+	positions := make(map[uuid.UUID]int, total)
+	for i := range batch.Entries {
+		applyEntryDefaults(&batch.Entries[i], now)
+		if _, ok := positions[batch.Entries[i].ID]; !ok {
+			positions[batch.Entries[i].ID] = i
 		}
-		if entries[i].Timestamp.IsZero() {
-			entries[i].Timestamp = now
+	}
+
+	if err := rejectInvalidLevels(batch); err != nil && len(batch.Entries) == 0 && !batch.Partial {
+		return models.BatchResult{Rejected: total, Failures: batch.Rejected}, err
+	}
+
+	run := NewIngestionRun(now, s.tenants)
+	if batch.Partial {
+		run.rejected = make(map[uuid.UUID]error)
+	}
+	entries, run, err := s.ingestRun(ctx, run, batch.Entries)
+	if err != nil {
+		s.releaseDedup(ctx, run, nil)
+		return models.BatchResult{Rejected: total, Failures: batch.Rejected}, err
+	}
+	for id, err := range run.rejected {
+		batch.Rejected = append(batch.Rejected, models.RejectedEntry{Index: positions[id], Reason: err.Error()})
+	}
+	sort.Slice(batch.Rejected, func(i, j int) bool { return batch.Rejected[i].Index < batch.Rejected[j].Index })
+	entries, batch.DuplicatesCollapsed = s.collapseDuplicateIDs(entries)
+
+	entries, err = s.storeBatch(ctx, batch, entries, positions)
+	batch.Entries = entries
+	if err == nil {
+		if err = s.commitCounters(ctx, run); err != nil && len(entries) > 0 {
+			// Stored rows must not be retried, so only the counts are lost
+			fmt.Printf("%v\n", err)
+			err = nil
 		}
 	}
+	s.releaseDedup(ctx, run, entries)
+	if err != nil {
+		return models.BatchResult{Rejected: total, Failures: batch.Rejected}, err
+	}
+	s.markWrites(ctx, entries)
+	s.streams.Publish(ctx, entries)
 
 	// Check alerts for error/fatal logs
-	go func() {
-		for _, entry := range entries {
-			if entry.Level == models.LogLevelError || entry.Level == models.LogLevelFatal {
-				s.checkAlerts(context.Background(), entry)
+	severe := make([]models.LogEntry, 0)
+	for _, entry := range entries {
+		if entry.Level == models.LogLevelError || entry.Level == models.LogLevelFatal {
+			severe = append(severe, entry)
+		}
+	}
+	if len(severe) > 0 {
+		go s.checkAlerts(context.Background(), severe)
+	}
+
+	return batchResult(batch, total), nil
+}
+
+// storeBatch inserts the entries in one transaction. When that fails, each
+// entry is inserted on its own so one bad row does not lose the batch; the
+// failures are recorded in batch.Rejected by request index. It returns the
+// stored entries, and the first failure when none could be stored.
+func (s *LogService) storeBatch(ctx context.Context, batch *models.LogBatch, entries []models.LogEntry, positions map[uuid.UUID]int) ([]models.LogEntry, error) {
+	err := s.logRepo.CreateBatch(ctx, entries)
+	if err == nil || len(entries) == 0 {
+		return entries, err
+	}
+	fmt.Printf("Batch insert of %d entries failed, inserting individually: %v\n", len(entries), err)
+
+	var first error
+	stored := entries[:0]
+	for _, entry := range entries {
+		if err := s.logRepo.Create(ctx, &entry); err != nil {
+			batch.Rejected = append(batch.Rejected, models.RejectedEntry{Index: positions[entry.ID], Reason: err.Error()})
+			if first == nil {
+				first = err
 			}
+			continue
 		}
-	}()
+		stored = append(stored, entry)
+	}
+	if len(stored) == 0 {
+		return stored, first
+	}
+	sort.Slice(batch.Rejected, func(i, j int) bool { return batch.Rejected[i].Index < batch.Rejected[j].Index })
+	return stored, nil
+}
 
-	return s.logRepo.CreateBatch(ctx, entries)
+// batchResult summarizes an ingested batch of total request entries. Entries
+// dropped by the pipeline, such as sampled ones, count as accepted.
+func batchResult(batch *models.LogBatch, total int) models.BatchResult {
+	return models.BatchResult{
+		Accepted: total - len(batch.Rejected),
+		Rejected: len(batch.Rejected),
+		Failures: batch.Rejected,
+	}
 }
 
-// BufferLog adds a log to the buffer for batch processing
-func (s *LogService) BufferLog(entry models.LogEntry) {
-	if entry.ID == uuid.Nil {
-		entry.ID = uuid.New()
+// IngestBatchChunked ingests entries in sub-batches, reporting progress after
+// each one. A failed sub-batch is counted as rejected and ingestion continues.
+func (s *LogService) IngestBatchChunked(ctx context.Context, entries []models.LogEntry, chunkSize int, progress func(models.BatchProgress)) models.BatchProgress {
+	if chunkSize < 1 {
+		chunkSize = 1000
 	}
-	if entry.Timestamp.IsZero() {
-		entry.Timestamp = time.Now().UTC()
+
+	summary := models.BatchProgress{Type: "summary", Total: len(entries)}
+	for start, n := 0, 1; start < len(entries); start, n = start+chunkSize, n+1 {
+		end := start + chunkSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		frame := models.BatchProgress{Type: "progress", SubBatch: n, Total: len(entries)}
+		chunk := &models.LogBatch{Entries: entries[start:end]}
+		if result, err := s.IngestBatch(ctx, chunk); err != nil {
+			frame.Rejected = end - start
+			frame.Error = err.Error()
+		} else {
+			frame.Accepted = result.Accepted
+			frame.Rejected = result.Rejected
+		}
+
+		summary.AcceptedTotal += frame.Accepted
+		summary.RejectedTotal += frame.Rejected
+		frame.AcceptedTotal = summary.AcceptedTotal
+		frame.RejectedTotal = summary.RejectedTotal
+		progress(frame)
+	}
+
+	summary.Accepted = summary.AcceptedTotal
+	summary.Rejected = summary.RejectedTotal
+	return summary
+}
+
+// routeRetentionTier tags the entry with the tier of the first matching route
+func (s *LogService) routeRetentionTier(entry *models.LogEntry) {
+	if entry.RetentionTier != "" {
+		return
+	}
+	for _, route := range s.routes {
+		if matchesFilter(*entry, route.Filter) {
+			entry.RetentionTier = route.Tier
+			return
+		}
 	}
+}
+
+// BufferLog adds a log to the buffer for batch processing, writing it to the
+// write-ahead log first when enabled. It returns ErrBufferSaturated when
+// pending entries exceed the high-watermark.
+func (s *LogService) BufferLog(entry models.LogEntry) error {
+	if s.BufferSaturated() {
+		return ErrBufferSaturated
+	}
+	if err := ValidateLevel(&entry); err != nil {
+		return err
+	}
+
+	kept, run, err := s.ingest(context.Background(), []models.LogEntry{entry}, time.Now().UTC())
+	if err != nil {
+		s.releaseDedup(context.Background(), run, nil)
+		return err
+	}
+	if len(kept) == 0 {
+		if err := s.commitCounters(context.Background(), run); err != nil {
+			s.releaseDedup(context.Background(), run, nil)
+			return err
+		}
+		return nil
+	}
+	entry = kept[0]
 
 	s.bufferMu.Lock()
+	if s.wal != nil {
+		if err := s.wal.Append(entry); err != nil {
+			s.bufferMu.Unlock()
+			s.releaseDedup(context.Background(), run, nil)
+			return fmt.Errorf("write-ahead log: %w", err)
+		}
+	}
 	s.buffer = append(s.buffer, entry)
-	shouldFlush := len(s.buffer) >= 1000
+	shouldFlush := len(s.buffer) >= 1000 || s.flushesImmediately(entry.Level)
 	s.bufferMu.Unlock()
 
 	if shouldFlush {
 		go s.flushBuffer()
 	}
+	return nil
+}
+
+// flushesImmediately reports whether buffering an entry at this level should
+// flush the buffer without waiting for the ticker
+func (s *LogService) flushesImmediately(level models.LogLevel) bool {
+	for _, l := range s.config.Ingestion.FlushLevels {
+		if strings.EqualFold(l, string(level)) {
+			return true
+		}
+	}
+	return false
+}
+
+// StrippedMetadataKeys returns how many metadata keys ingestion has removed
+func (s *LogService) StrippedMetadataKeys() int64 {
+	return s.metadataKeys.Stripped()
+}
+
+// BufferSaturated reports whether buffered plus in-flight entries have
+// reached the configured high-watermark
+func (s *LogService) BufferSaturated() bool {
+	watermark := s.config.Ingestion.BufferHighWatermark
+	if watermark <= 0 {
+		return false
+	}
+
+	s.bufferMu.Lock()
+	pending := len(s.buffer)
+	s.bufferMu.Unlock()
+
+	return int64(pending)+atomic.LoadInt64(&s.flushing) >= int64(watermark)
 }
 
 // flushBuffer writes buffered logs to the database
@@ -120,15 +401,50 @@ func (s *LogService) flushBuffer() {
 	}
 	entries := s.buffer
 	s.buffer = make([]models.LogEntry, 0, 1000)
+	// The rotated segment holds exactly the entries being flushed
+	var segment string
+	if s.wal != nil {
+		var err error
+		if segment, err = s.wal.Rotate(); err != nil {
+			fmt.Printf("Failed to rotate write-ahead log: %v\n", err)
+		}
+	}
+	atomic.AddInt64(&s.flushing, int64(len(entries)))
 	s.bufferMu.Unlock()
+	defer atomic.AddInt64(&s.flushing, -int64(len(entries)))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Separate async requests may reuse an ID; one must not fail the flush
+	entries, _ = s.collapseDuplicateIDs(entries)
 	if err := s.logRepo.CreateBatch(ctx, entries); err != nil {
 		// Log error (would normally use structured logging)
 		fmt.Printf("Failed to flush log buffer: %v\n", err)
+		return
 	}
+	// A failed flush keeps its segment for replay on the next start
+	if segment != "" {
+		if err := s.wal.Release(segment); err != nil {
+			fmt.Printf("Failed to release write-ahead log segment: %v\n", err)
+		}
+	}
+	s.markWrites(ctx, entries)
+	s.streams.Publish(ctx, entries)
+}
+
+// ReplayWAL stores the entries a previous run left in the write-ahead log,
+// skipping ones already stored, and returns how many were replayed. Call it
+// before accepting traffic.
+func (s *LogService) ReplayWAL(ctx context.Context) (int, error) {
+	if s.wal == nil {
+		return 0, nil
+	}
+	return s.wal.Replay(ctx, func(ctx context.Context, entries []models.LogEntry) error {
+		entries, _ = s.collapseDuplicateIDs(entries)
+		_, err := s.logRepo.CreateBatchSkipDuplicates(ctx, entries)
+		return err
+	})
 }
 
 // backgroundFlush periodically flushes the buffer
@@ -138,30 +454,160 @@ func (s *LogService) backgroundFlush() {
 	}
 }
 
+// Subscribe streams newly ingested entries matching the filter
+func (s *LogService) Subscribe(ctx context.Context, filter models.LogFilter) *StreamSubscription {
+	s.normalizeFilterIDs(&filter)
+	return s.streams.Subscribe(ctx, filter)
+}
+
+// Query page size bounds
+const (
+	// DefaultPageSize applies to queries that omit a page size when their
+	// tenant sets no default of its own
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// applyDefaultPageSize fills in an omitted page size with the tenant's
+// default, falling back to DefaultPageSize
+func (s *LogService) applyDefaultPageSize(ctx context.Context, filter *models.LogFilter) {
+	if filter.PageSize > 0 {
+		return
+	}
+	filter.PageSize = DefaultPageSize
+	if filter.TenantID == nil {
+		return
+	}
+	if settings := s.tenants.CachedSettings(ctx, *filter.TenantID); settings != nil && settings.DefaultPageSize > 0 {
+		filter.PageSize = settings.DefaultPageSize
+	}
+}
+
 // Query searches for log entries
 func (s *LogService) Query(ctx context.Context, filter models.LogFilter) (*models.LogQueryResult, error) {
-	// Try cache first for common queries
+	s.normalizeFilterIDs(&filter)
+	s.applyDefaultPageSize(ctx, &filter)
+	if err := filter.ResolveCursor(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	ctx = s.readContext(ctx, filter.TenantID)
+	profile := queryProfileFrom(ctx)
+
+	// Try cache first for common queries. A tenant that just wrote reads
+	// the primary, skipping results that may predate its write.
 	cacheKey := s.buildCacheKey(filter)
-	if cached, err := s.getCachedResult(ctx, cacheKey); err == nil && cached != nil {
-		return cached, nil
+	groupKey := cacheKey
+	if repository.ReadsPrimary(ctx) {
+		groupKey = "primary:" + cacheKey
+	} else {
+		cacheStart := time.Now()
+		cached, cacheErr := s.getCachedResult(ctx, cacheKey)
+		if profile != nil {
+			profile.CacheMs = ElapsedMs(cacheStart)
+			profile.CacheHit = cacheErr == nil && cached != nil
+		}
+		if cacheErr == nil && cached != nil {
+			return cached, nil
+		}
 	}
 
-	entries, total, err := s.logRepo.Query(ctx, filter)
+	// Coalesce identical concurrent queries into a single DB round-trip
+	if profile != nil {
+		defer func(start time.Time) { profile.DBMs = ElapsedMs(start) }(time.Now())
+	}
+	// The shared query outlives any one caller, so it runs detached with its
+	// own timeout while each caller waits only as long as its own context
+	ch := s.queryGroup.DoChan(groupKey, func() (interface{}, error) {
+		queryCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedQueryTimeout)
+		defer cancel()
+
+		result, err := s.queryPage(queryCtx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		// Cache the result
+		s.cacheResult(queryCtx, cacheKey, result, 30*time.Second)
+		s.rememberStale(queryCtx, cacheKey, result)
+
+		return result, nil
+	})
+
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if res.Err != nil {
+		if stale := s.staleResult(ctx, cacheKey, res.Err); stale != nil {
+			return stale, nil
+		}
+		return nil, res.Err
+	}
+
+	// Callers share the result, so each gets its own copy to modify
+	result := *res.Val.(*models.LogQueryResult)
+	result.Entries = append([]models.LogEntry(nil), result.Entries...)
+	return &result, nil
+}
+
+// queryPage fetches one page of a query by keyset when the filter carries
+// a position, else by offset, and sets the cursor of the page after it
+func (s *LogService) queryPage(ctx context.Context, filter models.LogFilter) (*models.LogQueryResult, error) {
+	result := &models.LogQueryResult{Page: filter.Page, PageSize: filter.PageSize}
+
+	var err error
+	if filter.AfterTimestamp != nil {
+		result.Entries, result.HasMore, err = s.logRepo.QueryCursor(ctx, filter)
+	} else {
+		result.Entries, result.TotalCount, err = s.logRepo.Query(ctx, filter)
+		// Proximity pages are not in timestamp order, so no cursor follows them
+		if filter.AroundTime == nil && len(result.Entries) == filter.PageSize {
+			page := filter.Page
+			if page < 1 {
+				page = 1
+			}
+			result.HasMore = int64(page*filter.PageSize) < result.TotalCount
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	result := &models.LogQueryResult{
-		Entries:    entries,
-		TotalCount: total,
-		Page:       filter.Page,
-		PageSize:   filter.PageSize,
+	if result.HasMore {
+		result.NextCursor = models.EncodeCursor(result.Entries[len(result.Entries)-1])
+	}
+	return result, nil
+}
+
+// QueryApprox returns a sampled, approximate result for exploratory queries
+func (s *LogService) QueryApprox(ctx context.Context, filter models.LogFilter) (*models.LogQueryResult, error) {
+	s.normalizeFilterIDs(&filter)
+	s.applyDefaultPageSize(ctx, &filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	if profile := queryProfileFrom(ctx); profile != nil {
+		defer func(start time.Time) { profile.DBMs = ElapsedMs(start) }(time.Now())
+	}
+	entries, total, err := s.logRepo.QueryApprox(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	// Cache the result
-	s.cacheResult(ctx, cacheKey, result, 30*time.Second)
+	return &models.LogQueryResult{
+		Entries:     entries,
+		TotalCount:  total,
+		Page:        1,
+		PageSize:    filter.PageSize,
+		Approximate: true,
+	}, nil
+}
 
-	return result, nil
+// Count returns the number of entries matching the filter
+func (s *LogService) Count(ctx context.Context, filter models.LogFilter) (int64, error) {
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	return s.logRepo.Count(ctx, filter)
 }
 
 // GetByID retrieves a single log entry
@@ -171,7 +617,7 @@ func (s *LogService) GetByID(ctx context.Context, id uuid.UUID) (*models.LogEntr
 
 // GetByTraceID retrieves all logs for a trace
 func (s *LogService) GetByTraceID(ctx context.Context, traceID string) ([]models.LogEntry, error) {
-	return s.logRepo.GetByTraceID(ctx, traceID)
+	return s.logRepo.GetByTraceID(ctx, NormalizeTraceID(traceID, s.config.Ingestion.TraceIDFormat))
 }
 
 // GetByRequestID retrieves all logs for a request
@@ -179,21 +625,134 @@ func (s *LogService) GetByRequestID(ctx context.Context, requestID string) ([]mo
 	return s.logRepo.GetByRequestID(ctx, requestID)
 }
 
+// GetByRequestIDs retrieves all logs for a group of related requests
+func (s *LogService) GetByRequestIDs(ctx context.Context, requestIDs []string) ([]models.LogEntry, error) {
+	if len(requestIDs) == 1 {
+		return s.logRepo.GetByRequestID(ctx, requestIDs[0])
+	}
+	return s.logRepo.GetByRequestIDs(ctx, requestIDs)
+}
+
 // GetStats retrieves aggregated statistics
 func (s *LogService) GetStats(ctx context.Context, tenantID *uuid.UUID, startTime, endTime time.Time) (*models.LogStats, error) {
-	return s.logRepo.GetStats(ctx, tenantID, startTime, endTime)
+	ctx = s.readContext(ctx, tenantID)
+	stats, err := s.logRepo.GetStats(ctx, tenantID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := s.logRepo.CounterTotals(ctx, tenantID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	addCounterTotals(stats, totals)
+	return stats, nil
 }
 
 // Aggregate retrieves time-bucketed aggregations
 func (s *LogService) Aggregate(ctx context.Context, filter models.LogFilter, interval string) ([]models.LogAggregation, error) {
-	return s.logRepo.Aggregate(ctx, filter, interval)
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	aggregations, err := s.logRepo.Aggregate(ctx, filter, interval)
+	if err != nil || !countersApply(filter) {
+		return aggregations, err
+	}
+
+	counters, err := s.logRepo.CounterAggregate(ctx, filter, interval)
+	if err != nil {
+		return nil, err
+	}
+	return withCounters(aggregations, counters, false), nil
+}
+
+// AggregateByService returns time-bucketed counts per service, for
+// comparing services in a single call
+func (s *LogService) AggregateByService(ctx context.Context, filter models.LogFilter, interval string) ([]models.LogAggregation, error) {
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	aggregations, err := s.logRepo.AggregateByService(ctx, filter, interval)
+	if err != nil || !countersApply(filter) {
+		return aggregations, err
+	}
+
+	counters, err := s.logRepo.CounterAggregate(ctx, filter, interval)
+	if err != nil {
+		return nil, err
+	}
+	return withCounters(aggregations, counters, true), nil
+}
+
+// GroupErrors groups matching error entries by error type, returning up to
+// limit groups
+func (s *LogService) GroupErrors(ctx context.Context, filter models.LogFilter, limit int) ([]models.ErrorGroup, error) {
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	return s.logRepo.GroupByErrorType(ctx, filter, limit)
+}
+
+// SearchWithAggregation returns a page of matching entries together with
+// their time-bucketed aggregation in one call
+func (s *LogService) SearchWithAggregation(ctx context.Context, filter models.LogFilter, interval string) (*models.LogSearchWithAggregation, error) {
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	entries, total, aggregations, err := s.logRepo.SearchWithAggregation(ctx, filter, interval)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LogSearchWithAggregation{
+		Entries:     entries,
+		Total:       total,
+		Aggregation: aggregations,
+	}, nil
+}
+
+// PurgeService deletes every log of a decommissioned service. A nil tenant
+// purges the service across all tenants.
+func (s *LogService) PurgeService(ctx context.Context, tenantID *uuid.UUID, serviceName string) (int64, error) {
+	deleted, err := s.logRepo.DeleteByService(ctx, tenantID, serviceName)
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		fmt.Printf("Purged %d log entries of service %s\n", deleted, serviceName)
+	}
+	return deleted, nil
+}
+
+// CountAffectedTraces counts distinct traces with logs matching the filter
+func (s *LogService) CountAffectedTraces(ctx context.Context, filter models.LogFilter) (int64, error) {
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	return s.logRepo.CountDistinctTraces(ctx, filter)
 }
 
 // GetServices returns available service names
 func (s *LogService) GetServices(ctx context.Context, tenantID *uuid.UUID) ([]string, error) {
+	ctx = s.readContext(ctx, tenantID)
 	return s.logRepo.GetServices(ctx, tenantID)
 }
 
+// GetSources returns the distinct origins entries were collected from
+func (s *LogService) GetSources(ctx context.Context, tenantID *uuid.UUID) ([]string, error) {
+	ctx = s.readContext(ctx, tenantID)
+	return s.logRepo.GetSources(ctx, tenantID)
+}
+
+// GetFirstPerService returns the earliest log entry for each service
+func (s *LogService) GetFirstPerService(ctx context.Context, tenantID *uuid.UUID) ([]models.ServiceLogEdge, error) {
+	ctx = s.readContext(ctx, tenantID)
+	return s.logRepo.GetServiceEdges(ctx, tenantID, false)
+}
+
+// GetLastPerService returns the latest log entry for each service
+func (s *LogService) GetLastPerService(ctx context.Context, tenantID *uuid.UUID) ([]models.ServiceLogEdge, error) {
+	ctx = s.readContext(ctx, tenantID)
+	return s.logRepo.GetServiceEdges(ctx, tenantID, true)
+}
+
 // GetStorageSize returns storage usage
 func (s *LogService) GetStorageSize(ctx context.Context, tenantID *uuid.UUID) (int64, error) {
 	return s.logRepo.GetStorageSize(ctx, tenantID)
@@ -201,76 +760,80 @@ func (s *LogService) GetStorageSize(ctx context.Context, tenantID *uuid.UUID) (i
 
 // Cleanup removes old log entries based on retention policies
 func (s *LogService) Cleanup(ctx context.Context) error {
+	if status := s.MaintenanceStatus(); status.Enabled {
+		fmt.Printf("Skipping log cleanup: maintenance mode on since %s (%s)\n", status.Since.Format(time.RFC3339), status.Reason)
+		return nil
+	}
+
 	policies, err := s.retentionRepo.FindAll(ctx)
 	if err != nil {
 		return err
 	}
 
+	// Tiered entries follow their tier's retention instead of the tenant's;
+	// a tier of zero days or fewer is kept forever, as in CheckEntryAge
+	var deleted int64
+	tiers := make([]string, 0, len(s.config.Retention.TierDays))
+	for tier, days := range s.config.Retention.TierDays {
+		tiers = append(tiers, tier)
+		if days <= 0 {
+			continue
+		}
+		cutoff := time.Now().AddDate(0, 0, -days)
+		n, err := s.logRepo.DeleteOlderThanInTier(ctx, tier, cutoff)
+		if err != nil {
+			fmt.Printf("Failed to cleanup logs for retention tier %s: %v\n", tier, err)
+		}
+		deleted += n
+	}
+
 	// Apply tenant-specific retention
 	for _, policy := range policies {
 		cutoff := time.Now().AddDate(0, 0, -policy.RetentionDays)
-		_, err := s.logRepo.DeleteOlderThan(ctx, &policy.TenantID, cutoff)
+		n, err := s.logRepo.DeleteOlderThan(ctx, &policy.TenantID, cutoff, tiers)
 		if err != nil {
 			fmt.Printf("Failed to cleanup logs for tenant %s: %v\n", policy.TenantID, err)
 		}
+		deleted += n
 	}
 
 	// Apply default retention for logs without tenant-specific policy
 	defaultCutoff := time.Now().AddDate(0, 0, -s.config.Retention.RetentionDays)
-	_, err = s.logRepo.DeleteOlderThan(ctx, nil, defaultCutoff)
-
-	return err
-}
-
-// checkAlerts evaluates alerts for the given log entry
-func (s *LogService) checkAlerts(ctx context.Context, entry models.LogEntry) {
-	alerts, err := s.alertRepo.FindEnabled(ctx)
-	if err != nil {
-		return
-	}
+	n, err := s.logRepo.DeleteOlderThan(ctx, nil, defaultCutoff, tiers)
+	deleted += n
+	s.analyzeAfterCleanup(ctx, deleted)
 
-	for _, alert := range alerts {
-		if s.matchesAlert(entry, alert) {
-			s.triggerAlert(ctx, alert, entry)
-		}
+	if _, pruneErr := s.PruneAlertEvents(ctx, time.Now()); pruneErr != nil {
+		fmt.Printf("Failed to prune alert events: %v\n", pruneErr)
 	}
-}
-
-// matchesAlert checks if a log entry matches an alert filter
-func (s *LogService) matchesAlert(entry models.LogEntry, alert models.LogAlert) bool {
-	// Parse the filter from JSON
-	var filter models.LogFilter
-	if err := json.Unmarshal(alert.Filter, &filter); err != nil {
-		return false
+	if _, pruneErr := s.PruneAlertOutbox(ctx, time.Now()); pruneErr != nil {
+		fmt.Printf("Failed to prune alert outbox: %v\n", pruneErr)
 	}
 
-	if filter.ServiceName != "" && filter.ServiceName != entry.ServiceName {
-		return false
-	}
+	return err
+}
 
-	if filter.Level != "" && filter.Level != entry.Level {
-		return false
+// analyzeAfterCleanup refreshes planner statistics once a cleanup deleted at
+// least the configured threshold of rows
+func (s *LogService) analyzeAfterCleanup(ctx context.Context, deleted int64) {
+	threshold := s.config.Retention.AnalyzeThreshold
+	if threshold <= 0 || deleted < int64(threshold) {
+		return
 	}
-
-	if filter.TenantID != nil && *filter.TenantID != entry.TenantID {
-		return false
+	if err := s.logRepo.Analyze(ctx, s.config.Retention.VacuumAfterCleanup); err != nil {
+		fmt.Printf("Failed to analyze log_entries after deleting %d rows: %v\n", deleted, err)
+		return
 	}
-
-	return true
+	fmt.Printf("Analyzed log_entries after cleanup deleted %d rows\n", deleted)
 }
 
-// triggerAlert handles alert triggering
-func (s *LogService) triggerAlert(ctx context.Context, alert models.LogAlert, entry models.LogEntry) {
-	// Rate limit alerts (minimum 1 minute between triggers)
-	if alert.LastTriggered != nil && time.Since(*alert.LastTriggered) < time.Minute {
-		return
+// PruneAlertEvents removes alert history older than the configured retention
+func (s *LogService) PruneAlertEvents(ctx context.Context, now time.Time) (int64, error) {
+	days := s.config.Retention.AlertEventDays
+	if days <= 0 {
+		return 0, nil
 	}
-
-	// Update last triggered
-	s.alertRepo.UpdateLastTriggered(ctx, alert.ID)
-
-	// Would normally send to notification channels here
-	fmt.Printf("Alert triggered: %s for log: %s\n", alert.Name, entry.Message)
+	return s.alertRepo.DeleteEventsOlderThan(ctx, now.AddDate(0, 0, -days))
 }
 
 // Cache helpers
@@ -316,4 +879,7 @@ func (s *LogService) Close() {
 		s.flushTicker.Stop()
 	}
 	s.flushBuffer()
+	if s.wal != nil {
+		s.wal.Close()
+	}
 }
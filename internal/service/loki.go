@@ -0,0 +1,317 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// ErrInvalidLokiPush is returned for push payloads that are not valid Loki JSON
+var ErrInvalidLokiPush = errors.New("invalid loki push payload")
+
+// Loki label names mapped onto entry fields, in order of preference
+var (
+	lokiServiceLabels     = []string{"service_name", "service", "app", "job"}
+	lokiEnvironmentLabels = []string{"environment", "env"}
+	lokiLevelLabels       = []string{"level", "detected_level", "severity"}
+	lokiHostLabels        = []string{"host", "hostname", "instance"}
+)
+
+// LokiPushEntries converts a Loki push request into log entries. Well-known
+// labels become the service, environment, level and host; the remaining
+// labels are kept under metadata "labels" and structured metadata is merged
+// into the entry metadata.
+func LokiPushEntries(req models.LokiPushRequest, tenantID uuid.UUID) ([]models.LogEntry, error) {
+	var entries []models.LogEntry
+
+	for i, stream := range req.Streams {
+		labels := make(map[string]string, len(stream.Stream))
+		for k, v := range stream.Stream {
+			labels[k] = v
+		}
+
+		service := takeLabel(labels, lokiServiceLabels)
+		environment := takeLabel(labels, lokiEnvironmentLabels)
+		level := takeLabel(labels, lokiLevelLabels)
+		host := takeLabel(labels, lokiHostLabels)
+		if service == "" {
+			service = "unknown"
+		}
+
+		for j, value := range stream.Values {
+			if len(value) < 2 {
+				return nil, fmt.Errorf("%w: streams[%d].values[%d] needs a timestamp and a line", ErrInvalidLokiPush, i, j)
+			}
+
+			var tsRaw, line string
+			if err := json.Unmarshal(value[0], &tsRaw); err != nil {
+				return nil, fmt.Errorf("%w: streams[%d].values[%d] timestamp must be a string", ErrInvalidLokiPush, i, j)
+			}
+			ns, err := strconv.ParseInt(tsRaw, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: streams[%d].values[%d] timestamp must be unix nanoseconds", ErrInvalidLokiPush, i, j)
+			}
+			if err := json.Unmarshal(value[1], &line); err != nil {
+				return nil, fmt.Errorf("%w: streams[%d].values[%d] line must be a string", ErrInvalidLokiPush, i, j)
+			}
+
+			metadata := make(map[string]interface{})
+			if len(value) > 2 {
+				var structured map[string]string
+				if err := json.Unmarshal(value[2], &structured); err != nil {
+					return nil, fmt.Errorf("%w: streams[%d].values[%d] structured metadata must be an object", ErrInvalidLokiPush, i, j)
+				}
+				for k, v := range structured {
+					metadata[k] = v
+				}
+			}
+			if len(labels) > 0 {
+				metadata["labels"] = labels
+			}
+
+			entry := models.LogEntry{
+				TenantID:    tenantID,
+				ServiceName: service,
+				Level:       lokiLevel(level),
+				Message:     line,
+				Timestamp:   time.Unix(0, ns).UTC(),
+				Host:        host,
+				Environment: environment,
+			}
+			if len(metadata) > 0 {
+				entry.Metadata, _ = json.Marshal(metadata)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// takeLabel removes and returns the first present label among names
+func takeLabel(labels map[string]string, names []string) string {
+	value := ""
+	for _, name := range names {
+		if v, ok := labels[name]; ok {
+			if value == "" {
+				value = v
+			}
+			delete(labels, name)
+		}
+	}
+	return value
+}
+
+// lokiLevel maps the level names common in Loki setups to ours, defaulting
+// to INFO
+func lokiLevel(level string) models.LogLevel {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case "TRACE", "DEBUG", "DBUG":
+		return models.LogLevelDebug
+	case "WARN", "WARNING":
+		return models.LogLevelWarn
+	case "ERROR", "ERR", "EROR":
+		return models.LogLevelError
+	case "FATAL", "CRITICAL", "CRIT", "PANIC":
+		return models.LogLevelFatal
+	default:
+		return models.LogLevelInfo
+	}
+}
+
+// ErrInvalidLokiQuery is returned for LogQL the compatibility layer cannot translate
+var ErrInvalidLokiQuery = errors.New("unsupported loki query")
+
+// ParseLokiQuery translates a LogQL-lite query into a filter. Supported is a
+// stream selector of equality matchers on service, environment and level
+// labels, optionally followed by one |= line filter, e.g.
+// {service="api", env="prod"} |= "timeout". The line filter is matched
+// case-insensitively.
+func ParseLokiQuery(query string) (models.LogFilter, error) {
+	var filter models.LogFilter
+	p := logqlParser{input: strings.TrimSpace(query)}
+
+	if !p.consume("{") {
+		return filter, fmt.Errorf("%w: expected a stream selector", ErrInvalidLokiQuery)
+	}
+	for !p.consume("}") {
+		name := p.identifier()
+		if name == "" {
+			return filter, fmt.Errorf("%w: expected a label name at offset %d", ErrInvalidLokiQuery, p.pos)
+		}
+		if !p.consume("=") || p.peek() == '~' {
+			return filter, fmt.Errorf("%w: only = matchers are supported", ErrInvalidLokiQuery)
+		}
+		value, err := p.quoted()
+		if err != nil {
+			return filter, err
+		}
+
+		switch {
+		case containsString(lokiServiceLabels, name):
+			filter.ServiceName = value
+		case containsString(lokiEnvironmentLabels, name):
+			filter.Environment = value
+		case containsString(lokiLevelLabels, name):
+			filter.Level = lokiLevel(value)
+		default:
+			return filter, fmt.Errorf("%w: label %q cannot be filtered on", ErrInvalidLokiQuery, name)
+		}
+
+		if !p.consume(",") && p.peek() != '}' {
+			return filter, fmt.Errorf("%w: expected , or } at offset %d", ErrInvalidLokiQuery, p.pos)
+		}
+	}
+
+	for !p.done() {
+		if !p.consume("|=") {
+			return filter, fmt.Errorf("%w: only |= line filters are supported", ErrInvalidLokiQuery)
+		}
+		if filter.Search != "" {
+			return filter, fmt.Errorf("%w: only one line filter is supported", ErrInvalidLokiQuery)
+		}
+		value, err := p.quoted()
+		if err != nil {
+			return filter, err
+		}
+		filter.Search = value
+	}
+
+	return filter, nil
+}
+
+// ParseLokiTime parses a Loki time parameter: unix nanoseconds, unix seconds
+// with an optional fraction, or RFC3339
+func ParseLokiTime(value string) (time.Time, error) {
+	if ns, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Loki treats short integers as seconds
+		if len(value) <= 10 {
+			return time.Unix(ns, 0).UTC(), nil
+		}
+		return time.Unix(0, ns).UTC(), nil
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))).UTC(), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// LokiStreams groups entries into Loki streams keyed by their labels. Entries
+// keep their order within a stream.
+func LokiStreams(entries []models.LogEntry) models.LokiQueryResponse {
+	result := make([]models.LokiStreamResult, 0)
+	index := make(map[string]int)
+
+	for _, entry := range entries {
+		labels := map[string]string{
+			"service_name": entry.ServiceName,
+			"level":        strings.ToLower(string(entry.Level)),
+		}
+		if entry.Environment != "" {
+			labels["environment"] = entry.Environment
+		}
+		if entry.Host != "" {
+			labels["host"] = entry.Host
+		}
+
+		key := entry.ServiceName + "\x00" + string(entry.Level) + "\x00" + entry.Environment + "\x00" + entry.Host
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			result = append(result, models.LokiStreamResult{Stream: labels, Values: [][2]string{}})
+		}
+		result[i].Values = append(result[i].Values, [2]string{
+			strconv.FormatInt(entry.Timestamp.UnixNano(), 10),
+			entry.Message,
+		})
+	}
+
+	return models.LokiQueryResponse{
+		Status: "success",
+		Data:   models.LokiQueryData{ResultType: "streams", Result: result},
+	}
+}
+
+// logqlParser is a minimal scanner over a LogQL-lite query
+type logqlParser struct {
+	input string
+	pos   int
+}
+
+func (p *logqlParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *logqlParser) done() bool {
+	p.skipSpace()
+	return p.pos >= len(p.input)
+}
+
+func (p *logqlParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// consume advances past token if it comes next
+func (p *logqlParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// identifier reads a label name
+func (p *logqlParser) identifier() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (p.pos > start && '0' <= c && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// quoted reads a double-quoted or backtick string literal
+func (p *logqlParser) quoted() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) || (p.input[p.pos] != '"' && p.input[p.pos] != '`') {
+		return "", fmt.Errorf("%w: expected a string at offset %d", ErrInvalidLokiQuery, p.pos)
+	}
+
+	quote := p.input[p.pos]
+	end := p.pos + 1
+	for end < len(p.input) && p.input[end] != quote {
+		if quote == '"' && p.input[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(p.input) {
+		return "", fmt.Errorf("%w: unterminated string at offset %d", ErrInvalidLokiQuery, p.pos)
+	}
+
+	value, err := strconv.Unquote(p.input[p.pos : end+1])
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid string at offset %d", ErrInvalidLokiQuery, p.pos)
+	}
+	p.pos = end + 1
+	return value, nil
+}
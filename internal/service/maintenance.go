@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// ErrMaintenanceMode is returned by destructive jobs started while
+// maintenance mode is on
+var ErrMaintenanceMode = errors.New("maintenance mode is on")
+
+// maintenanceMode is the in-memory maintenance flag. It is per process and
+// resets on restart.
+type maintenanceMode struct {
+	mu     sync.RWMutex
+	status models.MaintenanceStatus
+}
+
+// SetMaintenanceMode turns maintenance mode on or off. While on, Cleanup and
+// other destructive background jobs skip and archival is refused; ingestion
+// and queries continue.
+func (s *LogService) SetMaintenanceMode(enabled bool, reason string) models.MaintenanceStatus {
+	s.maintenance.mu.Lock()
+	defer s.maintenance.mu.Unlock()
+
+	if !enabled {
+		s.maintenance.status = models.MaintenanceStatus{}
+		return s.maintenance.status
+	}
+
+	if !s.maintenance.status.Enabled {
+		now := time.Now().UTC()
+		s.maintenance.status.Since = &now
+	}
+	s.maintenance.status.Enabled = true
+	s.maintenance.status.Reason = reason
+	return s.maintenance.status
+}
+
+// MaintenanceStatus returns the current maintenance mode state
+func (s *LogService) MaintenanceStatus() models.MaintenanceStatus {
+	s.maintenance.mu.RLock()
+	defer s.maintenance.mu.RUnlock()
+	return s.maintenance.status
+}
+
+// InMaintenance reports whether maintenance mode is on
+func (s *LogService) InMaintenance() bool {
+	return s.MaintenanceStatus().Enabled
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// Max-age modes for entries older than their retention
+const (
+	MaxAgeModeAccept = "accept"
+	MaxAgeModeReject = "reject"
+)
+
+// ErrEntryTooOld is returned when an entry would be deleted by the next
+// cleanup because it is older than its retention
+var ErrEntryTooOld = errors.New("entry is older than its retention")
+
+// CheckEntryAge returns ErrEntryTooOld when the entry's timestamp falls
+// before the retention cutoff; retentionDays <= 0 keeps entries forever
+func CheckEntryAge(entry models.LogEntry, retentionDays int, now time.Time) error {
+	if retentionDays <= 0 {
+		return nil
+	}
+	cutoff := now.AddDate(0, 0, -retentionDays)
+	if entry.Timestamp.Before(cutoff) {
+		return fmt.Errorf("%w: timestamp %s is before the %d-day retention cutoff %s",
+			ErrEntryTooOld, entry.Timestamp.Format(time.RFC3339), retentionDays, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// checkMaxAge applies the configured max-age mode to prepared entries. In
+// reject mode the first too-old entry fails the whole request; otherwise
+// too-old entries are stored with a warning.
+func (s *LogService) checkMaxAge(ctx context.Context, entries []models.LogEntry, now time.Time) error {
+	tenantDays := make(map[uuid.UUID]int)
+
+	// Retention is at least a day, so fresh entries skip the policy lookup
+	recent := now.AddDate(0, 0, -1)
+
+	for i, entry := range entries {
+		if !entry.Timestamp.Before(recent) {
+			continue
+		}
+		err := CheckEntryAge(entry, s.entryRetentionDays(ctx, entry, tenantDays), now)
+		if err == nil {
+			continue
+		}
+		if s.config.Ingestion.MaxAgeMode == MaxAgeModeReject {
+			if len(entries) > 1 {
+				return fmt.Errorf("entries[%d]: %w", i, err)
+			}
+			return err
+		}
+		fmt.Printf("Warning: ingesting entry that the next cleanup will delete (tenant %s): %v\n", entry.TenantID, err)
+	}
+	return nil
+}
+
+// entryRetentionDays resolves the retention that will apply to an entry,
+// mirroring Cleanup: its tier, then its tenant's policy, then the default.
+// Tenant lookups are memoized in tenantDays.
+func (s *LogService) entryRetentionDays(ctx context.Context, entry models.LogEntry, tenantDays map[uuid.UUID]int) int {
+	if days, ok := s.config.Retention.TierDays[entry.RetentionTier]; ok && entry.RetentionTier != "" {
+		return days
+	}
+
+	days, ok := tenantDays[entry.TenantID]
+	if !ok {
+		days = s.config.Retention.RetentionDays
+		if policy, err := s.retentionRepo.FindByTenantID(ctx, entry.TenantID); err == nil && policy != nil {
+			days = policy.RetentionDays
+		}
+		tenantDays[entry.TenantID] = days
+	}
+	return days
+}
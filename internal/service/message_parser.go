@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// Built-in message patterns
+var builtinPatterns = map[string]string{
+	// Apache/NCSA common log format
+	"common": `^(?P<client_ip>\S+) \S+ (?P<remote_user>\S+) \[(?P<time>[^\]]+)\] "(?P<method>[A-Z]+) (?P<path>\S+) (?P<protocol>[^"]+)" (?P<status>\d{3}) (?P<bytes>\d+|-)`,
+	// Apache/NCSA combined log format
+	"combined": `^(?P<client_ip>\S+) \S+ (?P<remote_user>\S+) \[(?P<time>[^\]]+)\] "(?P<method>[A-Z]+) (?P<path>\S+) (?P<protocol>[^"]+)" (?P<status>\d{3}) (?P<bytes>\d+|-) "(?P<referer>[^"]*)" "(?P<user_agent>[^"]*)"`,
+	// Short request lines such as "GET /api/users 200 145ms"
+	"request": `^(?P<method>[A-Z]+) (?P<path>\S+) (?P<status>\d{3}) (?P<latency_ms>\d+)ms`,
+}
+
+// MessageParser extracts structured fields from unstructured messages
+type MessageParser struct {
+	patterns map[string]*regexp.Regexp
+}
+
+// NewMessageParser compiles per-service parsing rules. A rule is either the
+// name of a built-in pattern or a regex with named capture groups. Invalid
+// rules are reported in the error and skipped; the returned parser still
+// applies every valid rule.
+func NewMessageParser(rules map[string]string) (*MessageParser, error) {
+	parser := &MessageParser{patterns: make(map[string]*regexp.Regexp)}
+
+	var errs []error
+	for service, rule := range rules {
+		pattern := rule
+		if builtin, ok := builtinPatterns[rule]; ok {
+			pattern = builtin
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid message pattern for service %s: %w", service, err))
+			continue
+		}
+		parser.patterns[service] = re
+	}
+
+	return parser, errors.Join(errs...)
+}
+
+// Apply merges fields extracted from the message into the entry metadata.
+// Existing metadata keys are never overwritten.
+func (p *MessageParser) Apply(entry *models.LogEntry) {
+	if p == nil {
+		return
+	}
+
+	re, ok := p.patterns[entry.ServiceName]
+	if !ok {
+		return
+	}
+
+	match := re.FindStringSubmatch(entry.Message)
+	if match == nil {
+		return
+	}
+
+	// Values stay as raw JSON so large integers are never re-encoded via float64
+	metadata := make(map[string]json.RawMessage)
+	if len(entry.Metadata) > 0 {
+		if err := json.Unmarshal(entry.Metadata, &metadata); err != nil {
+			// Non-object metadata is left untouched
+			return
+		}
+	}
+
+	for i, name := range re.SubexpNames() {
+		if name == "" || match[i] == "" || match[i] == "-" {
+			continue
+		}
+		if _, exists := metadata[name]; exists {
+			continue
+		}
+		metadata[name] = extractedValue(match[i])
+	}
+
+	if data, err := json.Marshal(metadata); err == nil {
+		entry.Metadata = data
+	}
+}
+
+// extractedValue encodes integers as JSON numbers and everything else as
+// strings. Integers are re-encoded, since captures such as "007" or "+5" are
+// not valid JSON numbers as written.
+func extractedValue(value string) json.RawMessage {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		data, _ := json.Marshal(n)
+		return data
+	}
+	data, _ := json.Marshal(value)
+	return data
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// FullMessageKey is the metadata key holding the gzip+base64 encoded
+// original message when truncation preserves it
+const FullMessageKey = "_full_message_gz"
+
+// TruncateMessage shortens messages longer than maxLen bytes, appending a
+// marker with the number of bytes removed. When preserveFull is set the
+// original message is stored compressed in metadata.
+func TruncateMessage(entry *models.LogEntry, maxLen int, preserveFull bool) {
+	if maxLen <= 0 || len(entry.Message) <= maxLen {
+		return
+	}
+
+	// Never cut a multi-byte character in half
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(entry.Message[cut]) {
+		cut--
+	}
+
+	original := entry.Message
+	entry.Message = fmt.Sprintf("%s…[truncated %d bytes]", original[:cut], len(original)-cut)
+
+	if preserveFull {
+		storeFullMessage(entry, original)
+	}
+}
+
+// storeFullMessage adds the compressed original message to the entry metadata
+func storeFullMessage(entry *models.LogEntry, message string) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(message)); err != nil {
+		return
+	}
+	if err := zw.Close(); err != nil {
+		return
+	}
+
+	metadata := make(map[string]json.RawMessage)
+	if len(entry.Metadata) > 0 {
+		if err := json.Unmarshal(entry.Metadata, &metadata); err != nil {
+			return
+		}
+	}
+
+	encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	metadata[FullMessageKey] = encoded
+
+	if data, err := json.Marshal(metadata); err == nil {
+		entry.Metadata = data
+	}
+}
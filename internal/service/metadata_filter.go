@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// MetadataKeyFilter removes disallowed top-level metadata keys. When an
+// allowlist is set only those keys survive; denylisted keys are always
+// removed. It complements redaction by dropping whole fields.
+type MetadataKeyFilter struct {
+	allow    map[string]bool
+	deny     map[string]bool
+	stripped int64
+}
+
+// NewMetadataKeyFilter creates a filter, or nil when both lists are empty
+func NewMetadataKeyFilter(allow, deny []string) *MetadataKeyFilter {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	f := &MetadataKeyFilter{allow: make(map[string]bool), deny: make(map[string]bool)}
+	for _, key := range allow {
+		f.allow[key] = true
+	}
+	for _, key := range deny {
+		f.deny[key] = true
+	}
+	return f
+}
+
+// Apply strips disallowed keys from the entry metadata, returning how many
+// were removed. Non-object metadata is left untouched.
+func (f *MetadataKeyFilter) Apply(entry *models.LogEntry) int {
+	if f == nil || len(entry.Metadata) == 0 {
+		return 0
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry.Metadata, &fields); err != nil {
+		return 0
+	}
+
+	removed := 0
+	for key := range fields {
+		if f.deny[key] || (len(f.allow) > 0 && !f.allow[key]) {
+			delete(fields, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0
+	}
+
+	if data, err := json.Marshal(fields); err == nil {
+		entry.Metadata = data
+	}
+	atomic.AddInt64(&f.stripped, int64(removed))
+	return removed
+}
+
+// Stripped returns the total number of keys removed so far
+func (f *MetadataKeyFilter) Stripped() int64 {
+	if f == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&f.stripped)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"gorm.io/gorm"
+)
+
+// maxBucketsPerEvaluation bounds backfill work for a single rule evaluation
+const maxBucketsPerEvaluation = 60
+
+// ErrInvalidMetricRule is returned when a metric rule definition is invalid
+var ErrInvalidMetricRule = errors.New("invalid metric rule")
+
+// ErrMetricRuleNotFound is returned for unknown metric rule IDs, including
+// rules of other tenants
+var ErrMetricRuleNotFound = errors.New("metric rule not found")
+
+// MetricService handles log-to-metric rule business logic
+type MetricService struct {
+	repo    *repository.MetricRepository
+	logRepo *repository.LogRepository
+}
+
+// NewMetricService creates a new metric service
+func NewMetricService(repo *repository.MetricRepository, logRepo *repository.LogRepository) *MetricService {
+	return &MetricService{repo: repo, logRepo: logRepo}
+}
+
+// CreateRule creates a new metric rule
+func (s *MetricService) CreateRule(ctx context.Context, rule *models.MetricRule) error {
+	if rule.ID == uuid.Nil {
+		rule.ID = uuid.New()
+	}
+	if err := validateMetricRule(rule); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, rule)
+}
+
+// UpdateRule copies the editable fields of changes onto the tenant's rule
+// with the given ID and saves it. The tenant, creation time and evaluation
+// progress of the stored rule are kept.
+func (s *MetricService) UpdateRule(ctx context.Context, tenantID, id uuid.UUID, changes models.MetricRule) (*models.MetricRule, error) {
+	rule, err := s.GetRule(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Name = changes.Name
+	rule.Description = changes.Description
+	rule.Enabled = changes.Enabled
+	rule.Filter = changes.Filter
+	rule.Aggregation = changes.Aggregation
+	rule.Field = changes.Field
+	rule.IntervalMins = changes.IntervalMins
+
+	if err := validateMetricRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// GetRule retrieves a tenant's metric rule by ID. Rules of other tenants
+// are reported as ErrMetricRuleNotFound, as by DeleteRule and GetSeries.
+func (s *MetricService) GetRule(ctx context.Context, tenantID, id uuid.UUID) (*models.MetricRule, error) {
+	rule, err := s.repo.FindByID(ctx, tenantID, id)
+	if err != nil {
+		return nil, metricRuleError(err)
+	}
+	return rule, nil
+}
+
+// GetRulesByTenant retrieves all metric rules for a tenant
+func (s *MetricService) GetRulesByTenant(ctx context.Context, tenantID uuid.UUID) ([]models.MetricRule, error) {
+	return s.repo.FindByTenantID(ctx, tenantID)
+}
+
+// DeleteRule removes a tenant's metric rule and its series
+func (s *MetricService) DeleteRule(ctx context.Context, tenantID, id uuid.UUID) error {
+	return metricRuleError(s.repo.Delete(ctx, tenantID, id))
+}
+
+// GetSeries retrieves evaluated values for a tenant's rule
+func (s *MetricService) GetSeries(ctx context.Context, tenantID, id uuid.UUID, start, end time.Time) ([]models.MetricPoint, error) {
+	if _, err := s.GetRule(ctx, tenantID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.FindSeries(ctx, id, start, end)
+}
+
+// metricRuleError maps a missing rule onto ErrMetricRuleNotFound
+func metricRuleError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMetricRuleNotFound
+	}
+	return err
+}
+
+// EvaluateRules evaluates every enabled rule for buckets completed before now
+func (s *MetricService) EvaluateRules(ctx context.Context, now time.Time) error {
+	rules, err := s.repo.FindEnabled(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if err := s.EvaluateRule(ctx, rule, now); err != nil {
+			fmt.Printf("Failed to evaluate metric rule %s: %v\n", rule.ID, err)
+		}
+	}
+	return nil
+}
+
+// EvaluateRule computes values for each completed bucket since the rule was last evaluated
+func (s *MetricService) EvaluateRule(ctx context.Context, rule models.MetricRule, now time.Time) error {
+	var filter models.LogFilter
+	if err := json.Unmarshal(rule.Filter, &filter); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMetricRule, err)
+	}
+	// Rules never read across tenants
+	filter.TenantID = &rule.TenantID
+
+	interval := time.Duration(rule.IntervalMins) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	end := now.UTC().Truncate(interval)
+	start := end.Add(-interval)
+	if rule.LastEvaluated != nil {
+		start = rule.LastEvaluated.UTC()
+	}
+	if earliest := end.Add(-maxBucketsPerEvaluation * interval); start.Before(earliest) {
+		start = earliest
+	}
+
+	for bucket := start; bucket.Before(end); bucket = bucket.Add(interval) {
+		bucketStart := bucket
+		bucketEnd := bucket.Add(interval - time.Nanosecond)
+		filter.StartTime = &bucketStart
+		filter.EndTime = &bucketEnd
+
+		value, err := s.logRepo.AggregateValue(ctx, filter, rule.Aggregation, rule.Field)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repo.SavePoint(ctx, &models.MetricPoint{
+			RuleID: rule.ID,
+			Bucket: bucketStart,
+			Value:  value,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.UpdateLastEvaluated(ctx, rule.ID, end)
+}
+
+// PruneSeries removes series values older than the retention period
+func (s *MetricService) PruneSeries(ctx context.Context, now time.Time, days int) (int64, error) {
+	if days <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeletePointsOlderThan(ctx, now.AddDate(0, 0, -days))
+}
+
+// validateMetricRule checks the aggregation and filter of a rule
+func validateMetricRule(rule *models.MetricRule) error {
+	switch rule.Aggregation {
+	case "":
+		rule.Aggregation = models.MetricAggregationCount
+	case models.MetricAggregationCount:
+	case models.MetricAggregationSum, models.MetricAggregationAvg,
+		models.MetricAggregationMin, models.MetricAggregationMax:
+		if rule.Field == "" {
+			return fmt.Errorf("%w: field is required for %s aggregation", ErrInvalidMetricRule, rule.Aggregation)
+		}
+	default:
+		return fmt.Errorf("%w: unknown aggregation %q", ErrInvalidMetricRule, rule.Aggregation)
+	}
+
+	if len(rule.Filter) == 0 {
+		rule.Filter = json.RawMessage(`{}`)
+	}
+	var filter models.LogFilter
+	if err := json.Unmarshal(rule.Filter, &filter); err != nil {
+		return fmt.Errorf("%w: invalid filter: %v", ErrInvalidMetricRule, err)
+	}
+	if err := ValidateFilter(filter); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMetricRule, err)
+	}
+
+	if rule.IntervalMins <= 0 {
+		rule.IntervalMins = 1
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// defaultNewErrorLookback is how long a fingerprint must be unseen to count
+// as new when an alert sets no lookback
+const defaultNewErrorLookback = 7 * 24 * time.Hour
+
+// Variable parts of messages, replaced in order so that occurrences of the
+// same error differing only in IDs, addresses or counts share a fingerprint
+var messageNormalizers = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`(?i)\b(?:0x[0-9a-f]+|[0-9a-f]{8,})\b`), "<hex>"},
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+	{regexp.MustCompile(`\d+`), "<n>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// NormalizeMessage replaces the variable parts of a message (UUIDs, IP
+// addresses, hex values, quoted strings and numbers) with placeholders
+func NormalizeMessage(message string) string {
+	for _, n := range messageNormalizers {
+		message = n.pattern.ReplaceAllString(message, n.replacement)
+	}
+	return strings.TrimSpace(message)
+}
+
+// MessageFingerprint identifies a service's normalized message
+func MessageFingerprint(serviceName, message string) string {
+	sum := sha256.Sum256([]byte(serviceName + "\x00" + NormalizeMessage(message)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// checkNewErrors records the fingerprint of every entry matching a new_error
+// alert and fires the alerts for which the fingerprint was unseen within
+// their lookback. Each fingerprint is recorded once per entry, so alerts
+// sharing entries agree on what is new.
+func (s *LogService) checkNewErrors(ctx context.Context, alerts []models.LogAlert, entries []models.LogEntry, now time.Time) {
+	if len(alerts) == 0 {
+		return
+	}
+
+	for _, entry := range entries {
+		var matched []models.LogAlert
+		for _, alert := range alerts {
+			if alert.TenantID == entry.TenantID && s.matchesAlert(entry, alert) {
+				matched = append(matched, alert)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		fingerprint := MessageFingerprint(entry.ServiceName, entry.Message)
+		lastSeen, err := s.alertRepo.TouchFingerprint(ctx, &models.ErrorFingerprint{
+			TenantID:    entry.TenantID,
+			Fingerprint: fingerprint,
+			ServiceName: entry.ServiceName,
+			Message:     entry.Message,
+		}, entry.Timestamp)
+		if err != nil {
+			fmt.Printf("Failed to record error fingerprint: %v\n", err)
+			continue
+		}
+
+		for _, alert := range matched {
+			if lastSeen == nil || lastSeen.Before(entry.Timestamp.Add(-newErrorLookback(alert))) {
+				s.fireNewError(ctx, alert, entry, fingerprint, now)
+			}
+		}
+	}
+}
+
+// fireNewError notifies a new_error alert of an entry with a new fingerprint.
+// New errors are events: the alert does not enter the firing state.
+func (s *LogService) fireNewError(ctx context.Context, alert models.LogAlert, entry models.LogEntry, fingerprint string, now time.Time) {
+	s.alertRepo.UpdateLastTriggered(ctx, alert.ID)
+	s.notify(ctx, models.AlertNotification{
+		Type:        models.AlertNotificationFiring,
+		AlertID:     alert.ID,
+		TenantID:    alert.TenantID,
+		AlertName:   alert.Name,
+		Severity:    alert.Severity,
+		Channels:    s.tenants.ResolveChannels(ctx, alert),
+		Count:       1,
+		Message:     entry.Message,
+		Fingerprint: fingerprint,
+		FiredAt:     now,
+	})
+}
+
+// newErrorLookback returns the alert lookback, treating unset as 7 days
+func newErrorLookback(alert models.LogAlert) time.Duration {
+	if alert.LookbackMins < 1 {
+		return defaultNewErrorLookback
+	}
+	return time.Duration(alert.LookbackMins) * time.Minute
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// Notifier delivers alert notifications to their channels
+type Notifier interface {
+	Notify(ctx context.Context, notification models.AlertNotification) error
+}
+
+// logNotifier writes notifications to stdout until channel delivery is configured
+type logNotifier struct{}
+
+// Notify prints the notification
+func (logNotifier) Notify(ctx context.Context, n models.AlertNotification) error {
+	switch n.Type {
+	case models.AlertNotificationResolved:
+		fmt.Printf("Alert resolved: %s after %ds\n", n.AlertName, n.DurationSec)
+	case models.AlertNotificationDigest:
+		fmt.Printf("Alert digest for tenant %s: %d notifications\n", n.TenantID, len(n.Digest))
+	default:
+		fmt.Printf("Alert triggered: %s (%d >= %d in %dm): %s\n", n.AlertName, n.Count, n.Threshold, n.WindowMins, n.Message)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// ErrInvalidOTLP is returned for OTLP payloads that cannot be decoded
+var ErrInvalidOTLP = errors.New("invalid otlp logs payload")
+
+// OTLP semantic convention attributes mapped onto entry fields, in order of
+// preference
+var (
+	otlpServiceAttrs     = []string{"service.name"}
+	otlpEnvironmentAttrs = []string{"deployment.environment.name", "deployment.environment"}
+	otlpHostAttrs        = []string{"host.name"}
+)
+
+// OTLPEntries converts an OTLP logs export into log entries. The service,
+// environment and host come from resource attributes, whose remainder is kept
+// under metadata "resource". Record attributes become the entry metadata,
+// with exception.type and exception.stacktrace lifted into the error fields.
+func OTLPEntries(req models.OTLPLogsRequest, tenantID uuid.UUID) []models.LogEntry {
+	var entries []models.LogEntry
+
+	for _, rl := range req.ResourceLogs {
+		resource := otlpAttributes(rl.Resource.Attributes)
+		service := takeAttr(resource, otlpServiceAttrs)
+		environment := takeAttr(resource, otlpEnvironmentAttrs)
+		host := takeAttr(resource, otlpHostAttrs)
+		if service == "" {
+			service = "unknown"
+		}
+
+		for _, sl := range rl.ScopeLogs {
+			for _, record := range sl.LogRecords {
+				metadata := otlpAttributes(record.Attributes)
+				errorType := takeAttr(metadata, []string{"exception.type"})
+				stackTrace := takeAttr(metadata, []string{"exception.stacktrace"})
+				if len(resource) > 0 {
+					metadata["resource"] = resource
+				}
+				if sl.Scope.Name != "" {
+					metadata["scope"] = sl.Scope.Name
+				}
+
+				entry := models.LogEntry{
+					TenantID:    tenantID,
+					ServiceName: service,
+					Level:       otlpLevel(record.SeverityNumber, record.SeverityText),
+					Message:     otlpBody(record.Body),
+					Timestamp:   otlpTime(record),
+					TraceID:     record.TraceID,
+					SpanID:      record.SpanID,
+					Host:        host,
+					Environment: environment,
+					ErrorType:   errorType,
+					StackTrace:  stackTrace,
+				}
+				if len(metadata) > 0 {
+					entry.Metadata, _ = json.Marshal(metadata)
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	return entries
+}
+
+// otlpLevel maps an OTLP severity number onto our levels. Records without a
+// number fall back to the severity text, then INFO.
+func otlpLevel(number int32, text string) models.LogLevel {
+	switch {
+	case number >= 1 && number <= 8:
+		return models.LogLevelDebug
+	case number >= 9 && number <= 12:
+		return models.LogLevelInfo
+	case number >= 13 && number <= 16:
+		return models.LogLevelWarn
+	case number >= 17 && number <= 20:
+		return models.LogLevelError
+	case number >= 21:
+		return models.LogLevelFatal
+	}
+	return lokiLevel(text)
+}
+
+// otlpTime returns the record time, falling back to the observed time. A zero
+// result is filled in at ingestion.
+func otlpTime(record models.OTLPLogRecord) time.Time {
+	ns := record.TimeUnixNano
+	if ns == 0 {
+		ns = record.ObservedTimeUnixNano
+	}
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns)).UTC()
+}
+
+// otlpBody renders a record body as the message: strings as is, other values
+// as JSON
+func otlpBody(body models.OTLPAnyValue) string {
+	if body.StringValue != nil {
+		return *body.StringValue
+	}
+	value := otlpValue(body)
+	if value == nil {
+		return ""
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// otlpAttributes flattens OTLP key-values into a map
+func otlpAttributes(kvs []models.OTLPKeyValue) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = otlpValue(kv.Value)
+	}
+	return attrs
+}
+
+// otlpValue converts an OTLP value into its plain Go equivalent
+func otlpValue(v models.OTLPAnyValue) interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	case v.ArrayValue != nil:
+		values := make([]interface{}, len(v.ArrayValue.Values))
+		for i, item := range v.ArrayValue.Values {
+			values[i] = otlpValue(item)
+		}
+		return values
+	case v.KvlistValue != nil:
+		return otlpAttributes(v.KvlistValue.Values)
+	}
+	return nil
+}
+
+// takeAttr removes and returns the first present string attribute among names
+func takeAttr(attrs map[string]interface{}, names []string) string {
+	value := ""
+	for _, name := range names {
+		if v, ok := attrs[name]; ok {
+			if s, isString := v.(string); isString && value == "" {
+				value = strings.TrimSpace(s)
+			}
+			delete(attrs, name)
+		}
+	}
+	return value
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// Protobuf wire types used by the OTLP logs messages
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoReader walks the fields of one protobuf message. Only the OTLP logs
+// messages are decoded, so a full protobuf runtime is not needed.
+type protoReader struct {
+	buf []byte
+}
+
+// next reads the tag of the next field, reporting false at the end
+func (r *protoReader) next() (field int, wire int, ok bool, err error) {
+	if len(r.buf) == 0 {
+		return 0, 0, false, nil
+	}
+	tag, err := r.varint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	field, wire = int(tag>>3), int(tag&7)
+	if field == 0 {
+		return 0, 0, false, fmt.Errorf("field number 0")
+	}
+	return field, wire, true, nil
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, fmt.Errorf("truncated varint")
+	}
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *protoReader) fixed64() (uint64, error) {
+	if len(r.buf) < 8 {
+		return 0, fmt.Errorf("truncated fixed64")
+	}
+	v := binary.LittleEndian.Uint64(r.buf)
+	r.buf = r.buf[8:]
+	return v, nil
+}
+
+func (r *protoReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.buf)) {
+		return nil, fmt.Errorf("truncated length-delimited field")
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b, nil
+}
+
+// skip discards a field of a type the decoder does not read
+func (r *protoReader) skip(wire int) error {
+	var err error
+	switch wire {
+	case wireVarint:
+		_, err = r.varint()
+	case wireFixed64:
+		_, err = r.fixed64()
+	case wireBytes:
+		_, err = r.bytes()
+	case wireFixed32:
+		if len(r.buf) < 4 {
+			return fmt.Errorf("truncated fixed32")
+		}
+		r.buf = r.buf[4:]
+	default:
+		return fmt.Errorf("unsupported wire type %d", wire)
+	}
+	return err
+}
+
+// readMessage calls fn for each field of msg; fn reports whether it read
+// the field, and unread fields are skipped
+func readMessage(msg []byte, fn func(r *protoReader, field, wire int) (bool, error)) error {
+	r := &protoReader{buf: msg}
+	for {
+		field, wire, ok, err := r.next()
+		if err != nil || !ok {
+			return err
+		}
+		read, err := fn(r, field, wire)
+		if err != nil {
+			return err
+		}
+		if !read {
+			if err := r.skip(wire); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readSub decodes a length-delimited field as a nested message
+func readSub(r *protoReader, fn func(msg []byte) error) (bool, error) {
+	msg, err := r.bytes()
+	if err != nil {
+		return true, err
+	}
+	return true, fn(msg)
+}
+
+// DecodeOTLPLogsProto decodes a protobuf ExportLogsServiceRequest
+func DecodeOTLPLogsProto(body []byte) (models.OTLPLogsRequest, error) {
+	var req models.OTLPLogsRequest
+	err := readMessage(body, func(r *protoReader, field, wire int) (bool, error) {
+		if field != 1 || wire != wireBytes {
+			return false, nil
+		}
+		return readSub(r, func(msg []byte) error {
+			var rl models.OTLPResourceLogs
+			if err := decodeResourceLogs(msg, &rl); err != nil {
+				return err
+			}
+			req.ResourceLogs = append(req.ResourceLogs, rl)
+			return nil
+		})
+	})
+	if err != nil {
+		return models.OTLPLogsRequest{}, fmt.Errorf("%w: %v", ErrInvalidOTLP, err)
+	}
+	return req, nil
+}
+
+func decodeResourceLogs(msg []byte, rl *models.OTLPResourceLogs) error {
+	return readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+		if wire != wireBytes {
+			return false, nil
+		}
+		switch field {
+		case 1:
+			return readSub(r, func(msg []byte) error {
+				return readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+					if field != 1 || wire != wireBytes {
+						return false, nil
+					}
+					return readSub(r, func(msg []byte) error {
+						kv, err := decodeKeyValue(msg)
+						rl.Resource.Attributes = append(rl.Resource.Attributes, kv)
+						return err
+					})
+				})
+			})
+		case 2:
+			return readSub(r, func(msg []byte) error {
+				var sl models.OTLPScopeLogs
+				if err := decodeScopeLogs(msg, &sl); err != nil {
+					return err
+				}
+				rl.ScopeLogs = append(rl.ScopeLogs, sl)
+				return nil
+			})
+		}
+		return false, nil
+	})
+}
+
+func decodeScopeLogs(msg []byte, sl *models.OTLPScopeLogs) error {
+	return readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+		if wire != wireBytes {
+			return false, nil
+		}
+		switch field {
+		case 1:
+			return readSub(r, func(msg []byte) error {
+				return readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+					if wire != wireBytes || (field != 1 && field != 2) {
+						return false, nil
+					}
+					b, err := r.bytes()
+					if field == 1 {
+						sl.Scope.Name = string(b)
+					} else {
+						sl.Scope.Version = string(b)
+					}
+					return true, err
+				})
+			})
+		case 2:
+			return readSub(r, func(msg []byte) error {
+				var record models.OTLPLogRecord
+				if err := decodeLogRecord(msg, &record); err != nil {
+					return err
+				}
+				sl.LogRecords = append(sl.LogRecords, record)
+				return nil
+			})
+		}
+		return false, nil
+	})
+}
+
+func decodeLogRecord(msg []byte, record *models.OTLPLogRecord) error {
+	return readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+		switch {
+		case (field == 1 || field == 11) && wire == wireFixed64:
+			v, err := r.fixed64()
+			if field == 1 {
+				record.TimeUnixNano = models.OTLPUint64(v)
+			} else {
+				record.ObservedTimeUnixNano = models.OTLPUint64(v)
+			}
+			return true, err
+		case field == 2 && wire == wireVarint:
+			v, err := r.varint()
+			record.SeverityNumber = int32(v)
+			return true, err
+		case field == 3 && wire == wireBytes:
+			b, err := r.bytes()
+			record.SeverityText = string(b)
+			return true, err
+		case field == 5 && wire == wireBytes:
+			return readSub(r, func(msg []byte) error {
+				v, err := decodeAnyValue(msg)
+				record.Body = v
+				return err
+			})
+		case field == 6 && wire == wireBytes:
+			return readSub(r, func(msg []byte) error {
+				kv, err := decodeKeyValue(msg)
+				record.Attributes = append(record.Attributes, kv)
+				return err
+			})
+		case (field == 9 || field == 10) && wire == wireBytes:
+			b, err := r.bytes()
+			if field == 9 {
+				record.TraceID = hex.EncodeToString(b)
+			} else {
+				record.SpanID = hex.EncodeToString(b)
+			}
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func decodeKeyValue(msg []byte) (models.OTLPKeyValue, error) {
+	var kv models.OTLPKeyValue
+	err := readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+		if wire != wireBytes {
+			return false, nil
+		}
+		switch field {
+		case 1:
+			b, err := r.bytes()
+			kv.Key = string(b)
+			return true, err
+		case 2:
+			return readSub(r, func(msg []byte) error {
+				v, err := decodeAnyValue(msg)
+				kv.Value = v
+				return err
+			})
+		}
+		return false, nil
+	})
+	return kv, err
+}
+
+func decodeAnyValue(msg []byte) (models.OTLPAnyValue, error) {
+	var v models.OTLPAnyValue
+	err := readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+		switch {
+		case field == 1 && wire == wireBytes:
+			b, err := r.bytes()
+			s := string(b)
+			v.StringValue = &s
+			return true, err
+		case field == 2 && wire == wireVarint:
+			n, err := r.varint()
+			b := n != 0
+			v.BoolValue = &b
+			return true, err
+		case field == 3 && wire == wireVarint:
+			n, err := r.varint()
+			i := models.OTLPInt64(int64(n))
+			v.IntValue = &i
+			return true, err
+		case field == 4 && wire == wireFixed64:
+			n, err := r.fixed64()
+			f := math.Float64frombits(n)
+			v.DoubleValue = &f
+			return true, err
+		case field == 5 && wire == wireBytes:
+			return readSub(r, func(msg []byte) error {
+				v.ArrayValue = &models.OTLPArrayValue{}
+				return readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+					if field != 1 || wire != wireBytes {
+						return false, nil
+					}
+					return readSub(r, func(msg []byte) error {
+						item, err := decodeAnyValue(msg)
+						v.ArrayValue.Values = append(v.ArrayValue.Values, item)
+						return err
+					})
+				})
+			})
+		case field == 6 && wire == wireBytes:
+			return readSub(r, func(msg []byte) error {
+				v.KvlistValue = &models.OTLPKeyValueList{}
+				return readMessage(msg, func(r *protoReader, field, wire int) (bool, error) {
+					if field != 1 || wire != wireBytes {
+						return false, nil
+					}
+					return readSub(r, func(msg []byte) error {
+						kv, err := decodeKeyValue(msg)
+						v.KvlistValue.Values = append(v.KvlistValue.Values, kv)
+						return err
+					})
+				})
+			})
+		case field == 7 && wire == wireBytes:
+			b, err := r.bytes()
+			s := base64.StdEncoding.EncodeToString(b)
+			v.BytesValue = &s
+			return true, err
+		}
+		return false, nil
+	})
+	return v, err
+}
+
+// EncodeOTLPLogsResponseProto encodes an ExportLogsServiceResponse
+func EncodeOTLPLogsResponseProto(resp models.OTLPLogsResponse) []byte {
+	if resp.PartialSuccess == nil {
+		return []byte{}
+	}
+
+	var partial []byte
+	if n := resp.PartialSuccess.RejectedLogRecords; n != 0 {
+		partial = binary.AppendUvarint(partial, 1<<3|wireVarint)
+		partial = binary.AppendUvarint(partial, uint64(n))
+	}
+	if msg := resp.PartialSuccess.ErrorMessage; msg != "" {
+		partial = binary.AppendUvarint(partial, 2<<3|wireBytes)
+		partial = binary.AppendUvarint(partial, uint64(len(msg)))
+		partial = append(partial, msg...)
+	}
+
+	out := binary.AppendUvarint(nil, 1<<3|wireBytes)
+	out = binary.AppendUvarint(out, uint64(len(partial)))
+	return append(out, partial...)
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// QueryProfile is an opt-in timing breakdown of a query
+type QueryProfile struct {
+	CacheMs     float64 `json:"cache_ms"`
+	CacheHit    bool    `json:"cache_hit"`
+	DBMs        float64 `json:"db_ms"`
+	SerializeMs float64 `json:"serialize_ms"`
+}
+
+type queryProfileKey struct{}
+
+// WithQueryProfile returns a context that makes queries record their timings
+// into the returned profile
+func WithQueryProfile(ctx context.Context) (context.Context, *QueryProfile) {
+	profile := &QueryProfile{}
+	return context.WithValue(ctx, queryProfileKey{}, profile), profile
+}
+
+// queryProfileFrom returns the profile attached to ctx, or nil
+func queryProfileFrom(ctx context.Context) *QueryProfile {
+	profile, _ := ctx.Value(queryProfileKey{}).(*QueryProfile)
+	return profile
+}
+
+// ElapsedMs returns the milliseconds elapsed since start
+func ElapsedMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
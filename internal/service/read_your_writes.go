@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+)
+
+// readYourWritesKeyPrefix prefixes the Redis markers of tenants that wrote
+// within the read-your-writes window
+const readYourWritesKeyPrefix = "log_rw:"
+
+// readYourWritesEnabled reports whether recent writers' reads need routing:
+// only with a replica, whose lag could hide them, and Redis to share the
+// markers across instances
+func (s *LogService) readYourWritesEnabled() bool {
+	return s.redis != nil && s.logRepo.HasReplica() && s.config.Postgres.ReadYourWritesWindow > 0
+}
+
+// markWrites sets the read-your-writes marker of every tenant with stored
+// entries, restarting its window
+func (s *LogService) markWrites(ctx context.Context, entries []models.LogEntry) {
+	if !s.readYourWritesEnabled() {
+		return
+	}
+
+	marked := make(map[uuid.UUID]bool)
+	pipe := s.redis.Pipeline()
+	for _, entry := range entries {
+		if marked[entry.TenantID] {
+			continue
+		}
+		marked[entry.TenantID] = true
+		pipe.Set(ctx, readYourWritesKeyPrefix+entry.TenantID.String(), 1, s.config.Postgres.ReadYourWritesWindow)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Printf("Failed to mark read-your-writes window: %v\n", err)
+	}
+}
+
+// readContext routes the reads of a tenant that wrote within the window to
+// the primary. Reads without a tenant, or when the marker cannot be
+// checked, use the replica.
+func (s *LogService) readContext(ctx context.Context, tenantID *uuid.UUID) context.Context {
+	if tenantID == nil || !s.readYourWritesEnabled() {
+		return ctx
+	}
+	n, err := s.redis.Exists(ctx, readYourWritesKeyPrefix+tenantID.String()).Result()
+	if err != nil || n == 0 {
+		return ctx
+	}
+	return repository.ReadPrimary(ctx)
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/config"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+)
+
+// replayPageSize is the number of entries read from the database at a time
+const replayPageSize = 500
+
+var (
+	// ErrWebhookNotAllowed is returned for replay destinations outside the allowlist
+	ErrWebhookNotAllowed = errors.New("webhook URL is not in the replay allowlist")
+	// ErrReplayNotFound is returned for unknown replay job IDs
+	ErrReplayNotFound = errors.New("replay job not found")
+)
+
+// replayJob tracks a running replay and its pause state
+type replayJob struct {
+	mu       sync.Mutex
+	job      models.ReplayJob
+	tenantID *uuid.UUID    // nil for an operator's cross-tenant replay
+	resume   chan struct{} // non-nil while paused
+}
+
+// ReplayService streams historical logs to webhooks
+type ReplayService struct {
+	logRepo *repository.LogRepository
+	config  *config.Config
+	client  *http.Client
+	jobsMu  sync.RWMutex
+	jobs    map[uuid.UUID]*replayJob
+}
+
+// NewReplayService creates a new replay service
+func NewReplayService(logRepo *repository.LogRepository, cfg *config.Config) *ReplayService {
+	return &ReplayService{
+		logRepo: logRepo,
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.Replay.Timeout},
+		jobs:    make(map[uuid.UUID]*replayJob),
+	}
+}
+
+// StartReplay validates the request and starts a background replay job
+// owned by the filter's tenant
+func (s *ReplayService) StartReplay(req models.ReplayRequest) (*models.ReplayJob, error) {
+	if !s.webhookAllowed(req.WebhookURL) {
+		return nil, ErrWebhookNotAllowed
+	}
+
+	now := time.Now().UTC()
+	rj := &replayJob{
+		job: models.ReplayJob{
+			ID:         uuid.New(),
+			Status:     models.ReplayStatusRunning,
+			WebhookURL: req.WebhookURL,
+			StartedAt:  now,
+		},
+		tenantID: req.Filter.TenantID,
+	}
+
+	s.jobsMu.Lock()
+	s.pruneJobs(now)
+	s.jobs[rj.job.ID] = rj
+	s.jobsMu.Unlock()
+
+	go s.run(rj, req)
+
+	job := rj.job
+	return &job, nil
+}
+
+// GetJob returns a snapshot of a replay job. A non-nil tenantID limits the
+// lookup to that tenant's jobs, as do PauseJob and ResumeJob.
+func (s *ReplayService) GetJob(id uuid.UUID, tenantID *uuid.UUID) (*models.ReplayJob, error) {
+	rj, err := s.findJob(id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	job := rj.job
+	return &job, nil
+}
+
+// PauseJob pauses a running replay
+func (s *ReplayService) PauseJob(id uuid.UUID, tenantID *uuid.UUID) (*models.ReplayJob, error) {
+	rj, err := s.findJob(id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	rj.mu.Lock()
+	if rj.job.Status == models.ReplayStatusRunning {
+		rj.job.Status = models.ReplayStatusPaused
+		rj.resume = make(chan struct{})
+	}
+	job := rj.job
+	rj.mu.Unlock()
+
+	return &job, nil
+}
+
+// ResumeJob resumes a paused replay
+func (s *ReplayService) ResumeJob(id uuid.UUID, tenantID *uuid.UUID) (*models.ReplayJob, error) {
+	rj, err := s.findJob(id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	rj.mu.Lock()
+	if rj.job.Status == models.ReplayStatusPaused {
+		rj.job.Status = models.ReplayStatusRunning
+		close(rj.resume)
+		rj.resume = nil
+	}
+	job := rj.job
+	rj.mu.Unlock()
+
+	return &job, nil
+}
+
+// run sends matching entries to the webhook in timestamp order
+func (s *ReplayService) run(rj *replayJob, req models.ReplayRequest) {
+	ctx := context.Background()
+
+	var throttle <-chan time.Time
+	if req.RatePerSec > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / req.RatePerSec))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	var afterTime time.Time
+	var afterID uuid.UUID
+	for {
+		entries, err := s.logRepo.QueryAfter(ctx, req.Filter, afterTime, afterID, replayPageSize)
+		if err != nil {
+			s.finish(rj, err)
+			return
+		}
+		if len(entries) == 0 {
+			s.finish(rj, nil)
+			return
+		}
+
+		for _, entry := range entries {
+			rj.waitWhilePaused()
+			if throttle != nil {
+				<-throttle
+			}
+
+			if err := s.send(ctx, req.WebhookURL, entry); err != nil {
+				s.finish(rj, err)
+				return
+			}
+
+			rj.mu.Lock()
+			rj.job.Sent++
+			rj.mu.Unlock()
+		}
+
+		last := entries[len(entries)-1]
+		afterTime, afterID = last.Timestamp, last.ID
+	}
+}
+
+// send posts a single entry to the webhook
+func (s *ReplayService) send(ctx context.Context, url string, entry models.LogEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// finish marks a job as completed or failed
+func (s *ReplayService) finish(rj *replayJob, err error) {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+
+	now := time.Now().UTC()
+	rj.job.FinishedAt = &now
+	rj.job.Status = models.ReplayStatusCompleted
+	if err != nil {
+		rj.job.Status = models.ReplayStatusFailed
+		rj.job.Error = err.Error()
+	}
+}
+
+// waitWhilePaused blocks until the job is resumed
+func (rj *replayJob) waitWhilePaused() {
+	rj.mu.Lock()
+	resume := rj.resume
+	rj.mu.Unlock()
+
+	if resume != nil {
+		<-resume
+	}
+}
+
+// findJob looks up a replay job by ID. Jobs of other tenants are reported
+// as not found so their IDs are not disclosed.
+func (s *ReplayService) findJob(id uuid.UUID, tenantID *uuid.UUID) (*replayJob, error) {
+	s.jobsMu.RLock()
+	defer s.jobsMu.RUnlock()
+
+	rj, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrReplayNotFound
+	}
+	if tenantID != nil && (rj.tenantID == nil || *rj.tenantID != *tenantID) {
+		return nil, ErrReplayNotFound
+	}
+	return rj, nil
+}
+
+// pruneJobs drops jobs that finished more than the configured retention ago.
+// Callers hold jobsMu.
+func (s *ReplayService) pruneJobs(now time.Time) {
+	for id, rj := range s.jobs {
+		rj.mu.Lock()
+		finishedAt := rj.job.FinishedAt
+		rj.mu.Unlock()
+		if finishedAt != nil && now.Sub(*finishedAt) >= s.config.Replay.JobRetention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// webhookAllowed checks the destination against the configured allowlist
+func (s *ReplayService) webhookAllowed(url string) bool {
+	for _, allowed := range s.config.Replay.WebhookURLs {
+		if url == allowed {
+			return true
+		}
+	}
+	return false
+}
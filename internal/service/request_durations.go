@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// Slowest request list bounds
+const (
+	defaultSlowRequestLimit = 20
+	maxSlowRequestLimit     = 1000
+)
+
+// RequestDurations measures requests logged as several entries sharing a
+// request_id, such as start/end pairs, from their first to their last entry
+// matching the filter. It returns the duration distribution and the slowest
+// requests taking at least minDurationMs.
+func (s *LogService) RequestDurations(ctx context.Context, filter models.LogFilter, minDurationMs float64, limit int) (*models.RequestDurations, error) {
+	if limit < 1 {
+		limit = defaultSlowRequestLimit
+	}
+	if limit > maxSlowRequestLimit {
+		limit = maxSlowRequestLimit
+	}
+	if minDurationMs < 0 {
+		minDurationMs = 0
+	}
+
+	s.normalizeFilterIDs(&filter)
+	ctx = s.readContext(ctx, filter.TenantID)
+	return s.logRepo.RequestDurations(ctx, filter, minDurationMs, limit)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/minisource/log/internal/models"
@@ -10,12 +11,13 @@ import (
 
 // RetentionService handles retention policy business logic
 type RetentionService struct {
-	repo *repository.RetentionRepository
+	repo    *repository.RetentionRepository
+	logRepo *repository.LogRepository
 }
 
 // NewRetentionService creates a new retention service
-func NewRetentionService(repo *repository.RetentionRepository) *RetentionService {
-	return &RetentionService{repo: repo}
+func NewRetentionService(repo *repository.RetentionRepository, logRepo *repository.LogRepository) *RetentionService {
+	return &RetentionService{repo: repo, logRepo: logRepo}
 }
 
 // CreatePolicy creates a new retention policy
@@ -53,3 +55,16 @@ func (s *RetentionService) UpsertPolicy(ctx context.Context, policy *models.LogR
 	}
 	return s.repo.Upsert(ctx, policy)
 }
+
+// Preview returns the age distribution of stored logs per tenant, or for a
+// single tenant when tenantID is set, to inform retention decisions
+func (s *RetentionService) Preview(ctx context.Context, tenantID *uuid.UUID) ([]models.RetentionPreview, error) {
+	previews, err := s.logRepo.AgeDistribution(ctx, tenantID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if previews == nil {
+		previews = []models.RetentionPreview{}
+	}
+	return previews, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// staleCacheSize bounds the results kept in memory for stale serving when
+// Redis is not configured
+const staleCacheSize = 1000
+
+// staleKeyPrefix namespaces stale copies of cached query results in Redis
+const staleKeyPrefix = "stale:"
+
+// staleResults keeps the last result of each query in memory, evicting the
+// oldest query once full
+type staleResults struct {
+	mu      sync.Mutex
+	results map[string]models.LogQueryResult
+	order   []string
+}
+
+func (c *staleResults) put(key string, result models.LogQueryResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.results == nil {
+		c.results = make(map[string]models.LogQueryResult)
+	}
+	if _, ok := c.results[key]; !ok {
+		if len(c.order) >= staleCacheSize {
+			delete(c.results, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.results[key] = result
+}
+
+func (c *staleResults) get(key string) (models.LogQueryResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[key]
+	return result, ok
+}
+
+// rememberStale keeps a long-lived copy of a query result to serve when the
+// database later fails
+func (s *LogService) rememberStale(ctx context.Context, key string, result *models.LogQueryResult) {
+	if !s.config.Postgres.ServeStaleOnError {
+		return
+	}
+	if s.redis == nil {
+		s.stale.put(key, *result)
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	s.redis.Set(ctx, staleKeyPrefix+key, data, s.config.Postgres.StaleCacheTTL)
+}
+
+// staleResult returns the last result of a query, marked stale, when stale
+// serving is enabled and one was kept
+func (s *LogService) staleResult(ctx context.Context, key string, cause error) *models.LogQueryResult {
+	if !s.config.Postgres.ServeStaleOnError {
+		return nil
+	}
+
+	var result models.LogQueryResult
+	if s.redis == nil {
+		var ok bool
+		if result, ok = s.stale.get(key); !ok {
+			return nil
+		}
+	} else {
+		data, err := s.redis.Get(ctx, staleKeyPrefix+key).Bytes()
+		if err != nil || json.Unmarshal(data, &result) != nil {
+			return nil
+		}
+	}
+
+	fmt.Printf("Serving stale query result: %v\n", cause)
+	result.Stale = true
+	return &result
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// streamChannelPrefix prefixes every stream pub/sub channel
+const streamChannelPrefix = "log_stream:"
+
+// streamBufferSize is the per-subscriber buffer; slow subscribers drop entries
+const streamBufferSize = 256
+
+// StreamChannel returns the channel an entry is published on. Entries are
+// partitioned by tenant and service so subscribers can listen narrowly.
+func StreamChannel(tenantID uuid.UUID, serviceName string) string {
+	return fmt.Sprintf("%s%s:%s", streamChannelPrefix, tenantID, serviceName)
+}
+
+// StreamTopic returns the narrowest channel, or channel pattern ending in
+// "*", covering every entry the filter can match
+func StreamTopic(filter models.LogFilter) string {
+	if filter.TenantID == nil {
+		return streamChannelPrefix + "*"
+	}
+	if filter.ServiceName != "" {
+		return StreamChannel(*filter.TenantID, filter.ServiceName)
+	}
+	return fmt.Sprintf("%s%s:*", streamChannelPrefix, *filter.TenantID)
+}
+
+// topicMatches reports whether a channel is covered by a topic
+func topicMatches(topic, channel string) bool {
+	if prefix, ok := strings.CutSuffix(topic, "*"); ok {
+		return strings.HasPrefix(channel, prefix)
+	}
+	return topic == channel
+}
+
+// StreamSubscription delivers newly ingested entries matching a filter
+type StreamSubscription struct {
+	C <-chan models.LogEntry
+
+	topic    string
+	filter   models.LogFilter
+	ch       chan models.LogEntry
+	received int64
+	close    func()
+	once     sync.Once
+}
+
+// Topic returns the channel or pattern the subscription listens on
+func (s *StreamSubscription) Topic() string {
+	return s.topic
+}
+
+// Received returns how many entries arrived on the subscription's channel,
+// before in-memory filtering
+func (s *StreamSubscription) Received() int64 {
+	return atomic.LoadInt64(&s.received)
+}
+
+// Close stops the subscription
+func (s *StreamSubscription) Close() {
+	s.once.Do(s.close)
+}
+
+// deliver filters an entry that arrived on the wire and hands it to the
+// subscriber without blocking
+func (s *StreamSubscription) deliver(entry models.LogEntry) {
+	atomic.AddInt64(&s.received, 1)
+	if !matchesFilter(entry, s.filter) {
+		return
+	}
+	select {
+	case s.ch <- entry:
+	default:
+	}
+}
+
+// StreamBroker fans newly ingested entries out to stream subscribers over
+// Redis pub/sub, or in-process when Redis is not configured
+type StreamBroker struct {
+	redis *redis.Client
+
+	mu   sync.RWMutex
+	subs map[*StreamSubscription]struct{}
+}
+
+// NewStreamBroker creates a new stream broker
+func NewStreamBroker(redisClient *redis.Client) *StreamBroker {
+	return &StreamBroker{
+		redis: redisClient,
+		subs:  make(map[*StreamSubscription]struct{}),
+	}
+}
+
+// Publish sends entries to their tenant/service channels
+func (b *StreamBroker) Publish(ctx context.Context, entries []models.LogEntry) {
+	for _, entry := range entries {
+		channel := StreamChannel(entry.TenantID, entry.ServiceName)
+
+		if b.redis == nil {
+			b.mu.RLock()
+			for sub := range b.subs {
+				if topicMatches(sub.topic, channel) {
+					sub.deliver(entry)
+				}
+			}
+			b.mu.RUnlock()
+			continue
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		if err := b.redis.Publish(ctx, channel, data).Err(); err != nil {
+			fmt.Printf("Failed to publish log entry to %s: %v\n", channel, err)
+		}
+	}
+}
+
+// Subscribe listens on the narrowest channel covering the filter. Time
+// bounds and pagination in the filter are ignored.
+func (b *StreamBroker) Subscribe(ctx context.Context, filter models.LogFilter) *StreamSubscription {
+	filter.StartTime, filter.EndTime, filter.AroundTime = nil, nil, nil
+	filter.Page, filter.PageSize = 0, 0
+
+	ch := make(chan models.LogEntry, streamBufferSize)
+	sub := &StreamSubscription{
+		C:      ch,
+		topic:  StreamTopic(filter),
+		filter: filter,
+		ch:     ch,
+	}
+
+	if b.redis == nil {
+		b.mu.Lock()
+		b.subs[sub] = struct{}{}
+		b.mu.Unlock()
+
+		sub.close = func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			b.mu.Unlock()
+		}
+		return sub
+	}
+
+	var pubsub *redis.PubSub
+	if strings.HasSuffix(sub.topic, "*") {
+		pubsub = b.redis.PSubscribe(ctx, sub.topic)
+	} else {
+		pubsub = b.redis.Subscribe(ctx, sub.topic)
+	}
+	sub.close = func() { pubsub.Close() }
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var entry models.LogEntry
+			if err := json.Unmarshal([]byte(msg.Payload), &entry); err == nil {
+				sub.deliver(entry)
+			}
+		}
+	}()
+
+	return sub
+}
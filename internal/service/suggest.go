@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Suggestion count bounds
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 100
+)
+
+// SuggestFields maps the fields offered for typeahead to their columns
+var SuggestFields = map[string]string{
+	"service_name": "service_name",
+	"environment":  "environment",
+	"host":         "host",
+	"source":       "source",
+	"error_type":   "error_type",
+	"level":        "level",
+}
+
+// ErrInvalidSuggestField is returned for a field not offered for typeahead
+var ErrInvalidSuggestField = errors.New("invalid suggest field")
+
+// Suggest returns up to limit distinct values of an allowlisted field
+// starting with prefix, in order, for search box typeahead
+func (s *LogService) Suggest(ctx context.Context, tenantID *uuid.UUID, field, prefix string, limit int) ([]string, error) {
+	column, ok := SuggestFields[field]
+	if !ok {
+		fields := make([]string, 0, len(SuggestFields))
+		for name := range SuggestFields {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		return nil, fmt.Errorf("%w: %q, expected one of %s", ErrInvalidSuggestField, field, strings.Join(fields, ", "))
+	}
+	if limit < 1 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	ctx = s.readContext(ctx, tenantID)
+	return s.logRepo.Suggest(ctx, tenantID, column, prefix, limit)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"gorm.io/gorm"
+)
+
+// settingsCacheTTL bounds how long settings are served from memory
+const settingsCacheTTL = 30 * time.Second
+
+type cachedSettings struct {
+	settings  *models.TenantSettings
+	expiresAt time.Time
+}
+
+// ErrInvalidTenantSettings is returned for malformed tenant settings
+var ErrInvalidTenantSettings = errors.New("invalid tenant settings")
+
+// TenantService handles per-tenant settings with an in-memory cache
+type TenantService struct {
+	repo    *repository.TenantRepository
+	cacheMu sync.RWMutex
+	cache   map[uuid.UUID]cachedSettings
+}
+
+// NewTenantService creates a new tenant service
+func NewTenantService(repo *repository.TenantRepository) *TenantService {
+	return &TenantService{
+		repo:  repo,
+		cache: make(map[uuid.UUID]cachedSettings),
+	}
+}
+
+// GetSettings retrieves settings for a tenant
+func (s *TenantService) GetSettings(ctx context.Context, tenantID uuid.UUID) (*models.TenantSettings, error) {
+	return s.repo.FindSettings(ctx, tenantID)
+}
+
+// ListSettings retrieves settings for all tenants
+func (s *TenantService) ListSettings(ctx context.Context) ([]models.TenantSettings, error) {
+	return s.repo.FindAllSettings(ctx)
+}
+
+// UpsertSettings creates or replaces settings for a tenant
+func (s *TenantService) UpsertSettings(ctx context.Context, settings *models.TenantSettings) error {
+	if settings.SampleRate <= 0 || settings.SampleRate > 1 {
+		settings.SampleRate = 1
+	}
+	if settings.DampeningLimit <= 0 {
+		settings.DampeningLimit = 100
+	}
+	if settings.DefaultPageSize < 0 || settings.DefaultPageSize > MaxPageSize {
+		settings.DefaultPageSize = 0
+	}
+	if settings.CountOnlySampleRate < 0 || settings.CountOnlySampleRate > 1 {
+		settings.CountOnlySampleRate = 0
+	}
+	if len(settings.CountOnlyServices) > 0 {
+		var services []string
+		if err := json.Unmarshal(settings.CountOnlyServices, &services); err != nil {
+			return fmt.Errorf("%w: count_only_services must be an array of service names", ErrInvalidTenantSettings)
+		}
+	}
+	if err := s.repo.UpsertSettings(ctx, settings); err != nil {
+		return err
+	}
+	s.invalidate(settings.TenantID)
+	return nil
+}
+
+// DeleteSettings removes settings for a tenant
+func (s *TenantService) DeleteSettings(ctx context.Context, tenantID uuid.UUID) error {
+	if err := s.repo.DeleteSettings(ctx, tenantID); err != nil {
+		return err
+	}
+	s.invalidate(tenantID)
+	return nil
+}
+
+// CachedSettings returns settings for the ingestion path, or nil when the
+// tenant has none. Lookup failures are treated as "no settings".
+func (s *TenantService) CachedSettings(ctx context.Context, tenantID uuid.UUID) *models.TenantSettings {
+	if s == nil {
+		return nil
+	}
+
+	s.cacheMu.RLock()
+	cached, ok := s.cache[tenantID]
+	s.cacheMu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.settings
+	}
+
+	settings, err := s.repo.FindSettings(ctx, tenantID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		settings = nil
+	}
+
+	s.cacheMu.Lock()
+	s.cache[tenantID] = cachedSettings{settings: settings, expiresAt: time.Now().Add(settingsCacheTTL)}
+	s.cacheMu.Unlock()
+
+	return settings
+}
+
+// invalidate drops a tenant from the settings cache
+func (s *TenantService) invalidate(tenantID uuid.UUID) {
+	s.cacheMu.Lock()
+	delete(s.cache, tenantID)
+	s.cacheMu.Unlock()
+}
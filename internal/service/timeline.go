@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// timelineLimit bounds the number of entries in a timeline
+const timelineLimit = 10000
+
+// GetTimeline returns the entries of a request or trace across services,
+// interleaved by timestamp and grouped into per-service lanes
+func (s *LogService) GetTimeline(ctx context.Context, filter models.LogFilter) (*models.Timeline, error) {
+	s.normalizeFilterIDs(&filter)
+	entries, err := s.logRepo.FindOrdered(ctx, filter, timelineLimit)
+	if err != nil {
+		return nil, err
+	}
+	return BuildTimeline(entries), nil
+}
+
+// BuildTimeline orders entries by timestamp, computes each entry's offset
+// from the first one and groups them into lanes in order of first appearance
+func BuildTimeline(entries []models.LogEntry) *models.Timeline {
+	timeline := &models.Timeline{
+		Entries: make([]models.TimelineEntry, 0, len(entries)),
+		Lanes:   []models.TimelineLane{},
+	}
+	if len(entries) == 0 {
+		return timeline
+	}
+
+	sorted := make([]models.LogEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	start := sorted[0].Timestamp
+	timeline.Start = &start
+
+	lanes := make(map[string]int)
+	for _, entry := range sorted {
+		te := models.TimelineEntry{
+			LogEntry: entry,
+			OffsetMs: entry.Timestamp.Sub(start).Milliseconds(),
+		}
+		timeline.Entries = append(timeline.Entries, te)
+
+		idx, ok := lanes[entry.ServiceName]
+		if !ok {
+			idx = len(timeline.Lanes)
+			lanes[entry.ServiceName] = idx
+			timeline.Lanes = append(timeline.Lanes, models.TimelineLane{
+				ServiceName:   entry.ServiceName,
+				FirstOffsetMs: te.OffsetMs,
+			})
+		}
+		lane := &timeline.Lanes[idx]
+		lane.LastOffsetMs = te.OffsetMs
+		lane.Entries = append(lane.Entries, te)
+	}
+
+	timeline.DurationMs = timeline.Entries[len(timeline.Entries)-1].OffsetMs
+	return timeline
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// Trace ID formats entries are normalized to at ingestion
+const (
+	// TraceIDFormatHex stores lowercase hex without dashes, left-padding
+	// 64-bit (B3) trace IDs to the 128-bit OpenTelemetry length
+	TraceIDFormatHex = "hex"
+	// TraceIDFormatUUID stores 128-bit trace IDs in dashed UUID form
+	TraceIDFormatUUID = "uuid"
+	// TraceIDFormatNone stores trace and span IDs as sent
+	TraceIDFormatNone = "none"
+)
+
+// NormalizeTraceID converts a trace ID to the canonical form of format.
+// IDs that are not hex are only trimmed so arbitrary string IDs still match.
+func NormalizeTraceID(id, format string) string {
+	id = strings.TrimSpace(id)
+	if format == TraceIDFormatNone {
+		return id
+	}
+
+	hex, ok := canonicalHex(id)
+	if !ok {
+		return id
+	}
+	if len(hex) == 16 {
+		hex = strings.Repeat("0", 16) + hex
+	}
+	if format == TraceIDFormatUUID && len(hex) == 32 {
+		return hex[0:8] + "-" + hex[8:12] + "-" + hex[12:16] + "-" + hex[16:20] + "-" + hex[20:32]
+	}
+	return hex
+}
+
+// NormalizeSpanID converts a span ID to lowercase hex without dashes. Span
+// IDs are 64-bit, so the UUID format leaves them in hex as well.
+func NormalizeSpanID(id, format string) string {
+	id = strings.TrimSpace(id)
+	if format == TraceIDFormatNone {
+		return id
+	}
+	if hex, ok := canonicalHex(id); ok {
+		return hex
+	}
+	return id
+}
+
+// canonicalHex lowercases id and strips dashes, reporting whether the
+// result is a non-empty hex string
+func canonicalHex(id string) (string, bool) {
+	hex := strings.ToLower(strings.ReplaceAll(id, "-", ""))
+	if hex == "" {
+		return "", false
+	}
+	for _, r := range hex {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return "", false
+		}
+	}
+	return hex, true
+}
+
+// normalizeIDs applies the configured trace ID format to an entry
+func (s *LogService) normalizeIDs(entry *models.LogEntry) {
+	format := s.config.Ingestion.TraceIDFormat
+	entry.TraceID = NormalizeTraceID(entry.TraceID, format)
+	entry.SpanID = NormalizeSpanID(entry.SpanID, format)
+}
+
+// normalizeFilterIDs applies the configured trace ID format to a filter so
+// it matches stored IDs regardless of how the caller formatted them
+func (s *LogService) normalizeFilterIDs(filter *models.LogFilter) {
+	if filter.TraceID != "" {
+		filter.TraceID = NormalizeTraceID(filter.TraceID, s.config.Ingestion.TraceIDFormat)
+	}
+	if len(filter.Or) > 0 {
+		groups := make([]models.LogFilter, len(filter.Or))
+		copy(groups, filter.Or)
+		for i := range groups {
+			s.normalizeFilterIDs(&groups[i])
+		}
+		filter.Or = groups
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+)
+
+// Volume forecast bounds, in days
+const (
+	defaultForecastHistoryDays = 30
+	maxForecastHistoryDays     = 365
+	defaultForecastDays        = 7
+	maxForecastDays            = 365
+)
+
+// ForecastVolume projects a tenant's daily log volume and storage for the
+// next days from a linear regression over the last historyDays complete
+// days. A nil tenant forecasts across all tenants.
+func (s *LogService) ForecastVolume(ctx context.Context, tenantID *uuid.UUID, historyDays, days int, now time.Time) (*models.VolumeForecast, error) {
+	historyDays = clampDays(historyDays, defaultForecastHistoryDays, maxForecastHistoryDays)
+	days = clampDays(days, defaultForecastDays, maxForecastDays)
+
+	// Today is incomplete, so history ends at its start
+	today := now.UTC().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -historyDays)
+	end := today.Add(-time.Nanosecond)
+	buckets, err := s.logRepo.Aggregate(ctx, models.LogFilter{
+		TenantID:  tenantID,
+		StartTime: &start,
+		EndTime:   &end,
+	}, "day")
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[time.Time]int64, len(buckets))
+	for _, bucket := range buckets {
+		byDay[bucket.Bucket.UTC().Truncate(24*time.Hour)] += bucket.Count
+	}
+
+	avgBytes, err := s.avgEntryBytes(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := &models.VolumeForecast{
+		History:       make([]models.VolumeDay, historyDays),
+		Forecast:      make([]models.VolumeDay, days),
+		AvgEntryBytes: avgBytes,
+	}
+	counts := make([]int64, historyDays)
+	for i := range counts {
+		date := start.AddDate(0, 0, i)
+		counts[i] = byDay[date]
+		forecast.History[i] = models.VolumeDay{Date: date, Count: counts[i], Bytes: counts[i] * avgBytes}
+	}
+
+	projected, slope := LinearForecast(counts, days)
+	forecast.SlopePerDay = slope
+	for i, count := range projected {
+		bytes := count * avgBytes
+		forecast.Forecast[i] = models.VolumeDay{Date: today.AddDate(0, 0, i), Count: count, Bytes: bytes}
+		forecast.ProjectedBytes += bytes
+	}
+	return forecast, nil
+}
+
+// avgEntryBytes returns the current storage size per stored entry
+func (s *LogService) avgEntryBytes(ctx context.Context, tenantID *uuid.UUID) (int64, error) {
+	count, err := s.logRepo.Count(ctx, models.LogFilter{TenantID: tenantID})
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	size, err := s.logRepo.GetStorageSize(ctx, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	return size / count, nil
+}
+
+// LinearForecast fits a least-squares line through the daily counts and
+// extends it days past the last one, returning the projected counts (never
+// negative) and the slope per day
+func LinearForecast(counts []int64, days int) ([]int64, float64) {
+	projected := make([]int64, days)
+	n := float64(len(counts))
+	if n == 0 {
+		return projected, 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, count := range counts {
+		x, y := float64(i), float64(count)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	slope := 0.0
+	if denominator := n*sumXX - sumX*sumX; denominator != 0 {
+		slope = (n*sumXY - sumX*sumY) / denominator
+	}
+	intercept := (sumY - slope*sumX) / n
+
+	for i := range projected {
+		value := intercept + slope*(n+float64(i))
+		projected[i] = int64(math.Max(0, math.Round(value)))
+	}
+	return projected, slope
+}
+
+// clampDays applies a default to unset day counts and caps them at max
+func clampDays(days, defaultDays, max int) int {
+	if days <= 0 {
+		return defaultDays
+	}
+	if days > max {
+		return max
+	}
+	return days
+}
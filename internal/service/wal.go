@@ -0,0 +1,170 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/minisource/log/internal/models"
+)
+
+// walSegmentSuffix names write-ahead log segment files
+const walSegmentSuffix = ".wal"
+
+// WriteAheadLog persists async entries to append-only segment files before
+// they are acknowledged. The active segment receives appends; rotating it
+// hands the entries written so far to a flush, whose segment is removed
+// once they are stored. Segments left behind by a crash are replayed on
+// startup.
+//
+// WriteAheadLog is not safe for concurrent use; the service serializes
+// access under its buffer lock.
+type WriteAheadLog struct {
+	dir     string
+	seq     int
+	active  *os.File
+	pending []string
+}
+
+// OpenWriteAheadLog opens the write-ahead log in dir, creating it when
+// needed. Segments already present are kept for Replay.
+func OpenWriteAheadLog(dir string) (*WriteAheadLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	segments, err := walSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &WriteAheadLog{dir: dir, pending: segments}
+	if len(segments) > 0 {
+		w.seq = walSegmentSeq(segments[len(segments)-1])
+	}
+	if err := w.openSegment(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// walSegments returns the segment paths in dir, oldest first
+func walSegments(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+walSegmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return walSegmentSeq(matches[i]) < walSegmentSeq(matches[j])
+	})
+	return matches, nil
+}
+
+// walSegmentSeq returns the sequence number of a segment path
+func walSegmentSeq(path string) int {
+	seq, _ := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), walSegmentSuffix))
+	return seq
+}
+
+// openSegment starts a new active segment, leaving the current one active
+// when the new segment cannot be created
+func (w *WriteAheadLog) openSegment() error {
+	path := filepath.Join(w.dir, fmt.Sprintf("%020d%s", w.seq+1, walSegmentSuffix))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.seq++
+	w.active = file
+	return nil
+}
+
+// Append durably writes an entry to the active segment
+func (w *WriteAheadLog) Append(entry models.LogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := w.active.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return w.active.Sync()
+}
+
+// Rotate closes the active segment, returning its path, and starts a new
+// one. The returned segment should be removed with Release once its entries
+// are stored. When the new segment cannot be created, the active segment
+// stays open for appends and an empty path is returned.
+func (w *WriteAheadLog) Rotate() (string, error) {
+	previous := w.active
+	if err := w.openSegment(); err != nil {
+		return "", err
+	}
+	// Every append was synced, so the closed segment is complete either way
+	if err := previous.Close(); err != nil {
+		return previous.Name(), err
+	}
+	return previous.Name(), nil
+}
+
+// Release removes a segment whose entries are stored
+func (w *WriteAheadLog) Release(path string) error {
+	return os.Remove(path)
+}
+
+// Close closes the active segment, keeping it on disk for replay
+func (w *WriteAheadLog) Close() error {
+	return w.active.Close()
+}
+
+// Replay passes the entries of segments left by a previous run to store,
+// one segment at a time, removing each segment store accepts. Lines that
+// cannot be decoded, such as a write torn by the crash, are skipped.
+// It returns the number of entries replayed.
+func (w *WriteAheadLog) Replay(ctx context.Context, store func(ctx context.Context, entries []models.LogEntry) error) (int, error) {
+	replayed := 0
+	for len(w.pending) > 0 {
+		path := w.pending[0]
+		entries, err := readWALSegment(path)
+		if err != nil {
+			return replayed, err
+		}
+		if len(entries) > 0 {
+			if err := store(ctx, entries); err != nil {
+				return replayed, fmt.Errorf("replaying %s: %w", filepath.Base(path), err)
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return replayed, err
+		}
+		replayed += len(entries)
+		w.pending = w.pending[1:]
+	}
+	return replayed, nil
+}
+
+// readWALSegment decodes the entries of a segment
+func readWALSegment(path string) ([]models.LogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []models.LogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var entry models.LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
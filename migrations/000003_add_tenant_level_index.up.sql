@@ -0,0 +1,2 @@
+-- Composite index for tenant-scoped min-level queries (level IN (...) + time range)
+CREATE INDEX IF NOT EXISTS idx_logs_tenant_level_time ON log_entries (tenant_id, level, timestamp DESC);
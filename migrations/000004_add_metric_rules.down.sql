@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS update_log_metric_rules_updated_at ON log_metric_rules;
+
+DROP TABLE IF EXISTS log_metric_points;
+DROP TABLE IF EXISTS log_metric_rules;
@@ -0,0 +1,32 @@
+-- Create log_metric_rules table
+CREATE TABLE IF NOT EXISTS log_metric_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    enabled BOOLEAN DEFAULT TRUE,
+    filter JSONB NOT NULL,
+    aggregation VARCHAR(20) NOT NULL DEFAULT 'count',
+    field VARCHAR(255),
+    interval_mins INTEGER NOT NULL DEFAULT 1,
+    last_evaluated TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_log_metric_rules_tenant_id ON log_metric_rules (tenant_id);
+
+-- Create log_metric_points table holding evaluated series values
+CREATE TABLE IF NOT EXISTS log_metric_points (
+    rule_id UUID NOT NULL,
+    bucket TIMESTAMPTZ NOT NULL,
+    value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (rule_id, bucket)
+);
+
+DROP TRIGGER IF EXISTS update_log_metric_rules_updated_at ON log_metric_rules;
+CREATE TRIGGER update_log_metric_rules_updated_at
+    BEFORE UPDATE ON log_metric_rules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_logs_retention_tier;
+
+ALTER TABLE log_entries DROP COLUMN IF EXISTS retention_tier;
@@ -0,0 +1,4 @@
+-- Retention tier assigned by ingestion routing rules
+ALTER TABLE log_entries ADD COLUMN IF NOT EXISTS retention_tier VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_logs_retention_tier ON log_entries (retention_tier, timestamp) WHERE retention_tier IS NOT NULL;
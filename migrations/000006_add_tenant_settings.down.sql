@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_log_tenant_settings_updated_at ON log_tenant_settings;
+
+DROP TABLE IF EXISTS log_tenant_settings;
@@ -0,0 +1,18 @@
+-- Create log_tenant_settings table with per-tenant ingestion toggles
+CREATE TABLE IF NOT EXISTS log_tenant_settings (
+    tenant_id UUID PRIMARY KEY,
+    dedup_enabled BOOLEAN DEFAULT FALSE,
+    sampling_enabled BOOLEAN DEFAULT FALSE,
+    sample_rate DOUBLE PRECISION DEFAULT 1,
+    redaction_enabled BOOLEAN DEFAULT FALSE,
+    dampening_enabled BOOLEAN DEFAULT FALSE,
+    dampening_limit INTEGER DEFAULT 100,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS update_log_tenant_settings_updated_at ON log_tenant_settings;
+CREATE TRIGGER update_log_tenant_settings_updated_at
+    BEFORE UPDATE ON log_tenant_settings
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_alerts_firing;
+
+ALTER TABLE log_alerts DROP COLUMN IF EXISTS firing_since;
+ALTER TABLE log_alerts DROP COLUMN IF EXISTS firing;
@@ -0,0 +1,5 @@
+-- Persist alert firing state for recovery notifications
+ALTER TABLE log_alerts ADD COLUMN IF NOT EXISTS firing BOOLEAN DEFAULT FALSE;
+ALTER TABLE log_alerts ADD COLUMN IF NOT EXISTS firing_since TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_alerts_firing ON log_alerts (firing) WHERE firing = TRUE;
@@ -0,0 +1,2 @@
+-- Hash index for exact message matches; btree cannot index arbitrarily long text
+CREATE INDEX IF NOT EXISTS idx_logs_message_hash ON log_entries USING hash (message);
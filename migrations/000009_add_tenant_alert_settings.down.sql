@@ -0,0 +1 @@
+DROP TABLE IF EXISTS log_tenant_alert_settings;
@@ -0,0 +1,8 @@
+-- Per-tenant default alert notification channels
+CREATE TABLE IF NOT EXISTS log_tenant_alert_settings (
+    tenant_id UUID PRIMARY KEY,
+    default_channels JSONB,
+    channel_merge VARCHAR(20) DEFAULT 'fallback',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,14 @@
+-- Alert firing/resolution history, pruned by ALERT_EVENT_RETENTION_DAYS
+CREATE TABLE IF NOT EXISTS log_alert_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    alert_id UUID NOT NULL,
+    tenant_id UUID,
+    type VARCHAR(20),
+    count BIGINT,
+    threshold INTEGER,
+    message TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_alert ON log_alert_events (alert_id);
+CREATE INDEX IF NOT EXISTS idx_alert_events_created ON log_alert_events (created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE log_tenant_alert_settings DROP COLUMN IF EXISTS digest_interval_mins;
+ALTER TABLE log_tenant_alert_settings DROP COLUMN IF EXISTS digest_enabled;
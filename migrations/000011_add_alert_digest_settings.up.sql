@@ -0,0 +1,3 @@
+-- Per-tenant alert notification digesting
+ALTER TABLE log_tenant_alert_settings ADD COLUMN IF NOT EXISTS digest_enabled BOOLEAN DEFAULT FALSE;
+ALTER TABLE log_tenant_alert_settings ADD COLUMN IF NOT EXISTS digest_interval_mins INTEGER DEFAULT 15;
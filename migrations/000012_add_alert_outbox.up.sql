@@ -0,0 +1,15 @@
+-- Pending alert notifications, drained by the outbox sender until delivered
+CREATE TABLE IF NOT EXISTS log_alert_outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    alert_id UUID NOT NULL,
+    tenant_id UUID,
+    type VARCHAR(20),
+    payload JSONB NOT NULL,
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_outbox_due ON log_alert_outbox (next_attempt_at) WHERE sent_at IS NULL;
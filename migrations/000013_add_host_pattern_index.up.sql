@@ -0,0 +1,2 @@
+-- text_pattern_ops serves anchored LIKE prefixes (host LIKE 'web-%') under any collation
+CREATE INDEX IF NOT EXISTS idx_logs_host_pattern ON log_entries (host text_pattern_ops);
@@ -0,0 +1,2 @@
+-- Per-tenant enrichment webhook
+ALTER TABLE log_tenant_settings ADD COLUMN IF NOT EXISTS enrichment_url VARCHAR(500);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_logs_error_type;
+
+ALTER TABLE log_entries DROP COLUMN IF EXISTS stack_trace;
+ALTER TABLE log_entries DROP COLUMN IF EXISTS error_type;
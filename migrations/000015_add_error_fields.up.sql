@@ -0,0 +1,5 @@
+-- Structured errors: a groupable error type and the stack trace
+ALTER TABLE log_entries ADD COLUMN IF NOT EXISTS error_type VARCHAR(255);
+ALTER TABLE log_entries ADD COLUMN IF NOT EXISTS stack_trace TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_logs_error_type ON log_entries (tenant_id, error_type, timestamp) WHERE error_type IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS log_error_fingerprints;
+
+ALTER TABLE log_alerts DROP COLUMN IF EXISTS lookback_mins;
+ALTER TABLE log_alerts DROP COLUMN IF EXISTS type;
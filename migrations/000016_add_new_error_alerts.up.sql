@@ -0,0 +1,16 @@
+-- Alert types: threshold (count in window) or new_error (unseen message fingerprint)
+ALTER TABLE log_alerts ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'threshold';
+ALTER TABLE log_alerts ADD COLUMN IF NOT EXISTS lookback_mins INTEGER;
+
+-- Last sighting of each normalized message, for new error detection
+CREATE TABLE IF NOT EXISTS log_error_fingerprints (
+    tenant_id UUID NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    service_name VARCHAR(100),
+    message TEXT,
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, fingerprint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_fingerprints_last_seen ON log_error_fingerprints (last_seen);
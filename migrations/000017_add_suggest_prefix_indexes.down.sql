@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_logs_tenant_env_prefix;
+DROP INDEX IF EXISTS idx_logs_tenant_service_prefix;
@@ -0,0 +1,4 @@
+-- Tenant-scoped text_pattern_ops indexes serve typeahead prefix lookups
+-- (service_name LIKE 'au%') under any collation; host uses idx_logs_host_pattern
+CREATE INDEX IF NOT EXISTS idx_logs_tenant_service_prefix ON log_entries (tenant_id, service_name text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_logs_tenant_env_prefix ON log_entries (tenant_id, environment text_pattern_ops);
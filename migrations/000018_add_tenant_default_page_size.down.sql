@@ -0,0 +1 @@
+ALTER TABLE log_tenant_settings DROP COLUMN IF EXISTS default_page_size;
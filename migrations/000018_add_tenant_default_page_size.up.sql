@@ -0,0 +1,2 @@
+-- Page size of a tenant's queries that omit one; 0 uses the global default
+ALTER TABLE log_tenant_settings ADD COLUMN IF NOT EXISTS default_page_size INTEGER NOT NULL DEFAULT 0;
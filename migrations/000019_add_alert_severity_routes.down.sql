@@ -0,0 +1,2 @@
+ALTER TABLE log_alerts DROP COLUMN IF EXISTS severity_routes;
+ALTER TABLE log_tenant_alert_settings DROP COLUMN IF EXISTS severity_routes;
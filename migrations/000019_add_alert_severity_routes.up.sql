@@ -0,0 +1,3 @@
+-- Severity to channel kinds routing; unlisted severities notify every channel
+ALTER TABLE log_tenant_alert_settings ADD COLUMN IF NOT EXISTS severity_routes JSONB;
+ALTER TABLE log_alerts ADD COLUMN IF NOT EXISTS severity_routes JSONB;
@@ -0,0 +1,3 @@
+ALTER TABLE log_tenant_settings DROP COLUMN IF EXISTS count_only_sample_rate;
+ALTER TABLE log_tenant_settings DROP COLUMN IF EXISTS count_only_services;
+DROP TABLE IF EXISTS log_counters;
@@ -0,0 +1,14 @@
+-- Per-minute counts of entries received but not stored for count-only services
+CREATE TABLE IF NOT EXISTS log_counters (
+    tenant_id UUID NOT NULL,
+    service_name VARCHAR(100) NOT NULL,
+    level VARCHAR(10) NOT NULL,
+    bucket TIMESTAMPTZ NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, service_name, level, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_log_counters_bucket ON log_counters (bucket);
+
+ALTER TABLE log_tenant_settings ADD COLUMN IF NOT EXISTS count_only_services JSONB;
+ALTER TABLE log_tenant_settings ADD COLUMN IF NOT EXISTS count_only_sample_rate DOUBLE PRECISION NOT NULL DEFAULT 0;
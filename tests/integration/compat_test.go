@@ -0,0 +1,447 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/handler"
+	"github.com/minisource/log/internal/middleware"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"github.com/minisource/log/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lokiPushPayload is a push as sent by Promtail/Grafana Agent in JSON mode
+const lokiPushPayload = `{
+  "streams": [
+    {
+      "stream": {"service_name": "checkout", "env": "prod", "level": "error", "host": "node-1", "region": "eu-west-1"},
+      "values": [
+        ["1700000000000000000", "payment declined", {"trace_id": "abc123"}],
+        ["1700000001500000000", "retrying payment"]
+      ]
+    },
+    {
+      "stream": {"job": "varlogs"},
+      "values": [["1700000002000000000", "plain line"]]
+    }
+  ]
+}`
+
+// TestLokiPushEntries tests mapping of a Loki push payload onto log entries
+func TestLokiPushEntries(t *testing.T) {
+	var req models.LokiPushRequest
+	require.NoError(t, json.Unmarshal([]byte(lokiPushPayload), &req))
+
+	tenantID := uuid.New()
+	entries, err := service.LokiPushEntries(req, tenantID)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	first := entries[0]
+	assert.Equal(t, tenantID, first.TenantID)
+	assert.Equal(t, "checkout", first.ServiceName)
+	assert.Equal(t, "prod", first.Environment)
+	assert.Equal(t, "node-1", first.Host)
+	assert.Equal(t, models.LogLevelError, first.Level)
+	assert.Equal(t, "payment declined", first.Message)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), first.Timestamp)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(first.Metadata, &metadata))
+	assert.Equal(t, "abc123", metadata["trace_id"])
+	assert.Equal(t, map[string]interface{}{"region": "eu-west-1"}, metadata["labels"])
+
+	second := entries[1]
+	assert.Equal(t, "retrying payment", second.Message)
+	assert.Equal(t, time.Unix(1700000001, 500000000).UTC(), second.Timestamp)
+
+	third := entries[2]
+	assert.Equal(t, "varlogs", third.ServiceName)
+	assert.Equal(t, models.LogLevelInfo, third.Level)
+	assert.Empty(t, third.Metadata)
+
+	t.Run("Rejects Malformed Values", func(t *testing.T) {
+		bad := models.LokiPushRequest{Streams: []models.LokiStream{{
+			Stream: map[string]string{"app": "x"},
+			Values: [][]json.RawMessage{{json.RawMessage(`1700000000`), json.RawMessage(`"line"`)}},
+		}}}
+		_, err := service.LokiPushEntries(bad, tenantID)
+		assert.ErrorIs(t, err, service.ErrInvalidLokiPush)
+	})
+}
+
+// TestParseLokiQuery tests translation of LogQL-lite selectors into filters
+func TestParseLokiQuery(t *testing.T) {
+	t.Run("Service Selector", func(t *testing.T) {
+		filter, err := service.ParseLokiQuery(`{service="checkout"}`)
+		require.NoError(t, err)
+		assert.Equal(t, models.LogFilter{ServiceName: "checkout"}, filter)
+	})
+
+	t.Run("Selector With Line Filter", func(t *testing.T) {
+		filter, err := service.ParseLokiQuery(`{service_name="api", env="prod", level="warning"} |= "time\"out"`)
+		require.NoError(t, err)
+		assert.Equal(t, models.LogFilter{
+			ServiceName: "api",
+			Environment: "prod",
+			Level:       models.LogLevelWarn,
+			Search:      `time"out`,
+		}, filter)
+	})
+
+	t.Run("Rejects Unsupported Syntax", func(t *testing.T) {
+		for _, query := range []string{
+			``,
+			`{service=~"api.*"}`,
+			`{service!="api"}`,
+			`{region="eu"}`,
+			`{service="api"} != "debug"`,
+			`{service="api"} |= "a" |= "b"`,
+			`{service="api"`,
+		} {
+			_, err := service.ParseLokiQuery(query)
+			assert.ErrorIs(t, err, service.ErrInvalidLokiQuery, query)
+		}
+	})
+}
+
+// TestParseLokiTime tests the accepted Loki time formats
+func TestParseLokiTime(t *testing.T) {
+	want := time.Unix(1700000000, 0).UTC()
+	for _, value := range []string{"1700000000000000000", "1700000000", "1700000000.0", "2023-11-14T22:13:20Z"} {
+		got, err := service.ParseLokiTime(value)
+		require.NoError(t, err, value)
+		assert.True(t, want.Equal(got), value)
+	}
+}
+
+// TestLokiStreamsResponseShape tests that query results serialize like Loki's
+func TestLokiStreamsResponseShape(t *testing.T) {
+	ts := time.Unix(1700000000, 0).UTC()
+	entries := []models.LogEntry{
+		{ServiceName: "api", Level: models.LogLevelError, Environment: "prod", Message: "second", Timestamp: ts.Add(time.Second)},
+		{ServiceName: "web", Level: models.LogLevelInfo, Message: "other", Timestamp: ts},
+		{ServiceName: "api", Level: models.LogLevelError, Environment: "prod", Message: "first", Timestamp: ts},
+	}
+
+	body, err := json.Marshal(service.LokiStreams(entries))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"status": "success",
+		"data": {
+			"resultType": "streams",
+			"result": [
+				{
+					"stream": {"service_name": "api", "level": "error", "environment": "prod"},
+					"values": [["1700000001000000000", "second"], ["1700000000000000000", "first"]]
+				},
+				{
+					"stream": {"service_name": "web", "level": "info"},
+					"values": [["1700000000000000000", "other"]]
+				}
+			]
+		}
+	}`, string(body))
+
+	empty, err := json.Marshal(service.LokiStreams(nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status": "success", "data": {"resultType": "streams", "result": []}}`, string(empty))
+}
+
+// TestLokiQueryRangeLimit tests a malformed limit is rejected and a large one
+// clamped to the maximum page rather than reset to the default
+func TestLokiQueryRangeLimit(t *testing.T) {
+	tenantID := uuid.New()
+	query := func(app *fiber.App, limit string) *http.Response {
+		params := url.Values{"query": {`{service="loki-limit"}`}, "limit": {limit}}
+		req := httptest.NewRequest(http.MethodGet, "/loki/api/v1/query_range?"+params.Encode(), nil)
+		req.Header.Set("X-Scope-OrgID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	newApp := func(h *handler.CompatHandler) *fiber.App {
+		app := fiber.New()
+		app.Use("/loki", middleware.ScopeOrgIDExtractor())
+		app.Get("/loki/api/v1/query_range", h.LokiQueryRange)
+		return app
+	}
+
+	rejecting := newApp(handler.NewCompatHandler(nil))
+	for _, limit := range []string{"abc", "0", "-5"} {
+		assert.Equal(t, http.StatusBadRequest, query(rejecting, limit).StatusCode, limit)
+	}
+
+	db := newTestDB(t)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	entries := make([]models.LogEntry, 150)
+	for i := range entries {
+		entries[i] = models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "loki-limit", Level: models.LogLevelInfo,
+			Message: fmt.Sprintf("line %d", i), Timestamp: time.Now().UTC().Add(-time.Duration(i) * time.Second),
+		}
+	}
+	require.NoError(t, repository.NewLogRepository(db).CreateBatch(context.Background(), entries))
+
+	resp := query(newApp(handler.NewCompatHandler(newTestLogService(t, db, nil))), "5000")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var body models.LokiQueryResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	lines := 0
+	for _, stream := range body.Data.Result {
+		lines += len(stream.Values)
+	}
+	assert.Equal(t, 150, lines)
+}
+
+// esBulkBody is a _bulk request as sent by Filebeat/Logstash
+const esBulkBody = `{"index":{"_index":"logs-checkout","_id":"0b7c5a52-1c1f-4a57-9e0c-6f1f6a0e5c11"}}
+{"@timestamp":"2023-11-14T22:13:20.5Z","message":"payment declined","log":{"level":"error","logger":"pay"},"service":{"name":"checkout"},"order_id":42}
+{"create":{"_index":"logs-web"}}
+{"@timestamp":1700000000000,"msg":"page served","level":"info"}
+{"delete":{"_index":"logs-web","_id":"1"}}
+{"index":{"_index":"logs-web"}}
+{"@timestamp":"yesterday","message":"bad"}
+`
+
+// TestParseESBulk tests mapping of _bulk documents and the bulk response shape
+func TestParseESBulk(t *testing.T) {
+	tenantID := uuid.New()
+	ops := service.ParseESBulk([]byte(esBulkBody), tenantID)
+	require.Len(t, ops, 4)
+
+	first := ops[0].Entry
+	require.NotNil(t, first)
+	assert.Equal(t, uuid.MustParse("0b7c5a52-1c1f-4a57-9e0c-6f1f6a0e5c11"), first.ID)
+	assert.Equal(t, tenantID, first.TenantID)
+	assert.Equal(t, "checkout", first.ServiceName)
+	assert.Equal(t, models.LogLevelError, first.Level)
+	assert.Equal(t, "payment declined", first.Message)
+	assert.Equal(t, time.Unix(1700000000, 500000000).UTC(), first.Timestamp)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(first.Metadata, &metadata))
+	assert.Equal(t, map[string]interface{}{
+		"log":      map[string]interface{}{"logger": "pay"},
+		"order_id": float64(42),
+	}, metadata)
+
+	second := ops[1].Entry
+	require.NotNil(t, second)
+	assert.Equal(t, "logs-web", second.ServiceName, "index name is the fallback service")
+	assert.Equal(t, models.LogLevelInfo, second.Level)
+	assert.Equal(t, "page served", second.Message)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), second.Timestamp)
+	assert.Empty(t, second.Metadata)
+
+	assert.Nil(t, ops[2].Entry)
+	assert.Nil(t, ops[3].Entry)
+
+	body, err := json.Marshal(service.ESBulkResult(ops, nil, nil, 3*time.Millisecond))
+	require.NoError(t, err)
+
+	var resp struct {
+		Took   int64                        `json:"took"`
+		Errors bool                         `json:"errors"`
+		Items  []map[string]json.RawMessage `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, int64(3), resp.Took)
+	assert.True(t, resp.Errors)
+	require.Len(t, resp.Items, 4)
+
+	assert.JSONEq(t, `{"_index":"logs-checkout","_id":"0b7c5a52-1c1f-4a57-9e0c-6f1f6a0e5c11","status":201,"result":"created"}`,
+		string(resp.Items[0]["index"]))
+	assert.Contains(t, resp.Items[1], "create")
+	assert.Contains(t, string(resp.Items[2]["delete"]), `"status":400`)
+	assert.Contains(t, string(resp.Items[3]["index"]), `"mapper_parsing_exception"`)
+
+	t.Run("Rejected Entries", func(t *testing.T) {
+		failures := []models.RejectedEntry{{Index: 1, Reason: "value too long"}}
+		body, err := json.Marshal(service.ESBulkResult(ops, failures, nil, time.Millisecond))
+		require.NoError(t, err)
+
+		var resp struct {
+			Items []map[string]json.RawMessage `json:"items"`
+		}
+		require.NoError(t, json.Unmarshal(body, &resp))
+		require.Len(t, resp.Items, 4)
+		assert.Contains(t, string(resp.Items[0]["index"]), `"status":201`)
+		assert.Contains(t, string(resp.Items[1]["create"]), `"status":400`)
+		assert.Contains(t, string(resp.Items[1]["create"]), "value too long")
+	})
+
+	t.Run("Large Integers Keep Precision", func(t *testing.T) {
+		body := "{\"index\":{\"_index\":\"logs-api\"}}\n" +
+			"{\"message\":\"m\",\"span_id\":9007199254740993,\"ratio\":0.25}\n"
+		ops := service.ParseESBulk([]byte(body), tenantID)
+		require.Len(t, ops, 1)
+		require.NotNil(t, ops[0].Entry)
+		assert.JSONEq(t, `{"span_id":9007199254740993,"ratio":0.25}`, string(ops[0].Entry.Metadata))
+		assert.Contains(t, string(ops[0].Entry.Metadata), "9007199254740993")
+	})
+}
+
+// otlpLogsPayload is an OTLP/JSON logs export as sent by an OpenTelemetry
+// collector
+const otlpLogsPayload = `{
+  "resourceLogs": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "checkout"}},
+      {"key": "deployment.environment", "value": {"stringValue": "prod"}},
+      {"key": "host.name", "value": {"stringValue": "node-1"}},
+      {"key": "cloud.region", "value": {"stringValue": "eu-west-1"}}
+    ]},
+    "scopeLogs": [{
+      "scope": {"name": "payments"},
+      "logRecords": [
+        {
+          "timeUnixNano": "1700000000000000000",
+          "severityNumber": 17,
+          "severityText": "Error",
+          "body": {"stringValue": "payment declined"},
+          "attributes": [
+            {"key": "order_id", "value": {"intValue": "42"}},
+            {"key": "exception.type", "value": {"stringValue": "CardError"}},
+            {"key": "exception.stacktrace", "value": {"stringValue": "at charge()"}}
+          ],
+          "traceId": "5b8efff798038103d269b633813fc60c",
+          "spanId": "eee19b7ec3c1b174"
+        },
+        {
+          "observedTimeUnixNano": 1700000001000000000,
+          "severityText": "warning",
+          "body": {"kvlistValue": {"values": [{"key": "retry", "value": {"boolValue": true}}]}}
+        }
+      ]
+    }]
+  }]
+}`
+
+// TestOTLPEntries tests mapping of an OTLP logs export onto log entries
+func TestOTLPEntries(t *testing.T) {
+	var req models.OTLPLogsRequest
+	require.NoError(t, json.Unmarshal([]byte(otlpLogsPayload), &req))
+
+	tenantID := uuid.New()
+	entries := service.OTLPEntries(req, tenantID)
+	require.Len(t, entries, 2)
+
+	first := entries[0]
+	assert.Equal(t, tenantID, first.TenantID)
+	assert.Equal(t, "checkout", first.ServiceName)
+	assert.Equal(t, "prod", first.Environment)
+	assert.Equal(t, "node-1", first.Host)
+	assert.Equal(t, models.LogLevelError, first.Level)
+	assert.Equal(t, "payment declined", first.Message)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), first.Timestamp)
+	assert.Equal(t, "5b8efff798038103d269b633813fc60c", first.TraceID)
+	assert.Equal(t, "eee19b7ec3c1b174", first.SpanID)
+	assert.Equal(t, "CardError", first.ErrorType)
+	assert.Equal(t, "at charge()", first.StackTrace)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(first.Metadata, &metadata))
+	assert.Equal(t, float64(42), metadata["order_id"])
+	assert.Equal(t, "payments", metadata["scope"])
+	assert.Equal(t, map[string]interface{}{"cloud.region": "eu-west-1"}, metadata["resource"])
+	assert.NotContains(t, metadata, "exception.type")
+
+	second := entries[1]
+	assert.Equal(t, models.LogLevelWarn, second.Level)
+	assert.Equal(t, `{"retry":true}`, second.Message)
+	assert.Equal(t, time.Unix(1700000001, 0).UTC(), second.Timestamp)
+
+	t.Run("Severity Numbers", func(t *testing.T) {
+		cases := map[int32]models.LogLevel{
+			1: models.LogLevelDebug, 8: models.LogLevelDebug,
+			9: models.LogLevelInfo, 13: models.LogLevelWarn,
+			20: models.LogLevelError, 24: models.LogLevelFatal,
+		}
+		for number, want := range cases {
+			req := models.OTLPLogsRequest{ResourceLogs: []models.OTLPResourceLogs{{
+				ScopeLogs: []models.OTLPScopeLogs{{LogRecords: []models.OTLPLogRecord{{SeverityNumber: number}}}},
+			}}}
+			entries := service.OTLPEntries(req, tenantID)
+			require.Len(t, entries, 1)
+			assert.Equal(t, want, entries[0].Level, "severity %d", number)
+			assert.Equal(t, "unknown", entries[0].ServiceName)
+			assert.True(t, entries[0].Timestamp.IsZero())
+		}
+	})
+}
+
+// protoField appends a length-delimited protobuf field
+func protoField(buf []byte, field int, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// TestDecodeOTLPLogsProto tests decoding of a protobuf logs export
+func TestDecodeOTLPLogsProto(t *testing.T) {
+	stringValue := func(s string) []byte { return protoField(nil, 1, []byte(s)) }
+	keyValue := func(k string, v []byte) []byte { return protoField(protoField(nil, 1, []byte(k)), 2, v) }
+
+	var record []byte
+	record = binary.AppendUvarint(record, 1<<3|1)
+	record = binary.LittleEndian.AppendUint64(record, 1700000000000000000)
+	record = binary.AppendUvarint(record, 2<<3|0)
+	record = binary.AppendUvarint(record, 13)
+	record = protoField(record, 5, stringValue("disk almost full"))
+	intValue := binary.AppendUvarint(binary.AppendUvarint(nil, 3<<3|0), 90)
+	record = protoField(record, 6, keyValue("usage", intValue))
+	record = protoField(record, 9, []byte{0x5b, 0x8e, 0xff, 0xf7})
+	// An unknown fixed32 field is skipped
+	record = binary.LittleEndian.AppendUint32(binary.AppendUvarint(record, 8<<3|5), 1)
+
+	resource := protoField(nil, 1, keyValue("service.name", stringValue("storage")))
+	scopeLogs := protoField(protoField(nil, 1, protoField(nil, 1, []byte("disk"))), 2, record)
+	resourceLogs := protoField(protoField(nil, 1, resource), 2, scopeLogs)
+	body := protoField(nil, 1, resourceLogs)
+
+	req, err := service.DecodeOTLPLogsProto(body)
+	require.NoError(t, err)
+
+	entries := service.OTLPEntries(req, uuid.Nil)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, "storage", entry.ServiceName)
+	assert.Equal(t, models.LogLevelWarn, entry.Level)
+	assert.Equal(t, "disk almost full", entry.Message)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), entry.Timestamp)
+	assert.Equal(t, "5b8efff7", entry.TraceID)
+	assert.JSONEq(t, `{"usage":90,"scope":"disk"}`, string(entry.Metadata))
+
+	t.Run("Rejects Truncated Payload", func(t *testing.T) {
+		_, err := service.DecodeOTLPLogsProto(body[:len(body)-3])
+		assert.ErrorIs(t, err, service.ErrInvalidOTLP)
+	})
+}
+
+// TestEncodeOTLPLogsResponseProto tests the protobuf partial success response
+func TestEncodeOTLPLogsResponseProto(t *testing.T) {
+	assert.Empty(t, service.EncodeOTLPLogsResponseProto(models.OTLPLogsResponse{}))
+
+	got := service.EncodeOTLPLogsResponseProto(models.OTLPLogsResponse{
+		PartialSuccess: &models.OTLPPartialSuccess{RejectedLogRecords: 2, ErrorMessage: "bad"},
+	})
+	assert.Equal(t, []byte{0x0a, 0x07, 0x08, 0x02, 0x12, 0x03, 'b', 'a', 'd'}, got)
+}
@@ -0,0 +1,194 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/log/config"
+	"github.com/minisource/log/internal/database"
+	"github.com/minisource/log/internal/handler"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newTestDB connects to the database configured via environment and
+// prepares the schema, skipping the test when no database is reachable
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Skipf("Requires configuration: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(cfg.Postgres)
+	if err != nil {
+		t.Skipf("Requires database connection: %v", err)
+	}
+
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := database.CreateIndexes(db); err != nil {
+		t.Fatalf("failed to create indexes: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// newTestRedis connects to the Redis configured via environment, skipping
+// the test when it is not reachable
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Skipf("Requires configuration: %v", err)
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("Requires redis connection: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+// TestPoolMonitorGauges verifies pool gauges are registered up front, track
+// sampled stats and warn when waits grow
+func TestPoolMonitorGauges(t *testing.T) {
+	stats := sql.DBStats{MaxOpenConnections: 10}
+	monitor := database.NewPoolMonitor(func() sql.DBStats { return stats }, 50, time.Second)
+
+	gauges := monitor.Gauges()
+	for _, name := range []string{
+		database.GaugeOpenConnections, database.GaugeInUse, database.GaugeIdle,
+		database.GaugeMaxOpenConnections, database.GaugeWaitCount, database.GaugeWaitDuration,
+	} {
+		assert.Contains(t, gauges, name)
+		assert.Zero(t, gauges[name])
+	}
+
+	stats.OpenConnections, stats.InUse, stats.Idle = 8, 6, 2
+	stats.WaitCount, stats.WaitDuration = 10, 100*time.Millisecond
+	assert.Empty(t, monitor.Sample())
+
+	gauges = monitor.Gauges()
+	assert.Equal(t, float64(8), gauges[database.GaugeOpenConnections])
+	assert.Equal(t, float64(6), gauges[database.GaugeInUse])
+	assert.Equal(t, float64(2), gauges[database.GaugeIdle])
+	assert.Equal(t, float64(10), gauges[database.GaugeMaxOpenConnections])
+	assert.Equal(t, float64(10), gauges[database.GaugeWaitCount])
+	assert.Equal(t, 0.1, gauges[database.GaugeWaitDuration])
+
+	t.Run("Warns On Growing Waits", func(t *testing.T) {
+		stats.WaitCount = 70
+		assert.Contains(t, monitor.Sample(), "DB_MAX_OPEN_CONNS")
+		assert.Empty(t, monitor.Sample(), "no growth since the last sample")
+
+		stats.WaitDuration += 2 * time.Second
+		assert.NotEmpty(t, monitor.Sample())
+	})
+
+	t.Run("Metrics Endpoint", func(t *testing.T) {
+		app := fiber.New()
+		app.Get("/metrics", handler.NewHealthHandler(monitor, nil).Metrics)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(body), "# TYPE db_pool_in_use_connections gauge\ndb_pool_in_use_connections 6\n")
+		assert.Contains(t, string(body), "db_pool_wait_count_total 70\n")
+	})
+}
+
+// TestMetadataIndexAdvisor verifies a path filtered repeatedly through the
+// repository gets a single index recommendation while rare or unsafe paths
+// do not
+func TestMetadataIndexAdvisor(t *testing.T) {
+	advisor := database.NewMetadataIndexAdvisor(3)
+
+	for i := 0; i < 3; i++ {
+		advisor.Observe(models.LogFilter{MetadataIn: map[string][]string{"customer_id": {"c1"}}}.MetadataPaths()...)
+	}
+	advisor.Observe("region")
+	advisor.Observe("bad'path", "bad'path", "bad'path")
+
+	recommendations := advisor.Analyze()
+	require.Len(t, recommendations, 1)
+	assert.Equal(t, "customer_id", recommendations[0].Path)
+	assert.Equal(t, int64(3), recommendations[0].Filters)
+	assert.Equal(t, "idx_logs_meta_customer_id", recommendations[0].Index)
+	assert.Equal(t,
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_logs_meta_customer_id ON log_entries ((metadata->>'customer_id'))",
+		recommendations[0].Statement)
+
+	t.Run("Counts Reset Per Analysis", func(t *testing.T) {
+		advisor.Observe("region", "region")
+		assert.Empty(t, advisor.Analyze(), "earlier observations do not carry over")
+	})
+
+	t.Run("Paths Are Recommended Once", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			advisor.Observe("customer_id")
+		}
+		assert.Empty(t, advisor.Analyze())
+	})
+
+	t.Run("Latency Filter Path", func(t *testing.T) {
+		assert.Equal(t, []string{"latency_ms", "status"},
+			models.LogFilter{MinLatencyMs: 500, MetadataIn: map[string][]string{"status": {"500"}}}.MetadataPaths())
+	})
+
+	t.Run("Repository Queries Are Observed", func(t *testing.T) {
+		db := newTestDB(t)
+		repoAdvisor := database.NewMetadataIndexAdvisor(2)
+		repo := repository.NewLogRepository(db)
+		repo.SetIndexAdvisor(repoAdvisor)
+
+		tenantID := uuid.New()
+		filter := models.LogFilter{TenantID: &tenantID, MetadataIn: map[string][]string{"order_id": {"o-1"}}}
+		for i := 0; i < 2; i++ {
+			_, _, err := repo.Query(context.Background(), filter)
+			require.NoError(t, err)
+		}
+
+		recommendations := repoAdvisor.Analyze()
+		require.Len(t, recommendations, 1)
+		assert.Equal(t, "order_id", recommendations[0].Path)
+		require.NoError(t, database.CreateRecommendedIndex(db, recommendations[0]))
+		t.Cleanup(func() { db.Exec("DROP INDEX IF EXISTS " + recommendations[0].Index) })
+	})
+}
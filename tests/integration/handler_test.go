@@ -0,0 +1,971 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/handler"
+	"github.com/minisource/log/internal/middleware"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"github.com/minisource/log/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSSEWriterGzipFrames tests that compressed stream frames decode correctly
+func TestSSEWriterGzipFrames(t *testing.T) {
+	app := fiber.New()
+	app.Get("/stream", func(c *fiber.Ctx) error {
+		c.Set("Content-Encoding", "gzip")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			sse := handler.NewSSEWriter(w, true)
+			defer sse.Close()
+			sse.WriteEvent("first")
+			sse.WriteComment("keep-alive")
+			sse.WriteEvent("second\nline")
+			sse.WriteNamedEvent("ERROR", `{"message":"third"}`)
+		})
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	frames := strings.Split(strings.TrimSuffix(string(body), "\n\n"), "\n\n")
+	require.Len(t, frames, 4)
+	assert.Equal(t, "data: first", frames[0])
+	assert.Equal(t, ": keep-alive", frames[1])
+	assert.Equal(t, "data: second\ndata: line", frames[2])
+	assert.Equal(t, "event: ERROR\ndata: {\"message\":\"third\"}", frames[3])
+}
+
+// TestStreamPathSkipsCompression tests stream path detection
+func TestStreamPathSkipsCompression(t *testing.T) {
+	assert.True(t, handler.IsStreamPath("/api/v1/logs/stream"))
+	assert.False(t, handler.IsStreamPath("/api/v1/logs/query"))
+}
+
+// TestCollectionEndpointsEmptyResults tests empty collections return 200 with found=false
+func TestCollectionEndpointsEmptyResults(t *testing.T) {
+	db := newTestDB(t)
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	app := fiber.New()
+	app.Get("/logs/trace/:trace_id", logHandler.GetByTrace)
+	app.Get("/logs/request/:request_id", logHandler.GetByRequest)
+
+	for _, path := range []string{"/logs/trace/no-such-trace", "/logs/request/no-such-a,no-such-b"} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var body struct {
+				Success bool              `json:"success"`
+				Found   bool              `json:"found"`
+				Data    []json.RawMessage `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.True(t, body.Success)
+			assert.False(t, body.Found)
+			assert.NotNil(t, body.Data)
+			assert.Empty(t, body.Data)
+		})
+	}
+}
+
+// TestPurgeServiceRemovesOnlyTenantService tests the admin purge is scoped to
+// the named service and tenant and requires confirmation
+func TestPurgeServiceRemovesOnlyTenantService(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewLogRepository(db)
+	adminHandler := handler.NewAdminHandler(newTestLogService(t, db, nil))
+
+	tenantID, otherTenant := uuid.New(), uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, otherTenant}).Delete(&models.LogEntry{})
+	})
+
+	seed := func(tenant uuid.UUID, service string) {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: service,
+			Level: models.LogLevelInfo, Message: "m", Timestamp: time.Now(),
+		}))
+	}
+	seed(tenantID, "old-service")
+	seed(tenantID, "old-service")
+	seed(tenantID, "kept-service")
+	seed(otherTenant, "old-service")
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Delete("/admin/logs", adminHandler.PurgeService)
+
+	purge := func(query string) *http.Response {
+		req := httptest.NewRequest(http.MethodDelete, "/admin/logs?"+query, nil)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	assert.Equal(t, http.StatusBadRequest, purge("service=old-service").StatusCode)
+
+	resp := purge("service=old-service&confirm=old-service")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	count := func(tenant uuid.UUID, service string) int64 {
+		var n int64
+		db.Model(&models.LogEntry{}).Where("tenant_id = ? AND service_name = ?", tenant, service).Count(&n)
+		return n
+	}
+	assert.Zero(t, count(tenantID, "old-service"))
+	assert.Equal(t, int64(1), count(tenantID, "kept-service"))
+	assert.Equal(t, int64(1), count(otherTenant, "old-service"))
+}
+
+// TestStreamLimiterRejectsExtraStreams tests the N+1th stream gets a 503 while
+// existing streams keep their slots
+func TestStreamLimiterRejectsExtraStreams(t *testing.T) {
+	limiter := handler.NewStreamLimiter(2)
+	require.True(t, limiter.Acquire())
+	require.True(t, limiter.Acquire())
+
+	logHandler := handler.NewLogHandler(nil, limiter)
+	app := fiber.New()
+	app.Get("/logs/stream", logHandler.Stream)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/logs/stream", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, 2, limiter.Active(), "existing streams keep their slots")
+
+	limiter.Release()
+	assert.True(t, limiter.Acquire())
+	assert.False(t, limiter.Acquire())
+}
+
+// TestStreamPushesIngestedEntries tests entries ingested after a stream
+// opens are pushed to it, and other tenants' entries are not
+func TestStreamPushesIngestedEntries(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+	logHandler := handler.NewLogHandler(svc, nil)
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Get("/logs/stream", logHandler.Stream)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	tenantID := uuid.New()
+	serviceName := "stream-" + uuid.NewString()[:8]
+	t.Cleanup(func() {
+		db.Where("service_name = ?", serviceName).Delete(&models.LogEntry{})
+	})
+
+	open := func(format string) (*http.Response, <-chan string) {
+		req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/logs/stream?service="+serviceName+format, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// Frames are sent as one line per field, ended by a blank line
+		frames := make(chan string, 8)
+		go func() {
+			var frame []string
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if line != "" {
+					frame = append(frame, line)
+					continue
+				}
+				if len(frame) > 0 && !strings.HasPrefix(frame[0], ":") {
+					frames <- strings.Join(frame, "\n")
+				}
+				frame = nil
+			}
+			close(frames)
+		}()
+		return resp, frames
+	}
+	jsonResp, jsonFrames := open("")
+	defer jsonResp.Body.Close()
+	textResp, textFrames := open("&format=text")
+	defer textResp.Body.Close()
+
+	ctx := context.Background()
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: uuid.New(), ServiceName: serviceName, Level: models.LogLevelInfo, Message: "other tenant",
+	}))
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: tenantID, ServiceName: serviceName, Level: models.LogLevelWarn, Message: "pushed", TraceID: "trace-stream",
+	}))
+
+	next := func(frames <-chan string) string {
+		select {
+		case frame := <-frames:
+			return frame
+		case <-time.After(5 * time.Second):
+			t.Fatal("ingested entry was not pushed to the stream")
+			return ""
+		}
+	}
+
+	frame := next(jsonFrames)
+	event, data, ok := strings.Cut(frame, "\n")
+	require.True(t, ok, frame)
+	assert.Equal(t, "event: WARN", event)
+	var entry models.LogEntry
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &entry))
+	assert.Equal(t, "pushed", entry.Message)
+	assert.Equal(t, models.LogLevelWarn, entry.Level)
+	assert.Equal(t, serviceName, entry.ServiceName)
+	assert.Equal(t, "trace-stream", entry.TraceID)
+
+	assert.Equal(t, "data: pushed", next(textFrames))
+}
+
+// TestStreamRejectsUnknownFormat tests the stream format is validated before
+// a stream slot is taken
+func TestStreamRejectsUnknownFormat(t *testing.T) {
+	limiter := handler.NewStreamLimiter(1)
+	logHandler := handler.NewLogHandler(nil, limiter)
+	app := fiber.New()
+	app.Get("/logs/stream", logHandler.Stream)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/logs/stream?format=xml", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 0, limiter.Active())
+}
+
+// TestQueryRejectsUnselectiveFilter tests queries without a selective
+// predicate get a 400 unless the caller sends the admin key
+func TestQueryRejectsUnselectiveFilter(t *testing.T) {
+	db := newTestDB(t)
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use(middleware.AdminExtractor("secret"))
+	app.Post("/logs/query", logHandler.Query)
+	app.Get("/logs", logHandler.List)
+	app.Head("/logs", logHandler.Count)
+
+	send := func(method, target, body string, headers map[string]string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusBadRequest && method != http.MethodHead {
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(raw), "unselective_query")
+		}
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/logs/query", `{}`, nil))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/logs/query", `{"level":"ERROR"}`, nil))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/logs", "", nil))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodHead, "/logs", "", nil))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/logs/query", `{}`, map[string]string{"X-Admin-Key": "wrong"}))
+
+	tenant := map[string]string{"X-Tenant-ID": uuid.NewString()}
+	start := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/logs/query", `{}`, tenant))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/logs/query", `{"service_name":"api"}`, nil))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/logs/query", `{"start_time":"`+start+`"}`, nil))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/logs/query", `{"trace_id":"trace-1"}`, nil))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/logs/query", `{"request_id":"req-1"}`, nil))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/logs?service=api", "", nil))
+	assert.Equal(t, http.StatusOK, send(http.MethodHead, "/logs", "", tenant))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/logs/query", `{"page_size":1}`, map[string]string{"X-Admin-Key": "secret"}))
+}
+
+// TestQueryProfileHeaders tests timing headers are present only when the
+// request opts in with X-Profile
+func TestQueryProfileHeaders(t *testing.T) {
+	db := newTestDB(t)
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	app := fiber.New()
+	app.Post("/logs/query", logHandler.Query)
+	app.Get("/logs", logHandler.List)
+
+	headers := []string{"X-Profile-Cache-Ms", "X-Profile-Cache-Hit", "X-Profile-DB-Ms", "X-Profile-Serialize-Ms"}
+	tenantID := uuid.New()
+
+	for _, profiled := range []bool{false, true} {
+		for _, newReq := range []func() *http.Request{
+			func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{"service_name":"profile-test"}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			func() *http.Request { return httptest.NewRequest(http.MethodGet, "/logs?service=profile-test", nil) },
+		} {
+			req := newReq()
+			req.Header.Set("X-Tenant-ID", tenantID.String())
+			if profiled {
+				req.Header.Set("X-Profile", "true")
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			for _, header := range headers {
+				if profiled {
+					assert.NotEmpty(t, resp.Header.Get(header), "%s %s", req.URL, header)
+				} else {
+					assert.Empty(t, resp.Header.Get(header), "%s %s", req.URL, header)
+				}
+			}
+			if profiled {
+				assert.Equal(t, "false", resp.Header.Get("X-Profile-Cache-Hit"), "no cache without Redis")
+			}
+		}
+	}
+}
+
+// TestHeadLogsReportsTotalCount tests HEAD /logs returns the full query's
+// total in X-Total-Count without a body
+func TestHeadLogsReportsTotalCount(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	for i, level := range []models.LogLevel{models.LogLevelError, models.LogLevelError, models.LogLevelInfo} {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "head-test",
+			Level: level, Message: fmt.Sprintf("m%d", i), Timestamp: time.Now(),
+		}))
+	}
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Head("/logs", logHandler.Count)
+	app.Get("/logs", logHandler.List)
+
+	for _, query := range []string{"service=head-test", "service=head-test&level=ERROR", "service=missing"} {
+		req := httptest.NewRequest(http.MethodGet, "/logs?"+query, nil)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var full struct {
+			Data models.LogQueryResult `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&full))
+		resp.Body.Close()
+
+		req = httptest.NewRequest(http.MethodHead, "/logs?"+query, nil)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err = app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, query)
+		assert.Equal(t, fmt.Sprint(full.Data.TotalCount), resp.Header.Get("X-Total-Count"), query)
+		assert.Empty(t, body, query)
+	}
+}
+
+// TestIngestEntryContextHeaders tests X-Log-Env and X-Log-Source fill entries
+// that omit those fields without overriding ones that set them
+func TestIngestEntryContextHeaders(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use(middleware.EntryContextExtractor())
+	app.Post("/logs", logHandler.IngestSingle)
+	app.Post("/logs/batch", logHandler.IngestBatch)
+
+	post := func(path, body string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		req.Header.Set("X-Log-Env", "prod")
+		req.Header.Set("X-Log-Source", "k8s")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+	post("/logs/batch", `{"entries":[
+		{"service_name":"ctx","level":"INFO","message":"plain"},
+		{"service_name":"ctx","level":"INFO","message":"explicit","environment":"staging","source":"vm"}
+	]}`)
+	post("/logs", `{"service_name":"ctx","level":"INFO","message":"single"}`)
+
+	var entries []models.LogEntry
+	require.NoError(t, db.WithContext(ctx).Where("tenant_id = ?", tenantID).Find(&entries).Error)
+	require.Len(t, entries, 3)
+	for _, entry := range entries {
+		if entry.Message == "explicit" {
+			assert.Equal(t, "staging", entry.Environment)
+			assert.Equal(t, "vm", entry.Source)
+			continue
+		}
+		assert.Equal(t, "prod", entry.Environment, entry.Message)
+		assert.Equal(t, "k8s", entry.Source, entry.Message)
+	}
+}
+
+// TestStrictJSONIngestion tests X-Strict-JSON rejects misspelled entry fields
+// while the default lenient parsing accepts them
+func TestStrictJSONIngestion(t *testing.T) {
+	const typo = `{"service_name":"strict","level":"INFO","mesage":"typo"}`
+
+	t.Run("Strict Rejects Unknown Fields", func(t *testing.T) {
+		// Rejected before reaching the service
+		logHandler := handler.NewLogHandler(nil, nil)
+		app := fiber.New()
+		app.Post("/logs", logHandler.IngestSingle)
+		app.Post("/logs/batch", logHandler.IngestBatch)
+
+		for path, body := range map[string]string{
+			"/logs":       typo,
+			"/logs/batch": `{"entries":[` + typo + `]}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Strict-JSON", "true")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+
+			raw, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(raw), "mesage", path)
+		}
+	})
+
+	t.Run("Lenient Accepts Unknown Fields", func(t *testing.T) {
+		db := newTestDB(t)
+		logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+		app := fiber.New()
+		app.Use(middleware.TenantExtractor())
+		app.Post("/logs", logHandler.IngestSingle)
+
+		tenantID := uuid.New()
+		t.Cleanup(func() {
+			db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/logs", strings.NewReader(typo))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+}
+
+// TestIngestLevelValidation tests unknown levels are rejected with the
+// allowed values, lowercase levels are normalized, and a batch reports its
+// invalid entries by index with a 207 while storing the rest
+func TestIngestLevelValidation(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Post("/logs", logHandler.IngestSingle)
+	app.Post("/logs/batch", logHandler.IngestBatch)
+
+	post := func(path, body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(raw)
+	}
+
+	status, body := post("/logs", `{"service_name":"levels","level":"warning","message":"bad level"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "invalid_level")
+	assert.Contains(t, body, "DEBUG, INFO, WARN, ERROR, FATAL")
+
+	status, _ = post("/logs", `{"service_name":"levels","level":" info ","message":"lowercase"}`)
+	assert.Equal(t, http.StatusCreated, status)
+
+	status, body = post("/logs/batch", `{"entries":[
+		{"service_name":"levels","level":"ERROR","message":"valid"},
+		{"service_name":"levels","level":"critical","message":"invalid"},
+		{"service_name":"levels","level":"warn","message":"lowercase in batch"},
+		{"service_name":"levels","message":"missing"}
+	]}`)
+	assert.Equal(t, http.StatusMultiStatus, status)
+	var result struct {
+		Data struct {
+			Count    int                    `json:"count"`
+			Failures []models.RejectedEntry `json:"failures"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &result))
+	assert.Equal(t, 2, result.Data.Count)
+	require.Len(t, result.Data.Failures, 2)
+	assert.Equal(t, 1, result.Data.Failures[0].Index)
+	assert.Contains(t, result.Data.Failures[0].Reason, `"CRITICAL"`)
+	assert.Equal(t, 3, result.Data.Failures[1].Index)
+	assert.Contains(t, result.Data.Failures[1].Reason, "level is required")
+
+	status, body = post("/logs/batch", `{"entries":[{"service_name":"levels","level":"verbose","message":"all invalid"}]}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "entries[0]")
+
+	levels := map[string]models.LogLevel{}
+	var stored []models.LogEntry
+	require.NoError(t, db.WithContext(ctx).Where("tenant_id = ?", tenantID).Find(&stored).Error)
+	for _, entry := range stored {
+		levels[entry.Message] = entry.Level
+	}
+	assert.Equal(t, map[string]models.LogLevel{
+		"lowercase":          models.LogLevelInfo,
+		"valid":              models.LogLevelError,
+		"lowercase in batch": models.LogLevelWarn,
+	}, levels)
+}
+
+// TestQueryCSVMatchesJSON tests POST /logs/query answers Accept: text/csv with
+// the same result as its JSON response, one row per entry
+func TestQueryCSVMatchesJSON(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	base := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "csv-test", Level: models.LogLevelWarn,
+			Message:   fmt.Sprintf("slow, \"quoted\" request %d", i),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Metadata:  json.RawMessage(fmt.Sprintf(`{"duration_ms": %d, "http": {"status": 50%d}}`, 100*i, i)),
+		}))
+	}
+	require.NoError(t, repo.Create(ctx, &models.LogEntry{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "other", Level: models.LogLevelInfo, Message: "excluded", Timestamp: base,
+	}))
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Post("/logs/query", logHandler.Query)
+
+	query := func(accept string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{"service_name":"csv-test","min_level":"WARN"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp
+	}
+
+	resp := query("")
+	var full struct {
+		Data models.LogQueryResult `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&full))
+	resp.Body.Close()
+	require.Len(t, full.Data.Entries, 3)
+	expected := models.FlattenQueryResult(&full.Data)
+
+	resp = query("text/csv")
+	defer resp.Body.Close()
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv"))
+	assert.Equal(t, "3", resp.Header.Get("X-Total-Count"))
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, records, 4, "header plus one row per entry")
+	assert.Equal(t, expected.Columns, records[0])
+	assert.Contains(t, records[0], "duration_ms")
+	assert.Contains(t, records[0], "http.status")
+	column := func(name string) int {
+		for i, c := range records[0] {
+			if c == name {
+				return i
+			}
+		}
+		t.Fatalf("missing column %s", name)
+		return -1
+	}
+	for i, entry := range full.Data.Entries {
+		row := records[i+1]
+		assert.Equal(t, entry.ID.String(), row[column("id")])
+		assert.Equal(t, entry.Message, row[column("message")])
+		assert.Equal(t, string(entry.Level), row[column("level")])
+		assert.Equal(t, entry.Timestamp.Format(time.RFC3339Nano), row[column("timestamp")])
+		assert.Equal(t, fmt.Sprint(expected.Rows[i]["duration_ms"]), row[column("duration_ms")])
+		assert.Equal(t, fmt.Sprint(expected.Rows[i]["http.status"]), row[column("http.status")])
+	}
+
+	t.Run("JSON Preferred Over CSV", func(t *testing.T) {
+		resp := query("application/json, text/csv;q=0.5")
+		defer resp.Body.Close()
+		assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json"))
+	})
+}
+
+// TestExportGzip verifies a gzipped export decompresses to the matching rows
+// and that clients not accepting gzip get the same rows uncompressed
+func TestExportGzip(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	base := time.Now().UTC().Truncate(time.Second)
+	var expected []uuid.UUID
+	for i := 0; i < 5; i++ {
+		entry := &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "export-test", Level: models.LogLevelInfo,
+			Message: fmt.Sprintf("exported %d", i), Timestamp: base.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, repo.Create(ctx, entry))
+		expected = append(expected, entry.ID)
+	}
+	require.NoError(t, repo.Create(ctx, &models.LogEntry{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "other", Level: models.LogLevelInfo, Message: "excluded", Timestamp: base,
+	}))
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Get("/logs/export", logHandler.Export)
+
+	export := func(acceptEncoding string) (*http.Response, []uuid.UUID) {
+		req := httptest.NewRequest(http.MethodGet, "/logs/export?service=export-test", nil)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := app.Test(req, 5000)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		defer resp.Body.Close()
+
+		var body io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(resp.Body)
+			require.NoError(t, err)
+			body = gz
+		}
+		var ids []uuid.UUID
+		dec := json.NewDecoder(body)
+		for {
+			var entry models.LogEntry
+			if err := dec.Decode(&entry); err == io.EOF {
+				break
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, "export-test", entry.ServiceName)
+			ids = append(ids, entry.ID)
+		}
+		return resp, ids
+	}
+
+	resp, ids := export("gzip")
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	assert.Equal(t, expected, ids, "entries in timestamp order")
+
+	resp, ids = export("")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, expected, ids)
+}
+
+// TestTenantDefaultPageSize verifies a listing without page_size uses the
+// tenant's configured default while other tenants get the global default
+func TestTenantDefaultPageSize(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	custom, other := uuid.New(), uuid.New()
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertSettings(ctx, &models.TenantSettings{TenantID: custom, DefaultPageSize: 5}))
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		tenants.DeleteSettings(ctx, custom)
+		db.Where("tenant_id IN ?", []uuid.UUID{custom, other}).Delete(&models.LogEntry{})
+	})
+	for _, tenantID := range []uuid.UUID{custom, other} {
+		var entries []models.LogEntry
+		for i := 0; i < 120; i++ {
+			entries = append(entries, models.LogEntry{
+				ID: uuid.New(), TenantID: tenantID, ServiceName: "paging", Level: models.LogLevelInfo,
+				Message: fmt.Sprintf("entry %d", i), Timestamp: time.Now().UTC(),
+			})
+		}
+		require.NoError(t, repo.CreateBatch(ctx, entries))
+	}
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Get("/logs", logHandler.List)
+
+	list := func(tenantID uuid.UUID, query string) models.LogQueryResult {
+		req := httptest.NewRequest(http.MethodGet, "/logs"+query, nil)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		defer resp.Body.Close()
+		var body struct {
+			Data models.LogQueryResult `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body.Data
+	}
+
+	result := list(custom, "")
+	assert.Len(t, result.Entries, 5)
+	assert.Equal(t, 5, result.PageSize)
+
+	result = list(custom, "?page_size=20")
+	assert.Len(t, result.Entries, 20, "an explicit page_size wins")
+
+	result = list(other, "")
+	assert.Len(t, result.Entries, service.DefaultPageSize)
+	assert.Equal(t, service.DefaultPageSize, result.PageSize)
+}
+
+// TestIngestBatchPartialSuccess tests a row the database refuses is reported
+// by index with a 207 while the rest of the batch is stored
+func TestIngestBatchPartialSuccess(t *testing.T) {
+	db := newTestDB(t)
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Post("/logs/batch", logHandler.IngestBatch)
+
+	post := func(body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/logs/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(raw)
+	}
+
+	// The environment column holds at most 50 characters
+	status, body := post(fmt.Sprintf(`{"entries":[
+		{"service_name":"partial","level":"INFO","message":"first"},
+		{"service_name":"partial","level":"INFO","message":"too long","environment":%q},
+		{"service_name":"partial","level":"INFO","message":"third"}
+	]}`, strings.Repeat("e", 80)))
+	assert.Equal(t, http.StatusMultiStatus, status)
+
+	var result struct {
+		Data struct {
+			Accepted int                    `json:"accepted"`
+			Rejected int                    `json:"rejected"`
+			Failures []models.RejectedEntry `json:"failures"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &result))
+	assert.Equal(t, 2, result.Data.Accepted)
+	assert.Equal(t, 1, result.Data.Rejected)
+	require.Len(t, result.Data.Failures, 1)
+	assert.Equal(t, 1, result.Data.Failures[0].Index)
+	assert.NotEmpty(t, result.Data.Failures[0].Reason)
+
+	var messages []string
+	require.NoError(t, db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).
+		Order("message").Pluck("message", &messages).Error)
+	assert.Equal(t, []string{"first", "third"}, messages)
+
+	t.Run("All Stored", func(t *testing.T) {
+		status, body := post(`{"entries":[{"service_name":"partial","level":"INFO","message":"clean"}]}`)
+		assert.Equal(t, http.StatusCreated, status)
+		assert.Contains(t, body, `"accepted":1`)
+		assert.Contains(t, body, `"rejected":0`)
+		assert.NotContains(t, body, "failures")
+	})
+}
+
+// TestExportTrace tests a trace bundle holds every entry of the tenant's
+// trace in timestamp order, as JSON or as one zip file per service
+func TestExportTrace(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logHandler := handler.NewLogHandler(newTestLogService(t, db, nil), nil)
+
+	tenantID := uuid.New()
+	otherTenant := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, otherTenant}).Delete(&models.LogEntry{})
+	})
+
+	traceID := strings.ReplaceAll(uuid.NewString(), "-", "")
+	base := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	newEntry := func(tenant uuid.UUID, serviceName, message string, offset time.Duration) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: serviceName, Level: models.LogLevelInfo,
+			Message: message, Timestamp: base.Add(offset), TraceID: traceID,
+		}
+	}
+	// Stored out of order to check the bundle sorts by timestamp
+	require.NoError(t, repo.CreateBatch(ctx, []models.LogEntry{
+		newEntry(tenantID, "payments", "charge card", 30*time.Millisecond),
+		newEntry(tenantID, "gateway", "request received", 0),
+		newEntry(tenantID, "orders", "create order", 10*time.Millisecond),
+		newEntry(tenantID, "gateway", "response sent", 50*time.Millisecond),
+		newEntry(tenantID, "orders", "order created", 40*time.Millisecond),
+		newEntry(otherTenant, "gateway", "other tenant", 20*time.Millisecond),
+	}))
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Get("/logs/trace/:trace_id/export", logHandler.ExportTrace)
+
+	get := func(path string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	ordered := []string{"request received", "create order", "charge card", "order created", "response sent"}
+
+	t.Run("JSON Bundle", func(t *testing.T) {
+		resp := get("/logs/trace/" + traceID + "/export")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Disposition"), "trace-"+traceID+".json")
+
+		var bundle models.TraceBundle
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&bundle))
+		assert.Equal(t, traceID, bundle.TraceID)
+		assert.Equal(t, 5, bundle.EntryCount)
+		assert.Equal(t, []string{"gateway", "orders", "payments"}, bundle.Services)
+		messages := make([]string, 0, len(bundle.Entries))
+		for _, entry := range bundle.Entries {
+			messages = append(messages, entry.Message)
+		}
+		assert.Equal(t, ordered, messages)
+	})
+
+	t.Run("Zip Per Service", func(t *testing.T) {
+		resp := get("/logs/trace/" + traceID + "/export?format=zip")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+
+		raw, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		archive, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+		require.NoError(t, err)
+
+		files := map[string][]string{}
+		for _, file := range archive.File {
+			rc, err := file.Open()
+			require.NoError(t, err)
+			var entries []models.LogEntry
+			require.NoError(t, json.NewDecoder(rc).Decode(&entries))
+			rc.Close()
+			for _, entry := range entries {
+				files[file.Name] = append(files[file.Name], entry.Message)
+			}
+		}
+		assert.Equal(t, map[string][]string{
+			"gateway.json":  {"request received", "response sent"},
+			"orders.json":   {"create order", "order created"},
+			"payments.json": {"charge card"},
+		}, files)
+	})
+
+	t.Run("Unknown Trace", func(t *testing.T) {
+		resp := get("/logs/trace/" + strings.Repeat("0", 32) + "/export")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Invalid Format", func(t *testing.T) {
+		resp := get("/logs/trace/" + traceID + "/export?format=tar")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
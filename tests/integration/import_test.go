@@ -0,0 +1,335 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/handler"
+	"github.com/minisource/log/internal/middleware"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"github.com/minisource/log/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryObjectStore is an in-memory ObjectStore for import tests
+type memoryObjectStore map[string][]byte
+
+func (m memoryObjectStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m memoryObjectStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m[key])), nil
+}
+
+// archiveObjectStore is a concurrency-safe in-memory store for archival
+// tests. Uploads under failPrefix fail; before accepting an upload it calls
+// onPut, which lets tests observe the database mid-archive.
+type archiveObjectStore struct {
+	failPrefix string
+	onPut      func(key string)
+
+	mu       sync.Mutex
+	objects  memoryObjectStore
+	inFlight int
+	maxPuts  int
+}
+
+func (s *archiveObjectStore) Put(ctx context.Context, key string, body []byte) error {
+	s.mu.Lock()
+	s.inFlight++
+	s.maxPuts = max(s.maxPuts, s.inFlight)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	if s.onPut != nil {
+		s.onPut(key)
+	}
+	// Keep uploads overlapping long enough to observe concurrency
+	time.Sleep(20 * time.Millisecond)
+	if s.failPrefix != "" && strings.HasPrefix(key, s.failPrefix) {
+		return fmt.Errorf("upload of %s rejected", key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = body
+	return nil
+}
+
+// ndjson encodes entries one per line, gzipping when compress is set
+func ndjson(t *testing.T, compress bool, entries ...models.LogEntry) []byte {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		require.NoError(t, enc.Encode(entry))
+	}
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+// TestImportFromObjectStore tests NDJSON archives are imported with duplicates skipped
+func TestImportFromObjectStore(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	ts := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newEntry := func(message string) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "archive",
+			Level: models.LogLevelInfo, Message: message, Timestamp: ts,
+		}
+	}
+	first, second, third := newEntry("one"), newEntry("two"), newEntry("three")
+
+	store := memoryObjectStore{
+		"archives/2024/a.ndjson":    ndjson(t, false, first, second),
+		"archives/2024/b.ndjson.gz": ndjson(t, true, second, third),
+		"other/c.ndjson":            ndjson(t, false, newEntry("ignored")),
+	}
+	svc := service.NewImportService(repository.NewLogRepository(db), newTestLogService(t, db, nil), store, "archives/")
+
+	var frames []models.ImportProgress
+	summary, err := svc.Import(ctx, "2024/", nil, func(frame models.ImportProgress) {
+		frames = append(frames, frame)
+	})
+	require.NoError(t, err)
+	assert.Len(t, frames, 2)
+	assert.Equal(t, 2, summary.Files)
+	assert.Equal(t, int64(3), summary.ImportedTotal)
+	assert.Equal(t, int64(1), summary.SkippedTotal)
+
+	var count int64
+	db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	// Re-importing the same archives inserts nothing
+	summary, err = svc.Import(ctx, "2024/", nil, nil)
+	require.NoError(t, err)
+	assert.Zero(t, summary.ImportedTotal)
+	assert.Equal(t, int64(4), summary.SkippedTotal)
+}
+
+// TestImportIntoCallerTenant tests imported entries land in the caller's
+// tenant whatever the file names, and invalid entries are not imported
+func TestImportIntoCallerTenant(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID, fileTenantID := uuid.New(), uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, fileTenantID}).Delete(&models.LogEntry{})
+	})
+
+	ts := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newEntry := func(tenant uuid.UUID, level models.LogLevel) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: "archive",
+			Level: level, Message: "imported", Timestamp: ts,
+		}
+	}
+	store := memoryObjectStore{
+		"a.ndjson": ndjson(t, false,
+			newEntry(fileTenantID, models.LogLevelInfo),
+			newEntry(uuid.Nil, models.LogLevelInfo),
+			newEntry(fileTenantID, models.LogLevel("verbose"))),
+	}
+	svc := service.NewImportService(repository.NewLogRepository(db), newTestLogService(t, db, nil), store, "")
+
+	summary, err := svc.Import(ctx, "", &tenantID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), summary.ImportedTotal)
+	assert.Equal(t, int64(1), summary.FailedTotal, "an invalid level is rejected")
+
+	var count int64
+	db.Model(&models.LogEntry{}).Where("tenant_id = ?", fileTenantID).Count(&count)
+	assert.Zero(t, count, "the file's tenant is overwritten")
+
+	t.Run("Keeping Tenants Fails Entries Without One", func(t *testing.T) {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+
+		summary, err := svc.Import(ctx, "", nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), summary.ImportedTotal)
+		assert.Equal(t, int64(2), summary.FailedTotal)
+	})
+}
+
+// TestArchiveConcurrently verifies days are archived in parallel, each day's
+// entries deleted only after its upload, and a failed day keeping its entries
+func TestArchiveConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	// Six days of 5 entries each, the fourth day failing to upload
+	repo := repository.NewLogRepository(db)
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -10)
+	var entries []models.LogEntry
+	for day := 0; day < 6; day++ {
+		for i := 0; i < 5; i++ {
+			entries = append(entries, models.LogEntry{
+				ID: uuid.New(), TenantID: tenantID, ServiceName: "archive", Level: models.LogLevelInfo,
+				Message: fmt.Sprintf("day %d entry %d", day, i), Timestamp: start.AddDate(0, 0, day).Add(time.Duration(i) * time.Hour),
+			})
+		}
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	countDay := func(day time.Time) int64 {
+		var count int64
+		db.Model(&models.LogEntry{}).
+			Where("tenant_id = ? AND timestamp >= ? AND timestamp < ?", tenantID, day, day.Add(24*time.Hour)).
+			Count(&count)
+		return count
+	}
+
+	failedDay := start.AddDate(0, 0, 3)
+	prefix := "archives/" + tenantID.String() + "/"
+	var mu sync.Mutex
+	presentAtUpload := make(map[string]int64)
+	store := &archiveObjectStore{
+		failPrefix: prefix + failedDay.Format("2006-01-02"),
+		objects:    memoryObjectStore{},
+	}
+	store.onPut = func(key string) {
+		day, err := time.Parse("2006-01-02", strings.Split(strings.TrimPrefix(key, prefix), "/")[0])
+		assert.NoError(t, err)
+		mu.Lock()
+		presentAtUpload[day.Format("2006-01-02")] = countDay(day)
+		mu.Unlock()
+	}
+
+	svc := service.NewArchiveService(repo, store, "archives/", 3)
+	summary, err := svc.Archive(ctx, tenantID, start, start.AddDate(0, 0, 6))
+	require.NoError(t, err)
+
+	require.Len(t, summary.Chunks, 6)
+	assert.Greater(t, store.maxPuts, 1, "days are uploaded concurrently")
+	assert.LessOrEqual(t, store.maxPuts, 3, "concurrency is bounded by the worker count")
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, int64(25), summary.Archived)
+	assert.Equal(t, int64(25), summary.Deleted)
+
+	for day := 0; day < 6; day++ {
+		date := start.AddDate(0, 0, day)
+		chunk := summary.Chunks[day]
+		assert.True(t, chunk.Start.Equal(date))
+		assert.Equal(t, int64(5), presentAtUpload[date.Format("2006-01-02")], "entries exist until their upload")
+
+		if date.Equal(failedDay) {
+			assert.NotEmpty(t, chunk.Error)
+			assert.Zero(t, chunk.Deleted)
+			assert.Equal(t, int64(5), countDay(date), "a failed day keeps its entries")
+			continue
+		}
+		assert.Empty(t, chunk.Error)
+		assert.Equal(t, int64(5), chunk.Deleted)
+		assert.Zero(t, countDay(date))
+		assert.Contains(t, store.objects, chunk.Key)
+	}
+
+	// Archives import back into the entries they replaced
+	imported, err := service.NewImportService(repo, newTestLogService(t, db, nil), store.objects, "").Import(ctx, prefix, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, imported.Files)
+	assert.Equal(t, int64(25), imported.ImportedTotal)
+}
+
+// TestArchiveRefusedInMaintenance verifies archival deletes nothing and the
+// handler answers 409 while maintenance mode is on
+func TestArchiveRefusedInMaintenance(t *testing.T) {
+	store := &archiveObjectStore{objects: memoryObjectStore{}}
+	svc := service.NewArchiveService(nil, store, "archives/", 1)
+	inMaintenance := true
+	svc.SetMaintenanceCheck(func() bool { return inMaintenance })
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, err := svc.Archive(context.Background(), uuid.New(), start, start.AddDate(0, 0, 1))
+	assert.ErrorIs(t, err, service.ErrMaintenanceMode)
+	assert.Empty(t, store.objects)
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Post("/logs/archive/s3", handler.NewArchiveHandler(svc).ArchiveS3)
+
+	body := fmt.Sprintf(`{"start":%q,"end":%q}`, start.Format(time.RFC3339), start.AddDate(0, 0, 1).Format(time.RFC3339))
+	req := httptest.NewRequest(http.MethodPost, "/logs/archive/s3", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", uuid.New().String())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+// TestImportRequiresTenant verifies imports without a tenant are rejected and
+// keeping the files' tenants is reserved to operators
+func TestImportRequiresTenant(t *testing.T) {
+	svc := service.NewImportService(nil, nil, memoryObjectStore{}, "")
+
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use(middleware.AdminExtractor("secret"))
+	app.Post("/logs/import/s3", handler.NewImportHandler(svc).ImportS3)
+
+	post := func(body string, headers map[string]string) int {
+		req := httptest.NewRequest(http.MethodPost, "/logs/import/s3", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(`{}`, nil))
+	assert.Equal(t, http.StatusForbidden, post(`{"keep_tenants":true}`,
+		map[string]string{"X-Tenant-ID": uuid.New().String()}))
+}
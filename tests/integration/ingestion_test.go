@@ -0,0 +1,941 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMessageParserExtractsFields tests field extraction from apache-style lines
+func TestMessageParserExtractsFields(t *testing.T) {
+	parser, err := service.NewMessageParser(map[string]string{
+		"web":     "combined",
+		"legacy":  "common",
+		"gateway": "request",
+	})
+	require.NoError(t, err)
+
+	t.Run("Combined Log Format", func(t *testing.T) {
+		entry := models.LogEntry{
+			ServiceName: "web",
+			Message:     `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`,
+		}
+		parser.Apply(&entry)
+
+		var metadata map[string]interface{}
+		require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+		assert.Equal(t, "GET", metadata["method"])
+		assert.Equal(t, "/apache_pb.gif", metadata["path"])
+		assert.Equal(t, float64(200), metadata["status"])
+		assert.Equal(t, "Mozilla/4.08", metadata["user_agent"])
+	})
+
+	t.Run("Common Log Format Keeps Existing Metadata", func(t *testing.T) {
+		entry := models.LogEntry{
+			ServiceName: "legacy",
+			Message:     `10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "POST /login HTTP/1.1" 401 -`,
+			Metadata:    json.RawMessage(`{"method":"original"}`),
+		}
+		parser.Apply(&entry)
+
+		var metadata map[string]interface{}
+		require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+		assert.Equal(t, "original", metadata["method"])
+		assert.Equal(t, float64(401), metadata["status"])
+		assert.NotContains(t, metadata, "bytes")
+	})
+
+	t.Run("Request Pattern", func(t *testing.T) {
+		entry := models.LogEntry{ServiceName: "gateway", Message: "GET /api/users 200 145ms"}
+		parser.Apply(&entry)
+
+		var metadata map[string]interface{}
+		require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+		assert.Equal(t, "/api/users", metadata["path"])
+		assert.Equal(t, float64(145), metadata["latency_ms"])
+	})
+
+	t.Run("Unconfigured Service Is Untouched", func(t *testing.T) {
+		entry := models.LogEntry{ServiceName: "other", Message: "GET /api/users 200 145ms"}
+		parser.Apply(&entry)
+		assert.Nil(t, entry.Metadata)
+	})
+}
+
+// TestMessageParserNormalizesNumbers verifies integer captures that are not
+// valid JSON numbers as written are stored as numbers
+func TestMessageParserNormalizesNumbers(t *testing.T) {
+	parser, err := service.NewMessageParser(map[string]string{
+		"worker": `^job (?P<job>\S+) took (?P<delta>[+-]?\d+)$`,
+	})
+	require.NoError(t, err)
+
+	entry := models.LogEntry{ServiceName: "worker", Message: "job 007 took +5"}
+	parser.Apply(&entry)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+	assert.Equal(t, float64(7), metadata["job"])
+	assert.Equal(t, float64(5), metadata["delta"])
+}
+
+// TestMessageParserSkipsInvalidPatterns verifies one bad rule does not
+// disable the others
+func TestMessageParserSkipsInvalidPatterns(t *testing.T) {
+	parser, err := service.NewMessageParser(map[string]string{
+		"gateway": "request",
+		"broken":  `(?P<unclosed`,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	require.NotNil(t, parser)
+
+	entry := models.LogEntry{ServiceName: "gateway", Message: "GET /api/users 200 145ms"}
+	parser.Apply(&entry)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+	assert.Equal(t, "/api/users", metadata["path"])
+}
+
+// TestMetadataPreservesLargeIntegers ensures metadata processing never loses precision
+func TestMetadataPreservesLargeIntegers(t *testing.T) {
+	parser, err := service.NewMessageParser(map[string]string{"gateway": "request"})
+	require.NoError(t, err)
+
+	entry := models.LogEntry{
+		ServiceName: "gateway",
+		Message:     "GET /api/orders 200 12ms",
+		Metadata:    json.RawMessage(`{"order_id":1234567890123456789}`),
+	}
+	parser.Apply(&entry)
+
+	assert.Contains(t, string(entry.Metadata), `"order_id":1234567890123456789`)
+}
+
+// TestTruncateMessage tests truncation at the configured boundary
+func TestTruncateMessage(t *testing.T) {
+	t.Run("At Limit Is Unchanged", func(t *testing.T) {
+		entry := models.LogEntry{Message: strings.Repeat("a", 10)}
+		service.TruncateMessage(&entry, 10, false)
+		assert.Equal(t, strings.Repeat("a", 10), entry.Message)
+	})
+
+	t.Run("Over Limit Is Truncated With Marker", func(t *testing.T) {
+		entry := models.LogEntry{Message: strings.Repeat("a", 11)}
+		service.TruncateMessage(&entry, 10, false)
+		assert.Equal(t, strings.Repeat("a", 10)+"…[truncated 1 bytes]", entry.Message)
+		assert.Nil(t, entry.Metadata)
+	})
+
+	t.Run("Multi-byte Characters Are Not Split", func(t *testing.T) {
+		entry := models.LogEntry{Message: "ab€cd"} // € is 3 bytes
+		service.TruncateMessage(&entry, 3, false)
+		assert.Equal(t, "ab…[truncated 5 bytes]", entry.Message)
+	})
+
+	t.Run("Full Message Preserved In Metadata", func(t *testing.T) {
+		original := strings.Repeat("stack frame\n", 100)
+		entry := models.LogEntry{Message: original, Metadata: json.RawMessage(`{"k":"v"}`)}
+		service.TruncateMessage(&entry, 20, true)
+
+		var metadata map[string]string
+		require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+		assert.Equal(t, "v", metadata["k"])
+
+		compressed, err := base64.StdEncoding.DecodeString(metadata[service.FullMessageKey])
+		require.NoError(t, err)
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		require.NoError(t, err)
+		restored, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, original, string(restored))
+	})
+}
+
+// TestMetadataKeyFilter tests denied keys are stripped and allowed keys retained
+func TestMetadataKeyFilter(t *testing.T) {
+	t.Run("Denylist", func(t *testing.T) {
+		filter := service.NewMetadataKeyFilter(nil, []string{"password", "ssn"})
+		entry := &models.LogEntry{Metadata: json.RawMessage(`{"user":"a","password":"x","ssn":"1","id":12345678901234567890}`)}
+
+		assert.Equal(t, 2, filter.Apply(entry))
+		assert.JSONEq(t, `{"user":"a","id":12345678901234567890}`, string(entry.Metadata))
+		assert.Equal(t, int64(2), filter.Stripped())
+	})
+
+	t.Run("Allowlist", func(t *testing.T) {
+		filter := service.NewMetadataKeyFilter([]string{"user", "status"}, []string{"status"})
+		entry := &models.LogEntry{Metadata: json.RawMessage(`{"user":"a","status":200,"debug_blob":"..."}`)}
+
+		assert.Equal(t, 2, filter.Apply(entry))
+		assert.JSONEq(t, `{"user":"a"}`, string(entry.Metadata))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		filter := service.NewMetadataKeyFilter(nil, nil)
+		entry := &models.LogEntry{Metadata: json.RawMessage(`{"password":"x"}`)}
+
+		assert.Zero(t, filter.Apply(entry))
+		assert.JSONEq(t, `{"password":"x"}`, string(entry.Metadata))
+	})
+}
+
+// TestStreamBrokerServicePartition tests a service-filtered subscription only
+// receives its own service's entries on the wire
+func TestStreamBrokerServicePartition(t *testing.T) {
+	broker := service.NewStreamBroker(nil)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	checkout := broker.Subscribe(ctx, models.LogFilter{TenantID: &tenantID, ServiceName: "checkout"})
+	defer checkout.Close()
+	tenantWide := broker.Subscribe(ctx, models.LogFilter{TenantID: &tenantID})
+	defer tenantWide.Close()
+
+	assert.Equal(t, service.StreamChannel(tenantID, "checkout"), checkout.Topic())
+	assert.True(t, strings.HasSuffix(tenantWide.Topic(), "*"))
+
+	otherTenant := uuid.New()
+	broker.Publish(ctx, []models.LogEntry{
+		{TenantID: tenantID, ServiceName: "search", Message: "search"},
+		{TenantID: tenantID, ServiceName: "checkout", Message: "checkout"},
+		{TenantID: otherTenant, ServiceName: "checkout", Message: "other tenant"},
+	})
+
+	assert.Equal(t, int64(1), checkout.Received(), "other services must not reach the subscription")
+	assert.Equal(t, "checkout", (<-checkout.C).Message)
+
+	assert.Equal(t, int64(2), tenantWide.Received())
+	assert.Equal(t, "search", (<-tenantWide.C).Message)
+	assert.Equal(t, "checkout", (<-tenantWide.C).Message)
+}
+
+// TestStreamBrokerMetadataIn verifies in-memory filtering honors multi-value
+// metadata matching the same way the SQL filter does
+func TestStreamBrokerMetadataIn(t *testing.T) {
+	broker := service.NewStreamBroker(nil)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	sub := broker.Subscribe(ctx, models.LogFilter{
+		TenantID:   &tenantID,
+		MetadataIn: map[string][]string{"region": {"eu", "us"}, "status": {"500"}},
+	})
+	defer sub.Close()
+
+	broker.Publish(ctx, []models.LogEntry{
+		{TenantID: tenantID, ServiceName: "api", Message: "eu", Metadata: json.RawMessage(`{"region":"eu","status":500}`)},
+		{TenantID: tenantID, ServiceName: "api", Message: "us ok", Metadata: json.RawMessage(`{"region":"us","status":200}`)},
+		{TenantID: tenantID, ServiceName: "api", Message: "ap", Metadata: json.RawMessage(`{"region":"ap","status":500}`)},
+		{TenantID: tenantID, ServiceName: "api", Message: "us", Metadata: json.RawMessage(`{"region":"us","status":"500"}`)},
+		{TenantID: tenantID, ServiceName: "api", Message: "none"},
+	})
+
+	assert.Equal(t, int64(5), sub.Received())
+	require.Len(t, sub.C, 2)
+	assert.Equal(t, "eu", (<-sub.C).Message)
+	assert.Equal(t, "us", (<-sub.C).Message)
+}
+
+// TestStreamBrokerMetadataSearch verifies in-memory filtering finds text in
+// metadata keys and values like the SQL filter does
+func TestStreamBrokerMetadataSearch(t *testing.T) {
+	broker := service.NewStreamBroker(nil)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	sub := broker.Subscribe(ctx, models.LogFilter{TenantID: &tenantID, MetadataSearch: "Customer"})
+	defer sub.Close()
+
+	broker.Publish(ctx, []models.LogEntry{
+		{TenantID: tenantID, ServiceName: "api", Message: "key", Metadata: json.RawMessage(`{"customer":{"id":7}}`)},
+		{TenantID: tenantID, ServiceName: "api", Message: "value", Metadata: json.RawMessage(`{"role":"CUSTOMER"}`)},
+		{TenantID: tenantID, ServiceName: "api", Message: "customer in message", Metadata: json.RawMessage(`{"role":"admin"}`)},
+		{TenantID: tenantID, ServiceName: "api", Message: "none"},
+	})
+
+	require.Len(t, sub.C, 2)
+	assert.Equal(t, "key", (<-sub.C).Message)
+	assert.Equal(t, "value", (<-sub.C).Message)
+}
+
+// TestStreamBrokerHostPattern tests in-memory host glob matching for prefix,
+// suffix and infix wildcards
+func TestStreamBrokerHostPattern(t *testing.T) {
+	hosts := []string{"web-1", "web-2.eu", "api-web-1", "db-1.eu", "web_1"}
+
+	for pattern, want := range map[string][]string{
+		"web-*":   {"web-1", "web-2.eu"},
+		"*.eu":    {"web-2.eu", "db-1.eu"},
+		"*web*":   {"web-1", "web-2.eu", "api-web-1", "web_1"},
+		"web-*.*": {"web-2.eu"},
+		"web-1":   {"web-1"},
+	} {
+		broker := service.NewStreamBroker(nil)
+		ctx := context.Background()
+		sub := broker.Subscribe(ctx, models.LogFilter{HostPattern: pattern})
+
+		var entries []models.LogEntry
+		for _, host := range hosts {
+			entries = append(entries, models.LogEntry{ServiceName: "api", Host: host, Message: host})
+		}
+		broker.Publish(ctx, entries)
+		sub.Close()
+
+		var got []string
+		for len(sub.C) > 0 {
+			got = append(got, (<-sub.C).Message)
+		}
+		assert.Equal(t, want, got, pattern)
+	}
+}
+
+// TestLevelsBetween tests severity ranks and the bands they select
+func TestLevelsBetween(t *testing.T) {
+	assert.Equal(t, 0, models.LogLevelDebug.Rank())
+	assert.Equal(t, 4, models.LogLevelFatal.Rank())
+	assert.Equal(t, -1, models.LogLevel("VERBOSE").Rank())
+	assert.Less(t, models.LogLevelWarn.Rank(), models.LogLevelError.Rank())
+
+	assert.Equal(t, []models.LogLevel{models.LogLevelWarn, models.LogLevelError},
+		models.LevelsBetween(models.LogLevelWarn, models.LogLevelError), "actionable errors without crashes")
+	assert.Equal(t, models.LogLevels, models.LevelsBetween("", ""))
+	assert.Equal(t, []models.LogLevel{models.LogLevelError, models.LogLevelFatal}, models.LevelsBetween(models.LogLevelError, ""))
+	assert.Equal(t, []models.LogLevel{models.LogLevelDebug, models.LogLevelInfo}, models.LevelsBetween("", models.LogLevelInfo))
+
+	assert.Empty(t, models.LevelsBetween(models.LogLevelError, models.LogLevelInfo))
+	assert.Empty(t, models.LevelsBetween("VERBOSE", ""), "an invalid level matches nothing")
+	assert.Empty(t, models.LevelsBetween("", "CRITICAL"))
+	assert.NotNil(t, models.LevelsBetween("VERBOSE", ""))
+
+	band := models.LevelsBetween(models.LogLevelInfo, models.LogLevelWarn)
+	band[0] = models.LogLevelFatal
+	assert.Equal(t, models.LogLevelInfo, models.LogLevels[1], "the result does not alias LogLevels")
+}
+
+// TestStreamBrokerLevelRange tests in-memory matching of min/max level bands
+func TestStreamBrokerLevelRange(t *testing.T) {
+	levels := []models.LogLevel{
+		models.LogLevelDebug, models.LogLevelInfo, models.LogLevelWarn, models.LogLevelError, models.LogLevelFatal,
+	}
+
+	for _, tc := range []struct {
+		min, max models.LogLevel
+		want     []models.LogLevel
+	}{
+		{models.LogLevelWarn, models.LogLevelError, []models.LogLevel{models.LogLevelWarn, models.LogLevelError}},
+		{models.LogLevelError, models.LogLevelError, []models.LogLevel{models.LogLevelError}},
+		{"", models.LogLevelInfo, []models.LogLevel{models.LogLevelDebug, models.LogLevelInfo}},
+		{models.LogLevelError, "", []models.LogLevel{models.LogLevelError, models.LogLevelFatal}},
+		{models.LogLevelFatal, models.LogLevelWarn, nil},
+		{"", "CRITICAL", nil},
+	} {
+		broker := service.NewStreamBroker(nil)
+		ctx := context.Background()
+		sub := broker.Subscribe(ctx, models.LogFilter{MinLevel: tc.min, MaxLevel: tc.max})
+
+		var entries []models.LogEntry
+		for _, level := range levels {
+			entries = append(entries, models.LogEntry{ServiceName: "api", Level: level})
+		}
+		broker.Publish(ctx, entries)
+		sub.Close()
+
+		var got []models.LogLevel
+		for len(sub.C) > 0 {
+			got = append(got, (<-sub.C).Level)
+		}
+		assert.Equal(t, tc.want, got, "%s..%s", tc.min, tc.max)
+	}
+}
+
+// TestCheckEntryAge tests the retention cutoff used by max-age rejection
+func TestCheckEntryAge(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	old := models.LogEntry{Timestamp: now.AddDate(0, 0, -31)}
+	err := service.CheckEntryAge(old, 30, now)
+	assert.ErrorIs(t, err, service.ErrEntryTooOld)
+	assert.Contains(t, err.Error(), "30-day retention cutoff 2024-05-02T12:00:00Z")
+
+	assert.NoError(t, service.CheckEntryAge(models.LogEntry{Timestamp: now.AddDate(0, 0, -29)}, 30, now))
+	assert.NoError(t, service.CheckEntryAge(old, 0, now), "zero retention keeps everything")
+}
+
+// TestNormalizeTraceID verifies differently formatted trace and span IDs
+// converge on one canonical form per format
+func TestNormalizeTraceID(t *testing.T) {
+	const canonical = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	for _, input := range []string{
+		"4bf92f3577b34da6a3ce929d0e0e4736",
+		"4BF92F3577B34DA6A3CE929D0E0E4736",
+		"4bf92f35-77b3-4da6-a3ce-929d0e0e4736",
+		" 4BF92F35-77B3-4DA6-A3CE-929D0E0E4736 ",
+	} {
+		assert.Equal(t, canonical, service.NormalizeTraceID(input, service.TraceIDFormatHex), input)
+		assert.Equal(t, "4bf92f35-77b3-4da6-a3ce-929d0e0e4736", service.NormalizeTraceID(input, service.TraceIDFormatUUID), input)
+	}
+
+	t.Run("B3 64-bit IDs are padded", func(t *testing.T) {
+		assert.Equal(t, "0000000000000000a3ce929d0e0e4736", service.NormalizeTraceID("A3CE929D0E0E4736", service.TraceIDFormatHex))
+		assert.Equal(t, "0000000000000000a3ce929d0e0e4736", service.NormalizeTraceID("0000000000000000a3ce929d0e0e4736", service.TraceIDFormatHex))
+	})
+
+	t.Run("Span IDs", func(t *testing.T) {
+		assert.Equal(t, "00f067aa0ba902b7", service.NormalizeSpanID("00F067AA0BA902B7", service.TraceIDFormatHex))
+		assert.Equal(t, "00f067aa0ba902b7", service.NormalizeSpanID("00F067AA0BA902B7", service.TraceIDFormatUUID))
+	})
+
+	t.Run("Non-hex IDs are kept", func(t *testing.T) {
+		assert.Equal(t, "req-trace-42", service.NormalizeTraceID(" req-trace-42 ", service.TraceIDFormatHex))
+		assert.Equal(t, "", service.NormalizeTraceID("", service.TraceIDFormatHex))
+	})
+
+	t.Run("None keeps IDs as sent", func(t *testing.T) {
+		assert.Equal(t, "4BF92F35-77B3-4DA6-A3CE-929D0E0E4736", service.NormalizeTraceID("4BF92F35-77B3-4DA6-A3CE-929D0E0E4736", service.TraceIDFormatNone))
+	})
+}
+
+// TestIngestionPipeline verifies stages run in the configured order, that
+// disabled stages are skipped and that invalid configurations are rejected
+func TestIngestionPipeline(t *testing.T) {
+	ctx := context.Background()
+
+	var ran []string
+	stage := func(name string) service.IngestionStage {
+		return service.NewIngestionStage(name, func(ctx context.Context, run *service.IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+			ran = append(ran, name)
+			for i := range entries {
+				entries[i].Message += "|" + name
+			}
+			return entries, nil
+		})
+	}
+	available := []service.IngestionStage{stage("a"), stage("b"), stage("c")}
+
+	run := func(p *service.IngestionPipeline) string {
+		ran = nil
+		entries, err := p.Run(ctx, service.NewIngestionRun(time.Now(), nil), []models.LogEntry{{Message: "m"}})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		return entries[0].Message
+	}
+
+	t.Run("Default Order", func(t *testing.T) {
+		p, err := service.NewIngestionPipeline(available, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, p.Stages())
+		assert.Equal(t, "m|a|b|c", run(p))
+	})
+
+	t.Run("Custom Order", func(t *testing.T) {
+		p, err := service.NewIngestionPipeline(available, []string{"c", "a", "b"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "m|c|a|b", run(p))
+		assert.Equal(t, []string{"c", "a", "b"}, ran)
+	})
+
+	t.Run("Disabled Stage Is Skipped", func(t *testing.T) {
+		p, err := service.NewIngestionPipeline(available, nil, []string{"b"})
+		require.NoError(t, err)
+		assert.Equal(t, "m|a|c", run(p))
+		assert.NotContains(t, ran, "b")
+	})
+
+	t.Run("Dropping Every Entry Stops The Pipeline", func(t *testing.T) {
+		drop := service.NewIngestionStage("drop", func(ctx context.Context, run *service.IngestionRun, entries []models.LogEntry) ([]models.LogEntry, error) {
+			ran = append(ran, "drop")
+			return entries[:0], nil
+		})
+		p, err := service.NewIngestionPipeline(append([]service.IngestionStage{drop}, available...), nil, nil)
+		require.NoError(t, err)
+
+		ran = nil
+		entries, err := p.Run(ctx, service.NewIngestionRun(time.Now(), nil), []models.LogEntry{{Message: "m"}})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+		assert.Equal(t, []string{"drop"}, ran)
+	})
+
+	t.Run("Invalid Configuration", func(t *testing.T) {
+		_, err := service.NewIngestionPipeline(available, []string{"a", "x"}, nil)
+		assert.Error(t, err)
+		_, err = service.NewIngestionPipeline(available, []string{"a", "a"}, nil)
+		assert.Error(t, err)
+		_, err = service.NewIngestionPipeline(available, nil, []string{"x"})
+		assert.Error(t, err)
+	})
+}
+
+// TestFlattenEntry tests expanding nested metadata into top-level columns
+func TestFlattenEntry(t *testing.T) {
+	entry := models.LogEntry{
+		ID:          uuid.New(),
+		TenantID:    uuid.New(),
+		ServiceName: "api",
+		Level:       models.LogLevelInfo,
+		Message:     "request done",
+		Metadata:    json.RawMessage(`{"user_id":"u-1","level":"debug","http":{"status":200,"route":{"name":"users"}},"tags":["a","b"]}`),
+	}
+
+	row := models.FlattenEntry(entry)
+	assert.Equal(t, "api", row["service_name"])
+	assert.Equal(t, "INFO", row["level"])
+	assert.Equal(t, "debug", row["metadata.level"], "colliding keys are prefixed")
+	assert.Equal(t, "u-1", row["metadata.user_id"])
+	assert.Nil(t, row["user_id"])
+	assert.Equal(t, json.Number("200"), row["http.status"])
+	assert.Equal(t, "users", row["http.route.name"])
+	assert.Equal(t, []interface{}{"a", "b"}, row["tags"])
+	assert.NotContains(t, row, "http")
+
+	t.Run("Query Result Columns", func(t *testing.T) {
+		plain := entry
+		plain.Metadata = json.RawMessage(`{"region":"eu"}`)
+		rows := models.FlattenQueryResult(&models.LogQueryResult{
+			Entries:    []models.LogEntry{entry, plain},
+			TotalCount: 2,
+			Page:       1,
+		})
+
+		require.Len(t, rows.Rows, 2)
+		assert.Equal(t, int64(2), rows.TotalCount)
+		assert.Equal(t, "id", rows.Columns[0])
+		assert.Equal(t, []string{"http.route.name", "http.status", "metadata.level", "metadata.user_id", "region", "tags"},
+			rows.Columns[len(rows.Columns)-6:])
+		assert.Equal(t, "eu", rows.Rows[1]["region"])
+	})
+}
+
+// TestCollapseRepeats tests consecutive identical lines fold into one with a
+// repeat count while interspersed repeats stay apart
+func TestCollapseRepeats(t *testing.T) {
+	line := func(service string, level models.LogLevel, message string) models.LogEntry {
+		return models.LogEntry{ID: uuid.New(), ServiceName: service, Level: level, Message: message}
+	}
+	entries := []models.LogEntry{
+		line("api", models.LogLevelError, "connection refused"),
+		line("api", models.LogLevelError, "connection refused"),
+		line("api", models.LogLevelError, "connection refused"),
+		line("api", models.LogLevelInfo, "retrying"),
+		line("api", models.LogLevelError, "connection refused"),
+		line("worker", models.LogLevelError, "connection refused"),
+		line("worker", models.LogLevelWarn, "connection refused"),
+		line("worker", models.LogLevelWarn, "connection refused"),
+	}
+
+	collapsed := models.CollapseRepeats(entries)
+	require.Len(t, collapsed, 5)
+
+	type summary struct {
+		Message string
+		Repeat  int
+	}
+	var got []summary
+	for _, entry := range collapsed {
+		got = append(got, summary{string(entry.Level) + " " + entry.ServiceName + ": " + entry.Message, entry.RepeatCount})
+	}
+	assert.Equal(t, []summary{
+		{"ERROR api: connection refused", 3},
+		{"INFO api: retrying", 0},
+		{"ERROR api: connection refused", 0},
+		{"ERROR worker: connection refused", 0},
+		{"WARN worker: connection refused", 2},
+	}, got)
+	assert.Equal(t, entries[0].ID, collapsed[0].ID, "a run keeps its first entry")
+
+	for _, entry := range entries {
+		assert.Zero(t, entry.RepeatCount, "the input is left unmodified")
+	}
+	assert.Empty(t, models.CollapseRepeats(nil))
+
+	assert.Equal(t, 3, models.FlattenEntry(collapsed[0])["repeat_count"])
+	assert.NotContains(t, models.FlattenEntry(collapsed[1]), "repeat_count")
+}
+
+// TestLinearForecast tests projecting a linear daily trend
+func TestLinearForecast(t *testing.T) {
+	counts := make([]int64, 14)
+	for i := range counts {
+		// 100 + 20/day with alternating noise
+		counts[i] = 100 + int64(20*i) + int64(3*(i%2*2-1))
+	}
+
+	projected, slope := service.LinearForecast(counts, 5)
+	assert.InDelta(t, 20, slope, 0.5)
+	require.Len(t, projected, 5)
+	for i, count := range projected {
+		assert.InDelta(t, 100+20*(14+i), count, 5)
+	}
+
+	t.Run("Declining Trend Stops At Zero", func(t *testing.T) {
+		projected, slope := service.LinearForecast([]int64{30, 20, 10}, 3)
+		assert.InDelta(t, -10, slope, 0.001)
+		assert.Equal(t, []int64{0, 0, 0}, projected)
+	})
+
+	t.Run("No History", func(t *testing.T) {
+		projected, slope := service.LinearForecast(nil, 2)
+		assert.Zero(t, slope)
+		assert.Equal(t, []int64{0, 0}, projected)
+	})
+}
+
+// TestCollapseDuplicateIDs tests collapsing entries that repeat an ID within
+// a batch
+func TestCollapseDuplicateIDs(t *testing.T) {
+	dup, other := uuid.New(), uuid.New()
+	entries := []models.LogEntry{
+		{ID: dup, Message: "first"},
+		{ID: other, Message: "other"},
+		{ID: dup, Message: "second"},
+		{ID: dup, Message: "third"},
+	}
+
+	kept, collapsed := service.CollapseDuplicateIDs(entries, service.DuplicateIDKeepFirst)
+	assert.Equal(t, 2, collapsed)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "first", kept[0].Message)
+	assert.Equal(t, "other", kept[1].Message)
+
+	kept, collapsed = service.CollapseDuplicateIDs(entries, service.DuplicateIDKeepLast)
+	assert.Equal(t, 2, collapsed)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "third", kept[0].Message)
+	assert.Equal(t, "other", kept[1].Message)
+
+	t.Run("Unique IDs Are Untouched", func(t *testing.T) {
+		unique := []models.LogEntry{{ID: uuid.New()}, {ID: uuid.New()}}
+		kept, collapsed := service.CollapseDuplicateIDs(unique, service.DuplicateIDKeepFirst)
+		assert.Zero(t, collapsed)
+		assert.Equal(t, unique, kept)
+	})
+}
+
+// TestWebhookEnricher tests merging enrichment webhook responses into
+// metadata and failing open on slow or disallowed webhooks
+func TestWebhookEnricher(t *testing.T) {
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry models.LogEntry
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&entry))
+		if entry.Message == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order_status":"shipped","customer":{"tier":"gold"},"order_id":"overridden"}`))
+	}))
+	defer mock.Close()
+
+	enricher := service.NewWebhookEnricher([]string{mock.URL}, 50*time.Millisecond)
+	ctx := context.Background()
+
+	entry := models.LogEntry{Message: "order placed", Metadata: json.RawMessage(`{"order_id":"o-1"}`)}
+	require.NoError(t, enricher.Enrich(ctx, &entry, mock.URL))
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+	assert.Equal(t, "o-1", metadata["order_id"], "existing keys are kept")
+	assert.Equal(t, "shipped", metadata["order_status"])
+	assert.Equal(t, map[string]interface{}{"tier": "gold"}, metadata["customer"])
+
+	t.Run("Timeout Fails Open", func(t *testing.T) {
+		slow := models.LogEntry{Message: "slow", Metadata: json.RawMessage(`{"order_id":"o-2"}`)}
+		start := time.Now()
+		assert.Error(t, enricher.Enrich(ctx, &slow, mock.URL))
+		assert.Less(t, time.Since(start), 150*time.Millisecond)
+		assert.JSONEq(t, `{"order_id":"o-2"}`, string(slow.Metadata))
+	})
+
+	t.Run("Disallowed URL", func(t *testing.T) {
+		other := models.LogEntry{Message: "order placed"}
+		assert.ErrorIs(t, enricher.Enrich(ctx, &other, mock.URL+"/other"), service.ErrEnrichmentURLNotAllowed)
+		assert.Empty(t, other.Metadata)
+	})
+}
+
+// TestWriteAheadLog tests segments survive a crash and are replayed once,
+// while rotated segments released after a flush are not
+func TestWriteAheadLog(t *testing.T) {
+	dir := t.TempDir()
+	entry := func(message string) models.LogEntry {
+		return models.LogEntry{ID: uuid.New(), ServiceName: "wal", Level: models.LogLevelInfo, Message: message, Timestamp: time.Now().UTC()}
+	}
+
+	wal, err := service.OpenWriteAheadLog(dir)
+	require.NoError(t, err)
+	require.NoError(t, wal.Append(entry("flushed")))
+	flushed, err := wal.Rotate()
+	require.NoError(t, err)
+	require.NoError(t, wal.Release(flushed))
+
+	pending := []models.LogEntry{entry("one"), entry("two")}
+	for _, e := range pending {
+		require.NoError(t, wal.Append(e))
+	}
+	// Crash: the active segment is never closed, and its last write is torn
+	segments, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	torn, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = torn.WriteString(`{"id":"`)
+	require.NoError(t, err)
+	require.NoError(t, torn.Close())
+
+	restarted, err := service.OpenWriteAheadLog(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { restarted.Close() })
+
+	var stored []models.LogEntry
+	replayed, err := restarted.Replay(context.Background(), func(ctx context.Context, entries []models.LogEntry) error {
+		stored = append(stored, entries...)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+	require.Len(t, stored, 2)
+	assert.Equal(t, pending[0].ID, stored[0].ID)
+	assert.Equal(t, pending[1].ID, stored[1].ID)
+
+	t.Run("Failed Store Keeps The Segment", func(t *testing.T) {
+		dir := t.TempDir()
+		wal, err := service.OpenWriteAheadLog(dir)
+		require.NoError(t, err)
+		require.NoError(t, wal.Append(entry("kept")))
+		require.NoError(t, wal.Close())
+
+		restarted, err := service.OpenWriteAheadLog(dir)
+		require.NoError(t, err)
+		_, err = restarted.Replay(context.Background(), func(ctx context.Context, entries []models.LogEntry) error {
+			return io.ErrUnexpectedEOF
+		})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.NoError(t, restarted.Close())
+
+		again, err := service.OpenWriteAheadLog(dir)
+		require.NoError(t, err)
+		t.Cleanup(func() { again.Close() })
+		replayed, err := again.Replay(context.Background(), func(ctx context.Context, entries []models.LogEntry) error {
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+	})
+
+	t.Run("Failed Rotation Keeps Appending", func(t *testing.T) {
+		dir := t.TempDir()
+		wal, err := service.OpenWriteAheadLog(dir)
+		require.NoError(t, err)
+		require.NoError(t, wal.Append(entry("before")))
+
+		// A directory where the next segment would go makes creating it fail
+		blocker := filepath.Join(dir, fmt.Sprintf("%020d.wal", 2))
+		require.NoError(t, os.Mkdir(blocker, 0o755))
+		_, err = wal.Rotate()
+		require.Error(t, err)
+		require.NoError(t, wal.Append(entry("after")))
+
+		require.NoError(t, os.Remove(blocker))
+		rotated, err := wal.Rotate()
+		require.NoError(t, err)
+		require.NoError(t, wal.Close())
+
+		restarted, err := service.OpenWriteAheadLog(dir)
+		require.NoError(t, err)
+		t.Cleanup(func() { restarted.Close() })
+		var messages []string
+		_, err = restarted.Replay(context.Background(), func(ctx context.Context, entries []models.LogEntry) error {
+			for _, e := range entries {
+				messages = append(messages, e.Message)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "00000000000000000001.wal", filepath.Base(rotated))
+		assert.Equal(t, []string{"before", "after"}, messages)
+	})
+}
+
+// TestMessageFingerprint verifies messages differing only in variable parts
+// share a fingerprint while distinct errors and services do not
+func TestMessageFingerprint(t *testing.T) {
+	assert.Equal(t,
+		"connection refused to <ip> after <n> attempts",
+		service.NormalizeMessage("connection refused to 10.0.0.1:5432  after 3 attempts"))
+	assert.Equal(t,
+		"user <uuid> not found in <str> (<hex>)",
+		service.NormalizeMessage(`user 6f1c2a9e-4b7d-4e2a-9c1f-0a8b3d5e7f91 not found in "orders" (0xdeadbeef)`))
+
+	base := service.MessageFingerprint("checkout", "timeout after 30s calling 10.0.0.1:443")
+	assert.Equal(t, base, service.MessageFingerprint("checkout", "timeout after 45s calling 10.0.0.7:443"))
+	assert.NotEqual(t, base, service.MessageFingerprint("billing", "timeout after 30s calling 10.0.0.1:443"))
+	assert.NotEqual(t, base, service.MessageFingerprint("checkout", "connection reset calling 10.0.0.1:443"))
+}
+
+// TestValidateFilterGroupLimits verifies Or groups are limited in depth and
+// count
+func TestValidateFilterGroupLimits(t *testing.T) {
+	nested := func(depth int) models.LogFilter {
+		filter := models.LogFilter{ServiceName: "api"}
+		for i := 0; i < depth; i++ {
+			filter = models.LogFilter{Or: []models.LogFilter{filter, {Level: models.LogLevelError}}}
+		}
+		return filter
+	}
+
+	assert.Equal(t, models.MaxFilterDepth, nested(models.MaxFilterDepth).GroupDepth())
+	assert.NoError(t, service.ValidateFilter(nested(models.MaxFilterDepth)))
+	assert.ErrorIs(t, service.ValidateFilter(nested(models.MaxFilterDepth+1)), service.ErrFilterTooComplex)
+
+	wide := models.LogFilter{Or: make([]models.LogFilter, models.MaxFilterGroups+1)}
+	for i := range wide.Or {
+		wide.Or[i] = models.LogFilter{ServiceName: fmt.Sprintf("svc-%d", i)}
+	}
+	assert.ErrorIs(t, service.ValidateFilter(wide), service.ErrFilterTooComplex)
+	wide.Or = wide.Or[:models.MaxFilterGroups]
+	assert.NoError(t, service.ValidateFilter(wide))
+}
+
+// TestValidateLevel verifies levels are normalized to upper case and unknown
+// or missing levels are rejected
+func TestValidateLevel(t *testing.T) {
+	entry := models.LogEntry{Level: " error "}
+	require.NoError(t, service.ValidateLevel(&entry))
+	assert.Equal(t, models.LogLevelError, entry.Level)
+
+	for _, level := range []models.LogLevel{"warning", "critical", ""} {
+		entry := models.LogEntry{Level: level}
+		assert.ErrorIs(t, service.ValidateLevel(&entry), service.ErrInvalidLevel, level)
+	}
+	assert.True(t, models.LogLevelFatal.Valid())
+	assert.False(t, models.LogLevel("info").Valid())
+}
+
+// TestValidateFilterMetadataPair verifies a metadata value needs a key, in
+// the filter and in its Or groups
+func TestValidateFilterMetadataPair(t *testing.T) {
+	assert.NoError(t, service.ValidateFilter(models.LogFilter{MetadataKey: "order_id", MetadataValue: "12345"}))
+	assert.NoError(t, service.ValidateFilter(models.LogFilter{MetadataKey: "order_id"}))
+	assert.ErrorIs(t, service.ValidateFilter(models.LogFilter{MetadataValue: "12345"}), service.ErrInvalidFilter)
+	assert.ErrorIs(t, service.ValidateFilter(models.LogFilter{
+		Or: []models.LogFilter{{ServiceName: "api"}, {MetadataValue: "12345"}},
+	}), service.ErrInvalidFilter)
+
+	paths := models.LogFilter{MetadataKey: "order_id", MetadataIn: map[string][]string{"region": {"eu"}}}.MetadataPaths()
+	assert.Equal(t, []string{"order_id", "region"}, paths)
+}
+
+// TestValidateSelective tests a filter must narrow by an indexed predicate
+func TestValidateSelective(t *testing.T) {
+	tenantID := uuid.New()
+	now := time.Now()
+
+	assert.ErrorIs(t, service.ValidateSelective(models.LogFilter{}), service.ErrUnselectiveQuery)
+	assert.ErrorIs(t, service.ValidateSelective(models.LogFilter{Level: models.LogLevelError, Search: "timeout"}), service.ErrUnselectiveQuery)
+	assert.ErrorIs(t, service.ValidateSelective(models.LogFilter{
+		Or: []models.LogFilter{{ServiceName: "api"}, {ServiceName: "worker"}},
+	}), service.ErrUnselectiveQuery, "or groups only widen a filter")
+
+	for name, filter := range map[string]models.LogFilter{
+		"tenant":      {TenantID: &tenantID},
+		"start":       {StartTime: &now},
+		"end":         {EndTime: &now},
+		"around":      {AroundTime: &now},
+		"service":     {ServiceName: "api"},
+		"trace":       {TraceID: "trace-1"},
+		"request":     {RequestID: "req-1"},
+		"request ids": {RequestIDs: []string{"req-1", "req-2"}},
+	} {
+		assert.NoError(t, service.ValidateSelective(filter), name)
+	}
+}
+
+// TestEnvironmentDeriver tests the environment is captured from service names
+// only for entries that do not set one
+func TestEnvironmentDeriver(t *testing.T) {
+	deriver, err := service.NewEnvironmentDeriver(`-(prod|staging|dev)$`)
+	require.NoError(t, err)
+
+	for serviceName, want := range map[string]string{
+		"auth-prod":       "prod",
+		"auth-staging":    "staging",
+		"billing-api-dev": "dev",
+		"auth":            "",
+		"prod-auth":       "",
+	} {
+		entry := models.LogEntry{ServiceName: serviceName}
+		deriver.Apply(&entry)
+		assert.Equal(t, want, entry.Environment, serviceName)
+	}
+
+	entry := models.LogEntry{ServiceName: "auth-prod", Environment: "canary"}
+	deriver.Apply(&entry)
+	assert.Equal(t, "canary", entry.Environment, "a set environment is kept")
+
+	named, err := service.NewEnvironmentDeriver(`^(\w+)\.(?P<environment>\w+)$`)
+	require.NoError(t, err)
+	entry = models.LogEntry{ServiceName: "auth.eu"}
+	named.Apply(&entry)
+	assert.Equal(t, "eu", entry.Environment, "the environment group wins over the first")
+
+	disabled, err := service.NewEnvironmentDeriver("")
+	require.NoError(t, err)
+	entry = models.LogEntry{ServiceName: "auth-prod"}
+	disabled.Apply(&entry)
+	assert.Empty(t, entry.Environment)
+
+	_, err = service.NewEnvironmentDeriver(`-prod$`)
+	assert.Error(t, err, "a pattern without a group captures nothing")
+	_, err = service.NewEnvironmentDeriver(`-(prod`)
+	assert.Error(t, err)
+}
+
+// TestLogCursor tests cursors round-trip an entry position and malformed
+// positions are rejected
+func TestLogCursor(t *testing.T) {
+	entry := models.LogEntry{ID: uuid.New(), Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)}
+	cursor := models.EncodeCursor(entry)
+
+	ts, id, err := models.DecodeCursor(cursor)
+	require.NoError(t, err)
+	assert.True(t, entry.Timestamp.Equal(ts), "nanoseconds survive")
+	assert.Equal(t, entry.ID, id)
+
+	filter := models.LogFilter{Cursor: cursor}
+	require.NoError(t, filter.ResolveCursor())
+	assert.Empty(t, filter.Cursor)
+	require.NotNil(t, filter.AfterTimestamp)
+	assert.True(t, entry.Timestamp.Equal(*filter.AfterTimestamp))
+	assert.Equal(t, entry.ID, *filter.AfterID)
+
+	for _, bad := range []string{"not-base64!", "bm8tc2VwYXJhdG9y", models.EncodeCursor(models.LogEntry{})[:10]} {
+		_, _, err := models.DecodeCursor(bad)
+		assert.ErrorIs(t, err, models.ErrInvalidCursor, bad)
+		assert.ErrorIs(t, service.ValidateFilter(models.LogFilter{Cursor: bad}), service.ErrInvalidFilter, bad)
+	}
+	assert.NoError(t, service.ValidateFilter(models.LogFilter{Cursor: cursor}))
+
+	afterID := uuid.New()
+	assert.ErrorIs(t, service.ValidateFilter(models.LogFilter{AfterID: &afterID}), service.ErrInvalidFilter)
+}
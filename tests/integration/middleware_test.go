@@ -0,0 +1,408 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/minisource/log/config"
+	"github.com/minisource/log/internal/handler"
+	"github.com/minisource/log/internal/middleware"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRequireTenant tests rejection of requests without a tenant
+func TestRequireTenant(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use("/api/v1/logs", middleware.RequireTenant())
+	app.Get("/api/v1/logs", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	t.Run("Missing Tenant", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/logs", nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Invalid Tenant", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/logs", nil)
+		req.Header.Set("X-Tenant-ID", "tenant-123")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Valid Tenant", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/logs", nil)
+		req.Header.Set("X-Tenant-ID", uuid.New().String())
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+// TestRequireTenantGuardsCompatRoutes tests every tenant-data prefix,
+// including the compatibility ingestion routes, rejects tenantless requests
+func TestRequireTenantGuardsCompatRoutes(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use("/loki", middleware.ScopeOrgIDExtractor())
+	for _, prefix := range router.TenantDataPrefixes {
+		app.Use(prefix, middleware.RequireTenant())
+	}
+	compat := handler.NewCompatHandler(nil)
+	app.Post("/loki/api/v1/push", compat.LokiPush)
+	app.Post("/_bulk", compat.ESBulk)
+	app.Get("/api/v1/alerts", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send := func(method, path, body string, headers map[string]string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/_bulk", "", nil))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/loki/api/v1/push", `{"streams":[]}`, nil))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/alerts", "", nil))
+
+	tenant := map[string]string{"X-Tenant-ID": uuid.New().String()}
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/_bulk", "", tenant))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/alerts", "", tenant))
+
+	orgID := map[string]string{"X-Scope-OrgID": uuid.New().String()}
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/loki/api/v1/push", `{"streams":[]}`, orgID))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/_bulk", "", orgID), "X-Scope-OrgID is only read by Loki routes")
+}
+
+// TestRequireAdmin tests admin routes reject callers without the admin key
+func TestRequireAdmin(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use(middleware.AdminExtractor("secret"))
+	app.Use("/api/v1/admin", middleware.RequireAdmin())
+	app.Post("/api/v1/admin/selftest", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	selftest := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/selftest", nil)
+		req.Header.Set("X-Tenant-ID", uuid.New().String())
+		if key != "" {
+			req.Header.Set("X-Admin-Key", key)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusForbidden, selftest(""))
+	assert.Equal(t, http.StatusForbidden, selftest("wrong"))
+	assert.Equal(t, http.StatusOK, selftest("secret"))
+
+	t.Run("Purge Across Tenants", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(middleware.AdminExtractor("secret"))
+		app.Delete("/admin/logs", handler.NewAdminHandler(nil).PurgeService)
+
+		req := httptest.NewRequest(http.MethodDelete, "/admin/logs?service=api&confirm=api&all=true", nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+// TestTenantSettingsAccess verifies tenant and alert settings can only be
+// read or changed by the tenant itself or an admin, and only admins list them
+func TestTenantSettingsAccess(t *testing.T) {
+	tenants := handler.NewTenantHandler(nil)
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use(middleware.AdminExtractor("secret"))
+	app.Get("/tenants/settings", tenants.ListSettings)
+	app.Get("/tenants/:tenant_id/settings", tenants.GetSettings)
+	app.Put("/tenants/:tenant_id/settings", tenants.UpsertSettings)
+	app.Delete("/tenants/:tenant_id/settings", tenants.DeleteSettings)
+	app.Get("/tenants/:tenant_id/alert-settings", tenants.GetAlertSettings)
+	app.Put("/tenants/:tenant_id/alert-settings", tenants.UpsertAlertSettings)
+	app.Delete("/tenants/:tenant_id/alert-settings", tenants.DeleteAlertSettings)
+
+	caller := uuid.New()
+	other := uuid.New()
+	send := func(method, path string, tenantID *uuid.UUID) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"redaction_enabled":false}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tenantID != nil {
+			req.Header.Set("X-Tenant-ID", tenantID.String())
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	for _, resource := range []string{"settings", "alert-settings"} {
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			path := "/tenants/" + other.String() + "/" + resource
+			assert.Equal(t, http.StatusForbidden, send(method, path, &caller), method+" "+resource)
+			assert.Equal(t, http.StatusForbidden, send(method, path, nil), method+" "+resource)
+		}
+	}
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/tenants/settings", &caller))
+}
+
+// TestRecoverPersistsPanic tests a handler panic is stored as a FATAL entry
+func TestRecoverPersistsPanic(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	app := fiber.New()
+	app.Use(middleware.Recover(svc.IngestSingle))
+	app.Use(middleware.RequestID())
+	app.Use(middleware.TenantExtractor())
+	app.Get("/boom", func(c *fiber.Ctx) error {
+		panic("kaboom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set("X-Tenant-ID", tenantID.String())
+	req.Header.Set("X-Request-ID", "panic-request")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	var entry models.LogEntry
+	require.NoError(t, db.Where("tenant_id = ?", tenantID).First(&entry).Error)
+	assert.Equal(t, models.LogLevelFatal, entry.Level)
+	assert.Equal(t, middleware.SelfServiceName, entry.ServiceName)
+	assert.Equal(t, "panic-request", entry.RequestID)
+	assert.Contains(t, entry.Message, "kaboom")
+	assert.Contains(t, string(entry.Metadata), "/boom")
+}
+
+// TestRecoverSurvivesFailingStore tests a panicking store cannot recurse
+func TestRecoverSurvivesFailingStore(t *testing.T) {
+	calls := 0
+	app := fiber.New()
+	app.Use(middleware.Recover(func(ctx context.Context, entry *models.LogEntry) error {
+		calls++
+		panic("store down")
+	}))
+	app.Get("/boom", func(c *fiber.Ctx) error {
+		panic("kaboom")
+	})
+
+	for i := 0; i < 2; i++ {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/boom", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+// TestRecoverRecordsConcurrentPanics tests panics raised while another is
+// being recorded are recorded too
+func TestRecoverRecordsConcurrentPanics(t *testing.T) {
+	var recorded int32
+	release := make(chan struct{})
+	app := fiber.New()
+	app.Use(middleware.Recover(func(ctx context.Context, entry *models.LogEntry) error {
+		if atomic.AddInt32(&recorded, 1) == 1 {
+			<-release
+		}
+		return nil
+	}))
+	app.Get("/boom", func(c *fiber.Ctx) error {
+		panic("kaboom")
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/boom", nil), -1)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		}()
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&recorded) == 4 }, 5*time.Second, 10*time.Millisecond,
+		"panics are recorded while the first is still being stored")
+	close(release)
+	wg.Wait()
+}
+
+// TestSecurityHeaders tests configured headers are set and disabled ones omitted
+func TestSecurityHeaders(t *testing.T) {
+	request := func(cfg config.SecurityConfig) *http.Response {
+		app := fiber.New()
+		app.Use(middleware.SecurityHeaders(cfg))
+		app.Get("/", func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		resp := request(config.SecurityConfig{
+			NoSniff:               true,
+			XSSProtection:         true,
+			FrameOptions:          "SAMEORIGIN",
+			HSTSMaxAge:            31536000,
+			HSTSIncludeSubdomains: true,
+			ContentSecurityPolicy: "default-src 'none'",
+		})
+		assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+		assert.Equal(t, "SAMEORIGIN", resp.Header.Get("X-Frame-Options"))
+		assert.Equal(t, "1; mode=block", resp.Header.Get("X-XSS-Protection"))
+		assert.Equal(t, "max-age=31536000; includeSubDomains", resp.Header.Get("Strict-Transport-Security"))
+		assert.Equal(t, "default-src 'none'", resp.Header.Get("Content-Security-Policy"))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		resp := request(config.SecurityConfig{})
+		for _, header := range []string{
+			"X-Content-Type-Options", "X-Frame-Options", "X-XSS-Protection",
+			"Strict-Transport-Security", "Content-Security-Policy",
+		} {
+			assert.Empty(t, resp.Header.Get(header), header)
+		}
+	})
+}
+
+// TestCompressThreshold tests small responses are sent uncompressed, large
+// ones compressed and skipped paths never compressed
+func TestCompressThreshold(t *testing.T) {
+	large := strings.Repeat("log line ", 500)
+
+	app := fiber.New()
+	app.Use(middleware.Compress(1024, func(c *fiber.Ctx) bool {
+		return c.Path() == "/stream"
+	}))
+	app.Get("/small", func(c *fiber.Ctx) error { return c.SendString(`{"ok":true}`) })
+	app.Get("/large", func(c *fiber.Ctx) error { return c.SendString(large) })
+	app.Get("/stream", func(c *fiber.Ctx) error { return c.SendString(large) })
+
+	get := func(path string) (*http.Response, []byte) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp, body
+	}
+
+	resp, body := get("/small")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, `{"ok":true}`, string(body))
+
+	resp, body = get("/large")
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, large, string(decoded))
+
+	resp, body = get("/stream")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, large, string(body))
+}
+
+// TestQueryRateLimit tests per-tenant query limits leave ingestion, other
+// tenants and admin callers unaffected
+func TestQueryRateLimit(t *testing.T) {
+	limiter := middleware.NewRateLimiter(nil, "query", 2, time.Hour)
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use(middleware.TenantRateLimit(limiter, "secret", func(c *fiber.Ctx) bool {
+		return handler.IsQueryRequest(c.Method(), c.Path())
+	}))
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Post("/api/v1/logs", ok)
+	app.Post("/api/v1/logs/query", ok)
+	app.Get("/api/v1/logs/stats", ok)
+
+	send := func(method, path string, tenantID uuid.UUID, headers map[string]string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Tenant-ID", tenantID.String())
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	tenant := uuid.New()
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/logs/query", tenant, nil).StatusCode)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/logs/stats", tenant, nil).StatusCode)
+
+	limited := send(http.MethodPost, "/api/v1/logs/query", tenant, nil)
+	assert.Equal(t, http.StatusTooManyRequests, limited.StatusCode)
+	assert.NotEmpty(t, limited.Header.Get("Retry-After"))
+
+	t.Run("Ingestion Is Not Limited", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/logs", tenant, nil).StatusCode)
+		}
+	})
+
+	t.Run("Other Tenants Keep Their Quota", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/logs/query", uuid.New(), nil).StatusCode)
+	})
+
+	t.Run("Admin Callers Are Exempt", func(t *testing.T) {
+		admin := map[string]string{"X-Admin-Key": "secret"}
+		assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/logs/query", tenant, admin).StatusCode)
+		wrong := map[string]string{"X-Admin-Key": "guess"}
+		assert.Equal(t, http.StatusTooManyRequests, send(http.MethodPost, "/api/v1/logs/query", tenant, wrong).StatusCode)
+	})
+}
+
+// TestRetentionPreviewRequiresTenant tests only an operator may preview every
+// tenant's log ages at once
+func TestRetentionPreviewRequiresTenant(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.TenantExtractor())
+	app.Use(middleware.AdminExtractor("secret"))
+	app.Get("/api/v1/logs/retention/preview", handler.NewRetentionHandler(nil).Preview)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/logs/retention/preview", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,1139 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/config"
+	"github.com/minisource/log/internal/database"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMinLevelQueryUsesTenantLevelIndex verifies the planner picks the
+// (tenant_id, level, timestamp) index for tenant-scoped min-level queries
+func TestMinLevelQueryUsesTenantLevelIndex(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+
+	entries := make([]models.LogEntry, 0, 200)
+	for i := 0; i < 200; i++ {
+		level := models.LogLevelInfo
+		if i%20 == 0 {
+			level = models.LogLevelError
+		}
+		entries = append(entries, models.LogEntry{
+			ID:          uuid.New(),
+			TenantID:    tenantID,
+			ServiceName: "explain-test",
+			Level:       level,
+			Message:     "entry",
+			Timestamp:   time.Now().Add(-time.Duration(i) * time.Minute),
+		})
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	require.NoError(t, db.Exec("ANALYZE log_entries").Error)
+
+	tx := db.Begin()
+	defer tx.Rollback()
+	require.NoError(t, tx.Exec("SET LOCAL enable_seqscan = off").Error)
+
+	var plan []string
+	require.NoError(t, tx.Raw(
+		`EXPLAIN SELECT * FROM log_entries
+		 WHERE tenant_id = ? AND level IN ? AND timestamp >= ?
+		 ORDER BY timestamp DESC LIMIT 100`,
+		tenantID,
+		[]models.LogLevel{models.LogLevelError, models.LogLevelFatal},
+		time.Now().Add(-time.Hour),
+	).Scan(&plan).Error)
+
+	assert.Contains(t, strings.Join(plan, "\n"), "idx_logs_tenant_level_time")
+}
+
+// TestCountDistinctTracesIgnoresEmpty verifies affected-trace counting
+func TestCountDistinctTracesIgnoresEmpty(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	traces := []string{"trace-a", "trace-a", "trace-b", "trace-c", "", ""}
+	entries := make([]models.LogEntry, 0, len(traces)+1)
+	for _, traceID := range traces {
+		entries = append(entries, models.LogEntry{
+			ID:          uuid.New(),
+			TenantID:    tenantID,
+			ServiceName: "traces-test",
+			Level:       models.LogLevelError,
+			Message:     "failure",
+			TraceID:     traceID,
+			Timestamp:   time.Now(),
+		})
+	}
+	entries = append(entries, models.LogEntry{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		ServiceName: "traces-test",
+		Level:       models.LogLevelInfo,
+		Message:     "ok",
+		TraceID:     "trace-d",
+		Timestamp:   time.Now(),
+	})
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	count, err := repo.CountDistinctTraces(ctx, models.LogFilter{
+		TenantID: &tenantID,
+		Level:    models.LogLevelError,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+// TestQueryApproxSatisfiesFilter verifies sampled results are flagged and filtered
+func TestQueryApproxSatisfiesFilter(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	ts := time.Now().UTC()
+	var entries []models.LogEntry
+	for i := 0; i < 50; i++ {
+		service := "approx-a"
+		if i%2 == 0 {
+			service = "approx-b"
+		}
+		entries = append(entries, models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: service,
+			Level: models.LogLevelInfo, Message: "m", Timestamp: ts,
+		})
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	result, err := svc.QueryApprox(ctx, models.LogFilter{TenantID: &tenantID, ServiceName: "approx-a"})
+	require.NoError(t, err)
+	assert.True(t, result.Approximate)
+	for _, entry := range result.Entries {
+		assert.Equal(t, "approx-a", entry.ServiceName)
+		assert.Equal(t, tenantID, entry.TenantID)
+	}
+
+	t.Run("Pages In Stable Order", func(t *testing.T) {
+		result, err := svc.QueryApprox(ctx, models.LogFilter{TenantID: &tenantID, PageSize: 5})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(result.Entries), 5)
+		assert.True(t, sort.SliceIsSorted(result.Entries, func(i, j int) bool {
+			return result.Entries[i].ID.String() > result.Entries[j].ID.String()
+		}), "entries with equal timestamps are ordered by ID")
+	})
+}
+
+// TestQueryByMultipleRequestIDs verifies several request IDs can be queried at once
+func TestQueryByMultipleRequestIDs(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	prefix := uuid.NewString()[:8]
+	var entries []models.LogEntry
+	for _, id := range []string{"a", "b", "c"} {
+		entries = append(entries, models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "multi-request",
+			Level: models.LogLevelInfo, Message: "m", RequestID: prefix + id, Timestamp: time.Now(),
+		})
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	wanted := []string{prefix + "a", prefix + "c"}
+
+	found, err := repo.GetByRequestIDs(ctx, wanted)
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	result, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, RequestIDs: wanted})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	for _, entry := range result {
+		assert.Contains(t, wanted, entry.RequestID)
+	}
+}
+
+// TestCreateNormalizesEntries verifies the BeforeCreate hook applies to direct repository writes
+func TestCreateNormalizesEntries(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	local := time.FixedZone("UTC+3", 3*60*60)
+	single := &models.LogEntry{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "  normalize  ",
+		Level: "error", Message: "m", Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, local),
+	}
+	require.NoError(t, repo.Create(ctx, single))
+	require.NoError(t, repo.CreateBatch(ctx, []models.LogEntry{{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "normalize\t",
+		Level: " warn ", Message: "m", Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, local),
+	}}))
+
+	entries, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.Equal(t, "normalize", entry.ServiceName)
+		assert.Contains(t, []models.LogLevel{models.LogLevelError, models.LogLevelWarn}, entry.Level)
+		assert.True(t, entry.Timestamp.Equal(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)))
+	}
+	assert.Equal(t, time.UTC, single.Timestamp.Location())
+}
+
+// TestExactMessageFilter verifies exact matching excludes case and substring variants
+func TestExactMessageFilter(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	for _, message := range []string{"Payment failed", "payment failed", "Payment failed twice"} {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "exact",
+			Level: models.LogLevelError, Message: message, Timestamp: time.Now(),
+		}))
+	}
+
+	fuzzy, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, Search: "Payment failed"})
+	require.NoError(t, err)
+	assert.Len(t, fuzzy, 3)
+
+	exact, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, ExactMessage: "Payment failed"})
+	require.NoError(t, err)
+	require.Len(t, exact, 1)
+	assert.Equal(t, "Payment failed", exact[0].Message)
+}
+
+// TestMetadataInFilter verifies a key matches any of several values, with
+// keys combined and numeric values matching their JSON form
+func TestMetadataInFilter(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	for i, metadata := range []string{
+		`{"region": "eu", "status": 500}`,
+		`{"region": "us", "status": 200}`,
+		`{"region": "ap", "status": 500}`,
+		`{"status": 500}`,
+	} {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "meta-in", Level: models.LogLevelInfo,
+			Message: fmt.Sprintf("entry %d", i), Metadata: json.RawMessage(metadata), Timestamp: time.Now(),
+		}))
+	}
+
+	query := func(in map[string][]string) []string {
+		entries, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MetadataIn: in})
+		require.NoError(t, err)
+		var messages []string
+		for _, e := range entries {
+			messages = append(messages, e.Message)
+		}
+		sort.Strings(messages)
+		return messages
+	}
+
+	assert.Equal(t, []string{"entry 0", "entry 1"}, query(map[string][]string{"region": {"eu", "us"}}))
+	assert.Equal(t, []string{"entry 0"}, query(map[string][]string{"region": {"eu", "us"}, "status": {"500"}}))
+	assert.Equal(t, []string{"entry 0", "entry 2", "entry 3"}, query(map[string][]string{"status": {"500", "404"}}))
+	assert.Empty(t, query(map[string][]string{"region": {"sa"}}))
+}
+
+// TestMinIngestLagFilter verifies only entries stored well after their
+// timestamp match the ingest lag filter
+func TestMinIngestLagFilter(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	for message, age := range map[string]time.Duration{
+		"on time":   5 * time.Second,
+		"buffered":  10 * time.Minute,
+		"very late": 2 * time.Hour,
+	} {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "lag", Level: models.LogLevelInfo,
+			Message: message, Timestamp: time.Now().Add(-age),
+		}))
+	}
+
+	late, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MinIngestLagSeconds: 3600})
+	require.NoError(t, err)
+	require.Len(t, late, 1)
+	assert.Equal(t, "very late", late[0].Message)
+
+	delayed, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MinIngestLagSeconds: 60})
+	require.NoError(t, err)
+	assert.Len(t, delayed, 2)
+}
+
+// TestSearchWithAggregationMatchesSeparateQueries verifies the combined call
+// returns what Query and Aggregate return separately for the same filter
+func TestSearchWithAggregationMatchesSeparateQueries(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	for i := 0; i < 12; i++ {
+		level := models.LogLevelInfo
+		if i%3 == 0 {
+			level = models.LogLevelError
+		}
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "dashboard", Level: level,
+			Message: fmt.Sprintf("entry %d", i), Timestamp: base.Add(time.Duration(i) * 15 * time.Minute),
+		}))
+	}
+
+	filter := models.LogFilter{TenantID: &tenantID, Level: models.LogLevelError, PageSize: 2}
+
+	entries, total, aggregations, err := repo.SearchWithAggregation(ctx, filter, "hour")
+	require.NoError(t, err)
+
+	wantEntries, wantTotal, err := repo.Query(ctx, filter)
+	require.NoError(t, err)
+	wantAggregations, err := repo.Aggregate(ctx, filter, "hour")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), total)
+	assert.Equal(t, wantTotal, total)
+	require.Len(t, entries, 2)
+	for i := range entries {
+		assert.Equal(t, wantEntries[i].ID, entries[i].ID)
+	}
+	assert.Equal(t, wantAggregations, aggregations)
+	assert.Len(t, aggregations, 3)
+}
+
+// TestHostPatternFilter verifies host globs match prefixes, suffixes and
+// exact hosts, treating LIKE metacharacters literally
+func TestHostPatternFilter(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	for _, host := range []string{"web-1", "web-2.eu", "api-web-1", "db-1.eu", "webx1", "web_1"} {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "hosts", Level: models.LogLevelInfo,
+			Message: host, Host: host, Timestamp: time.Now(),
+		}))
+	}
+
+	hostsMatching := func(pattern string) []string {
+		entries, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, HostPattern: pattern})
+		require.NoError(t, err)
+		var hosts []string
+		for _, entry := range entries {
+			hosts = append(hosts, entry.Host)
+		}
+		sort.Strings(hosts)
+		return hosts
+	}
+
+	assert.Equal(t, []string{"web-1", "web-2.eu"}, hostsMatching("web-*"))
+	assert.Equal(t, []string{"db-1.eu", "web-2.eu"}, hostsMatching("*.eu"))
+	assert.Equal(t, []string{"api-web-1", "web-1"}, hostsMatching("*web-1"))
+	assert.Equal(t, []string{"web_1"}, hostsMatching("web_*"), "_ is literal, not a single-character wildcard")
+	assert.Equal(t, []string{"web-1"}, hostsMatching("web-1"))
+}
+
+// TestLevelRangeFilter verifies min and max levels select a severity band
+func TestLevelRangeFilter(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	levels := []models.LogLevel{
+		models.LogLevelDebug, models.LogLevelInfo, models.LogLevelWarn, models.LogLevelError, models.LogLevelFatal,
+	}
+	for _, level := range levels {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "levels", Level: level,
+			Message: string(level), Timestamp: time.Now(),
+		}))
+	}
+
+	for _, tc := range []struct {
+		min, max models.LogLevel
+		want     []string
+	}{
+		{models.LogLevelWarn, models.LogLevelError, []string{"ERROR", "WARN"}},
+		{models.LogLevelInfo, models.LogLevelInfo, []string{"INFO"}},
+		{"", models.LogLevelWarn, []string{"DEBUG", "INFO", "WARN"}},
+		{models.LogLevelError, "", []string{"ERROR", "FATAL"}},
+		{models.LogLevelError, models.LogLevelInfo, nil},
+		{"VERBOSE", "", nil},
+		{models.LogLevelWarn, "CRITICAL", nil},
+	} {
+		entries, _, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MinLevel: tc.min, MaxLevel: tc.max, PageSize: 10})
+		require.NoError(t, err)
+
+		var got []string
+		for _, entry := range entries {
+			got = append(got, entry.Message)
+		}
+		sort.Strings(got)
+		assert.Equal(t, tc.want, got, "%s..%s", tc.min, tc.max)
+	}
+}
+
+// TestAggregateByService verifies per-service bucketed counts match
+// aggregating each service individually
+func TestAggregateByService(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	services := []string{"api", "billing", "worker"}
+	base := time.Now().UTC().Truncate(time.Hour).Add(-4 * time.Hour)
+	var entries []models.LogEntry
+	for i := 0; i < 30; i++ {
+		entries = append(entries, models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: services[i%len(services)], Level: models.LogLevelInfo,
+			Message: fmt.Sprintf("entry %d", i), Timestamp: base.Add(time.Duration(i*i) * time.Minute / 4),
+		})
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	filter := models.LogFilter{TenantID: &tenantID}
+	grouped, err := repo.AggregateByService(ctx, filter, "hour")
+	require.NoError(t, err)
+
+	var total int64
+	for _, service := range services {
+		serviceFilter := filter
+		serviceFilter.ServiceName = service
+		want, err := repo.Aggregate(ctx, serviceFilter, "hour")
+		require.NoError(t, err)
+
+		var got []models.LogAggregation
+		for _, aggregation := range grouped {
+			if aggregation.ServiceName == service {
+				got = append(got, models.LogAggregation{Bucket: aggregation.Bucket, Count: aggregation.Count})
+				total += aggregation.Count
+			}
+		}
+		require.Len(t, got, len(want), service)
+		for i := range want {
+			assert.True(t, want[i].Bucket.Equal(got[i].Bucket), service)
+			assert.Equal(t, want[i].Count, got[i].Count, service)
+		}
+	}
+	assert.Equal(t, int64(len(entries)), total)
+}
+
+// TestShadowDualWrite verifies inserts land in both the primary and the
+// shadow table, and that a failing shadow does not block the primary write
+func TestShadowDualWrite(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	const shadowTable = "log_entries_shadow_test"
+	require.NoError(t, db.Exec("CREATE TABLE IF NOT EXISTS "+shadowTable+" (LIKE log_entries INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING INDEXES)").Error)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Exec("DROP TABLE IF EXISTS " + shadowTable)
+	})
+
+	count := func(table string) int64 {
+		var n int64
+		require.NoError(t, db.Table(table).Where("tenant_id = ?", tenantID).Count(&n).Error)
+		return n
+	}
+	newEntries := func(n int) []models.LogEntry {
+		entries := make([]models.LogEntry, n)
+		for i := range entries {
+			entries[i] = models.LogEntry{
+				ID: uuid.New(), TenantID: tenantID, ServiceName: "shadow", Level: models.LogLevelInfo,
+				Message: fmt.Sprintf("m%d", i), Timestamp: time.Now(),
+			}
+		}
+		return entries
+	}
+
+	repo := repository.NewLogRepository(db)
+	repo.SetShadow(db, shadowTable)
+
+	require.NoError(t, repo.CreateBatch(ctx, newEntries(3)))
+	require.NoError(t, repo.Create(ctx, &newEntries(1)[0]))
+	assert.Equal(t, int64(4), count("log_entries"))
+	assert.Equal(t, int64(4), count(shadowTable))
+
+	t.Run("Shadow Failure Does Not Block Primary", func(t *testing.T) {
+		failing := repository.NewLogRepository(db)
+		failing.SetShadow(db, "log_entries_shadow_missing")
+
+		require.NoError(t, failing.CreateBatch(ctx, newEntries(2)))
+		assert.Equal(t, int64(6), count("log_entries"))
+		assert.Equal(t, int64(4), count(shadowTable))
+	})
+
+	t.Run("Same Table Without DSN Is Rejected", func(t *testing.T) {
+		_, err := database.NewShadowDB(config.PostgresConfig{ShadowTable: "log_entries"}, db)
+		assert.Error(t, err)
+		shadowDB, err := database.NewShadowDB(config.PostgresConfig{ShadowTable: shadowTable}, db)
+		require.NoError(t, err)
+		assert.Same(t, db, shadowDB)
+	})
+}
+
+// TestProximityQuery verifies AroundTime expands into a symmetric window and
+// orders results by distance from the point
+func TestProximityQuery(t *testing.T) {
+	around := time.Date(2024, 3, 1, 14, 32, 0, 0, time.UTC)
+
+	start, end, ok := models.LogFilter{AroundTime: &around, WindowMins: 10}.ProximityWindow()
+	require.True(t, ok)
+	assert.Equal(t, around.Add(-10*time.Minute), start)
+	assert.Equal(t, around.Add(10*time.Minute), end)
+
+	start, end, _ = models.LogFilter{AroundTime: &around}.ProximityWindow()
+	assert.Equal(t, 2*models.DefaultProximityWindowMins*time.Minute, end.Sub(start))
+
+	db := newTestDB(t)
+	ctx := context.Background()
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	for _, offset := range []time.Duration{-4 * time.Minute, time.Minute, -30 * time.Second, 3 * time.Minute, 20 * time.Minute} {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "proximity",
+			Level: models.LogLevelInfo, Message: offset.String(), Timestamp: around.Add(offset),
+		}))
+	}
+
+	entries, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, AroundTime: &around, WindowMins: 5})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"-30s", "1m0s", "3m0s", "-4m0s"}, messages)
+}
+
+// TestGroupByErrorType verifies error entries are grouped per error type and
+// that stack traces round-trip
+func TestGroupByErrorType(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newError := func(service, errorType string, offset time.Duration) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: service, Level: models.LogLevelError,
+			Message: fmt.Sprintf("%s in %s at %s", errorType, service, offset), Timestamp: base.Add(offset),
+			ErrorType: errorType, StackTrace: "goroutine 1 [running]:\nmain.handle()\n\t/app/main.go:42",
+		}
+	}
+	entries := []models.LogEntry{
+		newError("api", "*net.OpError", 0),
+		newError("api", "*net.OpError", time.Minute),
+		newError("worker", "*net.OpError", 2*time.Minute),
+		newError("api", "context.DeadlineExceeded", 3*time.Minute),
+		{ID: uuid.New(), TenantID: tenantID, ServiceName: "api", Level: models.LogLevelInfo, Message: "ok", Timestamp: base},
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	groups, err := repo.GroupByErrorType(ctx, models.LogFilter{TenantID: &tenantID}, 10)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	assert.Equal(t, "*net.OpError", groups[0].ErrorType)
+	assert.Equal(t, int64(3), groups[0].Count)
+	assert.Equal(t, int64(2), groups[0].Services)
+	assert.True(t, groups[0].FirstSeen.Equal(base))
+	assert.True(t, groups[0].LastSeen.Equal(base.Add(2*time.Minute)))
+	assert.Equal(t, entries[2].ID, groups[0].LatestID)
+	assert.Equal(t, entries[2].Message, groups[0].LatestMessage)
+
+	assert.Equal(t, "context.DeadlineExceeded", groups[1].ErrorType)
+	assert.Equal(t, int64(1), groups[1].Count)
+
+	t.Run("Filter By Error Type", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, ErrorType: "context.DeadlineExceeded"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		require.Len(t, found, 1)
+		assert.Equal(t, entries[3].ID, found[0].ID)
+	})
+
+	t.Run("Stack Trace Retrieval", func(t *testing.T) {
+		stored, err := repo.FindByID(ctx, groups[0].LatestID)
+		require.NoError(t, err)
+		assert.Equal(t, entries[2].StackTrace, stored.StackTrace)
+		assert.Equal(t, "*net.OpError", stored.ErrorType)
+	})
+}
+
+// TestSourceFilter verifies filtering by one or several sources and listing
+// the distinct sources of a tenant
+func TestSourceFilter(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newEntry := func(source string, offset time.Duration) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "api", Level: models.LogLevelInfo,
+			Message: "from " + source, Timestamp: base.Add(offset), Source: source,
+		}
+	}
+	entries := []models.LogEntry{
+		newEntry("stdout", 0),
+		newEntry("stderr", time.Minute),
+		newEntry("/var/log/app.log", 2*time.Minute),
+		newEntry("stdout", 3*time.Minute),
+		newEntry("", 4*time.Minute),
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	t.Run("Single Source", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, Source: "stdout"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		ids := []uuid.UUID{}
+		for _, entry := range found {
+			ids = append(ids, entry.ID)
+		}
+		assert.ElementsMatch(t, []uuid.UUID{entries[0].ID, entries[3].ID}, ids)
+	})
+
+	t.Run("Any Of Sources", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, Sources: []string{"stderr", "/var/log/app.log"}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		ids := []uuid.UUID{}
+		for _, entry := range found {
+			ids = append(ids, entry.ID)
+		}
+		assert.ElementsMatch(t, []uuid.UUID{entries[1].ID, entries[2].ID}, ids)
+	})
+
+	t.Run("Distinct Sources", func(t *testing.T) {
+		sources, err := repo.GetSources(ctx, &tenantID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/var/log/app.log", "stderr", "stdout"}, sources)
+	})
+}
+
+// TestOrGroups verifies Or groups match the union of their groups' rows while
+// top-level predicates still apply to every group
+func TestOrGroups(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newEntry := func(service string, level models.LogLevel, env string) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: service, Level: level,
+			Message: fmt.Sprintf("%s %s %s", service, level, env), Timestamp: base, Environment: env,
+		}
+	}
+	entries := []models.LogEntry{
+		newEntry("a", models.LogLevelError, "prod"), // a AND ERROR
+		newEntry("a", models.LogLevelWarn, "prod"),  // neither group
+		newEntry("b", models.LogLevelWarn, "prod"),  // b AND WARN
+		newEntry("b", models.LogLevelError, "prod"), // neither group
+		newEntry("a", models.LogLevelError, "dev"),  // group match, wrong environment
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	ids := func(found []models.LogEntry) []uuid.UUID {
+		result := []uuid.UUID{}
+		for _, entry := range found {
+			result = append(result, entry.ID)
+		}
+		return result
+	}
+
+	filter := models.LogFilter{
+		TenantID:    &tenantID,
+		Environment: "prod",
+		Or: []models.LogFilter{
+			{ServiceName: "a", Level: models.LogLevelError},
+			{ServiceName: "b", Level: models.LogLevelWarn},
+		},
+	}
+	found, total, err := repo.Query(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.ElementsMatch(t, []uuid.UUID{entries[0].ID, entries[2].ID}, ids(found))
+
+	t.Run("Nested Groups", func(t *testing.T) {
+		nested := models.LogFilter{
+			TenantID: &tenantID,
+			Or: []models.LogFilter{
+				{ServiceName: "b", Or: []models.LogFilter{{Level: models.LogLevelError}, {Environment: "staging"}}},
+				{Environment: "dev"},
+			},
+		}
+		found, total, err := repo.Query(ctx, nested)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.ElementsMatch(t, []uuid.UUID{entries[3].ID, entries[4].ID}, ids(found))
+	})
+
+	t.Run("Empty Group Matches Everything", func(t *testing.T) {
+		_, total, err := repo.Query(ctx, models.LogFilter{
+			TenantID: &tenantID,
+			Or:       []models.LogFilter{{ServiceName: "b"}, {}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(entries)), total)
+	})
+}
+
+// TestAgeDistribution verifies the retention preview buckets entries by age
+func TestAgeDistribution(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	otherTenant := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, otherTenant}).Delete(&models.LogEntry{})
+	})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	newEntry := func(tenant uuid.UUID, age time.Duration) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: "api", Level: models.LogLevelInfo,
+			Message: "aged " + age.String(), Timestamp: now.Add(-age),
+		}
+	}
+	day := 24 * time.Hour
+	entries := []models.LogEntry{
+		newEntry(tenantID, time.Hour),
+		newEntry(tenantID, 2*time.Hour),
+		newEntry(tenantID, 3*day),
+		newEntry(tenantID, 10*day),
+		newEntry(tenantID, 45*day),
+		newEntry(tenantID, 45*day),
+		newEntry(tenantID, 45*day),
+		newEntry(tenantID, 400*day),
+		newEntry(otherTenant, 2*day),
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	previews, err := repo.AgeDistribution(ctx, &tenantID, now)
+	require.NoError(t, err)
+	require.Len(t, previews, 1)
+
+	preview := previews[0]
+	assert.Equal(t, tenantID, preview.TenantID)
+	assert.Equal(t, int64(8), preview.TotalCount)
+	assert.True(t, preview.OldestTimestamp.Equal(now.Add(-400*day)))
+
+	counts := map[string]int64{}
+	for _, bucket := range preview.AgeBuckets {
+		counts[bucket.Label] = bucket.Count
+	}
+	assert.Equal(t, map[string]int64{
+		"0d-1d":    2,
+		"1d-7d":    1,
+		"7d-30d":   1,
+		"30d-90d":  3,
+		"90d-365d": 0,
+		"365d+":    1,
+	}, counts)
+
+	t.Run("All Tenants", func(t *testing.T) {
+		previews, err := repo.AgeDistribution(ctx, nil, now)
+		require.NoError(t, err)
+
+		byTenant := map[uuid.UUID]models.RetentionPreview{}
+		for _, preview := range previews {
+			byTenant[preview.TenantID] = preview
+		}
+		require.Contains(t, byTenant, otherTenant)
+		assert.Equal(t, int64(1), byTenant[otherTenant].TotalCount)
+		assert.Equal(t, int64(1), byTenant[otherTenant].AgeBuckets[1].Count)
+		assert.Equal(t, int64(8), byTenant[tenantID].TotalCount)
+	})
+}
+
+// TestMetadataKeyValueFilter verifies metadata key/value containment matches
+// numeric and string values and leaves message search unaffected
+func TestMetadataKeyValueFilter(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newEntry := func(message, metadata string, offset time.Duration) models.LogEntry {
+		entry := models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "orders", Level: models.LogLevelInfo,
+			Message: message, Timestamp: base.Add(offset),
+		}
+		if metadata != "" {
+			entry.Metadata = json.RawMessage(metadata)
+		}
+		return entry
+	}
+	entries := []models.LogEntry{
+		newEntry("order placed", `{"order_id":12345,"region":"eu"}`, 0),
+		newEntry("order shipped", `{"order_id":"12345"}`, time.Minute),
+		newEntry("order placed", `{"order_id":999}`, 2*time.Minute),
+		newEntry("payment failed", `{"customer":{"order_id":12345}}`, 3*time.Minute),
+		newEntry("order placed", "", 4*time.Minute),
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	ids := func(found []models.LogEntry) []uuid.UUID {
+		result := []uuid.UUID{}
+		for _, entry := range found {
+			result = append(result, entry.ID)
+		}
+		return result
+	}
+
+	t.Run("Key And Value", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MetadataKey: "order_id", MetadataValue: "12345"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total, "numeric and string values match, nested keys do not")
+		assert.ElementsMatch(t, []uuid.UUID{entries[0].ID, entries[1].ID}, ids(found))
+	})
+
+	t.Run("Combined With Search", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{
+			TenantID: &tenantID, Search: "placed", MetadataKey: "order_id", MetadataValue: "12345",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []uuid.UUID{entries[0].ID}, ids(found))
+	})
+
+	t.Run("Search Without Metadata", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, Search: "PLACED"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.ElementsMatch(t, []uuid.UUID{entries[0].ID, entries[2].ID, entries[4].ID}, ids(found))
+	})
+
+	t.Run("Metadata Search Matches Keys", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MetadataSearch: "CUSTOMER"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []uuid.UUID{entries[3].ID}, ids(found))
+	})
+
+	t.Run("Metadata Search Matches Values", func(t *testing.T) {
+		found, total, err := repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MetadataSearch: "2345"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total, "string, numeric and nested values match")
+		assert.ElementsMatch(t, []uuid.UUID{entries[0].ID, entries[1].ID, entries[3].ID}, ids(found))
+
+		found, total, err = repo.Query(ctx, models.LogFilter{TenantID: &tenantID, MetadataSearch: "eu", Search: "placed"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []uuid.UUID{entries[0].ID}, ids(found))
+	})
+}
+
+// TestUpsertSettingsKeepsCreatedAt verifies replacing tenant settings keeps
+// their original creation time
+func TestUpsertSettingsKeepsCreatedAt(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewTenantRepository(db)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		repo.DeleteSettings(ctx, tenantID)
+	})
+
+	require.NoError(t, repo.UpsertSettings(ctx, &models.TenantSettings{TenantID: tenantID, DedupEnabled: true}))
+	created, err := repo.FindSettings(ctx, tenantID)
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, repo.UpsertSettings(ctx, &models.TenantSettings{TenantID: tenantID, SamplingEnabled: true, SampleRate: 0.5}))
+	updated, err := repo.FindSettings(ctx, tenantID)
+	require.NoError(t, err)
+
+	assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+	assert.True(t, updated.UpdatedAt.After(created.UpdatedAt))
+	assert.False(t, updated.DedupEnabled)
+	assert.True(t, updated.SamplingEnabled)
+}
+
+// TestGetStatsTenantIsolation verifies the level and service breakdowns, like
+// the total, only count the requested tenant's entries
+func TestGetStatsTenantIsolation(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantA := uuid.New()
+	tenantB := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantA, tenantB}).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newEntry := func(tenant uuid.UUID, serviceName string, level models.LogLevel, offset time.Duration) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: serviceName, Level: level,
+			Message: "stats", Timestamp: base.Add(offset),
+		}
+	}
+	require.NoError(t, repo.CreateBatch(ctx, []models.LogEntry{
+		newEntry(tenantA, "checkout", models.LogLevelInfo, 0),
+		newEntry(tenantA, "checkout", models.LogLevelError, time.Minute),
+		newEntry(tenantA, "search", models.LogLevelInfo, 2*time.Minute),
+		newEntry(tenantB, "billing", models.LogLevelWarn, time.Minute),
+		newEntry(tenantB, "checkout", models.LogLevelInfo, 2*time.Minute),
+	}))
+
+	start, end := base.Add(-time.Minute), base.Add(5*time.Minute)
+
+	stats, err := repo.GetStats(ctx, &tenantA, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalCount)
+	assert.Equal(t, map[models.LogLevel]int64{models.LogLevelInfo: 2, models.LogLevelError: 1}, stats.LevelCounts)
+	assert.Equal(t, map[string]int64{"checkout": 2, "search": 1}, stats.ServiceCounts)
+
+	stats, err = repo.GetStats(ctx, &tenantB, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalCount)
+	assert.Equal(t, map[models.LogLevel]int64{models.LogLevelWarn: 1, models.LogLevelInfo: 1}, stats.LevelCounts)
+	assert.Equal(t, map[string]int64{"billing": 1, "checkout": 1}, stats.ServiceCounts)
+}
+
+// TestRequestDurations verifies requests are measured from their first to
+// their last entry, single-entry requests are left out, and the slowest are
+// listed first
+func TestRequestDurations(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	otherTenant := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, otherTenant}).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	newEntry := func(tenant uuid.UUID, requestID, message string, offset time.Duration) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: "api", Level: models.LogLevelInfo,
+			Message: message, Timestamp: base.Add(offset), RequestID: requestID,
+		}
+	}
+	ms := time.Millisecond
+	require.NoError(t, repo.CreateBatch(ctx, []models.LogEntry{
+		newEntry(tenantID, "req-fast", "request started", 0),
+		newEntry(tenantID, "req-fast", "request finished", 40*ms),
+		newEntry(tenantID, "req-medium", "request started", time.Second),
+		newEntry(tenantID, "req-medium", "request finished", time.Second+250*ms),
+		newEntry(tenantID, "req-slow", "request started", 2*time.Second),
+		newEntry(tenantID, "req-slow", "request finished", 2*time.Second+1500*ms),
+		newEntry(tenantID, "req-steps", "request started", 5*time.Second),
+		newEntry(tenantID, "req-steps", "querying", 5*time.Second+300*ms),
+		newEntry(tenantID, "req-steps", "request finished", 5*time.Second+900*ms),
+		newEntry(tenantID, "req-unfinished", "request started", 7*time.Second),
+		newEntry(tenantID, "", "no request", 8*time.Second),
+		newEntry(otherTenant, "req-other", "request started", 0),
+		newEntry(otherTenant, "req-other", "request finished", 10*time.Second),
+	}))
+
+	durations, err := repo.RequestDurations(ctx, models.LogFilter{TenantID: &tenantID}, 100, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), durations.Requests, "single-entry requests and other tenants are left out")
+	assert.InDelta(t, 1500, durations.MaxMs, 0.01)
+	assert.InDelta(t, 575, durations.P50Ms, 0.01, "median of 40, 250, 900 and 1500")
+
+	require.Len(t, durations.Slowest, 3, "requests under 100ms are not listed")
+	assert.Equal(t, "req-slow", durations.Slowest[0].RequestID)
+	assert.InDelta(t, 1500, durations.Slowest[0].DurationMs, 0.01)
+	assert.Equal(t, int64(2), durations.Slowest[0].Entries)
+	assert.True(t, durations.Slowest[0].Start.Equal(base.Add(2*time.Second)))
+	assert.True(t, durations.Slowest[0].End.Equal(base.Add(2*time.Second+1500*ms)))
+	assert.Equal(t, "req-steps", durations.Slowest[1].RequestID)
+	assert.InDelta(t, 900, durations.Slowest[1].DurationMs, 0.01)
+	assert.Equal(t, int64(3), durations.Slowest[1].Entries)
+	assert.Equal(t, "req-medium", durations.Slowest[2].RequestID)
+	assert.InDelta(t, 250, durations.Slowest[2].DurationMs, 0.01)
+
+	t.Run("Limit", func(t *testing.T) {
+		durations, err := repo.RequestDurations(ctx, models.LogFilter{TenantID: &tenantID}, 0, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), durations.Requests)
+		require.Len(t, durations.Slowest, 1)
+		assert.Equal(t, "req-slow", durations.Slowest[0].RequestID)
+	})
+
+	t.Run("No Requests", func(t *testing.T) {
+		empty := uuid.New()
+		durations, err := repo.RequestDurations(ctx, models.LogFilter{TenantID: &empty}, 0, 10)
+		require.NoError(t, err)
+		assert.Zero(t, durations.Requests)
+		assert.NotNil(t, durations.Slowest)
+		assert.Empty(t, durations.Slowest)
+	})
+}
+
+// TestServiceEdges verifies the first and last entry per service are found
+// and scoped to the tenant
+func TestServiceEdges(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewLogRepository(db)
+
+	tenantID := uuid.New()
+	otherTenant := uuid.New()
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+	entry := func(tenant uuid.UUID, service, message string, offset time.Duration) models.LogEntry {
+		return models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: service, Level: models.LogLevelInfo,
+			Message: message, Timestamp: base.Add(offset),
+		}
+	}
+	require.NoError(t, repo.CreateBatch(ctx, []models.LogEntry{
+		entry(tenantID, "api", "api-middle", time.Minute),
+		entry(tenantID, "api", "api-first", 0),
+		entry(tenantID, "api", "api-last", 2*time.Minute),
+		entry(tenantID, "worker", "worker-only", 30*time.Second),
+		entry(otherTenant, "api", "other-first", -time.Minute),
+		entry(otherTenant, "api", "other-last", 3*time.Minute),
+	}))
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, otherTenant}).Delete(&models.LogEntry{})
+	})
+
+	messages := func(edges []models.ServiceLogEdge) map[string]string {
+		byService := make(map[string]string, len(edges))
+		for _, edge := range edges {
+			require.NotNil(t, edge.Entry)
+			assert.True(t, edge.Timestamp.Equal(edge.Entry.Timestamp))
+			byService[edge.ServiceName] = edge.Entry.Message
+		}
+		return byService
+	}
+
+	t.Run("First", func(t *testing.T) {
+		edges, err := repo.GetServiceEdges(ctx, &tenantID, false)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"api": "api-first", "worker": "worker-only"}, messages(edges))
+	})
+
+	t.Run("Last", func(t *testing.T) {
+		edges, err := repo.GetServiceEdges(ctx, &tenantID, true)
+		require.NoError(t, err)
+		require.Len(t, edges, 2)
+		assert.Equal(t, "api", edges[0].ServiceName, "edges are ordered by service")
+		assert.Equal(t, map[string]string{"api": "api-last", "worker": "worker-only"}, messages(edges))
+	})
+}
@@ -0,0 +1,2488 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minisource/log/config"
+	"github.com/minisource/log/internal/models"
+	"github.com/minisource/log/internal/repository"
+	"github.com/minisource/log/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newTestLogService builds a LogService backed by the test database without
+// Redis, loading configuration from the environment when cfg is nil
+func newTestLogService(t *testing.T, db *gorm.DB, cfg *config.Config) *service.LogService {
+	t.Helper()
+
+	if cfg == nil {
+		var err error
+		cfg, err = config.Load()
+		require.NoError(t, err)
+	}
+
+	svc := service.NewLogService(
+		repository.NewLogRepository(db),
+		repository.NewRetentionRepository(db),
+		repository.NewAlertRepository(db),
+		service.NewTenantService(repository.NewTenantRepository(db)),
+		nil,
+		cfg,
+	)
+	t.Cleanup(svc.Close)
+
+	return svc
+}
+
+// TestQueryCoalescesConcurrentIdenticalQueries verifies identical concurrent
+// queries share a single DB round-trip
+func TestQueryCoalescesConcurrentIdenticalQueries(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+
+	var queries int32
+	require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:count_slow", func(tx *gorm.DB) {
+		if tx.Statement.Table == "log_entries" {
+			atomic.AddInt32(&queries, 1)
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(func() {
+		db.Callback().Query().Remove("test:count_slow")
+	})
+
+	tenantID := uuid.New()
+	filter := models.LogFilter{TenantID: &tenantID, ServiceName: "coalesce-test"}
+
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := svc.Query(context.Background(), filter)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// One COUNT plus one SELECT for the single shared execution
+	assert.Equal(t, int32(2), atomic.LoadInt32(&queries))
+}
+
+// TestQueryCoalescingSurvivesCanceledCaller verifies a caller canceling its
+// context neither fails the shared query for other callers nor shares its
+// result object with them
+func TestQueryCoalescingSurvivesCanceledCaller(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+
+	require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:slow", func(tx *gorm.DB) {
+		if tx.Statement.Table == "log_entries" {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(func() {
+		db.Callback().Query().Remove("test:slow")
+	})
+
+	tenantID := uuid.New()
+	filter := models.LogFilter{TenantID: &tenantID, ServiceName: "coalesce-cancel-test"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := svc.Query(ctx, filter)
+		leaderErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	results := make([]*models.LogQueryResult, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := svc.Query(context.Background(), filter)
+			assert.NoError(t, err)
+			results[i] = result
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	assert.ErrorIs(t, <-leaderErr, context.Canceled)
+	wg.Wait()
+	require.NotNil(t, results[0])
+	require.NotNil(t, results[1])
+	assert.NotSame(t, results[0], results[1])
+}
+
+// TestAlertLimitPerTenant verifies the enabled alert cap is enforced on create
+func TestAlertLimitPerTenant(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Alert.MaxPerTenant = 2
+
+	svc := service.NewAlertService(repository.NewAlertRepository(db), cfg)
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogAlert{})
+	})
+
+	newAlert := func() *models.LogAlert {
+		return &models.LogAlert{
+			TenantID:  tenantID,
+			Name:      "limit-test",
+			Enabled:   true,
+			Filter:    []byte(`{}`),
+			Threshold: 1,
+			Severity:  "high",
+		}
+	}
+
+	require.NoError(t, svc.CreateAlert(ctx, newAlert()))
+	require.NoError(t, svc.CreateAlert(ctx, newAlert()))
+
+	err = svc.CreateAlert(ctx, newAlert())
+	var limitErr *service.AlertLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, int64(2), limitErr.Count)
+	assert.Equal(t, 2, limitErr.Limit)
+
+	t.Run("Disabled Alerts Are Not Limited", func(t *testing.T) {
+		alert := newAlert()
+		alert.Enabled = false
+		require.NoError(t, svc.CreateAlert(ctx, alert))
+
+		stored, err := svc.GetAlert(ctx, alert.ID)
+		require.NoError(t, err)
+		assert.False(t, stored.Enabled)
+	})
+}
+
+// TestBulkAlerts verifies bulk actions change exactly the tenant's alerts
+// matching the selector
+func TestBulkAlerts(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Alert.MaxPerTenant = 4
+
+	svc := service.NewAlertService(repository.NewAlertRepository(db), cfg)
+	tenantID, otherTenant := uuid.New(), uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, otherTenant}).Delete(&models.LogAlert{})
+	})
+
+	create := func(tenant uuid.UUID, name, severity, filter string) uuid.UUID {
+		alert := &models.LogAlert{
+			TenantID: tenant, Name: name, Enabled: true, Filter: []byte(filter), Threshold: 1, Severity: severity,
+		}
+		require.NoError(t, svc.CreateAlert(ctx, alert))
+		return alert.ID
+	}
+	checkoutHigh := create(tenantID, "checkout-errors", "high", `{"service_name":"checkout"}`)
+	checkoutLow := create(tenantID, "checkout-slow", "low", `{"service_name":"checkout"}`)
+	authHigh := create(tenantID, "auth-errors", "high", `{"service_name":"auth"}`)
+	otherCheckout := create(otherTenant, "checkout-errors", "high", `{"service_name":"checkout"}`)
+
+	enabled := func() map[uuid.UUID]bool {
+		state := make(map[uuid.UUID]bool)
+		for _, id := range []uuid.UUID{checkoutHigh, checkoutLow, authHigh, otherCheckout} {
+			alert, err := svc.GetAlert(ctx, id)
+			require.NoError(t, err)
+			state[id] = alert.Enabled
+		}
+		return state
+	}
+
+	result, err := svc.BulkAlerts(ctx, tenantID, models.AlertBulkRequest{
+		Selector: models.AlertSelector{ServiceName: "checkout"}, Action: models.AlertBulkDisable,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, int64(2), result.Affected)
+	assert.Equal(t, map[uuid.UUID]bool{checkoutHigh: false, checkoutLow: false, authHigh: true, otherCheckout: true}, enabled())
+
+	result, err = svc.BulkAlerts(ctx, tenantID, models.AlertBulkRequest{
+		Selector: models.AlertSelector{Severity: "HIGH", NamePattern: "*-errors"}, Action: models.AlertBulkEnable,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, int64(1), result.Affected, "auth-errors was already enabled")
+	assert.Equal(t, map[uuid.UUID]bool{checkoutHigh: true, checkoutLow: false, authHigh: true, otherCheckout: true}, enabled())
+
+	cfg.Alert.MaxPerTenant = 2
+	_, err = svc.BulkAlerts(ctx, tenantID, models.AlertBulkRequest{
+		Selector: models.AlertSelector{NamePattern: "*"}, Action: models.AlertBulkEnable,
+	})
+	var limitErr *service.AlertLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.False(t, enabled()[checkoutLow], "nothing is enabled past the cap")
+
+	_, err = svc.BulkAlerts(ctx, tenantID, models.AlertBulkRequest{Action: models.AlertBulkDelete})
+	assert.ErrorIs(t, err, service.ErrInvalidBulkRequest, "an empty selector is refused")
+	_, err = svc.BulkAlerts(ctx, tenantID, models.AlertBulkRequest{
+		Selector: models.AlertSelector{NamePattern: "*"}, Action: "mute",
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidBulkRequest)
+
+	result, err = svc.BulkAlerts(ctx, tenantID, models.AlertBulkRequest{
+		Selector: models.AlertSelector{ServiceName: "auth"}, Action: models.AlertBulkDelete,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Affected)
+	_, err = svc.GetAlert(ctx, authHigh)
+	assert.Error(t, err)
+	_, err = svc.GetAlert(ctx, otherCheckout)
+	assert.NoError(t, err, "other tenants' alerts are untouched")
+}
+
+// TestBufferLogBackpressure verifies async ingestion is rejected above the watermark
+func TestBufferLogBackpressure(t *testing.T) {
+	db := newTestDB(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.BufferHighWatermark = 2
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	// Registered after the delete so buffered entries are flushed first
+	svc := newTestLogService(t, db, cfg)
+
+	entry := models.LogEntry{TenantID: tenantID, ServiceName: "backpressure", Level: models.LogLevelInfo, Message: "m"}
+
+	assert.NoError(t, svc.BufferLog(entry))
+	assert.False(t, svc.BufferSaturated())
+	assert.NoError(t, svc.BufferLog(entry))
+	assert.True(t, svc.BufferSaturated())
+	assert.ErrorIs(t, svc.BufferLog(entry), service.ErrBufferSaturated)
+}
+
+// TestBufferLogEarlyFlush verifies ERROR entries flush the buffer immediately
+// while INFO entries wait for the ticker
+func TestBufferLogEarlyFlush(t *testing.T) {
+	db := newTestDB(t)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.FlushLevels = []string{"ERROR", "FATAL"}
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	svc := newTestLogService(t, db, cfg)
+
+	count := func() int64 {
+		var n int64
+		db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&n)
+		return n
+	}
+
+	info := models.LogEntry{TenantID: tenantID, ServiceName: "early-flush", Level: models.LogLevelInfo, Message: "m"}
+	require.NoError(t, svc.BufferLog(info))
+	require.NoError(t, svc.BufferLog(info))
+	time.Sleep(200 * time.Millisecond)
+	assert.Zero(t, count(), "INFO entries should wait for the ticker")
+
+	severe := models.LogEntry{TenantID: tenantID, ServiceName: "early-flush", Level: models.LogLevelError, Message: "boom"}
+	require.NoError(t, svc.BufferLog(severe))
+	assert.Eventually(t, func() bool { return count() == 3 }, 2*time.Second, 50*time.Millisecond)
+}
+
+// TestMetricRuleCountsSeededLogs verifies a count rule produces per-bucket counts
+func TestMetricRuleCountsSeededLogs(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	logRepo := repository.NewLogRepository(db)
+	metricRepo := repository.NewMetricRepository(db)
+	svc := service.NewMetricService(metricRepo, logRepo)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	var entries []models.LogEntry
+	// 3 payment failures two minutes ago, 1 one minute ago, plus noise
+	for _, offset := range []time.Duration{-2 * time.Minute, -2 * time.Minute, -2 * time.Minute, -time.Minute} {
+		entries = append(entries, models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "payments",
+			Level: models.LogLevelError, Message: "payment_failed", Timestamp: now.Add(offset + time.Second),
+		})
+	}
+	entries = append(entries, models.LogEntry{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "payments",
+		Level: models.LogLevelInfo, Message: "payment_ok", Timestamp: now.Add(-time.Minute + time.Second),
+	})
+	require.NoError(t, logRepo.CreateBatch(ctx, entries))
+
+	rule := &models.MetricRule{
+		TenantID:     tenantID,
+		Name:         "payment failures",
+		Filter:       []byte(`{"service_name":"payments","search":"payment_failed"}`),
+		IntervalMins: 1,
+	}
+	require.NoError(t, svc.CreateRule(ctx, rule))
+	t.Cleanup(func() {
+		metricRepo.Delete(ctx, tenantID, rule.ID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	start := now.Add(-3 * time.Minute)
+	rule.LastEvaluated = &start
+	require.NoError(t, svc.EvaluateRule(ctx, *rule, now))
+
+	points, err := svc.GetSeries(ctx, tenantID, rule.ID, start, now)
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.Equal(t, float64(0), points[0].Value)
+	assert.Equal(t, float64(3), points[1].Value)
+	assert.Equal(t, float64(1), points[2].Value)
+}
+
+// TestMetricRuleUpdateKeepsServerFields verifies an update changes only the
+// editable fields of the caller's own rule, and that other tenants cannot
+// read, update or delete it
+func TestMetricRuleUpdateKeepsServerFields(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	metricRepo := repository.NewMetricRepository(db)
+	svc := service.NewMetricService(metricRepo, repository.NewLogRepository(db))
+
+	rule := &models.MetricRule{
+		TenantID:     tenantID,
+		Name:         "errors",
+		Enabled:      true,
+		Filter:       []byte(`{"level":"error"}`),
+		IntervalMins: 1,
+	}
+	require.NoError(t, svc.CreateRule(ctx, rule))
+	t.Cleanup(func() { metricRepo.Delete(ctx, tenantID, rule.ID) })
+	evaluated := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, metricRepo.UpdateLastEvaluated(ctx, rule.ID, evaluated))
+	created, err := svc.GetRule(ctx, tenantID, rule.ID)
+	require.NoError(t, err)
+
+	otherTenant := uuid.New()
+	changes := models.MetricRule{
+		TenantID:     otherTenant,
+		Name:         "fatal errors",
+		Filter:       []byte(`{"level":"fatal"}`),
+		IntervalMins: 5,
+	}
+
+	t.Run("Other Tenants Cannot Access", func(t *testing.T) {
+		_, err := svc.UpdateRule(ctx, otherTenant, rule.ID, changes)
+		assert.ErrorIs(t, err, service.ErrMetricRuleNotFound)
+		_, err = svc.GetRule(ctx, otherTenant, rule.ID)
+		assert.ErrorIs(t, err, service.ErrMetricRuleNotFound)
+		_, err = svc.GetSeries(ctx, otherTenant, rule.ID, evaluated.Add(-time.Hour), evaluated)
+		assert.ErrorIs(t, err, service.ErrMetricRuleNotFound)
+		assert.ErrorIs(t, svc.DeleteRule(ctx, otherTenant, rule.ID), service.ErrMetricRuleNotFound)
+
+		_, err = svc.GetRule(ctx, tenantID, rule.ID)
+		assert.NoError(t, err, "the rule survives another tenant's delete")
+	})
+
+	t.Run("Editable Fields Change", func(t *testing.T) {
+		updated, err := svc.UpdateRule(ctx, tenantID, rule.ID, changes)
+		require.NoError(t, err)
+		assert.Equal(t, "fatal errors", updated.Name)
+
+		stored, err := svc.GetRule(ctx, tenantID, rule.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "fatal errors", stored.Name)
+		assert.Equal(t, 5, stored.IntervalMins)
+		assert.False(t, stored.Enabled)
+		assert.Equal(t, tenantID, stored.TenantID)
+		assert.True(t, created.CreatedAt.Equal(stored.CreatedAt))
+		require.NotNil(t, stored.LastEvaluated)
+		assert.True(t, stored.LastEvaluated.Equal(evaluated))
+	})
+}
+
+// TestRetentionTierRouting verifies routing tags entries and cleanup prunes
+// per tier, keeping a zero-day tier forever
+func TestRetentionTierRouting(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Retention.RetentionDays = 30
+	cfg.Retention.TierDays = map[string]int{"short": 7, "long": 365, "forever": 0}
+	cfg.Retention.RoutingRules = `[
+		{"tier": "forever", "filter": {"environment": "audit"}},
+		{"tier": "short", "filter": {"environment": "development"}},
+		{"tier": "long", "filter": {"environment": "production", "min_level": "ERROR"}}
+	]`
+
+	svc := newTestLogService(t, db, cfg)
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	tenDaysAgo := time.Now().UTC().AddDate(0, 0, -10)
+	fortyDaysAgo := time.Now().UTC().AddDate(0, 0, -40)
+	batch := &models.LogBatch{Entries: []models.LogEntry{
+		{TenantID: tenantID, ServiceName: "svc", Level: models.LogLevelInfo, Environment: "development", Message: "dev", Timestamp: tenDaysAgo},
+		{TenantID: tenantID, ServiceName: "svc", Level: models.LogLevelError, Environment: "production", Message: "prod error", Timestamp: fortyDaysAgo},
+		{TenantID: tenantID, ServiceName: "svc", Level: models.LogLevelInfo, Environment: "production", Message: "prod info", Timestamp: fortyDaysAgo},
+		{TenantID: tenantID, ServiceName: "svc", Level: models.LogLevelInfo, Environment: "staging", Message: "staging", Timestamp: tenDaysAgo},
+		{TenantID: tenantID, ServiceName: "svc", Level: models.LogLevelInfo, Environment: "audit", Message: "audit", Timestamp: fortyDaysAgo},
+	}}
+	_, err = svc.IngestBatch(ctx, batch)
+	require.NoError(t, err)
+
+	assert.Equal(t, "short", batch.Entries[0].RetentionTier)
+	assert.Equal(t, "long", batch.Entries[1].RetentionTier)
+	assert.Empty(t, batch.Entries[2].RetentionTier)
+	assert.Empty(t, batch.Entries[3].RetentionTier)
+	assert.Equal(t, "forever", batch.Entries[4].RetentionTier)
+
+	require.NoError(t, svc.Cleanup(ctx))
+
+	var remaining []string
+	require.NoError(t, db.Model(&models.LogEntry{}).
+		Where("tenant_id = ?", tenantID).
+		Order("message").
+		Pluck("message", &remaining).Error)
+	assert.Equal(t, []string{"audit", "prod error", "staging"}, remaining)
+}
+
+// TestMetadataLargeIntegerRoundTrip verifies a 19-digit ID survives ingestion, storage and query
+func TestMetadataLargeIntegerRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	entry := &models.LogEntry{
+		TenantID:    tenantID,
+		ServiceName: "precision",
+		Level:       models.LogLevelInfo,
+		Message:     "order created",
+		Metadata:    []byte(`{"order_id": 9223372036854775807}`),
+	}
+	require.NoError(t, svc.IngestSingle(ctx, entry))
+
+	result, err := svc.Query(ctx, models.LogFilter{TenantID: &tenantID})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+	assert.Contains(t, string(result.Entries[0].Metadata), "9223372036854775807")
+}
+
+// TestQueryKeysetPagination verifies walking next_cursor visits every entry
+// once in (timestamp, id) order, ties included, without counting
+func TestQueryKeysetPagination(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+	repo := repository.NewLogRepository(db)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	// Pairs of entries share a timestamp so pages split ties
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	var entries []models.LogEntry
+	for i := 0; i < 7; i++ {
+		entries = append(entries, models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "keyset", Level: models.LogLevelInfo,
+			Message: fmt.Sprintf("entry %d", i), Timestamp: base.Add(time.Duration(i/2) * time.Second),
+		})
+	}
+	require.NoError(t, repo.CreateBatch(ctx, entries))
+
+	first, err := svc.Query(ctx, models.LogFilter{TenantID: &tenantID, PageSize: 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), first.TotalCount)
+	assert.True(t, first.HasMore)
+	require.NotEmpty(t, first.NextCursor)
+
+	seen := make(map[uuid.UUID]bool)
+	var order []models.LogEntry
+	collect := func(page []models.LogEntry) {
+		for _, entry := range page {
+			assert.False(t, seen[entry.ID], "entry %s repeated", entry.Message)
+			seen[entry.ID] = true
+			order = append(order, entry)
+		}
+	}
+	collect(first.Entries)
+
+	cursor := first.NextCursor
+	for pages := 1; cursor != ""; pages++ {
+		require.Less(t, pages, 5, "pagination must end")
+		result, err := svc.Query(ctx, models.LogFilter{TenantID: &tenantID, PageSize: 3, Cursor: cursor})
+		require.NoError(t, err)
+		assert.Zero(t, result.TotalCount, "keyset pages skip the count")
+		collect(result.Entries)
+		cursor = result.NextCursor
+		assert.Equal(t, result.HasMore, cursor != "")
+	}
+
+	require.Len(t, order, 7)
+	for i := 1; i < len(order); i++ {
+		prev, cur := order[i-1], order[i]
+		assert.True(t, prev.Timestamp.After(cur.Timestamp) ||
+			(prev.Timestamp.Equal(cur.Timestamp) && prev.ID.String() > cur.ID.String()), "descending (timestamp, id)")
+	}
+
+	last := order[len(order)-1]
+	result, err := svc.Query(ctx, models.LogFilter{TenantID: &tenantID, AfterTimestamp: &last.Timestamp, AfterID: &last.ID})
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+	assert.False(t, result.HasMore)
+	assert.Empty(t, result.NextCursor)
+}
+
+// TestTenantSamplingSettings verifies sampling applies only to the configured tenant
+func TestTenantSamplingSettings(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	svc := service.NewLogService(
+		repository.NewLogRepository(db),
+		repository.NewRetentionRepository(db),
+		repository.NewAlertRepository(db),
+		tenants,
+		nil,
+		cfg,
+	)
+	t.Cleanup(svc.Close)
+
+	sampled := uuid.New()
+	unsampled := uuid.New()
+	t.Cleanup(func() {
+		tenants.DeleteSettings(ctx, sampled)
+		db.Where("tenant_id IN ?", []uuid.UUID{sampled, unsampled}).Delete(&models.LogEntry{})
+	})
+
+	// A rate this small keeps effectively nothing below WARN
+	require.NoError(t, tenants.UpsertSettings(ctx, &models.TenantSettings{
+		TenantID:        sampled,
+		SamplingEnabled: true,
+		SampleRate:      1e-9,
+	}))
+
+	for _, tenantID := range []uuid.UUID{sampled, unsampled} {
+		batch := &models.LogBatch{}
+		for i := 0; i < 20; i++ {
+			batch.Entries = append(batch.Entries, models.LogEntry{
+				TenantID: tenantID, ServiceName: "sampling", Level: models.LogLevelInfo, Message: "tick",
+			})
+		}
+		batch.Entries = append(batch.Entries, models.LogEntry{
+			TenantID: tenantID, ServiceName: "sampling", Level: models.LogLevelError, Message: "boom",
+		})
+		_, err := svc.IngestBatch(ctx, batch)
+		require.NoError(t, err)
+	}
+
+	var sampledCount, unsampledCount int64
+	db.Model(&models.LogEntry{}).Where("tenant_id = ?", sampled).Count(&sampledCount)
+	db.Model(&models.LogEntry{}).Where("tenant_id = ?", unsampled).Count(&unsampledCount)
+
+	assert.Equal(t, int64(1), sampledCount, "only the ERROR entry survives sampling")
+	assert.Equal(t, int64(21), unsampledCount)
+}
+
+// TestIngestBatchChunkedProgress verifies progress frames and final totals
+func TestIngestBatchChunkedProgress(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	entries := make([]models.LogEntry, 25)
+	for i := range entries {
+		entries[i] = models.LogEntry{TenantID: tenantID, ServiceName: "chunked", Level: models.LogLevelInfo, Message: "m"}
+	}
+
+	var frames []models.BatchProgress
+	summary := svc.IngestBatchChunked(context.Background(), entries, 10, func(frame models.BatchProgress) {
+		frames = append(frames, frame)
+	})
+
+	require.Len(t, frames, 3)
+	assert.Equal(t, []int{10, 10, 5}, []int{frames[0].Accepted, frames[1].Accepted, frames[2].Accepted})
+	assert.Equal(t, []int{10, 20, 25}, []int{frames[0].AcceptedTotal, frames[1].AcceptedTotal, frames[2].AcceptedTotal})
+	assert.Equal(t, "summary", summary.Type)
+	assert.Equal(t, 25, summary.Accepted)
+	assert.Equal(t, 0, summary.Rejected)
+}
+
+// TestReplayToWebhook verifies replay sends every matching entry in timestamp order
+func TestReplayToWebhook(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var received []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry models.LogEntry
+		json.NewDecoder(r.Body).Decode(&entry)
+		mu.Lock()
+		received = append(received, entry.Message)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Replay.WebhookURLs = []string{webhook.URL}
+
+	logRepo := repository.NewLogRepository(db)
+	svc := service.NewReplayService(logRepo, cfg)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	base := time.Now().UTC().Add(-time.Hour)
+	var entries []models.LogEntry
+	for _, i := range []int{3, 1, 4, 0, 2} {
+		entries = append(entries, models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "replay", Level: models.LogLevelInfo,
+			Message: fmt.Sprintf("msg-%d", i), Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+	require.NoError(t, logRepo.CreateBatch(ctx, entries))
+
+	_, err = svc.StartReplay(models.ReplayRequest{
+		Filter:     models.LogFilter{TenantID: &tenantID},
+		WebhookURL: "http://not-allowed.example",
+	})
+	assert.ErrorIs(t, err, service.ErrWebhookNotAllowed)
+
+	job, err := svc.StartReplay(models.ReplayRequest{
+		Filter:     models.LogFilter{TenantID: &tenantID},
+		WebhookURL: webhook.URL,
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		current, err := svc.GetJob(job.ID, &tenantID)
+		return err == nil && current.Status == models.ReplayStatusCompleted
+	}, 5*time.Second, 20*time.Millisecond)
+
+	current, _ := svc.GetJob(job.ID, &tenantID)
+	assert.Equal(t, int64(5), current.Sent)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"msg-0", "msg-1", "msg-2", "msg-3", "msg-4"}, received)
+
+	t.Run("Other Tenants Cannot See The Job", func(t *testing.T) {
+		otherTenant := uuid.New()
+		_, err := svc.GetJob(job.ID, &otherTenant)
+		assert.ErrorIs(t, err, service.ErrReplayNotFound)
+		_, err = svc.PauseJob(job.ID, &otherTenant)
+		assert.ErrorIs(t, err, service.ErrReplayNotFound)
+		_, err = svc.ResumeJob(job.ID, &otherTenant)
+		assert.ErrorIs(t, err, service.ErrReplayNotFound)
+
+		_, err = svc.GetJob(job.ID, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Finished Jobs Are Evicted", func(t *testing.T) {
+		cfg.Replay.JobRetention = 0
+		_, err := svc.StartReplay(models.ReplayRequest{
+			Filter:     models.LogFilter{TenantID: &tenantID},
+			WebhookURL: webhook.URL,
+		})
+		require.NoError(t, err)
+
+		_, err = svc.GetJob(job.ID, &tenantID)
+		assert.ErrorIs(t, err, service.ErrReplayNotFound)
+	})
+}
+
+// recordingNotifier captures notifications for assertions
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []models.AlertNotification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification models.AlertNotification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *recordingNotifier) ofType(t models.AlertNotificationType) []models.AlertNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var result []models.AlertNotification
+	for _, notification := range n.notifications {
+		if notification.Type == t {
+			result = append(result, notification)
+		}
+	}
+	return result
+}
+
+// failingNotifier rejects every notification, like an unreachable channel
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, notification models.AlertNotification) error {
+	return fmt.Errorf("channel unreachable")
+}
+
+// TestAlertRecoveryNotification verifies fire then clear yields one resolution
+func TestAlertRecoveryNotification(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+
+	tenantID := uuid.New()
+	alertRepo := repository.NewAlertRepository(db)
+	alert := &models.LogAlert{
+		ID:         uuid.New(),
+		TenantID:   tenantID,
+		Name:       "checkout errors",
+		Enabled:    true,
+		Filter:     []byte(`{"service_name":"checkout","level":"ERROR"}`),
+		Threshold:  2,
+		WindowMins: 5,
+		Severity:   "high",
+	}
+	require.NoError(t, alertRepo.Create(ctx, alert))
+	t.Cleanup(func() {
+		alertRepo.Delete(ctx, alert.ID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	batch := &models.LogBatch{Entries: []models.LogEntry{
+		{TenantID: tenantID, ServiceName: "checkout", Level: models.LogLevelError, Message: "card declined"},
+		{TenantID: tenantID, ServiceName: "checkout", Level: models.LogLevelError, Message: "card declined"},
+	}}
+	_, err := svc.IngestBatch(ctx, batch)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(notifier.ofType(models.AlertNotificationFiring)) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// Condition clears once the window no longer holds enough errors
+	db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+
+	require.NoError(t, svc.CheckAlertRecoveries(ctx, time.Now().UTC()))
+	require.NoError(t, svc.CheckAlertRecoveries(ctx, time.Now().UTC()))
+
+	resolved := notifier.ofType(models.AlertNotificationResolved)
+	require.Len(t, resolved, 1)
+	assert.Equal(t, alert.ID, resolved[0].AlertID)
+	assert.NotNil(t, resolved[0].ResolvedAt)
+}
+
+// TestAlertUsesTenantDefaultChannels verifies an alert without channels
+// notifies the tenant's default channels when it fires
+func TestAlertUsesTenantDefaultChannels(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+
+	tenantID := uuid.New()
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertAlertSettings(ctx, &models.TenantAlertSettings{
+		TenantID:        tenantID,
+		DefaultChannels: []byte(`["slack:#oncall"]`),
+	}))
+
+	alertRepo := repository.NewAlertRepository(db)
+	alert := &models.LogAlert{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		Name:      "default channels",
+		Enabled:   true,
+		Filter:    []byte(`{"service_name":"billing","level":"ERROR"}`),
+		Threshold: 1,
+		Severity:  "high",
+	}
+	require.NoError(t, alertRepo.Create(ctx, alert))
+	t.Cleanup(func() {
+		alertRepo.Delete(ctx, alert.ID)
+		tenants.DeleteAlertSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: tenantID, ServiceName: "billing", Level: models.LogLevelError, Message: "invoice failed",
+	}))
+
+	require.Eventually(t, func() bool {
+		return len(notifier.ofType(models.AlertNotificationFiring)) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.JSONEq(t, `["slack:#oncall"]`, string(notifier.ofType(models.AlertNotificationFiring)[0].Channels))
+}
+
+// TestAlertSeverityRouting verifies a fire notifies only the channel kinds
+// its severity routes to
+func TestAlertSeverityRouting(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+
+	tenantID := uuid.New()
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertAlertSettings(ctx, &models.TenantAlertSettings{
+		TenantID:        tenantID,
+		DefaultChannels: []byte(`["pagerduty:payments","slack:#oncall","email:ops@example.com"]`),
+		SeverityRoutes:  []byte(`{"FATAL":["pagerduty"],"ERROR":["slack"],"WARN":["email"]}`),
+	}))
+
+	alertRepo := repository.NewAlertRepository(db)
+	newAlert := func(service, severity string) *models.LogAlert {
+		alert := &models.LogAlert{
+			ID:        uuid.New(),
+			TenantID:  tenantID,
+			Name:      severity + " routing",
+			Enabled:   true,
+			Filter:    []byte(fmt.Sprintf(`{"service_name":%q}`, service)),
+			Threshold: 1,
+			Severity:  severity,
+		}
+		require.NoError(t, alertRepo.Create(ctx, alert))
+		return alert
+	}
+	fatal := newAlert("payments", "fatal")
+	warn := newAlert("mailer", "warn")
+	t.Cleanup(func() {
+		alertRepo.Delete(ctx, fatal.ID)
+		alertRepo.Delete(ctx, warn.ID)
+		tenants.DeleteAlertSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: tenantID, ServiceName: "payments", Level: models.LogLevelFatal, Message: "ledger unavailable",
+	}))
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: tenantID, ServiceName: "mailer", Level: models.LogLevelWarn, Message: "queue backing up",
+	}))
+
+	require.Eventually(t, func() bool {
+		return len(notifier.ofType(models.AlertNotificationFiring)) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+
+	channels := make(map[uuid.UUID]string)
+	for _, n := range notifier.ofType(models.AlertNotificationFiring) {
+		channels[n.AlertID] = string(n.Channels)
+	}
+	assert.JSONEq(t, `["pagerduty:payments"]`, channels[fatal.ID])
+	assert.JSONEq(t, `["email:ops@example.com"]`, channels[warn.ID])
+
+	t.Run("Alert Routes Override Tenant", func(t *testing.T) {
+		alert := models.LogAlert{
+			TenantID:       tenantID,
+			Severity:       "fatal",
+			SeverityRoutes: []byte(`{"fatal":["slack"]}`),
+		}
+		assert.JSONEq(t, `["slack:#oncall"]`, string(tenants.ResolveChannels(ctx, alert)))
+	})
+
+	t.Run("Unrouted Severity Notifies Every Channel", func(t *testing.T) {
+		alert := models.LogAlert{TenantID: tenantID, Severity: "info"}
+		assert.JSONEq(t, `["pagerduty:payments","slack:#oncall","email:ops@example.com"]`, string(tenants.ResolveChannels(ctx, alert)))
+	})
+}
+
+// TestAlertEventRetention verifies old alert events are pruned at the cutoff
+// while recent ones remain
+func TestAlertEventRetention(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Retention.AlertEventDays = 30
+	svc := newTestLogService(t, db, cfg)
+
+	alertID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("alert_id = ?", alertID).Delete(&models.LogAlertEvent{})
+	})
+
+	alertRepo := repository.NewAlertRepository(db)
+	now := time.Now()
+	for _, age := range []time.Duration{31 * 24 * time.Hour, 29 * 24 * time.Hour, time.Hour} {
+		require.NoError(t, alertRepo.CreateEvent(ctx, &models.LogAlertEvent{
+			AlertID:   alertID,
+			Type:      models.AlertNotificationFiring,
+			CreatedAt: now.Add(-age),
+		}))
+	}
+
+	_, err = svc.PruneAlertEvents(ctx, now)
+	require.NoError(t, err)
+
+	events, err := alertRepo.FindEvents(ctx, alertID, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.True(t, event.CreatedAt.After(now.AddDate(0, 0, -30)))
+	}
+}
+
+// TestTimelineOrderingAndLanes verifies a multi-service request is interleaved
+// by timestamp and grouped per service
+func TestTimelineOrderingAndLanes(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	start := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	steps := []struct {
+		service string
+		offset  time.Duration
+	}{
+		{"payments", 120 * time.Millisecond},
+		{"gateway", 0},
+		{"orders", 40 * time.Millisecond},
+		{"gateway", 200 * time.Millisecond},
+		{"orders", 150 * time.Millisecond},
+	}
+	var entries []models.LogEntry
+	for _, step := range steps {
+		entries = append(entries, models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: step.service, Level: models.LogLevelInfo,
+			Message: step.service, RequestID: "timeline-req", Timestamp: start.Add(step.offset),
+		})
+	}
+	require.NoError(t, repository.NewLogRepository(db).CreateBatch(ctx, entries))
+
+	timeline, err := svc.GetTimeline(ctx, models.LogFilter{TenantID: &tenantID, RequestID: "timeline-req"})
+	require.NoError(t, err)
+
+	var offsets []int64
+	for _, entry := range timeline.Entries {
+		offsets = append(offsets, entry.OffsetMs)
+	}
+	assert.Equal(t, []int64{0, 40, 120, 150, 200}, offsets)
+	assert.Equal(t, int64(200), timeline.DurationMs)
+
+	require.Len(t, timeline.Lanes, 3)
+	assert.Equal(t, "gateway", timeline.Lanes[0].ServiceName)
+	assert.Equal(t, "orders", timeline.Lanes[1].ServiceName)
+	assert.Equal(t, "payments", timeline.Lanes[2].ServiceName)
+	assert.Len(t, timeline.Lanes[0].Entries, 2)
+	assert.Equal(t, int64(200), timeline.Lanes[0].LastOffsetMs)
+	assert.Equal(t, int64(40), timeline.Lanes[1].FirstOffsetMs)
+}
+
+// TestAlertDigest verifies several fires within the interval produce one digest
+func TestAlertDigest(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+
+	tenantID := uuid.New()
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertAlertSettings(ctx, &models.TenantAlertSettings{
+		TenantID:           tenantID,
+		DigestEnabled:      true,
+		DigestIntervalMins: 10,
+	}))
+
+	alertRepo := repository.NewAlertRepository(db)
+	var alertIDs []uuid.UUID
+	for _, service := range []string{"search", "checkout"} {
+		alert := &models.LogAlert{
+			ID:        uuid.New(),
+			TenantID:  tenantID,
+			Name:      service + " errors",
+			Enabled:   true,
+			Filter:    []byte(fmt.Sprintf(`{"service_name":%q,"level":"ERROR"}`, service)),
+			Threshold: 1,
+			Severity:  "high",
+		}
+		require.NoError(t, alertRepo.Create(ctx, alert))
+		alertIDs = append(alertIDs, alert.ID)
+	}
+	t.Cleanup(func() {
+		for _, id := range alertIDs {
+			alertRepo.Delete(ctx, id)
+		}
+		tenants.DeleteAlertSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogAlertEvent{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	_, err := svc.IngestBatch(ctx, &models.LogBatch{Entries: []models.LogEntry{
+		{TenantID: tenantID, ServiceName: "search", Level: models.LogLevelError, Message: "index down"},
+		{TenantID: tenantID, ServiceName: "checkout", Level: models.LogLevelError, Message: "card declined"},
+	}})
+	require.NoError(t, err)
+
+	// Fires are recorded but not sent individually
+	require.Eventually(t, func() bool {
+		var events int64
+		db.Model(&models.LogAlertEvent{}).Where("tenant_id = ?", tenantID).Count(&events)
+		return events == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Empty(t, notifier.ofType(models.AlertNotificationFiring))
+
+	svc.FlushAlertDigests(ctx, time.Now().UTC())
+	assert.Empty(t, notifier.ofType(models.AlertNotificationDigest), "interval has not elapsed")
+
+	svc.FlushAlertDigests(ctx, time.Now().UTC().Add(11*time.Minute))
+	digests := notifier.ofType(models.AlertNotificationDigest)
+	require.Len(t, digests, 1)
+	assert.Len(t, digests[0].Digest, 2)
+	assert.Equal(t, int64(2), digests[0].Count)
+}
+
+// TestSelfTestReportsSteps verifies each self-test step is reported and the
+// synthetic entry is removed
+func TestSelfTestReportsSteps(t *testing.T) {
+	db := newTestDB(t)
+	svc := newTestLogService(t, db, nil)
+
+	result := svc.SelfTest(context.Background())
+	require.True(t, result.Passed, "%+v", result.Steps)
+
+	steps := make(map[string]models.SelfTestStep)
+	var names []string
+	for _, step := range result.Steps {
+		steps[step.Name] = step
+		names = append(names, step.Name)
+	}
+	assert.Equal(t, []string{"write", "read", "cache", "delete"}, names)
+	assert.True(t, steps["write"].Passed)
+	assert.True(t, steps["read"].Passed)
+	assert.True(t, steps["cache"].Skipped, "no Redis in tests")
+	assert.True(t, steps["delete"].Passed)
+
+	var remaining int64
+	db.Model(&models.LogEntry{}).Where("tenant_id = ?", service.SelfTestTenantID).Count(&remaining)
+	assert.Zero(t, remaining)
+}
+
+// TestFilterPresets verifies each preset expands to its filter and returns
+// the matching entries
+func TestFilterPresets(t *testing.T) {
+	tenantID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	hourAgo := now.Add(-time.Hour)
+	quarterAgo := now.Add(-15 * time.Minute)
+
+	t.Run("Filters", func(t *testing.T) {
+		filter, err := service.PresetFilter("errors-last-hour", &tenantID, now, service.PresetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, models.LogFilter{
+			TenantID: &tenantID, MinLevel: models.LogLevelError, StartTime: &hourAgo, EndTime: &now,
+		}, filter)
+
+		filter, err = service.PresetFilter("slow-requests", &tenantID, now, service.PresetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, models.LogFilter{
+			TenantID: &tenantID, MinLatencyMs: service.DefaultSlowRequestMs, StartTime: &hourAgo, EndTime: &now,
+		}, filter)
+
+		filter, err = service.PresetFilter("recent-by-service", &tenantID, now, service.PresetOptions{ServiceName: "api"})
+		require.NoError(t, err)
+		assert.Equal(t, models.LogFilter{
+			TenantID: &tenantID, ServiceName: "api", StartTime: &quarterAgo, EndTime: &now,
+		}, filter)
+
+		_, err = service.PresetFilter("recent-by-service", &tenantID, now, service.PresetOptions{})
+		assert.ErrorIs(t, err, service.ErrPresetParam)
+		_, err = service.PresetFilter("nope", &tenantID, now, service.PresetOptions{})
+		assert.ErrorIs(t, err, service.ErrUnknownPreset)
+	})
+
+	t.Run("Results", func(t *testing.T) {
+		db := newTestDB(t)
+		ctx := context.Background()
+		svc := newTestLogService(t, db, nil)
+		t.Cleanup(func() {
+			db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		})
+
+		entry := func(service string, level models.LogLevel, msg string, age time.Duration, metadata string) models.LogEntry {
+			e := models.LogEntry{
+				ID: uuid.New(), TenantID: tenantID, ServiceName: service, Level: level,
+				Message: msg, Timestamp: now.Add(-age),
+			}
+			if metadata != "" {
+				e.Metadata = json.RawMessage(metadata)
+			}
+			return e
+		}
+		require.NoError(t, repository.NewLogRepository(db).CreateBatch(ctx, []models.LogEntry{
+			entry("api", models.LogLevelError, "recent error", 10*time.Minute, ""),
+			entry("api", models.LogLevelError, "old error", 2*time.Hour, ""),
+			entry("api", models.LogLevelInfo, "slow", 20*time.Minute, `{"latency_ms": 2500}`),
+			entry("api", models.LogLevelInfo, "fast", 5*time.Minute, `{"latency_ms": 12}`),
+			entry("web", models.LogLevelInfo, "not numeric", 5*time.Minute, `{"latency_ms": "slow"}`),
+		}))
+
+		messages := func(name string, opts service.PresetOptions) []string {
+			filter, err := service.PresetFilter(name, &tenantID, time.Now(), opts)
+			require.NoError(t, err)
+			result, err := svc.Query(ctx, filter)
+			require.NoError(t, err)
+			var out []string
+			for _, e := range result.Entries {
+				out = append(out, e.Message)
+			}
+			return out
+		}
+
+		assert.Equal(t, []string{"recent error"}, messages("errors-last-hour", service.PresetOptions{}))
+		assert.Equal(t, []string{"slow"}, messages("slow-requests", service.PresetOptions{}))
+		assert.Equal(t, []string{"fast", "recent error"}, messages("recent-by-service", service.PresetOptions{ServiceName: "api"}))
+	})
+}
+
+// TestMaxAgeRejection verifies entries older than their tenant's 30-day
+// retention are rejected in reject mode while recent ones are stored
+func TestMaxAgeRejection(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.MaxAgeMode = service.MaxAgeModeReject
+	cfg.Retention.RetentionDays = 365
+
+	tenantID := uuid.New()
+	retentionRepo := repository.NewRetentionRepository(db)
+	require.NoError(t, retentionRepo.Upsert(ctx, &models.LogRetention{TenantID: tenantID, RetentionDays: 30}))
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogRetention{})
+	})
+	svc := newTestLogService(t, db, cfg)
+
+	old := &models.LogEntry{
+		TenantID: tenantID, ServiceName: "max-age", Level: models.LogLevelInfo,
+		Message: "backdated", Timestamp: time.Now().AddDate(0, 0, -45),
+	}
+	err = svc.IngestSingle(ctx, old)
+	assert.ErrorIs(t, err, service.ErrEntryTooOld)
+	assert.Contains(t, err.Error(), "30-day retention")
+
+	recent := &models.LogEntry{
+		TenantID: tenantID, ServiceName: "max-age", Level: models.LogLevelInfo,
+		Message: "last week", Timestamp: time.Now().AddDate(0, 0, -7),
+	}
+	require.NoError(t, svc.IngestSingle(ctx, recent))
+
+	_, err = svc.IngestBatch(ctx, &models.LogBatch{Entries: []models.LogEntry{*recent, *old}})
+	assert.ErrorIs(t, err, service.ErrEntryTooOld)
+	assert.Contains(t, err.Error(), "entries[1]")
+
+	t.Run("Accept Mode Stores With Warning", func(t *testing.T) {
+		cfg.Ingestion.MaxAgeMode = service.MaxAgeModeAccept
+		assert.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+			TenantID: tenantID, ServiceName: "max-age", Level: models.LogLevelInfo,
+			Message: "backdated accepted", Timestamp: time.Now().AddDate(0, 0, -45),
+		}))
+	})
+}
+
+// TestFutureTimestampClamping verifies far-future timestamps are clamped to
+// the ingestion time with the original kept in metadata, or rejected
+func TestFutureTimestampClamping(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.MaxFutureSkew = 5 * time.Minute
+	cfg.Ingestion.FutureMode = service.FutureModeClamp
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	svc := newTestLogService(t, db, cfg)
+
+	future := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Millisecond)
+	clamped := &models.LogEntry{
+		TenantID: tenantID, ServiceName: "skewed", Level: models.LogLevelInfo,
+		Message: "bad clock", Timestamp: future, Metadata: []byte(`{"host_clock":"drifting"}`),
+	}
+	before := time.Now()
+	require.NoError(t, svc.IngestSingle(ctx, clamped))
+
+	var stored models.LogEntry
+	require.NoError(t, db.First(&stored, "id = ?", clamped.ID).Error)
+	assert.WithinDuration(t, before, stored.Timestamp, 5*time.Second)
+
+	var metadata map[string]string
+	require.NoError(t, json.Unmarshal(stored.Metadata, &metadata))
+	original, err := time.Parse(time.RFC3339Nano, metadata[service.OriginalTimestampKey])
+	require.NoError(t, err)
+	assert.True(t, original.Equal(future))
+	assert.Equal(t, "drifting", metadata["host_clock"])
+
+	t.Run("Within Skew Is Kept", func(t *testing.T) {
+		near := time.Now().UTC().Add(time.Minute).Truncate(time.Millisecond)
+		entry := &models.LogEntry{
+			TenantID: tenantID, ServiceName: "skewed", Level: models.LogLevelInfo, Message: "slightly ahead", Timestamp: near,
+		}
+		require.NoError(t, svc.IngestSingle(ctx, entry))
+		assert.True(t, entry.Timestamp.Equal(near))
+		assert.NotContains(t, string(entry.Metadata), service.OriginalTimestampKey)
+	})
+
+	t.Run("Reject Mode", func(t *testing.T) {
+		cfg.Ingestion.FutureMode = service.FutureModeReject
+		ok := models.LogEntry{TenantID: tenantID, ServiceName: "skewed", Level: models.LogLevelInfo, Message: "now"}
+		ahead := models.LogEntry{TenantID: tenantID, ServiceName: "skewed", Level: models.LogLevelInfo, Message: "ahead", Timestamp: future}
+		_, err := svc.IngestBatch(ctx, &models.LogBatch{Entries: []models.LogEntry{ok, ahead}})
+		assert.ErrorIs(t, err, service.ErrEntryInFuture)
+		assert.Contains(t, err.Error(), "entries[1]")
+	})
+}
+
+// TestCleanupAnalyzesAfterLargeDelete verifies log_entries is analyzed after
+// a cleanup deleting at least the threshold of rows, and not after smaller ones
+func TestCleanupAnalyzesAfterLargeDelete(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Retention.AnalyzeThreshold = 3
+	svc := newTestLogService(t, db, cfg)
+
+	tenantID := uuid.New()
+	repo := repository.NewLogRepository(db)
+	require.NoError(t, repository.NewRetentionRepository(db).Upsert(ctx, &models.LogRetention{TenantID: tenantID, RetentionDays: 30}))
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogRetention{})
+	})
+
+	seedExpired := func(n int) {
+		entries := make([]models.LogEntry, n)
+		for i := range entries {
+			entries[i] = models.LogEntry{
+				ID: uuid.New(), TenantID: tenantID, ServiceName: "analyze", Level: models.LogLevelInfo,
+				Message: "expired", Timestamp: time.Now().AddDate(0, 0, -45),
+			}
+		}
+		require.NoError(t, repo.CreateBatch(ctx, entries))
+	}
+	// Manual ANALYZE runs are counted apart from autovacuum's
+	analyzeCount := func() int64 {
+		var n int64
+		require.NoError(t, db.Raw("SELECT analyze_count FROM pg_stat_user_tables WHERE relname = 'log_entries'").Scan(&n).Error)
+		return n
+	}
+
+	// Clear rows other tests left behind so only this test's deletes count
+	require.NoError(t, svc.Cleanup(ctx))
+
+	seedExpired(2)
+	before := analyzeCount()
+	require.NoError(t, svc.Cleanup(ctx))
+	assert.Never(t, func() bool { return analyzeCount() > before }, 1500*time.Millisecond, 100*time.Millisecond,
+		"a cleanup below the threshold does not analyze")
+
+	seedExpired(3)
+	require.NoError(t, svc.Cleanup(ctx))
+	assert.Eventually(t, func() bool { return analyzeCount() > before }, 5*time.Second, 100*time.Millisecond,
+		"a cleanup reaching the threshold analyzes log_entries")
+
+	t.Run("Vacuum", func(t *testing.T) {
+		cfg.Retention.VacuumAfterCleanup = true
+		t.Cleanup(func() { cfg.Retention.VacuumAfterCleanup = false })
+
+		var vacuums int64
+		vacuumCount := func() int64 {
+			require.NoError(t, db.Raw("SELECT vacuum_count FROM pg_stat_user_tables WHERE relname = 'log_entries'").Scan(&vacuums).Error)
+			return vacuums
+		}
+		beforeVacuum := vacuumCount()
+		seedExpired(3)
+		require.NoError(t, svc.Cleanup(ctx))
+		assert.Eventually(t, func() bool { return vacuumCount() > beforeVacuum }, 5*time.Second, 100*time.Millisecond)
+	})
+}
+
+// TestMaintenanceModePausesCleanup verifies Cleanup is a no-op while
+// maintenance mode is on and deletes expired entries once it is cleared
+func TestMaintenanceModePausesCleanup(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+
+	tenantID := uuid.New()
+	require.NoError(t, repository.NewRetentionRepository(db).Upsert(ctx, &models.LogRetention{TenantID: tenantID, RetentionDays: 30}))
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogRetention{})
+	})
+
+	expired := models.LogEntry{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "maintenance", Level: models.LogLevelInfo,
+		Message: "expired", Timestamp: time.Now().AddDate(0, 0, -45),
+	}
+	require.NoError(t, repository.NewLogRepository(db).Create(ctx, &expired))
+
+	count := func() int64 {
+		var n int64
+		require.NoError(t, db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&n).Error)
+		return n
+	}
+
+	status := svc.SetMaintenanceMode(true, "schema migration")
+	assert.True(t, status.Enabled)
+	require.NotNil(t, status.Since)
+	assert.True(t, svc.InMaintenance())
+
+	require.NoError(t, svc.Cleanup(ctx))
+	assert.Equal(t, int64(1), count(), "cleanup must not delete in maintenance mode")
+
+	// Ingestion continues during maintenance
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: tenantID, ServiceName: "maintenance", Level: models.LogLevelInfo, Message: "fresh",
+	}))
+	assert.Equal(t, int64(2), count())
+
+	assert.False(t, svc.SetMaintenanceMode(false, "").Enabled)
+	require.NoError(t, svc.Cleanup(ctx))
+	assert.Equal(t, int64(1), count(), "cleanup resumes once maintenance is cleared")
+}
+
+// TestScheduledAlertEvaluation verifies the scheduler fires an alert whose
+// logs arrived without triggering ingestion, and notifies only once
+func TestScheduledAlertEvaluation(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	svc := newTestLogService(t, db, cfg)
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+
+	tenantID := uuid.New()
+	alertRepo := repository.NewAlertRepository(db)
+	alert := &models.LogAlert{
+		ID:         uuid.New(),
+		TenantID:   tenantID,
+		Name:       "slow errors",
+		Enabled:    true,
+		Filter:     []byte(`{"service_name":"scheduled","level":"ERROR"}`),
+		Threshold:  3,
+		WindowMins: 10,
+	}
+	require.NoError(t, alertRepo.Create(ctx, alert))
+	t.Cleanup(func() {
+		alertRepo.Delete(ctx, alert.ID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.AlertOutboxItem{})
+	})
+
+	// Written straight to the repository so no ingestion path evaluates them
+	now := time.Now().UTC()
+	logRepo := repository.NewLogRepository(db)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, logRepo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "scheduled", Level: models.LogLevelError,
+			Message: "slow", Timestamp: now.Add(-time.Duration(i+1) * time.Minute),
+		}))
+	}
+	assert.Empty(t, notifier.ofType(models.AlertNotificationFiring))
+
+	require.NoError(t, svc.EvaluateAlerts(ctx, now))
+	fired := notifier.ofType(models.AlertNotificationFiring)
+	require.Len(t, fired, 1)
+	assert.Equal(t, alert.ID, fired[0].AlertID)
+	assert.Equal(t, int64(3), fired[0].Count)
+
+	// Already firing: neither the scheduler nor ingestion notifies again
+	require.NoError(t, svc.EvaluateAlerts(ctx, now.Add(time.Minute)))
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: tenantID, ServiceName: "scheduled", Level: models.LogLevelError, Message: "more",
+	}))
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, notifier.ofType(models.AlertNotificationFiring), 1)
+}
+
+// TestVolumeForecast verifies a linearly growing tenant's volume is projected
+// along its trend
+func TestVolumeForecast(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	svc := newTestLogService(t, db, cfg)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	// 10 days growing by 5 entries a day: 10, 15, ..., 55
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	var entries []models.LogEntry
+	for day := 0; day < 10; day++ {
+		date := today.AddDate(0, 0, day-10)
+		for i := 0; i < 10+5*day; i++ {
+			entries = append(entries, models.LogEntry{
+				ID: uuid.New(), TenantID: tenantID, ServiceName: "forecast", Level: models.LogLevelInfo,
+				Message: "tick", Timestamp: date.Add(time.Duration(i) * time.Minute),
+			})
+		}
+	}
+	require.NoError(t, repository.NewLogRepository(db).CreateBatch(ctx, entries))
+
+	forecast, err := svc.ForecastVolume(ctx, &tenantID, 10, 3, now)
+	require.NoError(t, err)
+
+	require.Len(t, forecast.History, 10)
+	assert.Equal(t, int64(10), forecast.History[0].Count)
+	assert.Equal(t, int64(55), forecast.History[9].Count)
+	assert.InDelta(t, 5, forecast.SlopePerDay, 0.01)
+
+	require.Len(t, forecast.Forecast, 3)
+	for i, day := range forecast.Forecast {
+		assert.Equal(t, today.AddDate(0, 0, i), day.Date)
+		assert.InDelta(t, 60+5*i, day.Count, 1)
+		assert.Equal(t, day.Count*forecast.AvgEntryBytes, day.Bytes)
+	}
+}
+
+// TestIngestBatchCollapsesDuplicateIDs verifies a batch repeating a
+// client-supplied ID is stored instead of failing on the primary key
+func TestIngestBatchCollapsesDuplicateIDs(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.DuplicateIDKeep = service.DuplicateIDKeepLast
+	svc := newTestLogService(t, db, cfg)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	dup := uuid.New()
+	batch := &models.LogBatch{Entries: []models.LogEntry{
+		{ID: dup, TenantID: tenantID, ServiceName: "dup", Level: models.LogLevelInfo, Message: "first"},
+		{TenantID: tenantID, ServiceName: "dup", Level: models.LogLevelInfo, Message: "unrelated"},
+		{ID: dup, TenantID: tenantID, ServiceName: "dup", Level: models.LogLevelInfo, Message: "retried"},
+	}}
+	_, err = svc.IngestBatch(ctx, batch)
+	require.NoError(t, err)
+	assert.Equal(t, 1, batch.DuplicatesCollapsed)
+	assert.Len(t, batch.Entries, 2)
+
+	var stored models.LogEntry
+	require.NoError(t, db.First(&stored, "id = ?", dup).Error)
+	assert.Equal(t, "retried", stored.Message)
+
+	var count int64
+	require.NoError(t, db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+// TestAlertEnvironmentScope verifies an environment-scoped alert neither
+// matches nor counts logs from other environments
+func TestAlertEnvironmentScope(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	svc := newTestLogService(t, db, nil)
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+
+	tenantID := uuid.New()
+	alertRepo := repository.NewAlertRepository(db)
+	alert := &models.LogAlert{
+		ID:         uuid.New(),
+		TenantID:   tenantID,
+		Name:       "production errors",
+		Enabled:    true,
+		Filter:     []byte(`{"service_name":"checkout","level":"ERROR","environment":"production"}`),
+		Threshold:  2,
+		WindowMins: 10,
+	}
+	require.NoError(t, alertRepo.Create(ctx, alert))
+	t.Cleanup(func() {
+		alertRepo.Delete(ctx, alert.ID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.AlertOutboxItem{})
+	})
+
+	ingest := func(environment string, n int) {
+		for i := 0; i < n; i++ {
+			require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+				TenantID: tenantID, ServiceName: "checkout", Level: models.LogLevelError,
+				Environment: environment, Message: environment + " failure",
+			}))
+		}
+	}
+
+	ingest("staging", 3)
+	ingest("production", 1)
+	require.NoError(t, svc.EvaluateAlerts(ctx, time.Now().UTC()))
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, notifier.ofType(models.AlertNotificationFiring), "staging errors are not counted")
+
+	ingest("production", 1)
+	require.Eventually(t, func() bool {
+		return len(notifier.ofType(models.AlertNotificationFiring)) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	fired := notifier.ofType(models.AlertNotificationFiring)[0]
+	assert.Equal(t, int64(2), fired.Count)
+	assert.Equal(t, "production failure", fired.Message)
+}
+
+// TestAlertOutboxSurvivesRestart verifies a notification that failed to send
+// is delivered by a fresh service instance draining the persisted outbox
+func TestAlertOutboxSurvivesRestart(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Alert.OutboxRetryBackoff = time.Minute
+	cfg.Alert.OutboxMaxAttempts = 5
+
+	before := newTestLogService(t, db, cfg)
+	before.SetNotifier(failingNotifier{})
+
+	tenantID := uuid.New()
+	alertRepo := repository.NewAlertRepository(db)
+	alert := &models.LogAlert{
+		ID:         uuid.New(),
+		TenantID:   tenantID,
+		Name:       "outbox errors",
+		Enabled:    true,
+		Filter:     []byte(`{"service_name":"outbox","level":"ERROR"}`),
+		Threshold:  1,
+		WindowMins: 5,
+	}
+	require.NoError(t, alertRepo.Create(ctx, alert))
+	t.Cleanup(func() {
+		alertRepo.Delete(ctx, alert.ID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.AlertOutboxItem{})
+	})
+
+	require.NoError(t, before.IngestSingle(ctx, &models.LogEntry{
+		TenantID: tenantID, ServiceName: "outbox", Level: models.LogLevelError, Message: "boom",
+	}))
+
+	pending := func() []models.AlertOutboxItem {
+		var items []models.AlertOutboxItem
+		require.NoError(t, db.Where("tenant_id = ? AND sent_at IS NULL", tenantID).Find(&items).Error)
+		return items
+	}
+	require.Eventually(t, func() bool {
+		items := pending()
+		return len(items) == 1 && items[0].Attempts == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "channel unreachable", pending()[0].LastError)
+
+	// Simulated restart: a new instance with a working channel
+	before.Close()
+	after := newTestLogService(t, db, cfg)
+	notifier := &recordingNotifier{}
+	after.SetNotifier(notifier)
+
+	sent, err := after.DrainAlertOutbox(ctx, time.Now().UTC())
+	require.NoError(t, err)
+	assert.Zero(t, sent, "retry is not due before the backoff elapses")
+
+	sent, err = after.DrainAlertOutbox(ctx, time.Now().UTC().Add(5*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Empty(t, pending())
+
+	fired := notifier.ofType(models.AlertNotificationFiring)
+	require.Len(t, fired, 1)
+	assert.Equal(t, alert.ID, fired[0].AlertID)
+
+	sent, err = after.DrainAlertOutbox(ctx, time.Now().UTC().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, sent, "delivered notifications are not resent")
+}
+
+// TestTraceIDNormalization verifies entries ingested with differently
+// formatted trace IDs are all found by any formatting of the canonical ID
+func TestTraceIDNormalization(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.TraceIDFormat = service.TraceIDFormatHex
+	svc := newTestLogService(t, db, cfg)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	traceID := uuid.New()
+	canonical := strings.ReplaceAll(traceID.String(), "-", "")
+	formats := []string{canonical, strings.ToUpper(canonical), traceID.String(), strings.ToUpper(traceID.String())}
+
+	var entries []models.LogEntry
+	for _, format := range formats {
+		entries = append(entries, models.LogEntry{
+			TenantID: tenantID, ServiceName: "tracing", Level: models.LogLevelInfo,
+			Message: format, TraceID: format, SpanID: "00F067AA0BA902B7",
+		})
+	}
+	_, err = svc.IngestBatch(ctx, &models.LogBatch{Entries: entries})
+	require.NoError(t, err)
+
+	for _, query := range formats {
+		found, err := svc.GetByTraceID(ctx, query)
+		require.NoError(t, err)
+		require.Len(t, found, len(formats), query)
+		for _, entry := range found {
+			assert.Equal(t, canonical, entry.TraceID)
+			assert.Equal(t, "00f067aa0ba902b7", entry.SpanID)
+		}
+	}
+
+	result, err := svc.Query(ctx, models.LogFilter{TenantID: &tenantID, TraceID: traceID.String()})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(formats)), result.TotalCount)
+}
+
+// TestIngestionStageToggle verifies the service pipeline follows the
+// configured stages and that a disabled redaction stage stores entries as sent
+func TestIngestionStageToggle(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.PipelineStages = nil
+	cfg.Ingestion.PipelineDisabled = nil
+	redacting := newTestLogService(t, db, cfg)
+	assert.Equal(t, []string{
+		service.StageNormalize, service.StageParse, service.StageMetadataKeys, service.StageRedact, service.StageTruncate,
+		service.StageRoute, service.StageValidate, service.StageServiceCap, service.StageSample, service.StageDampen, service.StageDedup, service.StageCount, service.StageEnrich,
+	}, redacting.IngestionStages())
+
+	disabledCfg := *cfg
+	disabledCfg.Ingestion.PipelineDisabled = []string{service.StageRedact}
+	raw := newTestLogService(t, db, &disabledCfg)
+	assert.NotContains(t, raw.IngestionStages(), service.StageRedact)
+
+	tenantID := uuid.New()
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertSettings(ctx, &models.TenantSettings{TenantID: tenantID, RedactionEnabled: true}))
+	t.Cleanup(func() {
+		tenants.DeleteSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	redacted := &models.LogEntry{TenantID: tenantID, ServiceName: "auth", Level: models.LogLevelInfo, Message: "login password=hunter2"}
+	require.NoError(t, redacting.IngestSingle(ctx, redacted))
+	assert.NotContains(t, redacted.Message, "hunter2")
+
+	kept := &models.LogEntry{TenantID: tenantID, ServiceName: "auth", Level: models.LogLevelInfo, Message: "login password=hunter2"}
+	require.NoError(t, raw.IngestSingle(ctx, kept))
+	assert.Contains(t, kept.Message, "hunter2")
+}
+
+// TestRedactionCoversPreservedFullMessage verifies a truncated entry's
+// preserved full message is stored redacted
+func TestRedactionCoversPreservedFullMessage(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.PipelineStages = nil
+	cfg.Ingestion.PipelineDisabled = nil
+	cfg.Ingestion.MaxMessageLength = 32
+	cfg.Ingestion.PreserveFullMessage = true
+	svc := newTestLogService(t, db, cfg)
+
+	tenantID := uuid.New()
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertSettings(ctx, &models.TenantSettings{TenantID: tenantID, RedactionEnabled: true}))
+	t.Cleanup(func() {
+		tenants.DeleteSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	entry := &models.LogEntry{
+		TenantID: tenantID, ServiceName: "auth", Level: models.LogLevelInfo,
+		Message: strings.Repeat("retrying login ", 10) + "password=hunter2",
+	}
+	require.NoError(t, svc.IngestSingle(ctx, entry))
+	assert.Contains(t, entry.Message, "[truncated")
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(entry.Metadata, &metadata))
+	encoded, _ := metadata[service.FullMessageKey].(string)
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	full, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(full), "password=")
+	assert.NotContains(t, string(full), "hunter2")
+}
+
+// TestTenantEnrichmentWebhook verifies a tenant's enrichment webhook adds
+// metadata to stored entries while other tenants are not enriched
+func TestTenantEnrichmentWebhook(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"region":"eu-west"}`))
+	}))
+	defer mock.Close()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.EnrichmentURLs = []string{mock.URL}
+	svc := newTestLogService(t, db, cfg)
+
+	enriched, plain := uuid.New(), uuid.New()
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertSettings(ctx, &models.TenantSettings{TenantID: enriched, EnrichmentURL: mock.URL}))
+	t.Cleanup(func() {
+		tenants.DeleteSettings(ctx, enriched)
+		db.Where("tenant_id IN ?", []uuid.UUID{enriched, plain}).Delete(&models.LogEntry{})
+	})
+
+	_, err = svc.IngestBatch(ctx, &models.LogBatch{Entries: []models.LogEntry{
+		{TenantID: enriched, ServiceName: "orders", Level: models.LogLevelInfo, Message: "placed"},
+		{TenantID: plain, ServiceName: "orders", Level: models.LogLevelInfo, Message: "placed"},
+	}})
+	require.NoError(t, err)
+
+	var stored models.LogEntry
+	require.NoError(t, db.First(&stored, "tenant_id = ?", enriched).Error)
+	assert.JSONEq(t, `{"region":"eu-west"}`, string(stored.Metadata))
+
+	var unenriched models.LogEntry
+	require.NoError(t, db.First(&unenriched, "tenant_id = ?", plain).Error)
+	assert.Empty(t, unenriched.Metadata)
+}
+
+// TestQueryServesStaleResultOnDBError verifies a query failing at the
+// database is answered with its last result, flagged stale, when enabled
+func TestQueryServesStaleResultOnDBError(t *testing.T) {
+	db := newTestDB(t)
+	// The services get their own connection so the outage spares cleanup
+	outage := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Postgres.ServeStaleOnError = true
+	svc := newTestLogService(t, outage, cfg)
+
+	strictCfg := *cfg
+	strictCfg.Postgres.ServeStaleOnError = false
+	strict := newTestLogService(t, outage, &strictCfg)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	require.NoError(t, repository.NewLogRepository(db).Create(ctx, &models.LogEntry{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "dashboard", Level: models.LogLevelInfo,
+		Message: "cached", Timestamp: time.Now(),
+	}))
+
+	filter := models.LogFilter{TenantID: &tenantID, PageSize: 10}
+	fresh, err := svc.Query(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, fresh.Entries, 1)
+	assert.False(t, fresh.Stale)
+
+	_, err = strict.Query(ctx, filter)
+	require.NoError(t, err)
+
+	sqlDB, err := outage.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	stale, err := svc.Query(ctx, filter)
+	require.NoError(t, err)
+	assert.True(t, stale.Stale)
+	assert.Equal(t, fresh.TotalCount, stale.TotalCount)
+	require.Len(t, stale.Entries, 1)
+	assert.Equal(t, "cached", stale.Entries[0].Message)
+
+	_, err = strict.Query(ctx, filter)
+	assert.Error(t, err, "stale serving is off when disabled")
+
+	_, err = svc.Query(ctx, models.LogFilter{TenantID: &tenantID, PageSize: 5})
+	assert.Error(t, err, "queries never answered before have nothing to serve")
+}
+
+// TestHistogram verifies bucket counts of a numeric metadata field for a
+// known distribution, with out-of-range and non-numeric values kept apart
+func TestHistogram(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	svc := newTestLogService(t, db, nil)
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	// duration_ms 0..99 puts 10 entries in each of ten 10ms buckets
+	var entries []models.LogEntry
+	entry := func(metadata string) models.LogEntry {
+		e := models.LogEntry{
+			ID: uuid.New(), TenantID: tenantID, ServiceName: "histogram", Level: models.LogLevelInfo,
+			Message: "request", Timestamp: time.Now().UTC(),
+		}
+		if metadata != "" {
+			e.Metadata = json.RawMessage(metadata)
+		}
+		return e
+	}
+	for i := 0; i < 100; i++ {
+		entries = append(entries, entry(fmt.Sprintf(`{"duration_ms": %d}`, i)))
+	}
+	entries = append(entries,
+		entry(`{"duration_ms": -5}`),
+		entry(`{"duration_ms": 100}`),
+		entry(`{"duration_ms": 2500.5}`),
+		entry(`{"duration_ms": "slow"}`),
+		entry(`{"status": 200}`),
+		entry(""),
+	)
+	require.NoError(t, repository.NewLogRepository(db).CreateBatch(ctx, entries))
+
+	filter := models.LogFilter{TenantID: &tenantID}
+	histogram, err := svc.Histogram(ctx, filter, "duration_ms", 0, 100, 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, "duration_ms", histogram.Field)
+	require.Len(t, histogram.Buckets, 10)
+	for i, bucket := range histogram.Buckets {
+		assert.Equal(t, float64(i*10), bucket.Min)
+		assert.Equal(t, float64(i*10+10), bucket.Max)
+		assert.Equal(t, int64(10), bucket.Count, "bucket %d", i)
+	}
+	assert.Equal(t, int64(1), histogram.Underflow)
+	assert.Equal(t, int64(2), histogram.Overflow)
+	assert.Equal(t, int64(3), histogram.Missing)
+
+	t.Run("Respects The Filter", func(t *testing.T) {
+		filter := models.LogFilter{TenantID: &tenantID, ServiceName: "other"}
+		histogram, err := svc.Histogram(ctx, filter, "duration_ms", 0, 100, 4)
+		require.NoError(t, err)
+		require.Len(t, histogram.Buckets, 4)
+		for _, bucket := range histogram.Buckets {
+			assert.Zero(t, bucket.Count)
+		}
+		assert.Zero(t, histogram.Missing)
+	})
+
+	t.Run("Rejects An Invalid Range", func(t *testing.T) {
+		_, err := svc.Histogram(ctx, filter, "duration_ms", 100, 100, 10)
+		assert.ErrorIs(t, err, service.ErrInvalidHistogram)
+
+		_, err = svc.Histogram(ctx, filter, "", 0, 100, 10)
+		assert.ErrorIs(t, err, service.ErrInvalidHistogram)
+
+		_, err = svc.Histogram(ctx, filter, "duration_ms", 0, 100, 1000)
+		assert.ErrorIs(t, err, service.ErrInvalidHistogram)
+	})
+}
+
+// TestWriteAheadLogReplayAfterCrash verifies async entries acknowledged with
+// the write-ahead log enabled are stored after a crash loses the buffer
+func TestWriteAheadLogReplayAfterCrash(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.WALEnabled = true
+	cfg.Ingestion.WALDir = t.TempDir()
+	cfg.Ingestion.FlushLevels = nil
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	// The crashed service is never closed before the restart; its buffer is
+	// only released at cleanup, where its duplicate flush is rejected
+	crashed := newTestLogService(t, db, cfg)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, crashed.BufferLog(models.LogEntry{
+			TenantID: tenantID, ServiceName: "wal", Level: models.LogLevelInfo, Message: fmt.Sprintf("entry %d", i),
+		}))
+	}
+
+	var count int64
+	db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&count)
+	require.Zero(t, count, "entries are still buffered")
+
+	restarted := newTestLogService(t, db, cfg)
+	replayed, err := restarted.ReplayWAL(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, replayed)
+
+	db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	// Replayed segments are removed
+	replayed, err = newTestLogService(t, db, cfg).ReplayWAL(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, replayed)
+}
+
+// TestNewErrorAlert verifies a new_error alert fires for the first occurrence
+// of an error fingerprint and not for recurrences within its lookback
+func TestNewErrorAlert(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	svc := newTestLogService(t, db, nil)
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+
+	tenantID := uuid.New()
+	alertRepo := repository.NewAlertRepository(db)
+	alert := &models.LogAlert{
+		ID:           uuid.New(),
+		TenantID:     tenantID,
+		Name:         "new checkout errors",
+		Type:         models.AlertTypeNewError,
+		Enabled:      true,
+		Filter:       []byte(`{"service_name":"checkout","level":"ERROR"}`),
+		LookbackMins: 60,
+	}
+	require.NoError(t, alertRepo.Create(ctx, alert))
+	t.Cleanup(func() {
+		alertRepo.Delete(ctx, alert.ID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.ErrorFingerprint{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.AlertOutboxItem{})
+	})
+
+	ingest := func(message string) {
+		require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+			TenantID: tenantID, ServiceName: "checkout", Level: models.LogLevelError, Message: message,
+		}))
+	}
+
+	ingest("connection refused to 10.0.0.1:5432")
+	require.Eventually(t, func() bool {
+		return len(notifier.ofType(models.AlertNotificationFiring)) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	first := notifier.ofType(models.AlertNotificationFiring)[0]
+	assert.Equal(t, "connection refused to 10.0.0.1:5432", first.Message)
+	assert.Equal(t, service.MessageFingerprint("checkout", first.Message), first.Fingerprint)
+
+	ingest("connection refused to 10.0.0.2:5432")
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, notifier.ofType(models.AlertNotificationFiring), 1, "a recurring error does not fire")
+
+	ingest("deadlock detected on table orders")
+	require.Eventually(t, func() bool {
+		return len(notifier.ofType(models.AlertNotificationFiring)) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "deadlock detected on table orders", notifier.ofType(models.AlertNotificationFiring)[1].Message)
+
+	stored, err := alertRepo.FindByID(ctx, alert.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.Firing, "new errors do not leave the alert firing")
+}
+
+// TestMaxServicesPerTenant verifies entries for a new service beyond the cap
+// are rejected while the tenant's existing services keep ingesting
+func TestMaxServicesPerTenant(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	tenantID := uuid.New()
+	other := uuid.New()
+	repo := repository.NewLogRepository(db)
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, other}).Delete(&models.LogEntry{})
+	})
+	// Logged before the service started, so known from the database
+	require.NoError(t, repo.Create(ctx, &models.LogEntry{
+		ID: uuid.New(), TenantID: tenantID, ServiceName: "api", Level: models.LogLevelInfo, Message: "earlier", Timestamp: time.Now().UTC(),
+	}))
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.MaxServicesPerTenant = 2
+	svc := newTestLogService(t, db, cfg)
+
+	ingest := func(tenant uuid.UUID, serviceName string) {
+		_, err := svc.IngestBatch(ctx, &models.LogBatch{Entries: []models.LogEntry{
+			{TenantID: tenant, ServiceName: serviceName, Level: models.LogLevelInfo, Message: "hello from " + serviceName},
+		}})
+		require.NoError(t, err)
+	}
+	count := func(tenant uuid.UUID, serviceName string) int64 {
+		var n int64
+		db.Model(&models.LogEntry{}).Where("tenant_id = ? AND service_name = ?", tenant, serviceName).Count(&n)
+		return n
+	}
+
+	ingest(tenantID, "worker")
+	ingest(tenantID, "random-7f3a")
+	ingest(tenantID, "api")
+	ingest(tenantID, "worker")
+	ingest(other, "random-7f3a")
+
+	assert.Equal(t, int64(2), count(tenantID, "api"))
+	assert.Equal(t, int64(2), count(tenantID, "worker"))
+	assert.Zero(t, count(tenantID, "random-7f3a"), "a third service is over the cap")
+	assert.Equal(t, int64(1), count(other, "random-7f3a"), "the cap is per tenant")
+	assert.Equal(t, int64(1), svc.RejectedServiceEntries())
+
+	t.Run("Concurrent First Sight", func(t *testing.T) {
+		fresh := uuid.New()
+		t.Cleanup(func() { db.Where("tenant_id = ?", fresh).Delete(&models.LogEntry{}) })
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := svc.IngestBatch(ctx, &models.LogBatch{Entries: []models.LogEntry{
+					{TenantID: fresh, ServiceName: fmt.Sprintf("svc-%d", i), Level: models.LogLevelInfo, Message: "hello"},
+				}})
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+
+		var services int64
+		db.Model(&models.LogEntry{}).Where("tenant_id = ?", fresh).Distinct("service_name").Count(&services)
+		assert.Equal(t, int64(2), services, "racing first loads still honour the cap")
+	})
+}
+
+// TestSuggest verifies typeahead returns the tenant's distinct values with
+// the prefix, in order and capped, and rejects fields outside the allowlist
+func TestSuggest(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	svc := newTestLogService(t, db, nil)
+	repo := repository.NewLogRepository(db)
+
+	tenantID := uuid.New()
+	other := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{tenantID, other}).Delete(&models.LogEntry{})
+	})
+
+	seed := func(tenant uuid.UUID, serviceName string) {
+		require.NoError(t, repo.Create(ctx, &models.LogEntry{
+			ID: uuid.New(), TenantID: tenant, ServiceName: serviceName, Level: models.LogLevelInfo,
+			Message: "hello", Timestamp: time.Now().UTC(),
+		}))
+	}
+	for _, name := range []string{"auth", "audit", "auth", "autoscaler", "billing", "bil_x", "Auth-legacy"} {
+		seed(tenantID, name)
+	}
+	seed(other, "aurora")
+
+	suggestions, err := svc.Suggest(ctx, &tenantID, "service_name", "au", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"audit", "auth", "autoscaler"}, suggestions)
+
+	suggestions, err = svc.Suggest(ctx, &tenantID, "service_name", "au", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"audit", "auth"}, suggestions, "capped to the limit")
+
+	suggestions, err = svc.Suggest(ctx, &tenantID, "service_name", "bil_", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bil_x"}, suggestions, "LIKE wildcards in the prefix match literally")
+
+	_, err = svc.Suggest(ctx, &tenantID, "message", "a", 0)
+	assert.ErrorIs(t, err, service.ErrInvalidSuggestField)
+}
+
+// TestReadYourWrites verifies a tenant's reads go to the primary within the
+// window after it writes, while other tenants and later reads use the replica
+func TestReadYourWrites(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+	ctx := context.Background()
+
+	// A second root over the same connections stands in for the replica,
+	// counting the queries routed to it
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	replica, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	var replicaReads atomic.Int64
+	require.NoError(t, replica.Callback().Query().Before("gorm:query").Register("count_replica_reads", func(*gorm.DB) {
+		replicaReads.Add(1)
+	}))
+
+	logRepo := repository.NewLogRepository(db)
+	logRepo.SetReplica(replica)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Postgres.ReadYourWritesWindow = time.Second
+	svc := service.NewLogService(
+		logRepo,
+		repository.NewRetentionRepository(db),
+		repository.NewAlertRepository(db),
+		service.NewTenantService(repository.NewTenantRepository(db)),
+		redisClient,
+		cfg,
+	)
+	t.Cleanup(svc.Close)
+
+	writer, reader := uuid.New(), uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", []uuid.UUID{writer, reader}).Delete(&models.LogEntry{})
+	})
+
+	// Distinct page sizes keep each query out of the result cache
+	query := func(tenantID uuid.UUID, pageSize int) (*models.LogQueryResult, bool) {
+		before := replicaReads.Load()
+		result, err := svc.Query(ctx, models.LogFilter{TenantID: &tenantID, PageSize: pageSize})
+		require.NoError(t, err)
+		return result, replicaReads.Load() > before
+	}
+
+	_, fromReplica := query(writer, 10)
+	assert.True(t, fromReplica, "reads use the replica before any write")
+
+	require.NoError(t, svc.IngestSingle(ctx, &models.LogEntry{
+		TenantID: writer, ServiceName: "ryw", Level: models.LogLevelInfo, Message: "just written",
+	}))
+
+	result, fromReplica := query(writer, 11)
+	assert.False(t, fromReplica, "the writer reads the primary within the window")
+	require.Len(t, result.Entries, 1)
+	assert.Equal(t, "just written", result.Entries[0].Message)
+
+	_, fromReplica = query(reader, 12)
+	assert.True(t, fromReplica, "other tenants keep reading the replica")
+
+	time.Sleep(1200 * time.Millisecond)
+	_, fromReplica = query(writer, 13)
+	assert.True(t, fromReplica, "reads return to the replica after the window")
+}
+
+// TestCountOnlyServices verifies entries of count-only services are counted
+// rather than stored, and that stats and aggregations still include them
+func TestCountOnlyServices(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+
+	tenantID := uuid.New()
+	countOnly := "healthcheck-" + tenantID.String()[:8]
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertSettings(ctx, &models.TenantSettings{
+		TenantID:          tenantID,
+		CountOnlyServices: []byte(fmt.Sprintf(`[%q]`, countOnly)),
+	}))
+	t.Cleanup(func() {
+		tenants.DeleteSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogCounter{})
+	})
+
+	base := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Minute)
+	var entries []models.LogEntry
+	for i := 0; i < 5; i++ {
+		entries = append(entries, models.LogEntry{
+			TenantID: tenantID, ServiceName: countOnly, Level: models.LogLevelInfo, Message: "ok",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+	for i := 0; i < 2; i++ {
+		entries = append(entries, models.LogEntry{
+			TenantID: tenantID, ServiceName: countOnly, Level: models.LogLevelWarn, Message: "slow",
+			Timestamp: base.Add(time.Minute),
+		})
+	}
+	entries = append(entries, models.LogEntry{
+		TenantID: tenantID, ServiceName: "api", Level: models.LogLevelError, Message: "failed", Timestamp: base,
+	})
+	_, err := svc.IngestBatch(ctx, &models.LogBatch{Entries: entries})
+	require.NoError(t, err)
+
+	var stored int64
+	require.NoError(t, db.Model(&models.LogEntry{}).Where("tenant_id = ? AND service_name = ?", tenantID, countOnly).Count(&stored).Error)
+	assert.Zero(t, stored, "count-only entries are not stored")
+	require.NoError(t, db.Model(&models.LogEntry{}).Where("tenant_id = ? AND service_name = ?", tenantID, "api").Count(&stored).Error)
+	assert.Equal(t, int64(1), stored)
+
+	t.Run("Stats Include Counters", func(t *testing.T) {
+		stats, err := svc.GetStats(ctx, &tenantID, base.Add(-time.Minute), base.Add(5*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(8), stats.TotalCount)
+		assert.Equal(t, int64(7), stats.ServiceCounts[countOnly])
+	})
+
+	t.Run("Aggregate Includes Counters", func(t *testing.T) {
+		aggregations, err := svc.AggregateByService(ctx, models.LogFilter{TenantID: &tenantID}, "minute")
+		require.NoError(t, err)
+		counts := make(map[string]int64)
+		for _, agg := range aggregations {
+			if agg.ServiceName == countOnly {
+				counts[agg.Bucket.UTC().Format(time.RFC3339)] = agg.Count
+			}
+		}
+		assert.Equal(t, map[string]int64{
+			base.Format(time.RFC3339):                  5,
+			base.Add(time.Minute).Format(time.RFC3339): 2,
+		}, counts)
+
+		warn, err := svc.Aggregate(ctx, models.LogFilter{TenantID: &tenantID, Level: models.LogLevelWarn}, "hour")
+		require.NoError(t, err)
+		var total int64
+		for _, agg := range warn {
+			total += agg.Count
+		}
+		assert.Equal(t, int64(2), total)
+	})
+
+	t.Run("Other Predicates Exclude Counters", func(t *testing.T) {
+		aggregations, err := svc.Aggregate(ctx, models.LogFilter{TenantID: &tenantID, Search: "failed"}, "hour")
+		require.NoError(t, err)
+		var total int64
+		for _, agg := range aggregations {
+			total += agg.Count
+		}
+		assert.Equal(t, int64(1), total)
+	})
+}
+
+// TestDedupReleasedAfterFailedInsert verifies an entry whose insert failed is
+// stored when retried within the dedup window rather than dropped
+func TestDedupReleasedAfterFailedInsert(t *testing.T) {
+	db := newTestDB(t)
+	redisClient := newTestRedis(t)
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	tenantService := service.NewTenantService(repository.NewTenantRepository(db))
+	svc := service.NewLogService(
+		repository.NewLogRepository(db),
+		repository.NewRetentionRepository(db),
+		repository.NewAlertRepository(db),
+		tenantService,
+		redisClient,
+		cfg,
+	)
+	t.Cleanup(svc.Close)
+
+	tenantID := uuid.New()
+	require.NoError(t, tenantService.UpsertSettings(ctx, &models.TenantSettings{TenantID: tenantID, DedupEnabled: true}))
+	t.Cleanup(func() {
+		tenantService.DeleteSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+
+	var failInsert atomic.Bool
+	failInsert.Store(true)
+	require.NoError(t, db.Callback().Create().Before("gorm:create").Register("test:fail_insert", func(tx *gorm.DB) {
+		if tx.Statement.Table == "log_entries" && failInsert.Load() {
+			tx.AddError(fmt.Errorf("insert failed"))
+		}
+	}))
+	t.Cleanup(func() {
+		db.Callback().Create().Remove("test:fail_insert")
+	})
+
+	timestamp := time.Now().UTC().Truncate(time.Millisecond)
+	ingest := func() error {
+		return svc.IngestSingle(ctx, &models.LogEntry{
+			TenantID: tenantID, ServiceName: "dedup-retry", Level: models.LogLevelInfo,
+			Message: "order placed", Timestamp: timestamp,
+		})
+	}
+	count := func() int64 {
+		var n int64
+		db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&n)
+		return n
+	}
+
+	require.Error(t, ingest())
+	assert.Zero(t, count())
+
+	failInsert.Store(false)
+	require.NoError(t, ingest())
+	assert.Equal(t, int64(1), count())
+
+	// Once stored, the same entry is a duplicate again
+	require.NoError(t, ingest())
+	assert.Equal(t, int64(1), count())
+}
+
+// TestCountOnlyCountedWithStore verifies count-only entries are counted only
+// once the rest of their request is stored, and duplicates are not counted
+func TestCountOnlyCountedWithStore(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	svc := newTestLogService(t, db, nil)
+
+	tenantID := uuid.New()
+	countOnly := "healthcheck-" + tenantID.String()[:8]
+	tenants := service.NewTenantService(repository.NewTenantRepository(db))
+	require.NoError(t, tenants.UpsertSettings(ctx, &models.TenantSettings{
+		TenantID:          tenantID,
+		DedupEnabled:      true,
+		CountOnlyServices: []byte(fmt.Sprintf(`[%q]`, countOnly)),
+	}))
+	t.Cleanup(func() {
+		tenants.DeleteSettings(ctx, tenantID)
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogCounter{})
+	})
+
+	var failInsert atomic.Bool
+	failInsert.Store(true)
+	require.NoError(t, db.Callback().Create().Before("gorm:create").Register("test:fail_insert", func(tx *gorm.DB) {
+		if tx.Statement.Table == "log_entries" && failInsert.Load() {
+			tx.AddError(fmt.Errorf("insert failed"))
+		}
+	}))
+	t.Cleanup(func() {
+		db.Callback().Create().Remove("test:fail_insert")
+	})
+
+	timestamp := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	ingest := func() error {
+		_, err := svc.IngestBatch(ctx, &models.LogBatch{Entries: []models.LogEntry{
+			{TenantID: tenantID, ServiceName: countOnly, Level: models.LogLevelInfo, Message: "ok", Timestamp: timestamp},
+			{TenantID: tenantID, ServiceName: countOnly, Level: models.LogLevelInfo, Message: "ok", Timestamp: timestamp},
+			{TenantID: tenantID, ServiceName: countOnly, Level: models.LogLevelInfo, Message: "ok", Timestamp: timestamp.Add(time.Millisecond)},
+			{TenantID: tenantID, ServiceName: "api", Level: models.LogLevelError, Message: "failed", Timestamp: timestamp},
+		}})
+		return err
+	}
+	counted := func() int64 {
+		var n int64
+		db.Model(&models.LogCounter{}).Where("tenant_id = ?", tenantID).Select("COALESCE(SUM(count), 0)").Scan(&n)
+		return n
+	}
+
+	require.Error(t, ingest())
+	assert.Zero(t, counted(), "a failed request is not counted")
+
+	failInsert.Store(false)
+	require.NoError(t, ingest())
+	assert.Equal(t, int64(2), counted(), "the duplicate entry is not counted")
+}
+
+// TestPartialBatchRejectsInvalidEntries verifies a partial batch leaves out
+// entries failing validation and stores the rest, while a regular batch
+// still fails as a whole
+func TestPartialBatchRejectsInvalidEntries(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Ingestion.MaxFutureSkew = 5 * time.Minute
+	cfg.Ingestion.FutureMode = service.FutureModeReject
+
+	tenantID := uuid.New()
+	t.Cleanup(func() {
+		db.Where("tenant_id = ?", tenantID).Delete(&models.LogEntry{})
+	})
+	svc := newTestLogService(t, db, cfg)
+
+	now := time.Now().UTC()
+	entries := func() []models.LogEntry {
+		return []models.LogEntry{
+			{TenantID: tenantID, ServiceName: "otlp", Level: models.LogLevelInfo, Message: "ok", Timestamp: now},
+			{TenantID: tenantID, ServiceName: "otlp", Level: models.LogLevelInfo, Message: "skewed", Timestamp: now.Add(48 * time.Hour)},
+			{TenantID: tenantID, ServiceName: "otlp", Level: models.LogLevelInfo, Message: "also ok", Timestamp: now},
+		}
+	}
+
+	_, err = svc.IngestBatch(ctx, &models.LogBatch{Entries: entries()})
+	assert.ErrorIs(t, err, service.ErrEntryInFuture)
+
+	result, err := svc.IngestBatch(ctx, &models.LogBatch{Entries: entries(), Partial: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Accepted)
+	assert.Equal(t, 1, result.Rejected)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, 1, result.Failures[0].Index)
+	assert.Contains(t, result.Failures[0].Reason, service.ErrEntryInFuture.Error())
+
+	var stored int64
+	require.NoError(t, db.Model(&models.LogEntry{}).Where("tenant_id = ?", tenantID).Count(&stored).Error)
+	assert.Equal(t, int64(2), stored)
+}